@@ -12,6 +12,7 @@ import (
 	"io"
 	"log"
 	"reflect"
+	"slices"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -134,6 +135,16 @@ type Client struct {
 	// conn is the open connection
 	conn *uacp.Conn
 
+	// connMu guards conn since it is replaced by the reconnect loop.
+	connMu sync.Mutex
+
+	// rl accepts the connections from the server in reverse connect mode.
+	// It is created on the first call to Dial and is nil otherwise.
+	rl *uacp.ReverseListener
+
+	// rlMu guards rl since it is used by the reconnect loop.
+	rlMu sync.Mutex
+
 	// sechan is the open secure channel.
 	atomicSechan atomic.Value // *uasc.SecureChannel
 	sechanErr    chan error
@@ -174,6 +185,9 @@ type Client struct {
 
 	// monitorOnce ensures only one connection monitor is running
 	monitorOnce sync.Once
+
+	// types contains the structures resolved from the server.
+	types *typeCache
 }
 
 // NewClient creates a new Client.
@@ -201,6 +215,7 @@ func NewClient(endpoint string, opts ...Option) (*Client, error) {
 		pausech:     make(chan struct{}, 2),
 		resumech:    make(chan struct{}, 2),
 		stateCh:     cfg.stateCh,
+		types:       newTypeCache(),
 	}
 	c.pauseSubscriptions(context.Background())
 	c.setPublishTimeout(uasc.MaxTimeout)
@@ -328,7 +343,7 @@ func (c *Client) monitor(ctx context.Context) {
 				// the connection has been closed
 				action = createSecureChannel
 
-			case errors.Is(err, syscall.ECONNREFUSED):
+			case errors.Is(err, syscall.ECONNREFUSED) && c.cfg.reverse == nil:
 				// the connection has been refused by the server
 				action = abortReconnect
 
@@ -386,7 +401,9 @@ func (c *Client) monitor(ctx context.Context) {
 						// todo(fs): down.
 						//
 						// https://github.com/gopcua/opcua/pull/470
-						c.conn.Close()
+						if conn := c.uacpConn(); conn != nil {
+							conn.Close()
+						}
 						if sc := c.SecureChannel(); sc != nil {
 							sc.Close()
 							c.setSecureChannel(nil)
@@ -394,6 +411,12 @@ func (c *Client) monitor(ctx context.Context) {
 
 						c.setState(ctx, Reconnecting)
 
+						// in reverse connect mode Dial does not dial the server
+						// but blocks until the server opens the next connection.
+						if c.cfg.reverse != nil {
+							dlog.Printf("waiting for reverse connection")
+						}
+
 						dlog.Printf("trying to recreate secure channel")
 						for {
 							if err := c.Dial(ctx); err != nil {
@@ -594,21 +617,27 @@ func (c *Client) Dial(ctx context.Context) error {
 		return errors.Errorf("secure channel already connected")
 	}
 
+	var conn *uacp.Conn
 	var err error
-	var d = NewDialer(c.cfg)
-	c.conn, err = d.Dial(ctx, c.endpointURL)
+	if c.cfg.reverse != nil {
+		conn, err = c.acceptReverse(ctx)
+	} else {
+		var d = NewDialer(c.cfg)
+		conn, err = d.Dial(ctx, c.endpointURL)
+	}
 	if err != nil {
 		return err
 	}
+	c.setUACPConn(conn)
 
-	sc, err := uasc.NewSecureChannel(c.endpointURL, c.conn, c.cfg.sechan, c.sechanErr)
+	sc, err := uasc.NewSecureChannel(c.endpointURL, conn, c.cfg.sechan, c.sechanErr)
 	if err != nil {
-		c.conn.Close()
+		conn.Close()
 		return err
 	}
 
 	if err := sc.Open(ctx); err != nil {
-		c.conn.Close()
+		conn.Close()
 		return err
 	}
 	c.setSecureChannel(sc)
@@ -616,6 +645,36 @@ func (c *Client) Dial(ctx context.Context) error {
 	return nil
 }
 
+// uacpConn returns the current connection.
+func (c *Client) uacpConn() *uacp.Conn {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	return c.conn
+}
+
+func (c *Client) setUACPConn(conn *uacp.Conn) {
+	c.connMu.Lock()
+	c.conn = conn
+	c.connMu.Unlock()
+}
+
+// acceptReverse waits for the server to open a connection to the
+// reverse connect listener of the client.
+func (c *Client) acceptReverse(ctx context.Context) (*uacp.Conn, error) {
+	c.rlMu.Lock()
+	if c.rl == nil {
+		l, err := uacp.ListenReverse(c.cfg.reverse.listenAddr, NewDialer(c.cfg).ClientACK)
+		if err != nil {
+			c.rlMu.Unlock()
+			return nil, err
+		}
+		c.rl = l
+	}
+	rl := c.rl
+	c.rlMu.Unlock()
+	return rl.Accept(ctx, c.cfg.reverse.serverURI, c.endpointURL)
+}
+
 // Close closes the session and the secure channel.
 func (c *Client) Close(ctx context.Context) error {
 	stats.Client().Add("Close", 1)
@@ -643,10 +702,18 @@ func (c *Client) Close(ctx context.Context) error {
 
 	// close the connection but ignore the error since there isn't
 	// anything we can do about it anyway
-	if c.conn != nil {
-		c.conn.Close()
+	if conn := c.uacpConn(); conn != nil {
+		conn.Close()
 	}
 
+	// stop listening for reverse connections from the server
+	c.rlMu.Lock()
+	if c.rl != nil {
+		c.rl.Close()
+		c.rl = nil
+	}
+	c.rlMu.Unlock()
+
 	return nil
 }
 
@@ -674,6 +741,11 @@ func (c *Client) Namespaces() []string {
 }
 
 func (c *Client) setNamespaces(ns []string) {
+	// the namespace indices of the cached types are only valid for
+	// the namespace array they have been resolved with.
+	if old, _ := c.atomicNamespaces.Load().([]string); !slices.Equal(old, ns) {
+		c.types.reset()
+	}
 	c.atomicNamespaces.Store(ns)
 }
 
@@ -959,7 +1031,8 @@ func (c *Client) Send(ctx context.Context, req ua.Request, h func(ua.Response) e
 
 // sendWithTimeout sends the request via the secure channel with a custom timeout and registers a handler for
 // the response. If the client has an active session it injects the
-// authentication token.
+// authentication token. The extension objects of the request and the
+// response are resolved with the TypeRegistry of the client.
 func (c *Client) sendWithTimeout(ctx context.Context, req ua.Request, timeout time.Duration, h uasc.ResponseHandler) error {
 	sc := c.SecureChannel()
 	if sc == nil {
@@ -969,6 +1042,15 @@ func (c *Client) sendWithTimeout(ctx context.Context, req ua.Request, timeout ti
 	if s := c.Session(); s != nil {
 		authToken = s.resp.AuthenticationToken
 	}
+	if r := c.cfg.types; r != nil {
+		ns := c.Namespaces()
+		r.SetTypeIDs(req, ns)
+		next := h
+		h = func(v ua.Response) error {
+			r.DecodeExtensionObjects(v, ns)
+			return next(v)
+		}
+	}
 	return sc.SendRequestWithTimeout(ctx, req, authToken, timeout, h)
 }
 
@@ -1059,28 +1141,32 @@ func (c *Client) Read(ctx context.Context, req *ua.ReadRequest) (*ua.ReadRespons
 
 	var res *ua.ReadResponse
 	err := c.Send(ctx, req, func(v ua.Response) error {
-		err := safeAssign(v, &res)
-		if err != nil {
-			return err
-		}
+		return safeAssign(v, &res)
+	})
+	if err != nil {
+		return res, err
+	}
 
-		// If the client cannot decode an extension object then its
-		// value will be nil. However, since the EO was known to the
-		// server the StatusCode for that data value will be OK. We
-		// therefore check for extension objects with nil values and set
-		// the status code to StatusBadDataTypeIDUnknown.
-		for _, dv := range res.Results {
-			if dv.Value == nil {
-				continue
-			}
-			val := dv.Value.Value()
-			if eo, ok := val.(*ua.ExtensionObject); ok && eo.Value == nil {
-				dv.Status = ua.StatusBadDataTypeIDUnknown
-			}
+	// Extension objects whose type is not known to the client are
+	// decoded with the DataTypeDefinition of their DataType. This
+	// requires further requests and cannot happen in the handler.
+	c.resolveStructures(ctx, res.Results)
+
+	// If the client cannot decode an extension object then its
+	// value will be nil. However, since the EO was known to the
+	// server the StatusCode for that data value will be OK. We
+	// therefore check for extension objects with nil values and set
+	// the status code to StatusBadDataTypeIDUnknown.
+	for _, dv := range res.Results {
+		if dv.Value == nil {
+			continue
 		}
-		return nil
-	})
-	return res, err
+		val := dv.Value.Value()
+		if eo, ok := val.(*ua.ExtensionObject); ok && eo.Value == nil {
+			dv.Status = ua.StatusBadDataTypeIDUnknown
+		}
+	}
+	return res, nil
 }
 
 // Write executes a synchronous write request.
@@ -1152,6 +1238,9 @@ func (c *Client) Call(ctx context.Context, req *ua.CallMethodRequest) (*ua.CallM
 	if len(res.Results) != 1 {
 		return nil, ua.StatusBadUnknownResponse
 	}
+	if res.Results[0] != nil {
+		c.resolveStructures(ctx, res.Results[0].OutputArguments)
+	}
 	return res.Results[0], nil
 }
 
@@ -309,6 +309,7 @@ func (c *Client) notifySubscription(ctx context.Context, sub *Subscription, noti
 		case *ua.DataChangeNotification,
 			*ua.EventNotificationList,
 			*ua.StatusChangeNotification:
+			c.resolveStructures(ctx, data.Value)
 			sub.notify(ctx, &PublishNotificationData{
 				SubscriptionID: sub.SubscriptionID,
 				Value:          data.Value,
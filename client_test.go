@@ -245,3 +245,17 @@ func TestClient_SetState(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_SetNamespacesResetsTypes(t *testing.T) {
+	c, err := NewClient("opc.tcp://example.com:4840")
+	require.NoError(t, err)
+
+	c.setNamespaces([]string{"http://opcfoundation.org/UA/", "urn:a"})
+	c.types.builtins["ns=1;i=1"] = ua.TypeIDInt32
+
+	c.setNamespaces([]string{"http://opcfoundation.org/UA/", "urn:a"})
+	require.Len(t, c.types.builtins, 1, "same namespaces")
+
+	c.setNamespaces([]string{"http://opcfoundation.org/UA/", "urn:b", "urn:a"})
+	require.Empty(t, c.types.builtins, "changed namespaces")
+}
@@ -0,0 +1,283 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gopcua/opcua/debug"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// typeCache contains the structures and built-in types which the client
+// has resolved from the type system of the server. A nil descriptor
+// records that a type is not a structure or could not be resolved.
+type typeCache struct {
+	mu        sync.Mutex
+	encodings map[string]*ua.StructureDescriptor
+	dataTypes map[string]*ua.StructureDescriptor
+	builtins  map[string]ua.TypeID
+
+	// dictionaries contains the OPC Binary type dictionaries by the
+	// id of their DataTypeDictionary variable.
+	dictionaries map[string]*typeDictionary
+}
+
+func newTypeCache() *typeCache {
+	return &typeCache{
+		encodings: make(map[string]*ua.StructureDescriptor),
+		dataTypes: make(map[string]*ua.StructureDescriptor),
+		builtins:  make(map[string]ua.TypeID),
+
+		dictionaries: make(map[string]*typeDictionary),
+	}
+}
+
+// reset removes all types from the cache, e.g. when the namespace array
+// of the server has changed and the node ids of the types may refer to
+// other types.
+func (t *typeCache) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	clear(t.encodings)
+	clear(t.dataTypes)
+	clear(t.builtins)
+	clear(t.dictionaries)
+}
+
+// resolveStructures decodes the extension objects of the values whose
+// type is not known to the client as ua.DynamicStructure. The structures
+// are resolved from the DataTypeDefinition of their DataType on the
+// server and are cached per client.
+//
+// The values of Read, the output arguments of Call and the data change
+// and event notifications of subscriptions are resolved.
+func (c *Client) resolveStructures(ctx context.Context, v any) {
+	switch x := v.(type) {
+	case *ua.DataValue:
+		if x != nil {
+			c.resolveStructures(ctx, x.Value)
+		}
+	case []*ua.DataValue:
+		for _, dv := range x {
+			c.resolveStructures(ctx, dv)
+		}
+	case *ua.DataChangeNotification:
+		for _, item := range x.MonitoredItems {
+			if item != nil {
+				c.resolveStructures(ctx, item.Value)
+			}
+		}
+	case *ua.EventNotificationList:
+		for _, ev := range x.Events {
+			if ev != nil {
+				c.resolveStructures(ctx, ev.EventFields)
+			}
+		}
+	case *ua.Variant:
+		if x != nil {
+			c.resolveStructures(ctx, x.Value())
+		}
+	case []*ua.Variant:
+		for _, vv := range x {
+			c.resolveStructures(ctx, vv)
+		}
+	case []*ua.ExtensionObject:
+		for _, eo := range x {
+			c.resolveStructures(ctx, eo)
+		}
+	case *ua.ExtensionObject:
+		if x == nil || x.TypeID == nil {
+			return
+		}
+		if x.Value == nil && x.EncodingMask == ua.ExtensionObjectBinary {
+			d, err := c.structureByEncodingID(ctx, x.TypeID.NodeID)
+			if err != nil || d == nil {
+				debug.Printf("client: cannot resolve extension object %s: %v", x.TypeID, err)
+				return
+			}
+			if err := x.DecodeStructure(d); err != nil {
+				debug.Printf("client: cannot decode extension object %s: %v", x.TypeID, err)
+				return
+			}
+		}
+		c.resolveStructures(ctx, x.Value)
+	case *ua.DynamicStructure:
+		// fields which allow subtypes contain extension objects
+		for _, fv := range x.Values {
+			c.resolveStructures(ctx, fv)
+		}
+	case []*ua.DynamicStructure:
+		for _, s := range x {
+			c.resolveStructures(ctx, s)
+		}
+	}
+}
+
+// structureByEncodingID returns the structure with the encoding or nil
+// if the server does not describe the encoding.
+func (c *Client) structureByEncodingID(ctx context.Context, enc *ua.NodeID) (*ua.StructureDescriptor, error) {
+	key := enc.String()
+	c.types.mu.Lock()
+	d, ok := c.types.encodings[key]
+	c.types.mu.Unlock()
+	if ok {
+		return d, nil
+	}
+
+	refs, err := c.Node(enc).References(ctx, id.HasEncoding, ua.BrowseDirectionInverse, ua.NodeClassDataType, false)
+	if err != nil {
+		return nil, err
+	}
+	var dt *ua.NodeID
+	if len(refs) > 0 {
+		dt = refs[0].NodeID.NodeID
+		d, err = c.structureByDataTypeID(ctx, dt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if d == nil {
+		// servers before 1.04 describe their structures in an
+		// OPC Binary type dictionary.
+		d, err = c.dictionaryStructure(ctx, enc, dt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.types.mu.Lock()
+	c.types.encodings[key] = d
+	c.types.mu.Unlock()
+	return d, nil
+}
+
+// structureByDataTypeID returns the structure DataType or nil if the
+// DataType is not a structure or has no DataTypeDefinition.
+func (c *Client) structureByDataTypeID(ctx context.Context, dt *ua.NodeID) (*ua.StructureDescriptor, error) {
+	key := dt.String()
+	c.types.mu.Lock()
+	d, ok := c.types.dataTypes[key]
+	c.types.mu.Unlock()
+	if ok {
+		return d, nil
+	}
+
+	attrs, err := c.Node(dt).Attributes(ctx, ua.AttributeIDDataTypeDefinition, ua.AttributeIDBrowseName)
+	if err != nil {
+		return nil, err
+	}
+	var def *ua.StructureDefinition
+	if len(attrs) == 2 && attrs[0].Status == ua.StatusOK && attrs[0].Value != nil {
+		if eo, ok := attrs[0].Value.Value().(*ua.ExtensionObject); ok && eo != nil {
+			def, _ = eo.Value.(*ua.StructureDefinition)
+		}
+	}
+	if def == nil {
+		c.types.mu.Lock()
+		c.types.dataTypes[key] = nil
+		c.types.mu.Unlock()
+		return nil, nil
+	}
+	name := key
+	if attrs[1].Status == ua.StatusOK && attrs[1].Value != nil {
+		if qn, ok := attrs[1].Value.Value().(*ua.QualifiedName); ok && qn != nil {
+			name = qn.Name
+		}
+	}
+
+	var typeErr error
+	d = ua.NewStructureDescriptor(name, dt, def, func(t *ua.NodeID) ua.TypeID {
+		x, err := c.builtinType(ctx, t)
+		if err != nil && typeErr == nil {
+			typeErr = err
+		}
+		return x
+	})
+	if typeErr != nil {
+		return nil, typeErr
+	}
+
+	// the descriptor is cached before the nested structures are resolved
+	// since structures can contain themselves.
+	c.types.mu.Lock()
+	c.types.dataTypes[key] = d
+	c.types.mu.Unlock()
+
+	for _, f := range d.Fields {
+		if f.BuiltinType != ua.TypeIDNull {
+			continue
+		}
+		f.Structure, err = c.structureByDataTypeID(ctx, f.DataType)
+		if err != nil || f.Structure == nil {
+			c.types.mu.Lock()
+			delete(c.types.dataTypes, key)
+			c.types.mu.Unlock()
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// builtinType returns the built-in type which encodes the values of the
+// DataType or ua.TypeIDNull for concrete structures. See
+// ua.FieldDescriptor.
+func (c *Client) builtinType(ctx context.Context, dt *ua.NodeID) (ua.TypeID, error) {
+	if dt.Namespace() == 0 && dt.IntID() >= id.Boolean && dt.IntID() <= id.DiagnosticInfo {
+		// the ids of the built-in DataTypes are the type ids, e.g.
+		// Structure is encoded as ExtensionObject and BaseDataType as
+		// Variant.
+		return ua.TypeID(dt.IntID()), nil
+	}
+
+	key := dt.String()
+	c.types.mu.Lock()
+	t, ok := c.types.builtins[key]
+	c.types.mu.Unlock()
+	if ok {
+		return t, nil
+	}
+
+	switch {
+	case dt.Namespace() == 0 && dt.IntID() == id.Enumeration:
+		t = ua.TypeIDInt32
+	case dt.Namespace() == 0 && (dt.IntID() == id.Number || dt.IntID() == id.Integer || dt.IntID() == id.UInteger):
+		t = ua.TypeIDVariant
+	default:
+		refs, err := c.Node(dt).References(ctx, id.HasSubtype, ua.BrowseDirectionInverse, ua.NodeClassDataType, false)
+		if err != nil {
+			return ua.TypeIDNull, err
+		}
+		if len(refs) == 0 {
+			// the type hierarchy is incomplete
+			return ua.TypeIDNull, nil
+		}
+		t, err = c.builtinType(ctx, refs[0].NodeID.NodeID)
+		if err != nil {
+			return ua.TypeIDNull, err
+		}
+		if t == ua.TypeIDExtensionObject {
+			// concrete structures are encoded in place
+			v, err := c.Node(dt).Attribute(ctx, ua.AttributeIDIsAbstract)
+			if _, ok := err.(ua.StatusCode); err != nil && !ok {
+				return ua.TypeIDNull, err
+			}
+			abstract := false
+			if v != nil {
+				abstract, _ = v.Value().(bool)
+			}
+			if !abstract {
+				t = ua.TypeIDNull
+			}
+		}
+	}
+
+	c.types.mu.Lock()
+	c.types.builtins[key] = t
+	c.types.mu.Unlock()
+	return t, nil
+}
@@ -0,0 +1,458 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/gopcua/opcua/cmd/service/goname"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// goType describes the Go type of the values of a field and how they are
+// encoded.
+type goType struct {
+	name string
+
+	// read and write are the names of the Buffer methods which decode
+	// and encode scalar values, e.g. "Int32". They are empty for pointer
+	// types which are decoded with ReadStruct.
+	read, write string
+
+	// base is the type of the Buffer methods if values must be converted,
+	// e.g. "int32" for enumerations.
+	base string
+
+	// zero is the value which is encoded for nil pointers of mandatory
+	// fields. Types whose Encode method accepts nil have no zero value.
+	zero string
+}
+
+func (t goType) isPointer() bool {
+	return t.read == ""
+}
+
+// builtinTypes are the Go types of the built-in DataTypes of namespace 0.
+var builtinTypes = map[uint32]goType{
+	id.Boolean:        {name: "bool", read: "Bool", write: "Bool"},
+	id.SByte:          {name: "int8", read: "Int8", write: "Int8"},
+	id.Byte:           {name: "byte", read: "Byte", write: "Byte"},
+	id.Int16:          {name: "int16", read: "Int16", write: "Int16"},
+	id.UInt16:         {name: "uint16", read: "Uint16", write: "Uint16"},
+	id.Int32:          {name: "int32", read: "Int32", write: "Int32"},
+	id.UInt32:         {name: "uint32", read: "Uint32", write: "Uint32"},
+	id.Int64:          {name: "int64", read: "Int64", write: "Int64"},
+	id.UInt64:         {name: "uint64", read: "Uint64", write: "Uint64"},
+	id.Float:          {name: "float32", read: "Float32", write: "Float32"},
+	id.Double:         {name: "float64", read: "Float64", write: "Float64"},
+	id.String:         {name: "string", read: "String", write: "String"},
+	id.DateTime:       {name: "time.Time", read: "Time", write: "Time"},
+	id.GUID:           {name: "*ua.GUID", zero: "&ua.GUID{Data4: make([]byte, 8)}"},
+	id.ByteString:     {name: "[]byte", read: "Bytes", write: "ByteString"},
+	id.XMLElement:     {name: "ua.XMLElement", read: "String", write: "String", base: "string"},
+	id.NodeID:         {name: "*ua.NodeID", zero: "new(ua.NodeID)"},
+	id.ExpandedNodeID: {name: "*ua.ExpandedNodeID", zero: "new(ua.ExpandedNodeID)"},
+	id.StatusCode:     {name: "ua.StatusCode", read: "Uint32", write: "Uint32", base: "uint32"},
+	id.QualifiedName:  {name: "*ua.QualifiedName", zero: "new(ua.QualifiedName)"},
+	id.LocalizedText:  {name: "*ua.LocalizedText", zero: "new(ua.LocalizedText)"},
+	id.Structure:      {name: "*ua.ExtensionObject"},
+	id.DataValue:      {name: "*ua.DataValue", zero: "new(ua.DataValue)"},
+	id.BaseDataType:   {name: "*ua.Variant", zero: "new(ua.Variant)"},
+	id.DiagnosticInfo: {name: "*ua.DiagnosticInfo", zero: "new(ua.DiagnosticInfo)"},
+	id.Number:         {name: "*ua.Variant", zero: "new(ua.Variant)"},
+	id.Integer:        {name: "*ua.Variant", zero: "new(ua.Variant)"},
+	id.UInteger:       {name: "*ua.Variant", zero: "new(ua.Variant)"},
+	id.Enumeration:    {name: "int32", read: "Int32", write: "Int32"},
+}
+
+// typeName returns the name of the Go type of a DataType of the
+// namespace.
+func typeName(dt *dataType) string {
+	return identifier(goname.Format(dt.name))
+}
+
+// isOptionSet returns true if the DataType is an option set which is
+// encoded as an unsigned integer.
+func (dt *dataType) isOptionSet() bool {
+	return dt.enum != nil && !dt.isSubtype(id.Enumeration) && !dt.isSubtype(id.Structure)
+}
+
+// resolve returns the Go type of the values of a DataType.
+func (m *model) resolve(nid *ua.NodeID) (goType, error) {
+	dt := m.types[nid.String()]
+	if dt == nil {
+		return goType{}, fmt.Errorf("unknown DataType %s", nid)
+	}
+	for t := dt; t != nil; t = t.super {
+		if t.id.Namespace() == 0 && t.id.Type() != ua.NodeIDTypeString {
+			if b, ok := builtinTypes[t.id.IntID()]; ok {
+				return b, nil
+			}
+		}
+		switch {
+		case t.isSubtype(id.Structure):
+			return m.resolveStructure(t)
+		case t.isSubtype(id.Enumeration) && t.id.Namespace() == m.ns && t.enum != nil:
+			return goType{name: typeName(t), read: "Int32", write: "Int32", base: "int32"}, nil
+		case t.isSubtype(id.Enumeration):
+			return builtinTypes[id.Enumeration], nil
+		case t.id.Namespace() == m.ns && t.isOptionSet():
+			b, err := m.resolve(t.super.id)
+			if err != nil {
+				return goType{}, err
+			}
+			return goType{name: typeName(t), read: b.read, write: b.write, base: b.name}, nil
+		}
+	}
+	return goType{}, fmt.Errorf("unknown DataType %s", nid)
+}
+
+// resolveStructure returns the Go type of a structure. Values of abstract
+// structures are encoded as ExtensionObject and concrete structures are
+// encoded in place.
+func (m *model) resolveStructure(dt *dataType) (goType, error) {
+	switch ns := dt.id.Namespace(); {
+	case dt.abstract:
+		return builtinTypes[id.Structure], nil
+	case ns == m.ns && m.skipped[dt] != "":
+		return goType{}, fmt.Errorf("%s is skipped", dt.name)
+	case ns == m.ns && dt.structure != nil:
+		return goType{name: "*" + typeName(dt), zero: "new(" + typeName(dt) + ")"}, nil
+	case ns == 0 && dt.uaType != "":
+		return goType{name: "*ua." + dt.uaType, zero: "new(ua." + dt.uaType + ")"}, nil
+	default:
+		return goType{}, fmt.Errorf("structure %s of namespace %d has no Go type", dt.name, ns)
+	}
+}
+
+// field is a field of a generated struct.
+type field struct {
+	name     string
+	typ      goType
+	array    bool
+	optional bool
+}
+
+// goName returns the name of the Go type of the field.
+func (f *field) goName() string {
+	switch {
+	case f.array:
+		return "[]" + f.typ.name
+	case f.optional && !f.typ.isPointer():
+		return "*" + f.typ.name
+	default:
+		return f.typ.name
+	}
+}
+
+// fields returns the fields of a structure of the namespace.
+func (m *model) fields(dt *dataType) ([]*field, error) {
+	def := dt.structure
+	subtyped := def.StructureType == ua.StructureTypeStructureWithSubtypedValues || def.StructureType == ua.StructureTypeUnionWithSubtypedValues
+	var fields []*field
+	for _, f := range def.Fields {
+		if f.ValueRank > 1 || len(f.ArrayDimensions) > 1 {
+			return nil, fmt.Errorf("field %s is a multi-dimensional array", f.Name)
+		}
+		var t goType
+		switch ft := m.types[f.DataType.String()]; {
+		case subtyped && f.IsOptional && ft != nil && ft.isSubtype(id.Structure):
+			t = builtinTypes[id.Structure]
+		case subtyped && f.IsOptional:
+			t = builtinTypes[id.BaseDataType]
+		default:
+			var err error
+			if t, err = m.resolve(f.DataType); err != nil {
+				return nil, fmt.Errorf("field %s: %s", f.Name, err)
+			}
+		}
+		fields = append(fields, &field{
+			name:     identifier(goname.Format(f.Name)),
+			typ:      t,
+			array:    f.ValueRank >= 0,
+			optional: f.IsOptional && def.StructureType == ua.StructureTypeStructureWithOptionalFields,
+		})
+	}
+	return fields, nil
+}
+
+// skip determines the structures of the namespace which cannot be
+// generated. Structures with fields of skipped structures are skipped as
+// well.
+func (m *model) skip() {
+	m.skipped = map[*dataType]string{}
+	for {
+		n := len(m.skipped)
+		for _, dt := range m.structs {
+			if m.skipped[dt] != "" {
+				continue
+			}
+			if _, err := m.fields(dt); err != nil {
+				m.skipped[dt] = err.Error()
+			}
+		}
+		if len(m.skipped) == n {
+			break
+		}
+	}
+	for _, dt := range m.structs {
+		if reason := m.skipped[dt]; reason != "" {
+			log.Printf("Skipping %s: %s", dt.name, reason)
+		}
+	}
+}
+
+// write writes the Go source of the types of the namespace.
+func (m *model) write(w io.Writer, pkg string) error {
+	m.skip()
+
+	var body bytes.Buffer
+	for _, dt := range m.enums {
+		m.writeEnum(&body, dt)
+	}
+	var structs []*dataType
+	for _, dt := range m.structs {
+		if m.skipped[dt] != "" {
+			continue
+		}
+		structs = append(structs, dt)
+		fields, err := m.fields(dt)
+		if err != nil {
+			return err
+		}
+		m.writeStruct(&body, dt, fields)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by cmd/typegen. DO NOT EDIT!\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	fmt.Fprintf(&b, "import (\n")
+	for _, imp := range []string{"strconv", "time"} {
+		if strings.Contains(body.String(), imp+".") {
+			fmt.Fprintf(&b, "\t%q\n", imp)
+		}
+	}
+	fmt.Fprintf(&b, "\n\t\"github.com/gopcua/opcua/ua\"\n)\n\n")
+	fmt.Fprintf(&b, "// NamespaceURI is the uri of the namespace of the types.\n")
+	fmt.Fprintf(&b, "const NamespaceURI = %q\n\n", m.uri)
+
+	fmt.Fprintf(&b, "// RegisterTypes registers the structures of the namespace with the\n")
+	fmt.Fprintf(&b, "// type registry.\n")
+	fmt.Fprintf(&b, "func RegisterTypes(r *ua.TypeRegistry) error {\n")
+	for _, dt := range structs {
+		fmt.Fprintf(&b, "\tif err := r.RegisterURI(NamespaceURI, %s, new(%s)); err != nil {\n\t\treturn err\n\t}\n", localIDExpr(dt.encoding), typeName(dt))
+	}
+	fmt.Fprintf(&b, "\treturn nil\n}\n")
+
+	b.Write(body.Bytes())
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// localIDExpr returns the Go expression of the id without its namespace
+// index.
+func localIDExpr(nid *ua.NodeID) string {
+	switch nid.Type() {
+	case ua.NodeIDTypeTwoByte, ua.NodeIDTypeFourByte, ua.NodeIDTypeNumeric:
+		return fmt.Sprintf("ua.NewNumericNodeID(0, %d)", nid.IntID())
+	default:
+		s := nid.String()
+		if i := strings.IndexByte(s, ';'); i >= 0 && strings.HasPrefix(s, "ns=") {
+			s = s[i+1:]
+		}
+		return fmt.Sprintf("ua.MustParseNodeID(%q)", s)
+	}
+}
+
+// writeDoc writes the description of a DataType as doc comment.
+func writeDoc(w io.Writer, name string, dt *dataType) {
+	if dt.description == "" {
+		fmt.Fprintf(w, "\n// %s is the DataType %s.\n", name, dt.name)
+		return
+	}
+	fmt.Fprintf(w, "\n")
+	for _, line := range strings.Split(strings.TrimSpace(dt.description), "\n") {
+		fmt.Fprintf(w, "// %s\n", strings.TrimSpace(line))
+	}
+}
+
+func (m *model) writeEnum(w io.Writer, dt *dataType) {
+	name := typeName(dt)
+	base, shift := "int32", false
+	if dt.isOptionSet() {
+		t, err := m.resolve(dt.super.id)
+		if err != nil {
+			log.Printf("Skipping %s: %s", dt.name, err)
+			return
+		}
+		base, shift = t.name, true
+	}
+
+	fields := append([]*ua.EnumField(nil), dt.enum.Fields...)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Value < fields[j].Value })
+
+	writeDoc(w, name, dt)
+	fmt.Fprintf(w, "type %s %s\n\n", name, base)
+	fmt.Fprintf(w, "const (\n")
+	for _, f := range fields {
+		if shift {
+			fmt.Fprintf(w, "\t%s%s %s = 1 << %d\n", name, identifier(goname.Format(f.Name)), name, f.Value)
+		} else {
+			fmt.Fprintf(w, "\t%s%s %s = %d\n", name, identifier(goname.Format(f.Name)), name, f.Value)
+		}
+	}
+	fmt.Fprintf(w, ")\n\n")
+
+	fmt.Fprintf(w, "func (v %s) String() string {\n\tswitch v {\n", name)
+	seen := map[int64]bool{}
+	for _, f := range fields {
+		if seen[f.Value] {
+			continue
+		}
+		seen[f.Value] = true
+		fmt.Fprintf(w, "\tcase %s%s:\n\t\treturn %q\n", name, identifier(goname.Format(f.Name)), f.Name)
+	}
+	if shift {
+		fmt.Fprintf(w, "\t}\n\treturn %q + strconv.FormatUint(uint64(v), 10) + \")\"\n}\n", name+"(")
+	} else {
+		fmt.Fprintf(w, "\t}\n\treturn %q + strconv.FormatInt(int64(v), 10) + \")\"\n}\n", name+"(")
+	}
+}
+
+func (m *model) writeStruct(w io.Writer, dt *dataType, fields []*field) {
+	name := typeName(dt)
+	union := dt.structure.StructureType == ua.StructureTypeUnion || dt.structure.StructureType == ua.StructureTypeUnionWithSubtypedValues
+	optional := dt.structure.StructureType == ua.StructureTypeStructureWithOptionalFields
+
+	writeDoc(w, name, dt)
+	fmt.Fprintf(w, "type %s struct {\n", name)
+	if union {
+		fmt.Fprintf(w, "\t// SwitchField selects the encoded field. Fields are numbered from\n")
+		fmt.Fprintf(w, "\t// 1 and 0 encodes no field.\n")
+		fmt.Fprintf(w, "\tSwitchField uint32\n\n")
+	}
+	for _, f := range fields {
+		fmt.Fprintf(w, "\t%s %s\n", f.name, f.goName())
+	}
+	fmt.Fprintf(w, "}\n\n")
+
+	// Decode
+	fmt.Fprintf(w, "func (s *%s) Decode(b []byte) (int, error) {\n", name)
+	fmt.Fprintf(w, "\tbuf := ua.NewBuffer(b)\n")
+	switch {
+	case union:
+		fmt.Fprintf(w, "\ts.SwitchField = buf.ReadUint32()\n\tswitch s.SwitchField {\n")
+		for i, f := range fields {
+			fmt.Fprintf(w, "\tcase %d:\n", i+1)
+			writeDecode(w, f)
+		}
+		fmt.Fprintf(w, "\t}\n")
+	case optional:
+		fmt.Fprintf(w, "\tmask := buf.ReadUint32()\n")
+		bit := 0
+		for _, f := range fields {
+			if !f.optional {
+				writeDecode(w, f)
+				continue
+			}
+			fmt.Fprintf(w, "\tif mask&(1<<%d) != 0 {\n", bit)
+			writeDecode(w, f)
+			fmt.Fprintf(w, "\t}\n")
+			bit++
+		}
+	default:
+		for _, f := range fields {
+			writeDecode(w, f)
+		}
+	}
+	fmt.Fprintf(w, "\treturn buf.Pos(), buf.Error()\n}\n\n")
+
+	// Encode
+	fmt.Fprintf(w, "func (s *%s) Encode() ([]byte, error) {\n", name)
+	fmt.Fprintf(w, "\tif s == nil {\n\t\ts = new(%s)\n\t}\n", name)
+	fmt.Fprintf(w, "\tbuf := ua.NewBuffer(nil)\n")
+	switch {
+	case union:
+		fmt.Fprintf(w, "\tbuf.WriteUint32(s.SwitchField)\n\tswitch s.SwitchField {\n")
+		for i, f := range fields {
+			fmt.Fprintf(w, "\tcase %d:\n", i+1)
+			writeEncode(w, f)
+		}
+		fmt.Fprintf(w, "\t}\n")
+	case optional:
+		fmt.Fprintf(w, "\tvar mask uint32\n")
+		bit := 0
+		for _, f := range fields {
+			if f.optional {
+				fmt.Fprintf(w, "\tif s.%s != nil {\n\t\tmask |= 1 << %d\n\t}\n", f.name, bit)
+				bit++
+			}
+		}
+		fmt.Fprintf(w, "\tbuf.WriteUint32(mask)\n")
+		for _, f := range fields {
+			if !f.optional {
+				writeEncode(w, f)
+				continue
+			}
+			fmt.Fprintf(w, "\tif s.%s != nil {\n", f.name)
+			writeEncode(w, f)
+			fmt.Fprintf(w, "\t}\n")
+		}
+	default:
+		for _, f := range fields {
+			writeEncode(w, f)
+		}
+	}
+	fmt.Fprintf(w, "\treturn buf.Bytes(), buf.Error()\n}\n")
+}
+
+// readExpr returns the expression which reads a scalar value.
+func readExpr(t goType) string {
+	if t.base != "" {
+		return fmt.Sprintf("%s(buf.Read%s())", t.name, t.read)
+	}
+	return fmt.Sprintf("buf.Read%s()", t.read)
+}
+
+func writeDecode(w io.Writer, f *field) {
+	switch {
+	case f.array:
+		fmt.Fprintf(w, "\tbuf.ReadStruct(&s.%s)\n", f.name)
+	case f.typ.isPointer():
+		fmt.Fprintf(w, "\ts.%s = new(%s)\n", f.name, strings.TrimPrefix(f.typ.name, "*"))
+		fmt.Fprintf(w, "\tbuf.ReadStruct(s.%s)\n", f.name)
+	case f.optional:
+		fmt.Fprintf(w, "\tv%s := %s\n", f.name, readExpr(f.typ))
+		fmt.Fprintf(w, "\ts.%s = &v%s\n", f.name, f.name)
+	default:
+		fmt.Fprintf(w, "\ts.%s = %s\n", f.name, readExpr(f.typ))
+	}
+}
+
+func writeEncode(w io.Writer, f *field) {
+	v := "s." + f.name
+	switch {
+	case f.array:
+		fmt.Fprintf(w, "\tbuf.WriteStruct(%s)\n", v)
+	case f.typ.isPointer() && (f.optional || f.typ.zero == ""):
+		fmt.Fprintf(w, "\tbuf.WriteStruct(%s)\n", v)
+	case f.typ.isPointer():
+		fmt.Fprintf(w, "\tif %s != nil {\n\t\tbuf.WriteStruct(%s)\n\t} else {\n\t\tbuf.WriteStruct(%s)\n\t}\n", v, v, f.typ.zero)
+	default:
+		if f.optional {
+			v = "*" + v
+		}
+		if f.typ.base != "" {
+			v = fmt.Sprintf("%s(%s)", f.typ.base, v)
+		}
+		fmt.Fprintf(w, "\tbuf.Write%s(%s)\n", f.typ.write, v)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/gopcua/opcua/cmd/service/goname"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/schema"
+	"github.com/gopcua/opcua/ua"
+)
+
+// nodeID is the numeric id of a node of the namespace.
+type nodeID struct {
+	Name string
+	ID   uint32
+}
+
+// nodeSetNode is a node of a nodeset with the id of its parent node.
+type nodeSetNode struct {
+	*schema.UANode
+	parent string
+
+	// prefix is added to the name of the node.
+	prefix string
+}
+
+// symbolicName returns the symbolic name of the node or its browse name
+// without the namespace index.
+func (n *nodeSetNode) symbolicName() string {
+	s := n.SymbolicNameAttr
+	if s == "" {
+		s = n.BrowseNameAttr
+		if i := strings.IndexByte(s, ':'); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+	return n.prefix + s
+}
+
+// nodeSetIDs returns the ids of the nodes of the namespace in the
+// nodesets. The names are built like the names of namespace 0 in
+// NodeIds.csv, e.g. "Point_Encoding_DefaultBinary" or
+// "MachineType_Identification".
+func nodeSetIDs(sets []*schema.UANodeSet, uri string) []nodeID {
+	var ids []nodeID
+	for _, set := range sets {
+		if set.NamespaceUris == nil {
+			continue
+		}
+		idx := -1
+		for i, u := range set.NamespaceUris.Uri {
+			if u == uri {
+				idx = i + 1
+			}
+		}
+		if idx < 0 {
+			continue
+		}
+
+		nodes := map[string]*nodeSetNode{}
+		add := func(n *schema.UANode, parent string) {
+			if n != nil {
+				nodes[n.NodeIdAttr] = &nodeSetNode{UANode: n, parent: parent}
+			}
+		}
+		for _, n := range set.UAObject {
+			add(n.UANode, n.ParentNodeIdAttr)
+		}
+		for _, n := range set.UAVariable {
+			add(n.UANode, n.ParentNodeIdAttr)
+		}
+		for _, n := range set.UAMethod {
+			add(n.UANode, n.ParentNodeIdAttr)
+		}
+		for _, n := range set.UAView {
+			add(n.UANode, n.ParentNodeIdAttr)
+		}
+		for _, n := range set.UAObjectType {
+			add(n.UANode, "")
+		}
+		for _, n := range set.UAVariableType {
+			add(n.UANode, "")
+		}
+		for _, n := range set.UADataType {
+			add(n.UANode, "")
+		}
+		for _, n := range set.UAReferenceType {
+			add(n.UANode, "")
+		}
+
+		// encodings have no parent but are named after their DataType
+		hasEncoding := ua.NewNumericNodeID(0, id.HasEncoding).String()
+		for _, n := range set.UADataType {
+			if n.References == nil {
+				continue
+			}
+			for _, r := range n.References.Reference {
+				ref := r.ReferenceTypeAttr
+				if set.Aliases != nil {
+					for _, a := range set.Aliases.Alias {
+						if a.AliasAttr == ref {
+							ref = a.Value
+						}
+					}
+				}
+				if ref != hasEncoding && ref != "HasEncoding" || r.IsForwardAttr != nil && !*r.IsForwardAttr {
+					continue
+				}
+				if enc := nodes[r.Value]; enc != nil && enc.parent == "" {
+					enc.parent = n.NodeIdAttr
+					enc.prefix = "Encoding_"
+				}
+			}
+		}
+
+		names := map[string]string{}
+		var name func(n *nodeSetNode, depth int) string
+		name = func(n *nodeSetNode, depth int) string {
+			if s, ok := names[n.NodeIdAttr]; ok {
+				return s
+			}
+			s := n.symbolicName()
+			if p := nodes[n.parent]; p != nil && depth < 32 {
+				s = name(p, depth+1) + "_" + s
+			}
+			names[n.NodeIdAttr] = s
+			return s
+		}
+
+		for _, n := range nodes {
+			nid, err := ua.ParseNodeID(n.NodeIdAttr)
+			if err != nil || int(nid.Namespace()) != idx {
+				continue
+			}
+			switch nid.Type() {
+			case ua.NodeIDTypeTwoByte, ua.NodeIDTypeFourByte, ua.NodeIDTypeNumeric:
+				ids = append(ids, nodeID{Name: identifier(goname.Format(name(n, 0))), ID: nid.IntID()})
+			}
+		}
+	}
+	return uniqueIDs(ids)
+}
+
+// identifier returns s as an exported Go identifier.
+func identifier(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+	if s == "" {
+		return s
+	}
+	if r := []rune(s)[0]; !unicode.IsUpper(r) {
+		if unicode.IsLower(r) {
+			return string(unicode.ToUpper(r)) + s[len(string(r)):]
+		}
+		return "N" + s
+	}
+	return s
+}
+
+// uniqueIDs sorts the ids and removes the ids whose name is already used
+// by a node with a lower id.
+func uniqueIDs(ids []nodeID) []nodeID {
+	sort.Slice(ids, func(i, j int) bool { return ids[i].ID < ids[j].ID })
+	seen := map[string]bool{}
+	var unique []nodeID
+	for _, id := range ids {
+		if id.Name != "" && !seen[id.Name] {
+			seen[id.Name] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+var tmplIDs = template.Must(template.New("").Parse(`
+// Code generated by cmd/typegen. DO NOT EDIT!
+
+// Package id contains the numeric ids of the nodes of the namespace.
+package id
+
+import "strconv"
+
+const (
+	{{range .}}{{.Name}} = {{.ID}}
+	{{end}}
+)
+
+var names = map[uint32]string{
+	{{- range .}}
+	{{.ID}}: "{{.Name}}",
+	{{- end}}
+}
+
+// Name returns the name of the node with the numeric id.
+func Name(id uint32) string {
+	if s, ok := names[id]; ok {
+		return s
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
+`))
@@ -0,0 +1,191 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+// Command typegen generates a Go package with the DataTypes of a
+// namespace from NodeSet2 files or from a running server.
+//
+// The package contains a struct with Encode and Decode methods for every
+// concrete structure, a type with constants and a String method for every
+// enumeration, a RegisterTypes function which registers the structures
+// with a ua.TypeRegistry and a sub-package "id" with the numeric ids of the
+// nodes of the namespace.
+//
+//	typegen -nodeset Opc.Ua.Di.NodeSet2.xml -nodeset Opc.Ua.Machinery.NodeSet2.xml -namespace http://opcfoundation.org/UA/Machinery/ -out machinery
+//	typegen -endpoint opc.tcp://localhost:4840 -namespace urn:vendor:model -out model
+//
+// The NodeSet2 files of the models which are required by the namespace
+// must be passed as well. Structures with fields of structures from other
+// namespaces than namespace 0 or with multi-dimensional arrays cannot be
+// generated and are skipped.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"flag"
+	"go/format"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/schema"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+)
+
+type stringsFlag []string
+
+func (s *stringsFlag) String() string     { return strings.Join(*s, ",") }
+func (s *stringsFlag) Set(v string) error { *s = append(*s, v); return nil }
+
+func main() {
+	log.SetFlags(0)
+
+	var nodesets stringsFlag
+	flag.Var(&nodesets, "nodeset", "path to a NodeSet2 file (repeatable)")
+	endpoint := flag.String("endpoint", "", "endpoint of the server")
+	uri := flag.String("namespace", "", "uri of the namespace (default: the model of the last nodeset)")
+	out := flag.String("out", "", "path to the output directory")
+	pkg := flag.String("pkg", "", "Go package name (default: name of the output directory)")
+	flag.Parse()
+
+	if (len(nodesets) == 0) == (*endpoint == "") {
+		log.Fatal("either -nodeset or -endpoint is required")
+	}
+	if *out == "" {
+		log.Fatal("-out is required")
+	}
+	if *pkg == "" {
+		*pkg = strings.ReplaceAll(filepath.Base(*out), "-", "")
+	}
+
+	ctx := context.Background()
+
+	var src typeSource
+	var sets []*schema.UANodeSet
+	if len(nodesets) > 0 {
+		for _, fn := range nodesets {
+			set, err := readNodeSet(fn)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sets = append(sets, set)
+		}
+		if *uri == "" {
+			*uri = modelURI(sets[len(sets)-1])
+		}
+
+		// the nodesets are imported into a server which is not started
+		// so that the types are read in the same way as from a remote
+		// server.
+		srv, err := importNodeSets(sets)
+		if err != nil {
+			log.Fatalf("Error importing nodesets: %v", err)
+		}
+		src = serverSource{srv}
+	}
+	if *uri == "" {
+		log.Fatal("-namespace is required")
+	}
+
+	if src == nil {
+		c, err := opcua.NewClient(*endpoint, opcua.SecurityMode(ua.MessageSecurityModeNone))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := c.Connect(ctx); err != nil {
+			log.Fatalf("Error connecting to %s: %v", *endpoint, err)
+		}
+		defer c.Close(ctx)
+		src = clientSource{c}
+	}
+
+	types, ids, err := generate(ctx, src, sets, *uri, *pkg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(path.Join(*out, "id"), 0755); err != nil {
+		log.Fatal(err)
+	}
+	write(types, path.Join(*out, "types_gen.go"))
+	write(ids, path.Join(*out, "id", "id_gen.go"))
+}
+
+// generate returns the unformatted Go source of the types and of the ids
+// of the namespace. The ids are taken from the nodesets if there are any
+// and from the DataTypes of the server otherwise.
+func generate(ctx context.Context, src typeSource, sets []*schema.UANodeSet, uri, pkg string) (types, ids []byte, err error) {
+	m, err := loadModel(ctx, src, uri)
+	if err != nil {
+		return nil, nil, errors.Errorf("Error reading the types of %s: %v", uri, err)
+	}
+
+	var nids []nodeID
+	if len(sets) > 0 {
+		nids = nodeSetIDs(sets, uri)
+	} else {
+		nids = m.ids()
+	}
+
+	var b bytes.Buffer
+	if err := m.write(&b, pkg); err != nil {
+		return nil, nil, errors.Errorf("Error generating types: %v", err)
+	}
+	types = bytes.Clone(b.Bytes())
+
+	b.Reset()
+	if err := tmplIDs.Execute(&b, nids); err != nil {
+		return nil, nil, errors.Errorf("Error generating ids: %v", err)
+	}
+	return types, b.Bytes(), nil
+}
+
+func readNodeSet(filename string) (*schema.UANodeSet, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	set := new(schema.UANodeSet)
+	if err := xml.Unmarshal(b, set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// importNodeSets returns a server with the nodesets.
+func importNodeSets(sets []*schema.UANodeSet) (*server.Server, error) {
+	srv := server.New(server.EndPoint("localhost", 0))
+	if err := srv.ImportNodeSets(sets...); err != nil {
+		return nil, err
+	}
+	return srv, nil
+}
+
+// modelURI returns the uri of the model of the nodeset.
+func modelURI(set *schema.UANodeSet) string {
+	if set.Models != nil && len(set.Models.Model) > 0 {
+		return set.Models.Model[0].ModelUriAttr
+	}
+	if set.NamespaceUris != nil && len(set.NamespaceUris.Uri) > 0 {
+		return set.NamespaceUris.Uri[0]
+	}
+	return ""
+}
+
+func write(src []byte, filename string) {
+	b, err := format.Source(src)
+	if err != nil {
+		os.Stdout.Write(src)
+		log.Fatalf("Error formatting %s: %v", filename, err)
+	}
+	if err := os.WriteFile(filename, b, 0644); err != nil {
+		log.Fatalf("Error writing %s: %v", filename, err)
+	}
+	log.Printf("Wrote %s", filename)
+}
@@ -0,0 +1,89 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"go/format"
+	"os"
+	"testing"
+
+	"github.com/gopcua/opcua/cmd/typegen/testdata/example"
+	exampleid "github.com/gopcua/opcua/cmd/typegen/testdata/example/id"
+	"github.com/gopcua/opcua/schema"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the generated files in testdata")
+
+// TestGenerate verifies that the types and the ids generated from the
+// example nodeset match the example package in testdata.
+func TestGenerate(t *testing.T) {
+	set, err := readNodeSet("testdata/Example.NodeSet2.xml")
+	require.NoError(t, err)
+	sets := []*schema.UANodeSet{set}
+	srv, err := importNodeSets(sets)
+	require.NoError(t, err)
+
+	types, ids, err := generate(context.Background(), serverSource{srv}, sets, modelURI(set), "example")
+	require.NoError(t, err)
+
+	for fn, src := range map[string][]byte{
+		"testdata/example/types_gen.go": types,
+		"testdata/example/id/id_gen.go": ids,
+	} {
+		b, err := format.Source(src)
+		require.NoError(t, err, fn)
+		if *update {
+			require.NoError(t, os.WriteFile(fn, b, 0644))
+			continue
+		}
+		want, err := os.ReadFile(fn)
+		require.NoError(t, err)
+		require.Equal(t, string(want), string(b), "%s is outdated, run go test -update", fn)
+	}
+}
+
+// TestGeneratedTypes verifies that the generated structures can be
+// encoded and decoded and are registered with their encoding ids.
+func TestGeneratedTypes(t *testing.T) {
+	comment := "slow"
+	s := &example.Setting{
+		Name:    "pump",
+		Mode:    example.ModeOn,
+		Path:    []*example.Point{{X: 1, Y: 2}, {X: 3, Y: 4}},
+		Comment: &comment,
+	}
+	b, err := s.Encode()
+	require.NoError(t, err)
+	got := new(example.Setting)
+	n, err := got.Decode(b)
+	require.NoError(t, err)
+	require.Equal(t, len(b), n)
+	require.Equal(t, s, got)
+
+	// the optional field is omitted
+	s.Comment = nil
+	b, err = s.Encode()
+	require.NoError(t, err)
+	got = new(example.Setting)
+	_, err = got.Decode(b)
+	require.NoError(t, err)
+	require.Equal(t, s, got)
+
+	r := ua.NewTypeRegistry()
+	require.NoError(t, example.RegisterTypes(r))
+	// the namespace has another index on the server than in the nodeset
+	ns := []string{"http://opcfoundation.org/UA/", "urn:other", example.NamespaceURI}
+	for v, enc := range map[any]uint32{
+		new(example.Point):   exampleid.Point_Encoding_DefaultBinary,
+		new(example.Setting): exampleid.Setting_Encoding_DefaultBinary,
+	} {
+		require.Equal(t, ua.NewNumericNodeID(2, enc).String(), r.LookupWithNamespaces(v, ns).String())
+		require.IsType(t, v, r.NewWithNamespaces(ua.NewNumericNodeID(2, enc), ns))
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"sort"
+
+	"github.com/gopcua/opcua/cmd/service/goname"
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// dataType is a DataType of the server.
+type dataType struct {
+	id    *ua.NodeID
+	name  string
+	super *dataType
+
+	// the following fields are only read for the DataTypes of the
+	// namespace.
+	abstract    bool
+	description string
+	encoding    *ua.NodeID
+	structure   *ua.StructureDefinition
+	enum        *ua.EnumDefinition
+
+	// uaType is the name of the Go type of a structure of namespace 0
+	// in the ua package.
+	uaType string
+}
+
+// isSubtype returns true if the DataType is the DataType with the id or
+// one of its subtypes.
+func (dt *dataType) isSubtype(id uint32) bool {
+	for t := dt; t != nil; t = t.super {
+		if t.id.Namespace() == 0 && t.id.IntID() == id {
+			return true
+		}
+	}
+	return false
+}
+
+// model contains the DataTypes of the server and the DataTypes of the
+// namespace which are generated.
+type model struct {
+	uri   string
+	ns    uint16
+	types map[string]*dataType
+
+	// structs and enums are the DataTypes of the namespace sorted by
+	// name.
+	structs []*dataType
+	enums   []*dataType
+
+	// skipped contains the reasons why structures cannot be generated.
+	skipped map[*dataType]string
+}
+
+// loadModel reads the DataTypes of the server and the definitions of the
+// DataTypes of the namespace.
+func loadModel(ctx context.Context, src typeSource, uri string) (*model, error) {
+	ns, err := src.namespaceIndex(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	m := &model{uri: uri, ns: ns, types: map[string]*dataType{}}
+
+	root := &dataType{id: ua.NewNumericNodeID(0, id.BaseDataType), name: "BaseDataType"}
+	m.types[root.id.String()] = root
+	queue := []*dataType{root}
+	for len(queue) > 0 {
+		dt := queue[0]
+		queue = queue[1:]
+		refs, err := src.references(ctx, dt.id, id.HasSubtype, ua.NodeClassDataType)
+		if err != nil {
+			return nil, errors.Errorf("browse %s: %s", dt.id, err)
+		}
+		for _, r := range refs {
+			sub := &dataType{id: r.NodeID.NodeID, name: r.BrowseName.Name, super: dt}
+			if _, ok := m.types[sub.id.String()]; ok {
+				continue
+			}
+			m.types[sub.id.String()] = sub
+			queue = append(queue, sub)
+			if sub.id.Namespace() != ns {
+				continue
+			}
+			if err := m.read(ctx, src, sub); err != nil {
+				return nil, errors.Errorf("%s: %s", sub.name, err)
+			}
+			switch {
+			case sub.structure != nil && !sub.abstract:
+				m.structs = append(m.structs, sub)
+			case sub.enum != nil && !sub.isSubtype(id.Structure):
+				m.enums = append(m.enums, sub)
+			}
+		}
+	}
+
+	// structures of other namespaces are read to determine whether
+	// they are encoded in place.
+	for _, dt := range m.structs {
+		for _, f := range dt.structure.Fields {
+			ft := m.types[f.DataType.String()]
+			if ft == nil || ft.id.Namespace() == ns || ft.encoding != nil || !ft.isSubtype(id.Structure) {
+				continue
+			}
+			if ft.id.Namespace() == 0 && ft.id.IntID() == id.Structure {
+				continue
+			}
+			if err := m.read(ctx, src, ft); err != nil {
+				return nil, errors.Errorf("%s: %s", ft.name, err)
+			}
+			if ft.id.Namespace() == 0 && ft.encoding != nil {
+				if v := ua.NewScopedTypeRegistry().New(ft.encoding); v != nil {
+					ft.uaType = reflect.TypeOf(v).Elem().Name()
+				}
+			}
+		}
+	}
+	sort.Slice(m.structs, func(i, j int) bool { return m.structs[i].name < m.structs[j].name })
+	sort.Slice(m.enums, func(i, j int) bool { return m.enums[i].name < m.enums[j].name })
+	if len(m.structs)+len(m.enums) == 0 {
+		log.Printf("The namespace %s has no structures or enumerations", uri)
+	}
+	return m, nil
+}
+
+// read reads the attributes and the encoding of a DataType of the
+// namespace.
+func (m *model) read(ctx context.Context, src typeSource, dt *dataType) error {
+	attrs, err := src.attributes(ctx, dt.id, ua.AttributeIDIsAbstract, ua.AttributeIDDescription, ua.AttributeIDDataTypeDefinition)
+	if err != nil {
+		return err
+	}
+	if v := attrs[0].Value; attrs[0].Status == ua.StatusOK && v != nil {
+		dt.abstract, _ = v.Value().(bool)
+	}
+	if v := attrs[1].Value; attrs[1].Status == ua.StatusOK && v != nil {
+		if lt, ok := v.Value().(*ua.LocalizedText); ok && lt != nil {
+			dt.description = lt.Text
+		}
+	}
+	if v := attrs[2].Value; attrs[2].Status == ua.StatusOK && v != nil {
+		if eo, ok := v.Value().(*ua.ExtensionObject); ok && eo != nil {
+			switch def := eo.Value.(type) {
+			case *ua.StructureDefinition:
+				dt.structure = def
+			case *ua.EnumDefinition:
+				dt.enum = def
+			}
+		}
+	}
+	if dt.abstract || dt.structure == nil && dt.id.Namespace() == m.ns {
+		return nil
+	}
+
+	refs, err := src.references(ctx, dt.id, id.HasEncoding, ua.NodeClassObject)
+	if err != nil {
+		return err
+	}
+	for _, r := range refs {
+		if r.BrowseName.Name == "Default Binary" {
+			dt.encoding = r.NodeID.NodeID
+		}
+	}
+	if dt.encoding == nil {
+		return errors.Errorf("no Default Binary encoding")
+	}
+	return nil
+}
+
+// ids returns the ids of the DataTypes of the namespace and of their
+// encodings.
+func (m *model) ids() []nodeID {
+	var ids []nodeID
+	add := func(nid *ua.NodeID, name string) {
+		switch nid.Type() {
+		case ua.NodeIDTypeTwoByte, ua.NodeIDTypeFourByte, ua.NodeIDTypeNumeric:
+			ids = append(ids, nodeID{Name: identifier(goname.Format(name)), ID: nid.IntID()})
+		}
+	}
+	for _, dt := range m.types {
+		if dt.id.Namespace() != m.ns {
+			continue
+		}
+		add(dt.id, dt.name)
+		if dt.encoding != nil {
+			add(dt.encoding, dt.name+"_Encoding_DefaultBinary")
+		}
+	}
+	return uniqueIDs(ids)
+}
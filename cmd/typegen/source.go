@@ -0,0 +1,91 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+)
+
+// typeSource reads the nodes of the DataTypes either from a remote server
+// or from a server in the same process into which the nodesets have been
+// imported.
+type typeSource interface {
+	// namespaceIndex returns the index of the namespace with the uri.
+	namespaceIndex(ctx context.Context, uri string) (uint16, error)
+
+	// references returns the forward references of the reference type
+	// from the node to nodes of the node class.
+	references(ctx context.Context, nid *ua.NodeID, refType uint32, class ua.NodeClass) ([]*ua.ReferenceDescription, error)
+
+	// attributes returns the values of the attributes of the node.
+	attributes(ctx context.Context, nid *ua.NodeID, attrs ...ua.AttributeID) ([]*ua.DataValue, error)
+}
+
+// clientSource reads the DataTypes of a remote server.
+type clientSource struct {
+	c *opcua.Client
+}
+
+func (s clientSource) namespaceIndex(ctx context.Context, uri string) (uint16, error) {
+	return s.c.FindNamespace(ctx, uri)
+}
+
+func (s clientSource) references(ctx context.Context, nid *ua.NodeID, refType uint32, class ua.NodeClass) ([]*ua.ReferenceDescription, error) {
+	return s.c.Node(nid).References(ctx, refType, ua.BrowseDirectionForward, class, false)
+}
+
+func (s clientSource) attributes(ctx context.Context, nid *ua.NodeID, attrs ...ua.AttributeID) ([]*ua.DataValue, error) {
+	return s.c.Node(nid).Attributes(ctx, attrs...)
+}
+
+// serverSource reads the DataTypes of a server in the same process
+// without starting it.
+type serverSource struct {
+	srv *server.Server
+}
+
+func (s serverSource) namespaceIndex(_ context.Context, uri string) (uint16, error) {
+	for i, ns := range s.srv.Namespaces() {
+		if ns.Name() == uri {
+			return uint16(i), nil
+		}
+	}
+	return 0, errors.Errorf("namespace %s not found", uri)
+}
+
+func (s serverSource) references(_ context.Context, nid *ua.NodeID, refType uint32, class ua.NodeClass) ([]*ua.ReferenceDescription, error) {
+	ns, err := s.srv.Namespace(int(nid.Namespace()))
+	if err != nil {
+		return nil, err
+	}
+	res := ns.Browse(&ua.BrowseDescription{
+		NodeID:          nid,
+		BrowseDirection: ua.BrowseDirectionForward,
+		ReferenceTypeID: ua.NewNumericNodeID(0, refType),
+		NodeClassMask:   uint32(class),
+		ResultMask:      uint32(ua.BrowseResultMaskAll),
+	})
+	if res.StatusCode != ua.StatusOK {
+		return nil, res.StatusCode
+	}
+	return res.References, nil
+}
+
+func (s serverSource) attributes(_ context.Context, nid *ua.NodeID, attrs ...ua.AttributeID) ([]*ua.DataValue, error) {
+	ns, err := s.srv.Namespace(int(nid.Namespace()))
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]*ua.DataValue, len(attrs))
+	for i, a := range attrs {
+		vals[i] = ns.Attribute(nid, a)
+	}
+	return vals, nil
+}
@@ -0,0 +1,30 @@
+// Code generated by cmd/typegen. DO NOT EDIT!
+
+// Package id contains the numeric ids of the nodes of the namespace.
+package id
+
+import "strconv"
+
+const (
+	Mode                           = 3001
+	Point                          = 3002
+	Setting                        = 3003
+	Point_Encoding_DefaultBinary   = 5001
+	Setting_Encoding_DefaultBinary = 5002
+)
+
+var names = map[uint32]string{
+	3001: "Mode",
+	3002: "Point",
+	3003: "Setting",
+	5001: "Point_Encoding_DefaultBinary",
+	5002: "Setting_Encoding_DefaultBinary",
+}
+
+// Name returns the name of the node with the numeric id.
+func Name(id uint32) string {
+	if s, ok := names[id]; ok {
+		return s
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
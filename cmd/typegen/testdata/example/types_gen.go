@@ -0,0 +1,105 @@
+// Code generated by cmd/typegen. DO NOT EDIT!
+
+package example
+
+import (
+	"strconv"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// NamespaceURI is the uri of the namespace of the types.
+const NamespaceURI = "urn:gopcua:typegen:example"
+
+// RegisterTypes registers the structures of the namespace with the
+// type registry.
+func RegisterTypes(r *ua.TypeRegistry) error {
+	if err := r.RegisterURI(NamespaceURI, ua.NewNumericNodeID(0, 5001), new(Point)); err != nil {
+		return err
+	}
+	if err := r.RegisterURI(NamespaceURI, ua.NewNumericNodeID(0, 5002), new(Setting)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Mode is the operating mode of a machine.
+type Mode int32
+
+const (
+	ModeOff Mode = 0
+	ModeOn  Mode = 1
+)
+
+func (v Mode) String() string {
+	switch v {
+	case ModeOff:
+		return "Off"
+	case ModeOn:
+		return "On"
+	}
+	return "Mode(" + strconv.FormatInt(int64(v), 10) + ")"
+}
+
+// Point is a position in the plane.
+type Point struct {
+	X float64
+	Y float64
+}
+
+func (s *Point) Decode(b []byte) (int, error) {
+	buf := ua.NewBuffer(b)
+	s.X = buf.ReadFloat64()
+	s.Y = buf.ReadFloat64()
+	return buf.Pos(), buf.Error()
+}
+
+func (s *Point) Encode() ([]byte, error) {
+	if s == nil {
+		s = new(Point)
+	}
+	buf := ua.NewBuffer(nil)
+	buf.WriteFloat64(s.X)
+	buf.WriteFloat64(s.Y)
+	return buf.Bytes(), buf.Error()
+}
+
+// Setting is the DataType Setting.
+type Setting struct {
+	Name    string
+	Mode    Mode
+	Path    []*Point
+	Comment *string
+}
+
+func (s *Setting) Decode(b []byte) (int, error) {
+	buf := ua.NewBuffer(b)
+	mask := buf.ReadUint32()
+	s.Name = buf.ReadString()
+	s.Mode = Mode(buf.ReadInt32())
+	buf.ReadStruct(&s.Path)
+	if mask&(1<<0) != 0 {
+		vComment := buf.ReadString()
+		s.Comment = &vComment
+	}
+	return buf.Pos(), buf.Error()
+}
+
+func (s *Setting) Encode() ([]byte, error) {
+	if s == nil {
+		s = new(Setting)
+	}
+	buf := ua.NewBuffer(nil)
+	var mask uint32
+	if s.Comment != nil {
+		mask |= 1 << 0
+	}
+	buf.WriteUint32(mask)
+	buf.WriteString(s.Name)
+	buf.WriteInt32(int32(s.Mode))
+	buf.WriteStruct(s.Path)
+	if s.Comment != nil {
+		buf.WriteString(*s.Comment)
+	}
+	return buf.Bytes(), buf.Error()
+}
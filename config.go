@@ -58,6 +58,19 @@ type Config struct {
 	sechan  *uasc.Config
 	session *uasc.SessionConfig
 	stateCh chan<- ConnState
+	reverse *reverseConfig
+	types   *ua.TypeRegistry
+}
+
+// reverseConfig contains the settings for reverse connect where the
+// server opens the connection to the client.
+type reverseConfig struct {
+	// listenAddr is the local endpoint the client listens on.
+	listenAddr string
+
+	// serverURI is the application uri of the expected server.
+	// An empty value accepts any server.
+	serverURI string
 }
 
 // NewDialer creates a uacp.Dialer from the config options
@@ -576,6 +589,44 @@ func StateChangedCh(ch chan<- ConnState) Option {
 	}
 }
 
+// TypeRegistry sets the registry for the extension objects which are
+// exchanged with the server.
+//
+// Extension objects in responses whose type is registered with r are
+// decoded with this type and extension objects in requests get the id of
+// the type of their value in r. Types which are registered with
+// r.RegisterURI are resolved with the namespace array of the server. This
+// allows to use different types for servers with the same encoding ids.
+// Use ua.NewScopedTypeRegistry to fall back to the types registered with
+// ua.RegisterExtensionObject.
+func TypeRegistry(r *ua.TypeRegistry) Option {
+	return func(cfg *Config) error {
+		cfg.types = r
+		return nil
+	}
+}
+
+// ReverseConnect configures the client to wait for the server to open the
+// connection instead of dialing the endpoint.
+//
+// The client listens on listenAddr (e.g. "opc.tcp://0.0.0.0:4843") and
+// accepts the first ReverseHello message which has serverURI as ServerUri
+// and the endpoint of the client as EndpointUrl. An empty serverURI accepts
+// any server. The HEL/ACK and OpenSecureChannel exchange then runs over the
+// accepted connection. With auto-reconnect enabled the client waits for the
+// next reverse connection from the server.
+//
+// Specification: Part 6, 7.1.3
+func ReverseConnect(listenAddr, serverURI string) Option {
+	return func(cfg *Config) error {
+		cfg.reverse = &reverseConfig{
+			listenAddr: listenAddr,
+			serverURI:  serverURI,
+		}
+		return nil
+	}
+}
+
 func initDialer(cfg *Config) {
 	if cfg.dialer == nil {
 		cfg.dialer = &uacp.Dialer{}
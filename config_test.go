@@ -829,6 +829,16 @@ func TestOptions(t *testing.T) {
 				}(),
 			},
 		},
+		{
+			name: `ReverseConnect()`,
+			opt:  ReverseConnect("opc.tcp://0.0.0.0:4843", "urn:server"),
+			cfg: &Config{
+				reverse: &reverseConfig{
+					listenAddr: "opc.tcp://0.0.0.0:4843",
+					serverURI:  "urn:server",
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
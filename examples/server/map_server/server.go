@@ -175,6 +175,22 @@ func main() {
 	myMapNamespace1.Data["Tag5"] = true
 	myMapNamespace1.Data["Tag6"] = time.Now()
 
+	// nested maps are folders. The node id of a value in a folder is the path
+	// of the value, e.g. "Line1.Speed".
+	myMapNamespace1.Data["Line1"] = map[string]any{
+		"Speed":     12.5,
+		"Setpoints": []float64{10, 12.5, 15},
+	}
+	myMapNamespace1.SetMetadata("Line1.Speed", &server.MapMetadata{
+		Description: "speed of the conveyor",
+		EngineeringUnits: &ua.EUInformation{
+			NamespaceURI: "http://www.opcfoundation.org/UA/units/un/cefact",
+			UnitID:       -1,
+			DisplayName:  ua.NewLocalizedText("m/s"),
+			Description:  ua.NewLocalizedText("metre per second"),
+		},
+	})
+
 	myMapNamespace2.Data["Tag7"] = 56.78
 	myMapNamespace2.Data["Tag8"] = 92
 	myMapNamespace2.Data["Tag9"] = "different string"
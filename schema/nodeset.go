@@ -0,0 +1,19 @@
+package schema
+
+import "encoding/xml"
+
+const (
+	// NodeSetNamespace is the XML namespace of NodeSet2 documents.
+	NodeSetNamespace = "http://opcfoundation.org/UA/2011/03/UANodeSet.xsd"
+
+	// TypesNamespace is the XML namespace of the values in NodeSet2 documents.
+	TypesNamespace = "http://opcfoundation.org/UA/2008/02/Types.xsd"
+)
+
+// MarshalXML encodes the nodeset as a UANodeSet element in the
+// NodeSet2 namespace.
+func (n *UANodeSet) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type nodeSet UANodeSet
+	start.Name = xml.Name{Space: NodeSetNamespace, Local: "UANodeSet"}
+	return e.EncodeElement((*nodeSet)(n), start)
+}
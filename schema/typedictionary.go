@@ -0,0 +1,168 @@
+package schema
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// The namespaces of the types of the OPC Binary type system.
+const (
+	BinarySchemaNamespace = "http://opcfoundation.org/BinarySchema/"
+	UANamespace           = "http://opcfoundation.org/UA/"
+)
+
+// TypeDictionary is an OPC Binary type dictionary as it is published by
+// the DataTypeDictionary variables of servers which describe their
+// structures with the OPC Binary type system (OPC UA Part 5, Annex D and
+// Part 3 of the 1.03 specification).
+type TypeDictionary struct {
+	XMLName         xml.Name      `xml:"TypeDictionary"`
+	TargetNamespace string        `xml:"TargetNamespace,attr"`
+	Imports         []*Import     `xml:"Import"`
+	Types           []*StructType `xml:"StructuredType"`
+	Enums           []*EnumType   `xml:"EnumeratedType"`
+	Opaques         []*OpaqueType `xml:"OpaqueType"`
+
+	// namespaces maps the namespace prefixes to the namespaces
+	namespaces map[string]string
+}
+
+// Import is the import of another type dictionary.
+type Import struct {
+	Namespace string `xml:"Namespace,attr"`
+	Location  string `xml:"Location,attr"`
+}
+
+type EnumType struct {
+	Name   string       `xml:",attr"`
+	Bits   int          `xml:"LengthInBits,attr"`
+	Doc    string       `xml:"Documentation"`
+	Values []*EnumValue `xml:"EnumeratedValue"`
+}
+
+type EnumValue struct {
+	Name  string `xml:",attr"`
+	Value int    `xml:",attr"`
+}
+
+// OpaqueType is a type whose encoding is not described by the dictionary.
+type OpaqueType struct {
+	Name string `xml:",attr"`
+	Bits int    `xml:"LengthInBits,attr"`
+	Doc  string `xml:"Documentation"`
+}
+
+type StructType struct {
+	Name     string         `xml:",attr"`
+	BaseType string         `xml:"BaseType,attr"`
+	Doc      string         `xml:"Documentation"`
+	Fields   []*StructField `xml:"Field"`
+}
+
+func (s *StructType) IsLengthField(f *StructField) bool {
+	for _, ff := range s.Fields {
+		if f.Name == ff.LengthField {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSwitchField returns true if the field selects whether other fields
+// are encoded, e.g. the bits of optional fields or the switch of a union.
+func (s *StructType) IsSwitchField(f *StructField) bool {
+	for _, ff := range s.Fields {
+		if f.Name == ff.SwitchField {
+			return true
+		}
+	}
+	return false
+}
+
+type StructField struct {
+	Name        string `xml:",attr"`
+	Type        string `xml:"TypeName,attr"`
+	Length      int    `xml:",attr"`
+	LengthField string `xml:",attr"`
+	SwitchField string `xml:",attr"`
+	SwitchValue string `xml:",attr"`
+	IsEnum      bool   `xml:"-"`
+}
+
+func (f *StructField) IsSlice() bool {
+	return f.LengthField != ""
+}
+
+// UnmarshalXML records the namespace prefixes of the dictionary so that
+// the type names of the fields can be resolved.
+func (d *TypeDictionary) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	type dict TypeDictionary
+	if err := dec.DecodeElement((*dict)(d), &start); err != nil {
+		return err
+	}
+	d.namespaces = map[string]string{}
+	for _, a := range start.Attr {
+		if a.Name.Space == "xmlns" {
+			d.namespaces[a.Name.Local] = a.Value
+		}
+	}
+	return nil
+}
+
+// TypeName returns the namespace and the name of a qualified type name
+// like "opc:Int32" or "tns:MyStructure". The well-known prefixes "opc",
+// "ua" and "tns" are used if the dictionary does not declare them.
+func (d *TypeDictionary) TypeName(s string) (ns, name string) {
+	prefix, name, found := strings.Cut(s, ":")
+	if !found {
+		return d.TargetNamespace, s
+	}
+	if ns, ok := d.namespaces[prefix]; ok {
+		return ns, name
+	}
+	switch prefix {
+	case "opc":
+		return BinarySchemaNamespace, name
+	case "ua":
+		return UANamespace, name
+	default:
+		return d.TargetNamespace, name
+	}
+}
+
+// StructType returns the structure with the name or nil.
+func (d *TypeDictionary) StructType(name string) *StructType {
+	for _, t := range d.Types {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// EnumType returns the enumeration with the name or nil.
+func (d *TypeDictionary) EnumType(name string) *EnumType {
+	for _, t := range d.Enums {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// ParseTypeDictionary parses an OPC Binary type dictionary.
+func ParseTypeDictionary(r io.Reader) (*TypeDictionary, error) {
+	d := new(TypeDictionary)
+	if err := xml.NewDecoder(r).Decode(d); err != nil {
+		return nil, err
+	}
+
+	for _, t := range d.Types {
+		for _, f := range t.Fields {
+			ns, name := d.TypeName(f.Type)
+			f.IsEnum = ns == d.TargetNamespace && d.EnumType(name) != nil
+		}
+	}
+	return d, nil
+}
@@ -69,8 +69,8 @@ type NodesToDelete struct {
 
 // NodeToDelete ...
 type NodeToDelete struct {
-	DeleteReverseReferencesAttr bool `xml:"DeleteReverseReferences,attr,omitempty"`
-	*NodeId
+	DeleteReverseReferencesAttr bool   `xml:"DeleteReverseReferences,attr,omitempty"`
+	NodeId                      NodeId `xml:",chardata"` // EDIT: this was changed from an embedded *NodeId since encoding/xml ignores it
 }
 
 // ReferencesToChange ...
@@ -83,7 +83,7 @@ type ReferenceChange struct {
 	SourceAttr        string `xml:"Source,attr"`
 	ReferenceTypeAttr string `xml:"ReferenceType,attr"`
 	IsForwardAttr     *bool  `xml:"IsForward,attr,omitempty"` // EDIT: this was changed from a bool to a *bool because the default value if this attribute isn't present is true
-	*NodeId
+	NodeId            NodeId `xml:",chardata"`                // EDIT: this was changed from an embedded *NodeId since encoding/xml ignores it
 }
 
 // NodeSetStatus ...
@@ -216,7 +216,7 @@ type UANode struct {
 	DisplayName            []*LocalizedText       `xml:"DisplayName"`
 	Description            []*LocalizedText       `xml:"Description"`
 	Category               []string               `xml:"Category"`
-	Documentation          string                 `xml:"Documentation"`
+	Documentation          string                 `xml:"Documentation,omitempty"` // EDIT: added omitempty so that exported nodesets do not contain empty elements
 	References             *ListOfReferences      `xml:"References"`
 	RolePermissions        *ListOfRolePermissions `xml:"RolePermissions"`
 	Extensions             *ListOfExtensions      `xml:"Extensions"`
@@ -236,6 +236,7 @@ type UAObject struct {
 
 // Value ...
 type Value struct {
+	InnerXML string `xml:",innerxml"` // EDIT: the value is kept as raw XML since its element depends on the data type
 }
 
 // UAVariable ...
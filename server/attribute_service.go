@@ -25,6 +25,7 @@ func (s *AttributeService) Read(sc *uasc.SecureChannel, r ua.Request, reqID uint
 		return nil, err
 	}
 
+	sess := s.srv.Session(req.RequestHeader)
 	results := make([]*ua.DataValue, len(req.NodesToRead))
 	for i, n := range req.NodesToRead {
 		if s.srv.cfg.logger != nil {
@@ -40,8 +41,12 @@ func (s *AttributeService) Read(sc *uasc.SecureChannel, r ua.Request, reqID uint
 			}
 			continue
 		}
-		results[i] = ns.Attribute(n.NodeID, n.AttributeID)
-
+		nr, err := ua.ParseNumericRange(n.IndexRange)
+		if err != nil {
+			results[i] = statusDataValue(ua.StatusBadIndexRangeInvalid)
+			continue
+		}
+		results[i] = sliceDataValue(s.srv.readAttribute(ns, sess, n.NodeID, n.AttributeID, n.IndexRange), nr)
 	}
 
 	response := &ua.ReadResponse{
@@ -83,10 +88,60 @@ func (s *AttributeService) Write(sc *uasc.SecureChannel, r ua.Request, reqID uin
 
 		ns, err := s.srv.Namespace(int(n.NodeID.Namespace()))
 		if err != nil {
-			status[i] = ua.StatusBadNodeNotInView
+			status[i] = ua.StatusBadNodeIDUnknown
+			continue
+		}
+		if status[i] = s.checkWrite(ns, n); status[i] != ua.StatusOK {
+			continue
+		}
+		nr, err := ua.ParseNumericRange(n.IndexRange)
+		if err != nil {
+			status[i] = ua.StatusBadIndexRangeInvalid
+			continue
+		}
+
+		v := n.Value
+		if len(nr) > 0 && v.Value != nil {
+			// only the elements in the range are written
+			cur := ns.Attribute(n.NodeID, n.AttributeID)
+			if cur == nil || cur.Status != ua.StatusOK {
+				status[i] = ua.StatusBadIndexRangeNoData
+				continue
+			}
+			patched, err := nr.Patch(cur.Value, v.Value)
+			if err != nil {
+				status[i] = errStatus(err)
+				continue
+			}
+			dv := *v
+			dv.Value = patched
+			v = &dv
+		}
+		if n.AttributeID == ua.AttributeIDValue && !v.Has(ua.DataValueSourceTimestamp) {
+			// the server sets the source timestamp if the client did not provide one.
+			dv := *v
+			dv.EncodingMask |= ua.DataValueSourceTimestamp
+			dv.SourceTimestamp = time.Now()
+			v = &dv
+		}
+		if hooks := writeHooks(ns, n.NodeID); len(hooks) > 0 {
+			access := s.srv.attributeAccess(req.RequestHeader, n.NodeID, n.AttributeID, n.IndexRange)
+			for _, hook := range hooks {
+				hv, st := hook(access, v)
+				if status[i] = st; st != ua.StatusOK {
+					break
+				}
+				// a nil value leaves the value unchanged
+				if hv != nil {
+					v = hv
+				}
+			}
+		}
+		if status[i] != ua.StatusOK {
+			continue
 		}
 
-		status[i] = ns.SetAttribute(n.NodeID, n.AttributeID, n.Value)
+		status[i] = ns.SetAttribute(n.NodeID, n.AttributeID, v)
 
 	}
 	response := &ua.WriteResponse{
@@ -106,6 +161,39 @@ func (s *AttributeService) Write(sc *uasc.SecureChannel, r ua.Request, reqID uin
 
 }
 
+// statusDataValue returns a data value with the status code.
+func statusDataValue(status ua.StatusCode) *ua.DataValue {
+	return &ua.DataValue{
+		EncodingMask:    ua.DataValueServerTimestamp | ua.DataValueStatusCode,
+		ServerTimestamp: time.Now(),
+		Status:          status,
+	}
+}
+
+// sliceDataValue returns a copy of the data value with the elements of
+// the value which are selected by the range. Data values without a value
+// are returned unchanged.
+func sliceDataValue(dv *ua.DataValue, nr ua.NumericRange) *ua.DataValue {
+	if len(nr) == 0 || dv == nil || dv.Value == nil || dv.Status != ua.StatusOK {
+		return dv
+	}
+	v, err := nr.Slice(dv.Value)
+	if err != nil {
+		return statusDataValue(errStatus(err))
+	}
+	x := *dv
+	x.Value = v
+	return &x
+}
+
+// errStatus returns the status code of the error.
+func errStatus(err error) ua.StatusCode {
+	if status, ok := err.(ua.StatusCode); ok {
+		return status
+	}
+	return ua.StatusBadInternalError
+}
+
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.10.5
 func (s *AttributeService) HistoryUpdate(sc *uasc.SecureChannel, r ua.Request, reqID uint32) (ua.Response, error) {
 	if s.srv.cfg.logger != nil {
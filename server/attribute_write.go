@@ -0,0 +1,378 @@
+package server
+
+import (
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// writeMaskBits contains the bits of the WriteMask which allow writing
+// the attributes other than the Value.
+var writeMaskBits = map[ua.AttributeID]ua.AttributeWriteMask{
+	ua.AttributeIDAccessLevel:             ua.AttributeWriteMaskAccessLevel,
+	ua.AttributeIDArrayDimensions:         ua.AttributeWriteMaskArrayDimensions,
+	ua.AttributeIDBrowseName:              ua.AttributeWriteMaskBrowseName,
+	ua.AttributeIDContainsNoLoops:         ua.AttributeWriteMaskContainsNoLoops,
+	ua.AttributeIDDataType:                ua.AttributeWriteMaskDataType,
+	ua.AttributeIDDescription:             ua.AttributeWriteMaskDescription,
+	ua.AttributeIDDisplayName:             ua.AttributeWriteMaskDisplayName,
+	ua.AttributeIDEventNotifier:           ua.AttributeWriteMaskEventNotifier,
+	ua.AttributeIDExecutable:              ua.AttributeWriteMaskExecutable,
+	ua.AttributeIDHistorizing:             ua.AttributeWriteMaskHistorizing,
+	ua.AttributeIDInverseName:             ua.AttributeWriteMaskInverseName,
+	ua.AttributeIDIsAbstract:              ua.AttributeWriteMaskIsAbstract,
+	ua.AttributeIDMinimumSamplingInterval: ua.AttributeWriteMaskMinimumSamplingInterval,
+	ua.AttributeIDNodeClass:               ua.AttributeWriteMaskNodeClass,
+	ua.AttributeIDNodeID:                  ua.AttributeWriteMaskNodeID,
+	ua.AttributeIDSymmetric:               ua.AttributeWriteMaskSymmetric,
+	ua.AttributeIDUserAccessLevel:         ua.AttributeWriteMaskUserAccessLevel,
+	ua.AttributeIDUserExecutable:          ua.AttributeWriteMaskUserExecutable,
+	ua.AttributeIDUserWriteMask:           ua.AttributeWriteMaskUserWriteMask,
+	ua.AttributeIDValueRank:               ua.AttributeWriteMaskValueRank,
+	ua.AttributeIDWriteMask:               ua.AttributeWriteMaskWriteMask,
+	ua.AttributeIDDataTypeDefinition:      ua.AttributeWriteMaskDataTypeDefinition,
+	ua.AttributeIDRolePermissions:         ua.AttributeWriteMaskRolePermissions,
+	ua.AttributeIDAccessRestrictions:      ua.AttributeWriteMaskAccessRestrictions,
+	ua.AttributeIDAccessLevelEx:           ua.AttributeWriteMaskAccessLevelEx,
+}
+
+// attributeTypes contains the built-in types of the attributes other
+// than the Value. Attributes which are not listed are not checked.
+var attributeTypes = map[ua.AttributeID]ua.TypeID{
+	ua.AttributeIDNodeID:                  ua.TypeIDNodeID,
+	ua.AttributeIDNodeClass:               ua.TypeIDInt32,
+	ua.AttributeIDBrowseName:              ua.TypeIDQualifiedName,
+	ua.AttributeIDDisplayName:             ua.TypeIDLocalizedText,
+	ua.AttributeIDDescription:             ua.TypeIDLocalizedText,
+	ua.AttributeIDWriteMask:               ua.TypeIDUint32,
+	ua.AttributeIDUserWriteMask:           ua.TypeIDUint32,
+	ua.AttributeIDIsAbstract:              ua.TypeIDBoolean,
+	ua.AttributeIDSymmetric:               ua.TypeIDBoolean,
+	ua.AttributeIDInverseName:             ua.TypeIDLocalizedText,
+	ua.AttributeIDContainsNoLoops:         ua.TypeIDBoolean,
+	ua.AttributeIDEventNotifier:           ua.TypeIDByte,
+	ua.AttributeIDDataType:                ua.TypeIDNodeID,
+	ua.AttributeIDValueRank:               ua.TypeIDInt32,
+	ua.AttributeIDArrayDimensions:         ua.TypeIDUint32,
+	ua.AttributeIDAccessLevel:             ua.TypeIDByte,
+	ua.AttributeIDUserAccessLevel:         ua.TypeIDByte,
+	ua.AttributeIDMinimumSamplingInterval: ua.TypeIDDouble,
+	ua.AttributeIDHistorizing:             ua.TypeIDBoolean,
+	ua.AttributeIDExecutable:              ua.TypeIDBoolean,
+	ua.AttributeIDUserExecutable:          ua.TypeIDBoolean,
+	ua.AttributeIDAccessRestrictions:      ua.TypeIDUint16,
+	ua.AttributeIDAccessLevelEx:           ua.TypeIDUint32,
+}
+
+// attributeNodeClasses contains the node classes which have the
+// attributes that are not common to all nodes.
+var attributeNodeClasses = map[ua.AttributeID]ua.NodeClass{
+	ua.AttributeIDIsAbstract:              ua.NodeClassObjectType | ua.NodeClassVariableType | ua.NodeClassReferenceType | ua.NodeClassDataType,
+	ua.AttributeIDSymmetric:               ua.NodeClassReferenceType,
+	ua.AttributeIDInverseName:             ua.NodeClassReferenceType,
+	ua.AttributeIDContainsNoLoops:         ua.NodeClassView,
+	ua.AttributeIDEventNotifier:           ua.NodeClassObject | ua.NodeClassView,
+	ua.AttributeIDDataType:                ua.NodeClassVariable | ua.NodeClassVariableType,
+	ua.AttributeIDValueRank:               ua.NodeClassVariable | ua.NodeClassVariableType,
+	ua.AttributeIDArrayDimensions:         ua.NodeClassVariable | ua.NodeClassVariableType,
+	ua.AttributeIDAccessLevel:             ua.NodeClassVariable,
+	ua.AttributeIDUserAccessLevel:         ua.NodeClassVariable,
+	ua.AttributeIDMinimumSamplingInterval: ua.NodeClassVariable,
+	ua.AttributeIDHistorizing:             ua.NodeClassVariable,
+	ua.AttributeIDExecutable:              ua.NodeClassMethod,
+	ua.AttributeIDUserExecutable:          ua.NodeClassMethod,
+	ua.AttributeIDDataTypeDefinition:      ua.NodeClassDataType,
+	ua.AttributeIDAccessLevelEx:           ua.NodeClassVariable,
+}
+
+// checkWrite validates the write of the value to the attribute of the
+// node. It returns ua.StatusOK if the value can be written.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/5.10.4
+func (s *AttributeService) checkWrite(ns NameSpace, wv *ua.WriteValue) ua.StatusCode {
+	nc := ns.Attribute(wv.NodeID, ua.AttributeIDNodeClass)
+	if nc.Status == ua.StatusBadNodeIDUnknown || nc.Status == ua.StatusBadNodeIDInvalid {
+		return ua.StatusBadNodeIDUnknown
+	}
+	if wv.Value == nil {
+		return ua.StatusBadTypeMismatch
+	}
+
+	x, _ := attrInt(nc)
+	nodeClass := ua.NodeClass(x)
+	if wv.AttributeID != ua.AttributeIDValue {
+		if classes, ok := attributeNodeClasses[wv.AttributeID]; ok && classes&nodeClass == 0 {
+			return ua.StatusBadAttributeIDInvalid
+		}
+		return s.checkAttributeWrite(ns, wv)
+	}
+
+	var status ua.StatusCode
+	switch nodeClass {
+	case ua.NodeClassVariable:
+		status = checkAccessLevel(ns, wv)
+	case ua.NodeClassVariableType:
+		status = checkWriteMask(ns, wv.NodeID, ua.AttributeWriteMaskValueForVariableType)
+		if status == ua.StatusOK && wv.Value.EncodingMask&^ua.DataValueValue != 0 {
+			status = ua.StatusBadWriteNotSupported
+		}
+	default:
+		return ua.StatusBadAttributeIDInvalid
+	}
+	if status != ua.StatusOK {
+		return status
+	}
+
+	if !wv.Value.Has(ua.DataValueValue) || wv.Value.Value == nil {
+		// only the status code is written
+		if wv.Value.Has(ua.DataValueStatusCode) {
+			return ua.StatusOK
+		}
+		return ua.StatusBadTypeMismatch
+	}
+	return s.checkValue(ns, wv.NodeID, wv.Value.Value)
+}
+
+// checkAttributeWrite validates the write of an attribute other than the Value.
+func (s *AttributeService) checkAttributeWrite(ns NameSpace, wv *ua.WriteValue) ua.StatusCode {
+	bit, ok := writeMaskBits[wv.AttributeID]
+	if !ok {
+		return ua.StatusBadAttributeIDInvalid
+	}
+	if cur := ns.Attribute(wv.NodeID, wv.AttributeID); cur.Status == ua.StatusBadAttributeIDInvalid {
+		return ua.StatusBadAttributeIDInvalid
+	}
+	if status := checkWriteMask(ns, wv.NodeID, bit); status != ua.StatusOK {
+		return status
+	}
+	if wv.Value.EncodingMask != ua.DataValueValue {
+		return ua.StatusBadWriteNotSupported
+	}
+
+	v := wv.Value.Value
+	typ, ok := attributeTypes[wv.AttributeID]
+	switch {
+	case v == nil:
+		return ua.StatusBadTypeMismatch
+	case !ok:
+		return ua.StatusOK
+	case v.Type() != typ:
+		return ua.StatusBadTypeMismatch
+	case wv.AttributeID == ua.AttributeIDArrayDimensions && !v.Has(ua.VariantArrayValues):
+		return ua.StatusBadTypeMismatch
+	case wv.AttributeID != ua.AttributeIDArrayDimensions && v.Has(ua.VariantArrayValues):
+		return ua.StatusBadTypeMismatch
+	}
+	return ua.StatusOK
+}
+
+// checkWriteMask checks that the bit of the WriteMask and the UserWriteMask
+// of the node is set.
+func checkWriteMask(ns NameSpace, nid *ua.NodeID, bit ua.AttributeWriteMask) ua.StatusCode {
+	mask, _ := attrInt(ns.Attribute(nid, ua.AttributeIDWriteMask))
+	if ua.AttributeWriteMask(mask)&bit == 0 {
+		return ua.StatusBadNotWritable
+	}
+	if mask, ok := attrInt(ns.Attribute(nid, ua.AttributeIDUserWriteMask)); ok && ua.AttributeWriteMask(mask)&bit == 0 {
+		return ua.StatusBadUserAccessDenied
+	}
+	return ua.StatusOK
+}
+
+// checkAccessLevel checks that the AccessLevel and the UserAccessLevel of
+// the variable allow the write of the value, the status code and the
+// timestamps of the data value. The value of variables without an
+// AccessLevel can be written.
+func checkAccessLevel(ns NameSpace, wv *ua.WriteValue) ua.StatusCode {
+	level := ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite
+	if x, ok := attrInt(ns.Attribute(wv.NodeID, ua.AttributeIDAccessLevel)); ok {
+		level = ua.AccessLevelType(x)
+	}
+	if x, ok := attrInt(ns.Attribute(wv.NodeID, ua.AttributeIDUserAccessLevel)); ok {
+		// the server does not know more about the user than the access
+		// level of the variable
+		if level&ua.AccessLevelTypeCurrentWrite != 0 && ua.AccessLevelType(x)&ua.AccessLevelTypeCurrentWrite == 0 {
+			return ua.StatusBadUserAccessDenied
+		}
+	}
+
+	dv := wv.Value
+	switch {
+	case level&ua.AccessLevelTypeCurrentWrite == 0:
+		return ua.StatusBadNotWritable
+	case dv.Has(ua.DataValueStatusCode) && level&ua.AccessLevelTypeStatusWrite == 0:
+		return ua.StatusBadWriteNotSupported
+	case dv.Has(ua.DataValueSourceTimestamp) && level&ua.AccessLevelTypeTimestampWrite == 0:
+		return ua.StatusBadWriteNotSupported
+	case dv.Has(ua.DataValueSourcePicoseconds) && level&ua.AccessLevelTypeTimestampWrite == 0:
+		return ua.StatusBadWriteNotSupported
+	case dv.Has(ua.DataValueServerTimestamp), dv.Has(ua.DataValueServerPicoseconds):
+		return ua.StatusBadWriteNotSupported
+	}
+	return ua.StatusOK
+}
+
+// checkValue checks that the value matches the DataType, the ValueRank
+// and the ArrayDimensions of the variable.
+func (s *AttributeService) checkValue(ns NameSpace, nid *ua.NodeID, v *ua.Variant) ua.StatusCode {
+	dt := attrNodeID(ns.Attribute(nid, ua.AttributeIDDataType))
+	rank, ok := attrInt(ns.Attribute(nid, ua.AttributeIDValueRank))
+	if !ok {
+		rank = -2 // Any
+	}
+	var max []uint32
+	if dv := ns.Attribute(nid, ua.AttributeIDArrayDimensions); dv.Status == ua.StatusOK && dv.Value != nil {
+		max, _ = dv.Value.Value().([]uint32)
+	}
+	return s.srv.checkVariant(v, dt, rank, max)
+}
+
+// checkVariant checks that the value matches the data type, the value
+// rank and the array dimensions. The array dimensions are the maximum
+// lengths of the dimensions and are not checked if max is empty.
+func (s *Server) checkVariant(v *ua.Variant, dt *ua.NodeID, rank int64, max []uint32) ua.StatusCode {
+	if v == nil || v.Type() == ua.TypeIDNull {
+		return ua.StatusOK
+	}
+	if !s.isDataType(dt, v) {
+		return ua.StatusBadTypeMismatch
+	}
+
+	// a ByteString can be written to a one-dimensional array of bytes
+	isArray := v.Has(ua.VariantArrayValues)
+	dims := []int32{v.ArrayLength()}
+	switch {
+	case v.Has(ua.VariantArrayDimensions):
+		dims = v.ArrayDimensions()
+	case v.Type() == ua.TypeIDByteString && !isArray && dt != nil && dt.Equal(ua.NewNumericNodeID(0, id.Byte)):
+		isArray = true
+		dims = []int32{int32(len(v.ByteString()))}
+	case !isArray:
+		dims = nil
+	}
+
+	var valid bool
+	switch {
+	case rank == -3: // ScalarOrOneDimension
+		valid = len(dims) <= 1
+	case rank == -2: // Any
+		valid = true
+	case rank == -1: // Scalar
+		valid = !isArray
+	case rank == 0: // OneOrMoreDimensions
+		valid = isArray
+	default:
+		valid = isArray && int64(len(dims)) == rank
+	}
+	if !valid {
+		return ua.StatusBadTypeMismatch
+	}
+
+	if len(max) != len(dims) {
+		return ua.StatusOK
+	}
+	for i := range max {
+		if max[i] > 0 && uint32(dims[i]) > max[i] {
+			return ua.StatusBadOutOfRange
+		}
+	}
+	return ua.StatusOK
+}
+
+// isDataType returns true if the built-in type of the value is the data
+// type or one of its subtypes. Values of data types which are not known
+// by the server are not checked.
+func (s *Server) isDataType(dt *ua.NodeID, v *ua.Variant) bool {
+	if dt == nil {
+		return true
+	}
+	n := s.Node(dt)
+	if n == nil || n.NodeClass() != ua.NodeClassDataType {
+		return true
+	}
+	if dt.Equal(ua.NewNumericNodeID(0, id.BaseDataType)) {
+		return true
+	}
+
+	typ := ua.NewNumericNodeID(0, uint32(v.Type()))
+	switch v.Type() {
+	case ua.TypeIDByteString:
+		// a ByteString is also an array of bytes
+		if dt.Equal(ua.NewNumericNodeID(0, id.Byte)) {
+			return true
+		}
+	case ua.TypeIDInt32:
+		// enumerations are encoded as Int32
+		if s.IsSubtype(dt, ua.NewNumericNodeID(0, id.Enumeration)) {
+			return true
+		}
+	case ua.TypeIDExtensionObject:
+		typ = ua.NewNumericNodeID(0, id.Structure)
+		if eo, ok := v.Value().(*ua.ExtensionObject); ok && eo != nil && eo.TypeID != nil {
+			if t := s.encodedDataType(eo.TypeID.NodeID); t != nil {
+				typ = t
+			}
+		} else if eos, ok := v.Value().([]*ua.ExtensionObject); ok && len(eos) > 0 && eos[0] != nil && eos[0].TypeID != nil {
+			if t := s.encodedDataType(eos[0].TypeID.NodeID); t != nil {
+				typ = t
+			}
+		}
+		if typ.Equal(ua.NewNumericNodeID(0, id.Structure)) {
+			// the data type of the encoding is unknown
+			return s.IsSubtype(dt, typ)
+		}
+	}
+	return s.IsSubtype(typ, dt)
+}
+
+// encodedDataType returns the data type of the encoding node or nil if
+// the encoding is unknown.
+func (s *Server) encodedDataType(enc *ua.NodeID) *ua.NodeID {
+	n := s.Node(enc)
+	if n == nil {
+		return nil
+	}
+	for _, r := range n.refs {
+		if !r.IsForward && r.ReferenceTypeID.IntID() == id.HasEncoding && r.NodeID != nil {
+			return r.NodeID.NodeID
+		}
+	}
+	return nil
+}
+
+// attrInt returns the integer value of the attribute.
+func attrInt(dv *ua.DataValue) (int64, bool) {
+	if dv == nil || dv.Status != ua.StatusOK || dv.Value == nil {
+		return 0, false
+	}
+	switch x := dv.Value.Value().(type) {
+	case byte:
+		return int64(x), true
+	case int16:
+		return int64(x), true
+	case uint16:
+		return int64(x), true
+	case int32:
+		return int64(x), true
+	case uint32:
+		return int64(x), true
+	case int64:
+		return x, true
+	default:
+		return 0, false
+	}
+}
+
+// attrNodeID returns the node id value of the attribute.
+func attrNodeID(dv *ua.DataValue) *ua.NodeID {
+	if dv == nil || dv.Status != ua.StatusOK || dv.Value == nil {
+		return nil
+	}
+	switch x := dv.Value.Value().(type) {
+	case *ua.NodeID:
+		return x
+	case *ua.ExpandedNodeID:
+		return x.NodeID
+	default:
+		return nil
+	}
+}
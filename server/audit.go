@@ -0,0 +1,325 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uasc"
+)
+
+// EnableAuditing enables the generation of audit events for security
+// related operations: opening secure channels, certificate failures,
+// creating, activating and closing sessions, writes, method calls and
+// node management.
+//
+// The events are reported on the Server object and the Auditing
+// property of the Server object is set to true.
+//
+// https://reference.opcfoundation.org/Core/Part5/v105/docs/6.4.3
+func EnableAuditing() Option {
+	return func(s *serverConfig) {
+		s.auditing = true
+	}
+}
+
+// auditSeverity is the severity of successful audit events. Failed
+// operations are reported with auditFailedSeverity.
+const (
+	auditSeverity       = 100
+	auditFailedSeverity = 500
+)
+
+// initAuditing installs the hooks which generate the audit events.
+func (s *Server) initAuditing() {
+	s.cb.onOpen = s.auditOpenSecureChannel
+
+	// the audit middleware is the outermost middleware so that
+	// it also records requests which are rejected by other middleware.
+	s.cfg.middleware = append([]Middleware{s.auditMiddleware}, s.cfg.middleware...)
+}
+
+// newAuditEvent returns an audit event of type typ reported by the server.
+func (s *Server) newAuditEvent(typ uint32, sourceName string, hdr *ua.RequestHeader, sess *session, err error) *Event {
+	ev := &Event{
+		EventType:  ua.NewNumericNodeID(0, typ),
+		SourceNode: ua.NewNumericNodeID(0, id.Server),
+		SourceName: sourceName,
+		Severity:   auditSeverity,
+		Message:    sourceName,
+		Fields: map[string]any{
+			"ActionTimeStamp": time.Now(),
+			"Status":          err == nil,
+			"ServerId":        s.cfg.applicationURI,
+		},
+	}
+	if err != nil {
+		ev.Severity = auditFailedSeverity
+		ev.Message = fmt.Sprintf("%s failed: %s", sourceName, err)
+	}
+	if hdr != nil {
+		ev.Fields["ClientAuditEntryId"] = hdr.AuditEntryID
+	}
+	if sess != nil {
+		ev.Fields["ClientUserId"] = sess.clientUserID()
+	}
+	return ev
+}
+
+// auditOpenSecureChannel reports an AuditOpenSecureChannelEventType
+// event or one of the AuditCertificateEventType events if the channel
+// was rejected because of the client certificate.
+func (s *Server) auditOpenSecureChannel(sc *uasc.SecureChannel, secureChannelID uint32, err error) {
+	if typ, ok := certificateEventType(err); ok {
+		ev := s.newAuditEvent(typ, "Security/Certificate", nil, nil, err)
+		ev.Fields["Certificate"] = sc.RemoteCertificate()
+		s.reportAudit(ev)
+	}
+
+	ev := s.newAuditEvent(id.AuditOpenSecureChannelEventType, "SecureChannel/OpenSecureChannel", nil, nil, err)
+	ev.Fields["SecureChannelId"] = fmt.Sprint(secureChannelID)
+	ev.Fields["ClientCertificate"] = sc.RemoteCertificate()
+	ev.Fields["SecurityPolicyUri"] = sc.SecurityPolicyURI()
+	ev.Fields["SecurityMode"] = int32(sc.SecurityMode())
+	s.reportAudit(ev)
+}
+
+// certificateEventType returns the type of the AuditCertificateEventType
+// event for a certificate error.
+func certificateEventType(err error) (uint32, bool) {
+	var code ua.StatusCode
+	if !errors.As(err, &code) {
+		return 0, false
+	}
+	switch code {
+	case ua.StatusBadCertificateUntrusted:
+		return id.AuditCertificateUntrustedEventType, true
+	case ua.StatusBadCertificateTimeInvalid, ua.StatusBadCertificateIssuerTimeInvalid:
+		return id.AuditCertificateExpiredEventType, true
+	case ua.StatusBadCertificateRevoked, ua.StatusBadCertificateIssuerRevoked:
+		return id.AuditCertificateRevokedEventType, true
+	case ua.StatusBadCertificateInvalid, ua.StatusBadSecurityChecksFailed,
+		ua.StatusBadCertificateUseNotAllowed, ua.StatusBadCertificateURIInvalid,
+		ua.StatusBadCertificateHostNameInvalid:
+		return id.AuditCertificateInvalidEventType, true
+	default:
+		return 0, false
+	}
+}
+
+// auditMiddleware reports the audit events for the service requests.
+func (s *Server) auditMiddleware(next Handler) Handler {
+	return func(sc *uasc.SecureChannel, r ua.Request, reqID uint32) (ua.Response, error) {
+		hdr := r.Header()
+		if hdr == nil {
+			return next(sc, r, reqID)
+		}
+
+		switch req := r.(type) {
+		case *ua.CreateSessionRequest:
+			resp, err := next(sc, r, reqID)
+			status := serviceErr(resp, err)
+			var sess *session
+			ev := s.newAuditEvent(id.AuditCreateSessionEventType, "Session/CreateSession", hdr, nil, status)
+			if resp, ok := resp.(*ua.CreateSessionResponse); ok && status == nil {
+				sess = s.sb.Session(resp.AuthenticationToken)
+				ev.Fields["SessionId"] = resp.SessionID
+				ev.Fields["RevisedSessionTimeout"] = resp.RevisedSessionTimeout
+			}
+			ev.Fields["SecureChannelId"] = fmt.Sprint(s.cb.channelID(sc))
+			ev.Fields["ClientCertificate"] = req.ClientCertificate
+			if sess != nil {
+				ev.Fields["ClientUserId"] = sess.clientUserID()
+			}
+			s.reportAudit(ev)
+			return resp, err
+
+		case *ua.ActivateSessionRequest:
+			resp, err := next(sc, r, reqID)
+			sess := s.sb.Session(hdr.AuthenticationToken)
+			ev := s.newAuditEvent(id.AuditActivateSessionEventType, "Session/ActivateSession", hdr, sess, serviceErr(resp, err))
+			if sess != nil {
+				ev.Fields["SessionId"] = sess.ID
+			}
+			ev.Fields["SecureChannelId"] = fmt.Sprint(s.cb.channelID(sc))
+			if req.UserIdentityToken != nil {
+				ev.Fields["UserIdentityToken"] = req.UserIdentityToken
+			}
+			s.reportAudit(ev)
+			return resp, err
+
+		case *ua.CloseSessionRequest:
+			// the session is gone after the request has been handled.
+			sess := s.sb.Session(hdr.AuthenticationToken)
+			resp, err := next(sc, r, reqID)
+			ev := s.newAuditEvent(id.AuditSessionEventType, "Session/CloseSession", hdr, sess, serviceErr(resp, err))
+			if sess != nil {
+				ev.Fields["SessionId"] = sess.ID
+			}
+			s.reportAudit(ev)
+			return resp, err
+
+		case *ua.WriteRequest:
+			// the old values must be read before they are overwritten.
+			sess := s.sb.Session(hdr.AuthenticationToken)
+			old := make([]*ua.DataValue, len(req.NodesToWrite))
+			for i, wv := range req.NodesToWrite {
+				if wv == nil {
+					continue
+				}
+				old[i] = s.auditOldValue(sess, wv)
+			}
+			resp, err := next(sc, r, reqID)
+			status := serviceErr(resp, err)
+			var results []ua.StatusCode
+			if resp, ok := resp.(*ua.WriteResponse); ok {
+				results = resp.Results
+			}
+			for i, wv := range req.NodesToWrite {
+				if wv == nil {
+					continue
+				}
+				nodeErr := status
+				if nodeErr == nil && i < len(results) && results[i] != ua.StatusOK {
+					nodeErr = results[i]
+				}
+				ev := s.newAuditEvent(id.AuditWriteUpdateEventType, "Attribute/Write", hdr, sess, nodeErr)
+				ev.SourceNode = wv.NodeID
+				ev.Fields["AttributeId"] = uint32(wv.AttributeID)
+				ev.Fields["IndexRange"] = wv.IndexRange
+				ev.Fields["NewValue"] = dataValueValue(wv.Value)
+				if old[i] != nil {
+					ev.Fields["OldValue"] = dataValueValue(old[i])
+				}
+				s.reportAudit(ev)
+			}
+			return resp, err
+
+		case *ua.CallRequest:
+			resp, err := next(sc, r, reqID)
+			status := serviceErr(resp, err)
+			sess := s.sb.Session(hdr.AuthenticationToken)
+			var results []*ua.CallMethodResult
+			if resp, ok := resp.(*ua.CallResponse); ok {
+				results = resp.Results
+			}
+			for i, m := range req.MethodsToCall {
+				if m == nil {
+					continue
+				}
+				methodErr := status
+				if methodErr == nil && i < len(results) && results[i] != nil && results[i].StatusCode != ua.StatusOK {
+					methodErr = results[i].StatusCode
+				}
+				ev := s.newAuditEvent(id.AuditUpdateMethodEventType, "Method/Call", hdr, sess, methodErr)
+				ev.SourceNode = m.ObjectID
+				ev.Fields["MethodId"] = m.MethodID
+				ev.Fields["InputArguments"] = m.InputArguments
+				s.reportAudit(ev)
+			}
+			return resp, err
+
+		case *ua.AddNodesRequest:
+			resp, err := next(sc, r, reqID)
+			ev := s.newAuditEvent(id.AuditAddNodesEventType, "NodeManagement/AddNodes", hdr, s.sb.Session(hdr.AuthenticationToken), serviceErr(resp, err))
+			ev.Fields["NodesToAdd"] = extensionObjects(req.NodesToAdd)
+			s.reportAudit(ev)
+			return resp, err
+
+		case *ua.DeleteNodesRequest:
+			resp, err := next(sc, r, reqID)
+			ev := s.newAuditEvent(id.AuditDeleteNodesEventType, "NodeManagement/DeleteNodes", hdr, s.sb.Session(hdr.AuthenticationToken), serviceErr(resp, err))
+			ev.Fields["NodesToDelete"] = extensionObjects(req.NodesToDelete)
+			s.reportAudit(ev)
+			return resp, err
+
+		case *ua.AddReferencesRequest:
+			resp, err := next(sc, r, reqID)
+			ev := s.newAuditEvent(id.AuditAddReferencesEventType, "NodeManagement/AddReferences", hdr, s.sb.Session(hdr.AuthenticationToken), serviceErr(resp, err))
+			ev.Fields["ReferencesToAdd"] = extensionObjects(req.ReferencesToAdd)
+			s.reportAudit(ev)
+			return resp, err
+
+		case *ua.DeleteReferencesRequest:
+			resp, err := next(sc, r, reqID)
+			ev := s.newAuditEvent(id.AuditDeleteReferencesEventType, "NodeManagement/DeleteReferences", hdr, s.sb.Session(hdr.AuthenticationToken), serviceErr(resp, err))
+			ev.Fields["ReferencesToDelete"] = extensionObjects(req.ReferencesToDelete)
+			s.reportAudit(ev)
+			return resp, err
+
+		default:
+			return next(sc, r, reqID)
+		}
+	}
+}
+
+// reportAudit reports an audit event on the Server object.
+func (s *Server) reportAudit(ev *Event) {
+	s.ReportEvent(ua.NewNumericNodeID(0, id.Server), ev)
+}
+
+// serviceErr returns the error of a service call which is either
+// returned by the handler or set as the service result of the response.
+func serviceErr(resp ua.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if resp == nil || resp.Header() == nil {
+		return nil
+	}
+	if code := resp.Header().ServiceResult; code != ua.StatusOK {
+		return code
+	}
+	return nil
+}
+
+// auditOldValue returns the value of the attribute before the write as
+// the client of the session would read it. It returns nil if the client
+// is not allowed to read the attribute.
+func (s *Server) auditOldValue(sess *session, wv *ua.WriteValue) *ua.DataValue {
+	ns, err := s.Namespace(int(wv.NodeID.Namespace()))
+	if err != nil {
+		return nil
+	}
+	if wv.AttributeID == ua.AttributeIDValue {
+		for _, attr := range []ua.AttributeID{ua.AttributeIDAccessLevel, ua.AttributeIDUserAccessLevel} {
+			if x, ok := attrInt(ns.Attribute(wv.NodeID, attr)); ok && ua.AccessLevelType(x)&ua.AccessLevelTypeCurrentRead == 0 {
+				return nil
+			}
+		}
+	}
+	// read hooks which deny the read return a data value without a value.
+	nr, err := ua.ParseNumericRange(wv.IndexRange)
+	if err != nil {
+		return nil
+	}
+	dv := sliceDataValue(s.readAttribute(ns, sess, wv.NodeID, wv.AttributeID, wv.IndexRange), nr)
+	if dv == nil || dv.Value == nil {
+		return nil
+	}
+	return dv
+}
+
+// dataValueValue returns the value of a data value or nil.
+func dataValueValue(v *ua.DataValue) any {
+	if v == nil || v.Value == nil {
+		return nil
+	}
+	return v.Value.Value()
+}
+
+// extensionObjects wraps the items in extension objects so that they
+// can be used as event fields.
+func extensionObjects[T any](items []T) []*ua.ExtensionObject {
+	eos := make([]*ua.ExtensionObject, len(items))
+	for i, item := range items {
+		eos[i] = ua.NewExtensionObject(item)
+	}
+	return eos
+}
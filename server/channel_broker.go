@@ -33,6 +33,10 @@ type channelBroker struct {
 	// get funneled into for handling
 	msgChan chan *uasc.MessageBody
 	logger  Logger
+
+	// onOpen is called after an OpenSecureChannel request has been
+	// handled with the error of the request. May be nil.
+	onOpen func(sc *uasc.SecureChannel, secureChannelID uint32, err error)
 }
 
 func newChannelBroker(logger Logger) *channelBroker {
@@ -88,6 +92,7 @@ func (c *channelBroker) RegisterConn(ctx context.Context, conn *uacp.Conn, local
 	}
 	c.mu.Unlock()
 	c.wg.Add(1)
+	opened := false
 outer:
 	for {
 		select {
@@ -109,8 +114,20 @@ outer:
 				if c.logger != nil {
 					c.logger.Error("Secure Channel %d error: %s", secureChannelID, msg.Err)
 				}
+				if !opened && c.onOpen != nil {
+					c.onOpen(sc, secureChannelID, msg.Err)
+				}
 				break outer
 			}
+			// the secure channel returns an empty message after
+			// it has handled the OpenSecureChannel request.
+			if !opened && msg.Request() == nil {
+				opened = true
+				if c.onOpen != nil {
+					c.onOpen(sc, secureChannelID, nil)
+				}
+				continue
+			}
 			// todo(fs): honor ctx
 			c.msgChan <- msg
 		}
@@ -124,6 +141,19 @@ outer:
 	return nil
 }
 
+// channelID returns the id of the secure channel or 0 if
+// the channel is not known to the broker.
+func (c *channelBroker) channelID(sc *uasc.SecureChannel) uint32 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for id, s := range c.s {
+		if s == sc {
+			return id
+		}
+	}
+	return 0
+}
+
 // Close gracefully closes all secure channels
 // todo(fs): use ctx
 func (c *channelBroker) Close() error {
@@ -0,0 +1,351 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// AddStructureDataType adds a structure DataType for the Go struct type
+// of v to the namespace so that generic clients can decode the values of
+// the type.
+//
+// v is a pointer to the struct which must have been registered under the
+// id of its Default Binary encoding in this namespace, either with the
+// type registry of the TypeRegistry option or with
+// ua.RegisterExtensionObject, e.g.
+//
+//	ua.RegisterExtensionObject(ua.NewNumericNodeID(ns.ID(), 5001), new(Motor))
+//	ns.AddStructureDataType("Motor", ua.NewNumericNodeID(ns.ID(), 5000), new(Motor))
+//
+// The method adds the DataType node with its DataTypeDefinition and the
+// encoding node. The fields of the struct must be of a built-in type, an
+// enumeration added with AddEnumDataType, a structure added before or a
+// slice of them. A nil nodeID allocates a numeric node id.
+//
+// Variables of the type use the node id of the DataType as their DataType
+// attribute and have a *ua.ExtensionObject value.
+func (ns *NodeNameSpace) AddStructureDataType(name string, nodeID *ua.NodeID, v any) (*Node, error) {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%T is not a pointer to a struct", v)
+	}
+	enc := ns.srv.encodingID(v)
+	if enc == nil || enc.Namespace() != ns.ID() {
+		return nil, fmt.Errorf("%T is not registered as an extension object in namespace %d", v, ns.ID())
+	}
+	if ns.Node(enc) != nil {
+		return nil, fmt.Errorf("encoding %s already exists", enc)
+	}
+	nodeID, err := ns.dataTypeNodeID(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	def := &ua.StructureDefinition{
+		DefaultEncodingID: enc,
+		BaseDataType:      ua.NewNumericNodeID(0, id.Structure),
+		StructureType:     ua.StructureTypeStructure,
+	}
+	st := t.Elem()
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		if !f.IsExported() {
+			return nil, fmt.Errorf("%s.%s: unexported fields cannot be decoded", st, f.Name)
+		}
+		dt, rank, err := ns.srv.goDataType(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", st, f.Name, err)
+		}
+		sf := &ua.StructureField{
+			Name:        f.Name,
+			Description: ua.NewLocalizedText(f.Tag.Get("description")),
+			DataType:    dt,
+			ValueRank:   rank,
+		}
+		if f.Type.Kind() == reflect.Array {
+			sf.ArrayDimensions = []uint32{uint32(f.Type.Len())}
+		}
+		def.Fields = append(def.Fields, sf)
+	}
+
+	n := ns.addDataType(name, nodeID, id.Structure, def)
+
+	encNode := NewNode(enc, Attributes{
+		ua.AttributeIDNodeClass:   DataValueFromValue(uint32(ua.NodeClassObject)),
+		ua.AttributeIDBrowseName:  DataValueFromValue(&ua.QualifiedName{Name: "Default Binary"}),
+		ua.AttributeIDDisplayName: DataValueFromValue(ua.NewLocalizedText("Default Binary")),
+	}, nil, nil)
+	if td := ns.srv.Node(ua.NewNumericNodeID(0, id.DataTypeEncodingType)); td != nil {
+		encNode.AddRef(td, id.HasTypeDefinition, true)
+	}
+	ns.AddNode(encNode)
+	n.AddRef(encNode, id.HasEncoding, true)
+	encNode.AddRef(n, id.HasEncoding, false)
+
+	ns.srv.registerDataType(t, nodeID)
+	return n, nil
+}
+
+// AddEnumDataType adds an enumeration DataType to the namespace.
+//
+// v is a value of the Go type of the enumeration, e.g. Color(0), which
+// must be an int32 type since enumerations are encoded as Int32. Struct
+// fields of this type refer to the DataType. Values of the predeclared
+// type int32 add the DataType without a Go type so that int32 fields
+// remain Int32.
+//
+// The fields are the values of the enumeration. The DataType gets the
+// EnumStrings property if the values are 0, 1, 2... and the EnumValues
+// property otherwise. A nil nodeID allocates a numeric node id.
+func (ns *NodeNameSpace) AddEnumDataType(name string, nodeID *ua.NodeID, v any, fields []*ua.EnumField) (*Node, error) {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Int32 {
+		return nil, fmt.Errorf("%T is not an int32 type", v)
+	}
+	nodeID, err := ns.dataTypeNodeID(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	sequential := true
+	def := &ua.EnumDefinition{Fields: make([]*ua.EnumField, len(fields))}
+	strs := make([]*ua.LocalizedText, len(fields))
+	vals := make([]*ua.ExtensionObject, len(fields))
+	for i, f := range fields {
+		// the display name defaults to the name and the localized texts
+		// must not be nil since they are encoded.
+		x := *f
+		if x.DisplayName == nil || x.DisplayName.Text == "" {
+			x.DisplayName = ua.NewLocalizedText(x.Name)
+		}
+		if x.Description == nil {
+			x.Description = ua.NewLocalizedText("")
+		}
+		def.Fields[i] = &x
+		sequential = sequential && x.Value == int64(i)
+		strs[i] = x.DisplayName
+		vals[i] = ua.NewExtensionObject(&ua.EnumValueType{Value: x.Value, DisplayName: x.DisplayName, Description: x.Description})
+	}
+
+	n := ns.addDataType(name, nodeID, id.Enumeration, def)
+	if sequential {
+		ns.addDataTypeProperty(n, "EnumStrings", id.LocalizedText, strs)
+	} else {
+		ns.addDataTypeProperty(n, "EnumValues", id.EnumValueType, vals)
+	}
+
+	if t.PkgPath() != "" {
+		ns.srv.registerDataType(t, nodeID)
+	}
+	return n, nil
+}
+
+// dataTypeNodeID checks that the node id of a new DataType belongs to the
+// namespace. A nil node id is replaced with the next numeric node id.
+func (ns *NodeNameSpace) dataTypeNodeID(nodeID *ua.NodeID) (*ua.NodeID, error) {
+	if nodeID == nil {
+		return ua.NewNumericNodeID(ns.ID(), ns.GetNextNodeID()), nil
+	}
+	if nodeID.Namespace() != ns.ID() {
+		return nil, fmt.Errorf("node id %s is not in namespace %d", nodeID, ns.ID())
+	}
+	if ns.Node(nodeID) != nil {
+		return nil, fmt.Errorf("node id %s already exists", nodeID)
+	}
+	return nodeID, nil
+}
+
+// addDataType adds the DataType node as a subtype of the base DataType.
+func (ns *NodeNameSpace) addDataType(name string, nodeID *ua.NodeID, base uint32, def any) *Node {
+	n := NewNode(nodeID, Attributes{
+		ua.AttributeIDNodeClass:          DataValueFromValue(uint32(ua.NodeClassDataType)),
+		ua.AttributeIDBrowseName:         DataValueFromValue(&ua.QualifiedName{NamespaceIndex: ns.ID(), Name: name}),
+		ua.AttributeIDDisplayName:        DataValueFromValue(ua.NewLocalizedText(name)),
+		ua.AttributeIDIsAbstract:         DataValueFromValue(false),
+		ua.AttributeIDDataTypeDefinition: DataValueFromValue(ua.NewExtensionObject(def)),
+	}, nil, nil)
+	ns.AddNode(n)
+	if b := ns.srv.Node(ua.NewNumericNodeID(0, base)); b != nil {
+		b.AddRef(n, id.HasSubtype, true)
+		n.AddRef(b, id.HasSubtype, false)
+	}
+	return n
+}
+
+// addDataTypeProperty adds the EnumStrings or EnumValues property.
+func (ns *NodeNameSpace) addDataTypeProperty(n *Node, name string, dt uint32, v any) {
+	length := uint32(reflect.ValueOf(v).Len())
+	prop := NewNode(ua.NewNumericNodeID(ns.ID(), ns.GetNextNodeID()), Attributes{
+		ua.AttributeIDNodeClass:       DataValueFromValue(uint32(ua.NodeClassVariable)),
+		ua.AttributeIDBrowseName:      DataValueFromValue(&ua.QualifiedName{Name: name}),
+		ua.AttributeIDDisplayName:     DataValueFromValue(ua.NewLocalizedText(name)),
+		ua.AttributeIDDataType:        DataValueFromValue(ua.NewNumericExpandedNodeID(0, dt)),
+		ua.AttributeIDValueRank:       DataValueFromValue(int32(1)),
+		ua.AttributeIDArrayDimensions: DataValueFromValue([]uint32{length}),
+		ua.AttributeIDAccessLevel:     DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead)),
+	}, nil, func() *ua.DataValue { return DataValueFromValue(v) })
+	if td := ns.srv.Node(ua.NewNumericNodeID(0, id.PropertyType)); td != nil {
+		prop.AddRef(td, id.HasTypeDefinition, true)
+	}
+	ns.AddNode(prop)
+	n.AddRef(prop, id.HasProperty, true)
+	prop.AddRef(n, id.HasProperty, false)
+}
+
+// registerDataType records the DataType of the Go type.
+func (s *Server) registerDataType(t reflect.Type, dt *ua.NodeID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dataTypes[t] = dt
+}
+
+// builtinDataTypes contains the DataTypes of the Go types of the ua
+// package which are encoded as built-in types.
+var builtinDataTypes = map[reflect.Type]uint32{
+	reflect.TypeOf(ua.StatusCode(0)):        id.StatusCode,
+	reflect.TypeOf(new(ua.GUID)):            id.GUID,
+	reflect.TypeOf(ua.XMLElement("")):       id.XMLElement,
+	reflect.TypeOf(new(ua.NodeID)):          id.NodeID,
+	reflect.TypeOf(new(ua.ExpandedNodeID)):  id.ExpandedNodeID,
+	reflect.TypeOf(new(ua.QualifiedName)):   id.QualifiedName,
+	reflect.TypeOf(new(ua.LocalizedText)):   id.LocalizedText,
+	reflect.TypeOf(new(ua.ExtensionObject)): id.Structure,
+	reflect.TypeOf(new(ua.DataValue)):       id.DataValue,
+	reflect.TypeOf(new(ua.Variant)):         id.BaseDataType,
+	reflect.TypeOf(new(ua.DiagnosticInfo)):  id.DiagnosticInfo,
+	reflect.TypeOf([]byte(nil)):             id.ByteString,
+	reflect.TypeOf(ua.ByteArray(nil)):       id.ByteString,
+	timeType:                                id.DateTime,
+}
+
+// goDataType returns the DataType and the value rank of a field of a
+// structure with the Go type t.
+func (s *Server) goDataType(t reflect.Type) (*ua.NodeID, int32, error) {
+	s.mu.Lock()
+	dt := s.dataTypes[t]
+	s.mu.Unlock()
+	if dt != nil {
+		return dt, -1, nil
+	}
+	if x, ok := builtinDataTypes[t]; ok {
+		return ua.NewNumericNodeID(0, x), -1, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		dt, rank, err := s.goDataType(t.Elem())
+		if err != nil {
+			return nil, 0, err
+		}
+		if rank != -1 {
+			return nil, 0, fmt.Errorf("multi-dimensional arrays are not supported")
+		}
+		return dt, 1, nil
+	case reflect.Struct:
+		t = reflect.PointerTo(t)
+		fallthrough
+	case reflect.Pointer:
+		// structures of namespace 0 or structures added before
+		enc := ua.ExtensionObjectTypeID(reflect.New(t.Elem()).Interface()).NodeID
+		if dt := s.encodedDataType(enc); dt != nil {
+			return dt, -1, nil
+		}
+		return nil, 0, fmt.Errorf("%s has no DataType", t)
+	case reflect.Int, reflect.Uint:
+		return nil, 0, fmt.Errorf("%s has no fixed size", t)
+	}
+	if x, rank, ok := fieldDataType(t); ok {
+		return ua.NewNumericNodeID(0, uint32(x)), rank, nil
+	}
+	return nil, 0, fmt.Errorf("%s is not supported", t)
+}
+
+// builtinType returns the built-in type which encodes the values of the
+// DataType or ua.TypeIDNull for concrete structures. See
+// ua.FieldDescriptor.
+func (s *Server) builtinType(dt *ua.NodeID) ua.TypeID {
+	abstract := false
+	if n := s.Node(dt); n != nil {
+		if v, err := n.Attribute(ua.AttributeIDIsAbstract); err == nil && v.Value != nil && v.Value.Value != nil {
+			abstract, _ = v.Value.Value.Value().(bool)
+		}
+	}
+	structure := false
+	for i := 0; dt != nil && i < 100; i++ {
+		if dt.Namespace() == 0 {
+			switch x := dt.IntID(); {
+			case x == id.Structure && structure && !abstract:
+				// a concrete structure is encoded in place
+				return ua.TypeIDNull
+			case x >= id.Boolean && x <= id.DiagnosticInfo:
+				// the ids of the built-in DataTypes are the type ids,
+				// e.g. Structure is encoded as ExtensionObject and
+				// BaseDataType as Variant.
+				return ua.TypeID(x)
+			case x == id.Enumeration:
+				return ua.TypeIDInt32
+			case x == id.Number || x == id.Integer || x == id.UInteger:
+				return ua.TypeIDVariant
+			}
+		}
+		structure = true
+		dt = s.superType(dt)
+	}
+	// the type hierarchy is incomplete
+	return ua.TypeIDNull
+}
+
+// structureTable contains the descriptors of structures by the ids of
+// their DataType and of their Default Binary encoding. The descriptors
+// belong to a single server since the namespace indexes in the ids differ
+// between servers.
+type structureTable struct {
+	mu         sync.RWMutex
+	byDataType map[string]*ua.StructureDescriptor
+	byEncoding map[string]*ua.StructureDescriptor
+}
+
+// addStructure adds the descriptor of a structure. A descriptor which is
+// added again for the same DataType replaces the previous descriptor.
+func (s *Server) addStructure(d *ua.StructureDescriptor) {
+	s.structures.mu.Lock()
+	defer s.structures.mu.Unlock()
+	if d.DataTypeID != nil {
+		s.structures.byDataType[d.DataTypeID.String()] = d
+	}
+	if d.EncodingID != nil {
+		s.structures.byEncoding[d.EncodingID.String()] = d
+	}
+}
+
+// StructureByDataTypeID returns the descriptor of a structure DataType
+// which has been imported from a nodeset or nil.
+func (s *Server) StructureByDataTypeID(nid *ua.NodeID) *ua.StructureDescriptor {
+	if nid == nil {
+		return nil
+	}
+	s.structures.mu.RLock()
+	defer s.structures.mu.RUnlock()
+	return s.structures.byDataType[nid.String()]
+}
+
+// StructureByEncodingID returns the descriptor of the structure with the
+// Default Binary encoding which has been imported from a nodeset or nil.
+func (s *Server) StructureByEncodingID(nid *ua.NodeID) *ua.StructureDescriptor {
+	if nid == nil {
+		return nil
+	}
+	s.structures.mu.RLock()
+	defer s.structures.mu.RUnlock()
+	return s.structures.byEncoding[nid.String()]
+}
+
+// decodeStructures decodes the extension objects in v whose type has no
+// Go type with the descriptors of the server.
+func (s *Server) decodeStructures(v any) {
+	ua.DecodeStructures(v, s.StructureByEncodingID)
+}
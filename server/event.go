@@ -0,0 +1,242 @@
+package server
+
+import (
+	"crypto/rand"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// Event is an event which is reported to the clients which monitor the
+// EventNotifier attribute of a node.
+//
+// https://reference.opcfoundation.org/Core/Part5/v105/docs/6.4.2
+type Event struct {
+	// EventType is the node id of the event type, e.g. BaseEventType.
+	EventType *ua.NodeID
+
+	// SourceNode is the node which caused the event.
+	SourceNode *ua.NodeID
+
+	// SourceName is a description of the source of the event.
+	SourceName string
+
+	// Time is the time the event occurred. Defaults to the time the
+	// event was reported.
+	Time time.Time
+
+	// Severity is the urgency of the event between 1 and 1000.
+	Severity uint16
+
+	// Message is a human readable description of the event.
+	Message string
+
+	// Fields contains the values of the additional fields of the event
+	// type by browse path, e.g. "Status" or "ClientUserId". The names of
+	// nested fields are separated by '/'.
+	Fields map[string]any
+
+	id          []byte
+	receiveTime time.Time
+}
+
+// Field returns the value of the event field with the given browse path.
+// It returns nil if the event does not have this field.
+func (e *Event) Field(path string) any {
+	switch path {
+	case "EventId":
+		return e.id
+	case "EventType":
+		return e.EventType
+	case "SourceNode":
+		return e.SourceNode
+	case "SourceName":
+		return e.SourceName
+	case "Time":
+		return e.Time
+	case "ReceiveTime":
+		return e.receiveTime
+	case "Severity":
+		return e.Severity
+	case "Message":
+		return ua.NewLocalizedText(e.Message)
+	default:
+		return e.Fields[path]
+	}
+}
+
+// ReportEvent reports an event on the EventNotifier node notifier,
+// e.g. the Server object, to all monitored items which subscribed to it.
+func (s *Server) ReportEvent(notifier *ua.NodeID, ev *Event) {
+	if ev.EventType == nil {
+		ev.EventType = ua.NewNumericNodeID(0, id.BaseEventType)
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	ev.receiveTime = time.Now()
+	ev.id = make([]byte, 16)
+	if _, err := rand.Read(ev.id); err != nil {
+		if s.cfg.logger != nil {
+			s.cfg.logger.Error("error creating event id: %v", err)
+		}
+		return
+	}
+
+	// the handlers are registered in Start and we cannot report
+	// anything before that.
+	if s.MonitoredItemService == nil {
+		return
+	}
+	s.MonitoredItemService.EventNotification(notifier, ev)
+}
+
+// eventFields returns the values for the select clauses of the event filter.
+// Fields of event types which the event is not a subtype of are null.
+func (s *Server) eventFields(f *ua.EventFilter, ev *Event) []*ua.Variant {
+	fields := make([]*ua.Variant, len(f.SelectClauses))
+	for i, op := range f.SelectClauses {
+		fields[i] = ua.MustVariant(nil)
+		if op == nil || op.AttributeID != ua.AttributeIDValue || !s.hasEventType(ev, op.TypeDefinitionID) {
+			continue
+		}
+		names := make([]string, len(op.BrowsePath))
+		for j, qn := range op.BrowsePath {
+			names[j] = qn.Name
+		}
+		v := ev.Field(strings.Join(names, "/"))
+		if v == nil {
+			continue
+		}
+		if vv, err := ua.NewVariant(v); err == nil {
+			fields[i] = vv
+		}
+	}
+	return fields
+}
+
+// hasEventType returns true if the event is of the type of a select clause
+// or an operand. Operands without a type match all events.
+func (s *Server) hasEventType(ev *Event, typ *ua.NodeID) bool {
+	if typ == nil || typ.Equal(ua.NewNumericNodeID(0, 0)) {
+		return true
+	}
+	return s.IsSubtype(ev.EventType, typ)
+}
+
+// checkEventFilter returns ua.StatusBadEventFilterInvalid if a select
+// clause or an OfType operator of the filter refers to a type which is not
+// an event type or if an element operand does not refer to an element
+// after the element which contains it.
+func (s *Server) checkEventFilter(f *ua.EventFilter) ua.StatusCode {
+	for _, op := range f.SelectClauses {
+		if op != nil && op.TypeDefinitionID != nil && !op.TypeDefinitionID.Equal(ua.NewNumericNodeID(0, 0)) && !s.isEventType(op.TypeDefinitionID) {
+			return ua.StatusBadEventFilterInvalid
+		}
+	}
+	if f.WhereClause == nil {
+		return ua.StatusOK
+	}
+	elems := f.WhereClause.Elements
+	for i, el := range elems {
+		if el == nil {
+			continue
+		}
+		// https://reference.opcfoundation.org/Core/Part4/v105/docs/7.7.4.5
+		for _, op := range el.FilterOperands {
+			if op == nil {
+				continue
+			}
+			if eo, ok := op.Value.(*ua.ElementOperand); ok && (int(eo.Index) <= i || int(eo.Index) >= len(elems)) {
+				return ua.StatusBadEventFilterInvalid
+			}
+		}
+		if el.FilterOperator != ua.FilterOperatorOfType || len(el.FilterOperands) == 0 || el.FilterOperands[0] == nil {
+			continue
+		}
+		lit, ok := el.FilterOperands[0].Value.(*ua.LiteralOperand)
+		if !ok || lit.Value == nil {
+			continue
+		}
+		if typ, ok := lit.Value.Value().(*ua.NodeID); ok && !s.isEventType(typ) {
+			return ua.StatusBadEventFilterInvalid
+		}
+	}
+	return ua.StatusOK
+}
+
+// matchEvent evaluates the where clause of the event filter for ev.
+//
+// Only the OfType, Equals, And, Or and Not operators are supported.
+// Filters with other operators match every event.
+func (s *Server) matchEvent(f *ua.EventFilter, ev *Event) bool {
+	if f.WhereClause == nil || len(f.WhereClause.Elements) == 0 {
+		return true
+	}
+	return s.evalFilterElement(f.WhereClause.Elements, 0, ev)
+}
+
+func (s *Server) evalFilterElement(elems []*ua.ContentFilterElement, idx int, ev *Event) bool {
+	if idx >= len(elems) || elems[idx] == nil {
+		return false
+	}
+	el := elems[idx]
+
+	operand := func(i int) any {
+		if i >= len(el.FilterOperands) || el.FilterOperands[i] == nil {
+			return nil
+		}
+		switch op := el.FilterOperands[i].Value.(type) {
+		case *ua.LiteralOperand:
+			if op.Value == nil {
+				return nil
+			}
+			return op.Value.Value()
+		case *ua.SimpleAttributeOperand:
+			if !s.hasEventType(ev, op.TypeDefinitionID) {
+				return nil
+			}
+			names := make([]string, len(op.BrowsePath))
+			for j, qn := range op.BrowsePath {
+				names[j] = qn.Name
+			}
+			return ev.Field(strings.Join(names, "/"))
+		case *ua.ElementOperand:
+			// operands must refer to later elements. Otherwise a
+			// filter could refer to itself.
+			if int(op.Index) <= idx {
+				return nil
+			}
+			return s.evalFilterElement(elems, int(op.Index), ev)
+		default:
+			return nil
+		}
+	}
+
+	switch el.FilterOperator {
+	case ua.FilterOperatorOfType:
+		typ, ok := operand(0).(*ua.NodeID)
+		return ok && s.IsSubtype(ev.EventType, typ)
+	case ua.FilterOperatorEquals:
+		return reflect.DeepEqual(operand(0), operand(1))
+	case ua.FilterOperatorAnd:
+		a, _ := operand(0).(bool)
+		b, _ := operand(1).(bool)
+		return a && b
+	case ua.FilterOperatorOr:
+		a, _ := operand(0).(bool)
+		b, _ := operand(1).(bool)
+		return a || b
+	case ua.FilterOperatorNot:
+		a, _ := operand(0).(bool)
+		return !a
+	default:
+		if s.cfg.logger != nil {
+			s.cfg.logger.Warn("event filter operator %v not supported", el.FilterOperator)
+		}
+		return true
+	}
+}
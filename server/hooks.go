@@ -0,0 +1,214 @@
+package server
+
+import (
+	"github.com/gopcua/opcua/ua"
+)
+
+// AttributeAccess describes the read or the write of an attribute by a
+// client.
+type AttributeAccess struct {
+	// SessionID is the id of the session of the client. It is nil if
+	// the session is unknown.
+	SessionID *ua.NodeID
+
+	// UserIdentity is the identity token which activated the session.
+	UserIdentity *ua.ExtensionObject
+
+	// User is the user name or the subject of the user certificate.
+	// It is empty for anonymous users.
+	User string
+
+	NodeID      *ua.NodeID
+	AttributeID ua.AttributeID
+
+	// IndexRange is the index range of the request, e.g. "1:3".
+	IndexRange string
+}
+
+// ReadHook is called after an attribute has been read by a client. It
+// can replace the value or fail the read with a bad status code. A nil
+// value leaves the value unchanged.
+type ReadHook func(a *AttributeAccess, v *ua.DataValue) (*ua.DataValue, ua.StatusCode)
+
+// WriteHook is called before an attribute is written by a client. It can
+// replace the value or reject the write with a bad status code, e.g.
+// ua.StatusBadOutOfRange. A nil value leaves the value unchanged.
+type WriteHook func(a *AttributeAccess, v *ua.DataValue) (*ua.DataValue, ua.StatusCode)
+
+// hookNameSpace is implemented by namespaces which support hooks for
+// all of their nodes.
+type hookNameSpace interface {
+	readHook() ReadHook
+	writeHook() WriteHook
+}
+
+// OnRead sets the hook which is called when a client reads an attribute
+// of the node. It is called before the hook of the namespace.
+func (n *Node) OnRead(f ReadHook) {
+	if ns, ok := n.ns.(*NodeNameSpace); ok {
+		ns.mu.Lock()
+		defer ns.mu.Unlock()
+	}
+	n.onRead = f
+}
+
+// OnWrite sets the hook which is called when a client writes an attribute
+// of the node. It is called before the hook of the namespace.
+func (n *Node) OnWrite(f WriteHook) {
+	if ns, ok := n.ns.(*NodeNameSpace); ok {
+		ns.mu.Lock()
+		defer ns.mu.Unlock()
+	}
+	n.onWrite = f
+}
+
+// readHook returns the read hook of the node.
+func (n *Node) readHook() ReadHook {
+	if ns, ok := n.ns.(*NodeNameSpace); ok {
+		ns.mu.RLock()
+		defer ns.mu.RUnlock()
+	}
+	return n.onRead
+}
+
+// writeHook returns the write hook of the node.
+func (n *Node) writeHook() WriteHook {
+	if ns, ok := n.ns.(*NodeNameSpace); ok {
+		ns.mu.RLock()
+		defer ns.mu.RUnlock()
+	}
+	return n.onWrite
+}
+
+// OnRead sets the hook which is called when a client reads an attribute
+// of a node of the namespace.
+func (ns *NodeNameSpace) OnRead(f ReadHook) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.onRead = f
+}
+
+// OnWrite sets the hook which is called when a client writes an attribute
+// of a node of the namespace.
+func (ns *NodeNameSpace) OnWrite(f WriteHook) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.onWrite = f
+}
+
+func (ns *NodeNameSpace) readHook() ReadHook {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+	return ns.onRead
+}
+
+func (ns *NodeNameSpace) writeHook() WriteHook {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+	return ns.onWrite
+}
+
+// OnRead sets the hook which is called when a client reads an attribute
+// of a key of the namespace.
+func (ns *MapNamespace) OnRead(f ReadHook) {
+	ns.Mu.Lock()
+	defer ns.Mu.Unlock()
+	ns.onRead = f
+}
+
+// OnWrite sets the hook which is called when a client writes an attribute
+// of a key of the namespace.
+func (ns *MapNamespace) OnWrite(f WriteHook) {
+	ns.Mu.Lock()
+	defer ns.Mu.Unlock()
+	ns.onWrite = f
+}
+
+func (ns *MapNamespace) readHook() ReadHook {
+	ns.Mu.RLock()
+	defer ns.Mu.RUnlock()
+	return ns.onRead
+}
+
+func (ns *MapNamespace) writeHook() WriteHook {
+	ns.Mu.RLock()
+	defer ns.Mu.RUnlock()
+	return ns.onWrite
+}
+
+// attributeAccess returns the description of the access to the
+// attribute by the client of the request.
+func (s *Server) attributeAccess(hdr *ua.RequestHeader, nid *ua.NodeID, attr ua.AttributeID, indexRange string) *AttributeAccess {
+	var sess *session
+	if hdr != nil {
+		sess = s.Session(hdr)
+	}
+	return sessionAccess(sess, nid, attr, indexRange)
+}
+
+// sessionAccess returns the description of the access to the attribute
+// by the client of the session. sess can be nil.
+func sessionAccess(sess *session, nid *ua.NodeID, attr ua.AttributeID, indexRange string) *AttributeAccess {
+	a := &AttributeAccess{NodeID: nid, AttributeID: attr, IndexRange: indexRange}
+	if sess != nil {
+		a.SessionID = sess.ID
+		a.UserIdentity = sess.userIdentity
+		a.User = sess.clientUserID()
+	}
+	return a
+}
+
+// readAttribute returns the value of the attribute after the read hooks
+// of the node and the namespace have been called. It is used for reads
+// and for the samples of monitored items so that the hooks cannot be
+// bypassed with a subscription. sess is the session of the client and
+// can be nil.
+func (s *Server) readAttribute(ns NameSpace, sess *session, nid *ua.NodeID, attr ua.AttributeID, indexRange string) *ua.DataValue {
+	dv := ns.Attribute(nid, attr)
+	hooks := readHooks(ns, nid)
+	if len(hooks) == 0 {
+		return dv
+	}
+	access := sessionAccess(sess, nid, attr, indexRange)
+	for _, hook := range hooks {
+		v, status := hook(access, dv)
+		if status != ua.StatusOK {
+			return statusDataValue(status)
+		}
+		// a nil value leaves the value unchanged
+		if v != nil {
+			dv = v
+		}
+	}
+	return dv
+}
+
+// readHooks returns the hooks of the node and the namespace which are
+// called when the node is read.
+func readHooks(ns NameSpace, nid *ua.NodeID) []ReadHook {
+	var hooks []ReadHook
+	if n := ns.Node(nid); n != nil {
+		if h := n.readHook(); h != nil {
+			hooks = append(hooks, h)
+		}
+	}
+	if hns, ok := ns.(hookNameSpace); ok && hns.readHook() != nil {
+		hooks = append(hooks, hns.readHook())
+	}
+	return hooks
+}
+
+// writeHooks returns the hooks of the node and the namespace which are
+// called when the node is written.
+func writeHooks(ns NameSpace, nid *ua.NodeID) []WriteHook {
+	var hooks []WriteHook
+	if n := ns.Node(nid); n != nil {
+		if h := n.writeHook(); h != nil {
+			hooks = append(hooks, h)
+		}
+	}
+	if hns, ok := ns.(hookNameSpace); ok && hns.writeHook() != nil {
+		hooks = append(hooks, hns.writeHook())
+	}
+	return hooks
+}
@@ -0,0 +1,273 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package server
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// Instance is an object which has been created from an ObjectType
+// with NodeNameSpace.Instantiate.
+type Instance struct {
+	node *Node
+
+	// children contains the instantiated children by browse path,
+	// e.g. "Status" or "Identification/SerialNumber".
+	children map[string]*Node
+}
+
+// Node returns the node of the instance.
+func (i *Instance) Node() *Node {
+	return i.node
+}
+
+// Child returns the child node with the browse path, e.g. "Status" or
+// "Identification/SerialNumber". It returns nil if there is no child
+// with this path.
+func (i *Instance) Child(path string) *Node {
+	return i.children[path]
+}
+
+// Children returns the browse paths of all instantiated children.
+func (i *Instance) Children() []string {
+	return slices.Sorted(maps.Keys(i.children))
+}
+
+// SetValueFunc binds the value of the child variable with the browse path.
+func (i *Instance) SetValueFunc(path string, f ValueFunc) error {
+	n := i.children[path]
+	if n == nil {
+		return fmt.Errorf("instance %s has no child %s", i.node.ID(), path)
+	}
+	n.val = f
+	return nil
+}
+
+// InstantiateOption is an option for NodeNameSpace.Instantiate.
+type InstantiateOption func(*instantiateConfig)
+
+type instantiateConfig struct {
+	// optional contains the browse paths of the optional children which
+	// are instantiated. If allOptional is set all optional children are
+	// instantiated.
+	optional    map[string]bool
+	allOptional bool
+}
+
+// WithOptional instantiates the optional children with the browse paths
+// in addition to the mandatory children. If no paths are given then all
+// optional children are instantiated.
+func WithOptional(paths ...string) InstantiateOption {
+	return func(cfg *instantiateConfig) {
+		if len(paths) == 0 {
+			cfg.allOptional = true
+			return
+		}
+		for _, p := range paths {
+			cfg.optional[p] = true
+		}
+	}
+}
+
+// Instantiate creates an object of the ObjectType typeID below parent.
+//
+// The HasComponent and HasProperty children of the type and its
+// supertypes are copied into the namespace if their modelling rule is
+// Mandatory. Optional children are only copied if they are requested
+// with WithOptional. The children are instantiated recursively and keep
+// the HasTypeDefinition references of their instance declarations.
+// Methods keep the handlers of their instance declarations.
+//
+// An error is returned if a child has the type definition of one of its
+// ancestors since the instance would be infinite. No node is added in
+// this case.
+//
+// https://reference.opcfoundation.org/Core/Part3/v105/docs/6.4
+func (ns *NodeNameSpace) Instantiate(typeID *ua.NodeID, parent *Node, browseName string, opts ...InstantiateOption) (*Instance, error) {
+	cfg := &instantiateConfig{optional: map[string]bool{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	typ := ns.srv.Node(typeID)
+	if typ == nil {
+		return nil, fmt.Errorf("type %s not found", typeID)
+	}
+	if typ.NodeClass() != ua.NodeClassObjectType {
+		return nil, fmt.Errorf("%s is not an ObjectType", typeID)
+	}
+
+	n := NewNode(
+		ua.NewNumericNodeID(ns.ID(), ns.GetNextNodeID()),
+		Attributes{
+			ua.AttributeIDNodeClass:     DataValueFromValue(uint32(ua.NodeClassObject)),
+			ua.AttributeIDBrowseName:    DataValueFromValue(&ua.QualifiedName{NamespaceIndex: ns.ID(), Name: browseName}),
+			ua.AttributeIDDisplayName:   DataValueFromValue(ua.NewLocalizedText(browseName)),
+			ua.AttributeIDEventNotifier: DataValueFromValue(byte(0)),
+		},
+		nil,
+		nil,
+	)
+	n.AddRef(typ, id.HasTypeDefinition, true)
+	ns.AddNode(n)
+
+	inst := &Instance{node: n, children: map[string]*Node{}}
+	types := map[string]bool{typ.ID().String(): true}
+	if err := ns.instantiateChildren(inst, cfg, n, "", ns.typeHierarchy(typ), types); err != nil {
+		for _, c := range inst.children {
+			ns.DeleteNode(c.ID())
+		}
+		ns.DeleteNode(n.ID())
+		return nil, err
+	}
+
+	ns.linkChild(parent, n)
+	return inst, nil
+}
+
+// linkChild adds the references between parent and n. n is organized by
+// parent if parent is a folder and a component of parent otherwise.
+// Nothing is added if parent is nil.
+func (ns *NodeNameSpace) linkChild(parent, n *Node) {
+	if parent == nil {
+		return
+	}
+	rt := RefType(id.HasComponent)
+	if ns.srv.IsSubtype(typeDefinition(parent), ua.NewNumericNodeID(0, id.FolderType)) {
+		rt = id.Organizes
+	}
+	parent.AddRef(n, rt, true)
+	n.AddRef(parent, rt, false)
+}
+
+// instantiateChildren copies the children of the instance declarations
+// and types in decls to n. The first declaration of a child wins so
+// that subtypes can override the children of their supertypes. types
+// contains the type definitions of n and its ancestors. It returns an
+// error if a child has one of these type definitions.
+func (ns *NodeNameSpace) instantiateChildren(inst *Instance, cfg *instantiateConfig, n *Node, path string, decls []*Node, types map[string]bool) error {
+	aggregates := ua.NewNumericNodeID(0, id.Aggregates)
+
+	seen := map[string]bool{}
+	for _, decl := range decls {
+		for _, ref := range decl.refs {
+			if !ref.IsForward || ref.NodeID == nil || !ns.srv.IsSubtype(ref.ReferenceTypeID, aggregates) {
+				continue
+			}
+			child := ns.srv.Node(ref.NodeID.NodeID)
+			if child == nil {
+				continue
+			}
+			name := child.BrowseName().Name
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			childPath := name
+			if path != "" {
+				childPath = path + "/" + name
+			}
+			switch rule := modellingRule(child); {
+			case rule.Equal(modellingRuleMandatory):
+			case rule.Equal(modellingRuleOptional):
+				if !cfg.allOptional && !cfg.optional[childPath] {
+					continue
+				}
+			default:
+				// placeholders and children without a modelling rule
+				// are not part of the instance.
+				continue
+			}
+
+			c := &Node{
+				id:   ua.NewNumericNodeID(ns.ID(), ns.GetNextNodeID()),
+				attr: maps.Clone(child.attr),
+				val:  child.val,
+				call: child.call,
+			}
+			c.sanitize()
+			// methods have no type definition
+			var childDecls []*Node
+			var childType string
+			if tid := typeDefinition(child); tid != nil {
+				if td := ns.srv.Node(tid); td != nil {
+					childType = td.ID().String()
+					if types[childType] {
+						return fmt.Errorf("child %s has the type definition %s of an ancestor", childPath, tid)
+					}
+					c.AddRef(td, id.HasTypeDefinition, true)
+					childDecls = ns.typeHierarchy(td)
+				}
+			}
+			ns.AddNode(c)
+
+			n.addReference(c, ref.ReferenceTypeID, true)
+			c.addReference(n, ref.ReferenceTypeID, false)
+			inst.children[childPath] = c
+
+			if childType != "" {
+				types[childType] = true
+			}
+			err := ns.instantiateChildren(inst, cfg, c, childPath, append([]*Node{child}, childDecls...), types)
+			delete(types, childType)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// typeHierarchy returns the type and all its supertypes.
+func (ns *NodeNameSpace) typeHierarchy(typ *Node) []*Node {
+	var types []*Node
+	for typ != nil && !slices.Contains(types, typ) {
+		types = append(types, typ)
+		var super *Node
+		for _, ref := range typ.refs {
+			if !ref.IsForward && ref.NodeID != nil && ref.ReferenceTypeID.Equal(hasSubtype) {
+				super = ns.srv.Node(ref.NodeID.NodeID)
+				break
+			}
+		}
+		typ = super
+	}
+	return types
+}
+
+var (
+	hasTypeDefinition      = ua.NewNumericNodeID(0, id.HasTypeDefinition)
+	hasModellingRule       = ua.NewNumericNodeID(0, id.HasModellingRule)
+	modellingRuleMandatory = ua.NewNumericNodeID(0, id.ModellingRule_Mandatory)
+	modellingRuleOptional  = ua.NewNumericNodeID(0, id.ModellingRule_Optional)
+)
+
+// typeDefinition returns the target of the HasTypeDefinition reference
+// of the node or nil.
+func typeDefinition(n *Node) *ua.NodeID {
+	for _, ref := range n.refs {
+		if ref.IsForward && ref.NodeID != nil && ref.ReferenceTypeID.Equal(hasTypeDefinition) {
+			return ref.NodeID.NodeID
+		}
+	}
+	return nil
+}
+
+// modellingRule returns the id of the modelling rule of the instance
+// declaration or nil if it has none.
+func modellingRule(n *Node) *ua.NodeID {
+	for _, ref := range n.refs {
+		if ref.IsForward && ref.NodeID != nil && ref.ReferenceTypeID.Equal(hasModellingRule) {
+			return ref.NodeID.NodeID
+		}
+	}
+	return nil
+}
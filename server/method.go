@@ -0,0 +1,209 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// MethodCall describes the call of a method by a client.
+type MethodCall struct {
+	// SessionID is the id of the session of the client. It is nil if
+	// the session is unknown.
+	SessionID *ua.NodeID
+
+	// User is the user name or the subject of the user certificate.
+	// It is empty for anonymous users.
+	User string
+
+	ObjectID *ua.NodeID
+	MethodID *ua.NodeID
+}
+
+// MethodHandler is called when a client calls a method. The input
+// arguments have been checked against the InputArguments of the method.
+// It returns the output arguments or a bad status code, e.g.
+// ua.StatusBadInvalidArgument.
+type MethodHandler func(c *MethodCall, in []*ua.Variant) ([]*ua.Variant, ua.StatusCode)
+
+// Argument describes an input or an output argument of a method.
+//
+// Use Arg to derive the DataType and the ValueRank from a Go type.
+type Argument struct {
+	Name        string
+	Description string
+
+	// DataType, ValueRank and ArrayDimensions describe the value of
+	// the argument. They are set by Arg.
+	DataType        *ua.NodeID
+	ValueRank       int32
+	ArrayDimensions []uint32
+
+	// typ is the Go type of an argument which has been created with
+	// Arg. Structures are resolved when the method is added.
+	typ reflect.Type
+}
+
+// Arg returns the description of an argument whose values have the Go
+// type T. The same types as for Variable are supported.
+//
+//	server.Arg[float64]("Speed", "speed in rpm")
+func Arg[T any](name, description string) Argument {
+	return Argument{Name: name, Description: description, typ: reflect.TypeOf((*T)(nil)).Elem()}
+}
+
+// AddMethod adds a method with the input and the output arguments as a
+// component of the object or the object type n. The InputArguments and
+// OutputArguments properties are created for the arguments and calls of
+// the method are dispatched to h.
+//
+// If n is an object type the method and its argument properties are
+// mandatory instance declarations. They are copied by Instantiate and
+// calls of the method on the instances are dispatched to h as well.
+//
+// n must have been added to a NodeNameSpace.
+//
+// https://reference.opcfoundation.org/Core/Part3/v105/docs/5.7
+func (n *Node) AddMethod(name string, in, out []Argument, h MethodHandler) (*Node, error) {
+	ns, ok := n.ns.(*NodeNameSpace)
+	if !ok {
+		return nil, fmt.Errorf("node %s is not part of a namespace", n.ID())
+	}
+	if h == nil {
+		return nil, fmt.Errorf("method %s: no handler", name)
+	}
+	inArgs, err := ns.srv.arguments(in)
+	if err != nil {
+		return nil, fmt.Errorf("method %s: %w", name, err)
+	}
+	outArgs, err := ns.srv.arguments(out)
+	if err != nil {
+		return nil, fmt.Errorf("method %s: %w", name, err)
+	}
+
+	m := NewNode(
+		ua.NewNumericNodeID(ns.ID(), ns.GetNextNodeID()),
+		Attributes{
+			ua.AttributeIDNodeClass:      DataValueFromValue(uint32(ua.NodeClassMethod)),
+			ua.AttributeIDBrowseName:     DataValueFromValue(&ua.QualifiedName{NamespaceIndex: ns.ID(), Name: name}),
+			ua.AttributeIDDisplayName:    DataValueFromValue(ua.NewLocalizedText(name)),
+			ua.AttributeIDExecutable:     DataValueFromValue(true),
+			ua.AttributeIDUserExecutable: DataValueFromValue(true),
+		},
+		nil,
+		nil,
+	)
+	m.call = h
+	ns.AddNode(m)
+	n.AddRef(m, id.HasComponent, true)
+	m.AddRef(n, id.HasComponent, false)
+
+	decls := []*Node{m}
+	if len(inArgs) > 0 {
+		decls = append(decls, ns.addArgumentsProperty(m, "InputArguments", inArgs))
+	}
+	if len(outArgs) > 0 {
+		decls = append(decls, ns.addArgumentsProperty(m, "OutputArguments", outArgs))
+	}
+	if n.NodeClass() == ua.NodeClassObjectType {
+		if rule := ns.srv.Node(ua.NewNumericNodeID(0, id.ModellingRule_Mandatory)); rule != nil {
+			for _, d := range decls {
+				d.AddRef(rule, id.HasModellingRule, true)
+			}
+		}
+	}
+	return m, nil
+}
+
+// arguments returns the ua.Argument values for the argument descriptions.
+func (s *Server) arguments(args []Argument) ([]*ua.Argument, error) {
+	res := make([]*ua.Argument, len(args))
+	for i, a := range args {
+		arg := &ua.Argument{
+			Name:            a.Name,
+			DataType:        a.DataType,
+			ValueRank:       a.ValueRank,
+			ArrayDimensions: a.ArrayDimensions,
+			Description:     ua.NewLocalizedText(a.Description),
+		}
+		if a.typ != nil {
+			conv, err := s.valueConv(a.typ)
+			if err != nil {
+				return nil, fmt.Errorf("argument %s: %w", a.Name, err)
+			}
+			arg.DataType, arg.ValueRank = conv.dataType, conv.rank
+			if conv.rank == 1 {
+				arg.ArrayDimensions = []uint32{conv.dim}
+			}
+		}
+		if arg.DataType == nil {
+			return nil, fmt.Errorf("argument %s: no DataType", a.Name)
+		}
+		if arg.ArrayDimensions == nil {
+			arg.ArrayDimensions = []uint32{}
+		}
+		res[i] = arg
+	}
+	return res, nil
+}
+
+// addArgumentsProperty adds the InputArguments or the OutputArguments
+// property to the method and returns it.
+func (ns *NodeNameSpace) addArgumentsProperty(m *Node, name string, args []*ua.Argument) *Node {
+	eos := make([]*ua.ExtensionObject, len(args))
+	for i, a := range args {
+		eos[i] = ua.NewExtensionObject(a)
+	}
+	access := byte(ua.AccessLevelTypeCurrentRead)
+	p := NewNode(
+		ua.NewNumericNodeID(ns.ID(), ns.GetNextNodeID()),
+		Attributes{
+			ua.AttributeIDNodeClass:       DataValueFromValue(uint32(ua.NodeClassVariable)),
+			ua.AttributeIDBrowseName:      DataValueFromValue(&ua.QualifiedName{Name: name}),
+			ua.AttributeIDDisplayName:     DataValueFromValue(ua.NewLocalizedText(name)),
+			ua.AttributeIDDataType:        DataValueFromValue(ua.NewNumericExpandedNodeID(0, id.Argument)),
+			ua.AttributeIDValueRank:       DataValueFromValue(int32(1)),
+			ua.AttributeIDArrayDimensions: DataValueFromValue([]uint32{uint32(len(args))}),
+			ua.AttributeIDAccessLevel:     DataValueFromValue(access),
+			ua.AttributeIDUserAccessLevel: DataValueFromValue(access),
+		},
+		nil,
+		func() *ua.DataValue { return DataValueFromValue(eos) },
+	)
+	if td := ns.srv.Node(ua.NewNumericNodeID(0, id.PropertyType)); td != nil {
+		p.AddRef(td, id.HasTypeDefinition, true)
+	}
+	ns.AddNode(p)
+	m.AddRef(p, id.HasProperty, true)
+	p.AddRef(m, id.HasProperty, false)
+	return p
+}
+
+// methodArguments returns the values of the InputArguments or the
+// OutputArguments property of the method.
+func (s *Server) methodArguments(m *Node, name string) []*ua.Argument {
+	for _, r := range m.refs {
+		if !r.IsForward || r.NodeID == nil || r.ReferenceTypeID.IntID() != id.HasProperty {
+			continue
+		}
+		p := s.Node(r.NodeID.NodeID)
+		if p == nil || p.BrowseName().Name != name {
+			continue
+		}
+		dv := p.Value()
+		if dv == nil || dv.Value == nil {
+			return nil
+		}
+		eos, _ := dv.Value.Value().([]*ua.ExtensionObject)
+		var args []*ua.Argument
+		for _, eo := range eos {
+			if a, ok := eo.Value.(*ua.Argument); ok {
+				args = append(args, a)
+			}
+		}
+		return args
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"github.com/gopcua/opcua/id"
 	"github.com/gopcua/opcua/ua"
 	"github.com/gopcua/opcua/uasc"
 )
@@ -22,5 +23,105 @@ func (s *MethodService) Call(sc *uasc.SecureChannel, r ua.Request, reqID uint32)
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+	if len(req.MethodsToCall) == 0 {
+		return &ua.CallResponse{ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadNothingToDo)}, nil
+	}
+
+	results := make([]*ua.CallMethodResult, len(req.MethodsToCall))
+	for i, m := range req.MethodsToCall {
+		results[i] = s.call(req.RequestHeader, m)
+	}
+	return &ua.CallResponse{
+		ResponseHeader:  responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		Results:         results,
+		DiagnosticInfos: []*ua.DiagnosticInfo{},
+	}, nil
+}
+
+// call calls the handler of a method after the object, the method and
+// the input arguments have been checked.
+func (s *MethodService) call(hdr *ua.RequestHeader, req *ua.CallMethodRequest) *ua.CallMethodResult {
+	res := &ua.CallMethodResult{
+		InputArgumentResults:         []ua.StatusCode{},
+		InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+		OutputArguments:              []*ua.Variant{},
+	}
+	if req == nil || req.ObjectID == nil || req.MethodID == nil {
+		res.StatusCode = ua.StatusBadNodeIDInvalid
+		return res
+	}
+
+	obj := s.srv.Node(req.ObjectID)
+	if obj == nil {
+		res.StatusCode = ua.StatusBadNodeIDUnknown
+		return res
+	}
+	m := s.srv.Node(req.MethodID)
+	if m == nil || m.NodeClass() != ua.NodeClassMethod || !hasComponent(obj, req.MethodID) {
+		res.StatusCode = ua.StatusBadMethodInvalid
+		return res
+	}
+	if m.call == nil || !attrBool(m, ua.AttributeIDExecutable) {
+		res.StatusCode = ua.StatusBadNotExecutable
+		return res
+	}
+	if !attrBool(m, ua.AttributeIDUserExecutable) {
+		res.StatusCode = ua.StatusBadUserAccessDenied
+		return res
+	}
+
+	args := s.srv.methodArguments(m, "InputArguments")
+	switch {
+	case len(req.InputArguments) < len(args):
+		res.StatusCode = ua.StatusBadArgumentsMissing
+		return res
+	case len(req.InputArguments) > len(args):
+		res.StatusCode = ua.StatusBadTooManyArguments
+		return res
+	}
+	if len(args) > 0 {
+		res.InputArgumentResults = make([]ua.StatusCode, len(args))
+		for i, a := range args {
+			res.InputArgumentResults[i] = s.srv.checkVariant(req.InputArguments[i], a.DataType, int64(a.ValueRank), a.ArrayDimensions)
+			if res.InputArgumentResults[i] != ua.StatusOK {
+				res.StatusCode = ua.StatusBadInvalidArgument
+			}
+		}
+		if res.StatusCode != ua.StatusOK {
+			return res
+		}
+		res.InputArgumentResults = []ua.StatusCode{}
+	}
+
+	c := &MethodCall{ObjectID: req.ObjectID, MethodID: req.MethodID}
+	if sess := s.srv.Session(hdr); sess != nil {
+		c.SessionID = sess.ID
+		c.User = sess.clientUserID()
+	}
+	out, status := m.call(c, req.InputArguments)
+	res.StatusCode = status
+	if status == ua.StatusOK && out != nil {
+		res.OutputArguments = out
+	}
+	return res
+}
+
+// hasComponent returns true if the method is a component of the object.
+func hasComponent(obj *Node, method *ua.NodeID) bool {
+	for _, r := range obj.refs {
+		if r.IsForward && r.NodeID != nil && r.ReferenceTypeID.IntID() == id.HasComponent && r.NodeID.NodeID.Equal(method) {
+			return true
+		}
+	}
+	return false
+}
+
+// attrBool returns the value of a boolean attribute of the node.
+func attrBool(n *Node, attr ua.AttributeID) bool {
+	v, err := n.Attribute(attr)
+	if err != nil || v.Value == nil || v.Value.Value == nil {
+		return false
+	}
+	b, _ := v.Value.Value.Value().(bool)
+	return b
 }
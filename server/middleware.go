@@ -0,0 +1,112 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package server
+
+import (
+	"fmt"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uasc"
+)
+
+// Middleware wraps the Handler of a service request.
+//
+// A middleware can inspect the request before it is passed to next and
+// the response or error after next has returned. It can also answer the
+// request itself by not calling next, e.g. to enforce a rate limit or to
+// reject an invalid request with a status code.
+//
+// The secure channel and the request are passed to the handler.
+// Server.RequestInfo returns the session and the user of the request.
+// ua.ServiceTypeID(req) returns the type of the request.
+//
+//	func audit(next server.Handler) server.Handler {
+//		return func(sc *uasc.SecureChannel, req ua.Request, reqID uint32) (ua.Response, error) {
+//			start := time.Now()
+//			resp, err := next(sc, req, reqID)
+//			log.Printf("%T took %s: %v", req, time.Since(start), err)
+//			return resp, err
+//		}
+//	}
+type Middleware func(next Handler) Handler
+
+// ServiceMiddleware adds middleware to the chain which wraps every service
+// handler of the server. See Server.Use for details.
+func ServiceMiddleware(mw ...Middleware) Option {
+	return func(s *serverConfig) {
+		s.middleware = append(s.middleware, mw...)
+	}
+}
+
+// Use adds middleware to the chain which wraps every service handler of
+// the server, including the handlers registered with RegisterHandler.
+//
+// The middleware is applied in order, i.e. the first middleware is the
+// outermost one and sees the request first and the response last.
+// Use returns an error after Start since the handlers are wrapped once
+// when the server starts.
+func (s *Server) Use(mw ...Middleware) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return fmt.Errorf("cannot add middleware: server has been started")
+	}
+	s.cfg.middleware = append(s.cfg.middleware, mw...)
+	return nil
+}
+
+// RequestInfo describes the client which sent a service request.
+type RequestInfo struct {
+	// SessionID is the id of the session of the client. It is nil for
+	// requests which are not bound to a session.
+	SessionID *ua.NodeID
+
+	// UserIdentity is the identity token which activated the session.
+	UserIdentity *ua.ExtensionObject
+
+	// User is the user name or the subject of the user certificate.
+	// It is empty for anonymous users.
+	User string
+}
+
+// RequestInfo returns the session and the user of the request so that
+// middleware can make decisions based on the client.
+func (s *Server) RequestInfo(req ua.Request) *RequestInfo {
+	info := &RequestInfo{}
+	if req == nil || req.Header() == nil {
+		return info
+	}
+	if sess := s.Session(req.Header()); sess != nil {
+		info.SessionID = sess.ID
+		info.UserIdentity = sess.userIdentity
+		info.User = sess.clientUserID()
+	}
+	return info
+}
+
+// chainHandlers wraps the registered handlers and the handler for
+// unsupported services with the middleware. It is called once by Start.
+func (s *Server) chainHandlers() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = true
+	for typeID, h := range s.handlers {
+		s.handlers[typeID] = s.chain(h)
+	}
+	s.unsupported = s.chain(unsupportedHandler)
+}
+
+// chain wraps h with the configured middleware.
+func (s *Server) chain(h Handler) Handler {
+	for i := len(s.cfg.middleware) - 1; i >= 0; i-- {
+		h = s.cfg.middleware[i](h)
+	}
+	return h
+}
+
+// unsupportedHandler is the handler for services without a registered handler.
+func unsupportedHandler(*uasc.SecureChannel, ua.Request, uint32) (ua.Response, error) {
+	return nil, ua.StatusBadServiceUnsupported
+}
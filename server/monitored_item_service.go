@@ -112,7 +112,7 @@ func (s *MonitoredItemService) ChangeNotification(n *ua.NodeID) {
 
 	for i := range items {
 		item := items[i]
-		if item == nil {
+		if item == nil || item.EventFilter != nil {
 			continue
 		}
 		val := new(ua.MonitoredItemNotification)
@@ -127,13 +127,42 @@ func (s *MonitoredItemService) ChangeNotification(n *ua.NodeID) {
 			item.Sub.NotifyChannel <- val
 			continue
 		}
-		dv := ns.Attribute(n, item.Req.ItemToMonitor.AttributeID)
-		val.Value = dv
+		dv := s.SubService.srv.readAttribute(ns, item.Sub.Session, n, item.Req.ItemToMonitor.AttributeID, item.Req.ItemToMonitor.IndexRange)
+		val.Value = sliceDataValue(dv, item.indexRange)
 		item.Sub.NotifyChannel <- val
 	}
 
 }
 
+// EventNotification sends the event to all monitored items which monitor
+// the EventNotifier attribute of the notifier node and whose filter
+// matches the event.
+func (s *MonitoredItemService) EventNotification(notifier *ua.NodeID, ev *Event) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	for _, item := range s.Nodes[notifier.String()] {
+		if item == nil || item.EventFilter == nil || item.Mode == ua.MonitoringModeDisabled {
+			continue
+		}
+		if !s.SubService.srv.matchEvent(item.EventFilter, ev) {
+			continue
+		}
+		efl := &ua.EventFieldList{
+			ClientHandle: item.Req.RequestedParameters.ClientHandle,
+			EventFields:  s.SubService.srv.eventFields(item.EventFilter, ev),
+		}
+		// do not block the caller if the subscription cannot keep up.
+		select {
+		case item.Sub.EventChannel <- efl:
+		default:
+			if s.SubService.srv.cfg.logger != nil {
+				s.SubService.srv.cfg.logger.Warn("event queue of subscription %d is full. dropping event", item.Sub.ID)
+			}
+		}
+	}
+}
+
 func (s *MonitoredItemService) NextID() uint32 {
 	i := atomic.AddUint32(&s.id, 1)
 	if i == 0 {
@@ -149,6 +178,13 @@ type MonitoredItem struct {
 
 	//TODO: use this
 	Mode ua.MonitoringMode
+
+	// EventFilter is the filter of items which monitor events.
+	// It is nil for items which monitor data changes.
+	EventFilter *ua.EventFilter
+
+	// indexRange selects the elements of the value which are reported.
+	indexRange ua.NumericRange
 }
 
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.12.2
@@ -188,10 +224,43 @@ func (s *MonitoredItemService) CreateMonitoredItems(sc *uasc.SecureChannel, r ua
 		itemreq := req.ItemsToCreate[i]
 		nodeid := itemreq.ItemToMonitor.NodeID
 		item := MonitoredItem{
-			ID:  s.NextID(),
-			Sub: sub,
-			Req: itemreq,
+			ID:   s.NextID(),
+			Sub:  sub,
+			Req:  itemreq,
+			Mode: itemreq.MonitoringMode,
+		}
+
+		if itemreq.ItemToMonitor.AttributeID == ua.AttributeIDEventNotifier {
+			var ef *ua.EventFilter
+			if f := itemreq.RequestedParameters.Filter; f != nil {
+				ef, _ = f.Value.(*ua.EventFilter)
+			}
+			if ef == nil {
+				res[i] = &ua.MonitoredItemCreateResult{
+					StatusCode:   ua.StatusBadMonitoredItemFilterInvalid,
+					FilterResult: ua.NewExtensionObject(nil),
+				}
+				continue
+			}
+			if status := s.SubService.srv.checkEventFilter(ef); status != ua.StatusOK {
+				res[i] = &ua.MonitoredItemCreateResult{
+					StatusCode:   status,
+					FilterResult: ua.NewExtensionObject(nil),
+				}
+				continue
+			}
+			item.EventFilter = ef
+		}
+
+		nr, err := ua.ParseNumericRange(itemreq.ItemToMonitor.IndexRange)
+		if err != nil {
+			res[i] = &ua.MonitoredItemCreateResult{
+				StatusCode:   ua.StatusBadIndexRangeInvalid,
+				FilterResult: ua.NewExtensionObject(nil),
+			}
+			continue
 		}
+		item.indexRange = nr
 
 		// book keeping of the new item
 		s.Items[item.ID] = &item
@@ -221,6 +290,11 @@ func (s *MonitoredItemService) CreateMonitoredItems(sc *uasc.SecureChannel, r ua
 			RevisedQueueSize:        1,
 			FilterResult:            ua.NewExtensionObject(nil),
 		}
+		// event items are only notified when an event is reported.
+		if item.EventFilter != nil {
+			continue
+		}
+
 		// do an initial update for the nodeids in the background.
 		// These lock the mutex so we can't do them inline here.
 		// This will cause them to happen once we unlock.
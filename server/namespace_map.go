@@ -1,6 +1,8 @@
 package server
 
 import (
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,7 +14,14 @@ import (
 // This namespaces give a convenient way to have data mapped to the OPC server
 // without having to map your application data to the OCP-UA data abstraction
 //
-// It (currently) supports ints, floats, strings, and timestamps. No maps inside of maps and no arrays.
+// It (currently) supports ints, floats, strings, timestamps and arrays of them.
+// Values of type map[string]any are folders which contain their keys. The node
+// ids are string ids with the path of the key where the keys of the folders are
+// separated by a dot, e.g. "Line1.Motor.Speed".
+//
+// The DataType of a variable is derived from its value unless it is set in the
+// Metadata of the key. Writes which would change the DataType of a variable are
+// rejected.
 //
 // To notify subscribers of changes, be sure to call ChangeNotification(key) after changing the value.
 // To be notified of changes from the opc-ua server to the map, receive on ExternalNotification channel
@@ -22,31 +31,78 @@ type MapNamespace struct {
 	Mu   sync.RWMutex
 	Data map[string]any
 
+	// Metadata contains the optional metadata of the variables by path.
+	Metadata map[string]*MapMetadata
+
 	// This can be used to be alerted when a value is changed from the opc server
 	ExternalNotification chan string
 
+	onRead  ReadHook
+	onWrite WriteHook
+
 	id uint16
 }
 
+// MapMetadata describes a variable of a MapNamespace. Fields with a
+// zero value are derived from the value of the variable.
+type MapMetadata struct {
+	// DataType is the data type of the variable.
+	DataType *ua.NodeID
+
+	// EngineeringUnits is exposed as the EngineeringUnits property
+	// of the variable.
+	EngineeringUnits *ua.EUInformation
+
+	// AccessLevel defaults to CurrentRead and CurrentWrite.
+	AccessLevel byte
+
+	Description string
+
+	// ValueRank defaults to -1 for scalars and 1 for arrays.
+	ValueRank int32
+}
+
+// mapPathSeparator separates the keys of the nested maps in a path.
+const mapPathSeparator = "."
+
 // Get the value associated with key from the MapNamespace.
 // This function handles locking and getting the value.
+// The key can be the path of a value in a folder.
 //
 // Returns nil if the value doesn't exist.
 func (s *MapNamespace) GetValue(key string) any {
 	s.Mu.RLock()
 	defer s.Mu.RUnlock()
-	return s.Data[key]
+	m, _, k, ok := mapLocate(s.Data, "", key)
+	if !ok {
+		return nil
+	}
+	return m[k]
 }
 
 // update the value associated with a key and trigger the change notification
-// to the OPC server
+// to the OPC server. The key can be the path of a value in a folder.
 func (s *MapNamespace) SetValue(key string, value any) {
 	s.Mu.Lock()
 	defer s.Mu.Unlock()
-	s.Data[key] = value
+	m, _, k, ok := mapLocate(s.Data, "", key)
+	if !ok {
+		m, k = s.Data, key
+	}
+	m[k] = value
 	s.ChangeNotification(key)
 }
 
+// SetMetadata sets the metadata of the variable with the path.
+func (s *MapNamespace) SetMetadata(path string, md *MapMetadata) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	if s.Metadata == nil {
+		s.Metadata = make(map[string]*MapMetadata)
+	}
+	s.Metadata[path] = md
+}
+
 // This function is used to notify OPC UA subscribers if a key was changed without using the
 // SetValue() function
 func (s *MapNamespace) ChangeNotification(key string) {
@@ -58,6 +114,7 @@ func NewMapNamespace(srv *Server, name string) *MapNamespace {
 		srv:                  srv,
 		name:                 name,
 		Data:                 make(map[string]any),
+		Metadata:             make(map[string]*MapMetadata),
 		ExternalNotification: make(chan string),
 	}
 	srv.AddNamespace(&mrw)
@@ -71,6 +128,160 @@ func (ns *MapNamespace) SetID(id uint16) {
 	ns.id = id
 }
 
+// mapNodeKind is the kind of node which a node id of a MapNamespace refers to.
+type mapNodeKind int
+
+const (
+	mapNodeUnknown mapNodeKind = iota
+	mapNodeObjects
+	mapNodeFolder
+	mapNodeVariable
+	mapNodeEngineeringUnits
+)
+
+// mapNode is a node of a MapNamespace.
+type mapNode struct {
+	kind mapNodeKind
+
+	// path is the path of the node and parent the path of its parent.
+	// The path of the Objects folder is empty.
+	path   string
+	parent string
+
+	// name is the key of the node in its folder.
+	name string
+
+	// folder contains the value of the node.
+	folder map[string]any
+}
+
+func (n *mapNode) value() any {
+	return n.folder[n.name]
+}
+
+// engineeringUnitsSuffix is appended to the path of a variable for the
+// node id of its EngineeringUnits property.
+const engineeringUnitsSuffix = mapPathSeparator + "EngineeringUnits"
+
+// resolve returns the node with the node id.
+func (ns *MapNamespace) resolve(nid *ua.NodeID) *mapNode {
+	if nid.Type() != ua.NodeIDTypeString {
+		if nid.IntID() == id.ObjectsFolder {
+			return &mapNode{kind: mapNodeObjects, folder: ns.Data}
+		}
+		return &mapNode{kind: mapNodeUnknown}
+	}
+
+	path := nid.StringID()
+	if m, parent, k, ok := mapLocate(ns.Data, "", path); ok {
+		n := &mapNode{kind: mapNodeVariable, path: path, parent: parent, name: k, folder: m}
+		if _, ok := m[k].(map[string]any); ok {
+			n.kind = mapNodeFolder
+		}
+		return n
+	}
+
+	if vpath, ok := strings.CutSuffix(path, engineeringUnitsSuffix); ok {
+		if md := ns.Metadata[vpath]; md != nil && md.EngineeringUnits != nil {
+			return &mapNode{kind: mapNodeEngineeringUnits, path: path, parent: vpath, name: "EngineeringUnits"}
+		}
+	}
+	return &mapNode{kind: mapNodeUnknown}
+}
+
+// mapLocate returns the folder which contains the value of the path, the
+// path of the folder and the key of the value in the folder. Keys can
+// contain the separator, e.g. a key "a.b" in the folder "x" has the path
+// "x.a.b".
+func mapLocate(m map[string]any, prefix, path string) (map[string]any, string, string, bool) {
+	if _, ok := m[path]; ok {
+		return m, prefix, path, true
+	}
+	for i := range len(path) {
+		if !strings.HasPrefix(path[i:], mapPathSeparator) {
+			continue
+		}
+		sub, ok := m[path[:i]].(map[string]any)
+		if !ok {
+			continue
+		}
+		if f, fpath, k, ok := mapLocate(sub, mapJoin(prefix, path[:i]), path[i+len(mapPathSeparator):]); ok {
+			return f, fpath, k, true
+		}
+	}
+	return nil, "", "", false
+}
+
+func mapJoin(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + mapPathSeparator + key
+}
+
+// keyName returns the key of the value with the path in its folder.
+func (ns *MapNamespace) keyName(path string) string {
+	if _, _, k, ok := mapLocate(ns.Data, "", path); ok {
+		return k
+	}
+	return path
+}
+
+// nodeID returns the node id of the node with the path.
+func (ns *MapNamespace) nodeID(path string) *ua.ExpandedNodeID {
+	if path == "" {
+		return ua.NewNumericExpandedNodeID(ns.id, id.ObjectsFolder)
+	}
+	return ua.NewStringExpandedNodeID(ns.id, path)
+}
+
+func mapRef(refType uint32, forward bool, nid *ua.ExpandedNodeID, name *ua.QualifiedName, nc ua.NodeClass, typeDef uint32) *ua.ReferenceDescription {
+	return &ua.ReferenceDescription{
+		ReferenceTypeID: ua.NewNumericNodeID(0, refType),
+		IsForward:       forward,
+		NodeID:          nid,
+		BrowseName:      name,
+		DisplayName:     &ua.LocalizedText{EncodingMask: ua.LocalizedTextText, Text: name.Name},
+		NodeClass:       nc,
+		TypeDefinition:  ua.NewNumericExpandedNodeID(0, typeDef),
+	}
+}
+
+// typeRef returns the HasTypeDefinition reference to the type.
+func typeRef(typeDef uint32, name string, nc ua.NodeClass) *ua.ReferenceDescription {
+	return mapRef(id.HasTypeDefinition, true, ua.NewNumericExpandedNodeID(0, typeDef), &ua.QualifiedName{Name: name}, nc, 0)
+}
+
+// childRefs returns the references from the folder to its keys sorted by key.
+func (ns *MapNamespace) childRefs(path string, folder map[string]any) []*ua.ReferenceDescription {
+	keys := make([]string, 0, len(folder))
+	for k := range folder {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	refs := make([]*ua.ReferenceDescription, 0, len(keys))
+	for _, k := range keys {
+		name := &ua.QualifiedName{NamespaceIndex: ns.id, Name: k}
+		nid := ns.nodeID(mapJoin(path, k))
+		if _, ok := folder[k].(map[string]any); ok {
+			refs = append(refs, mapRef(id.Organizes, true, nid, name, ua.NodeClassObject, id.FolderType))
+		} else {
+			refs = append(refs, mapRef(id.HasComponent, true, nid, name, ua.NodeClassVariable, id.BaseDataVariableType))
+		}
+	}
+	return refs
+}
+
+// parentRef returns the inverse reference from the node to its folder.
+func (ns *MapNamespace) parentRef(refType uint32, parent string) *ua.ReferenceDescription {
+	name := ns.name
+	if parent != "" {
+		name = ns.keyName(parent)
+	}
+	return mapRef(refType, false, ns.nodeID(parent), &ua.QualifiedName{NamespaceIndex: ns.id, Name: name}, ua.NodeClassObject, id.FolderType)
+}
+
 func (ns *MapNamespace) Browse(bd *ua.BrowseDescription) *ua.BrowseResult {
 	ns.Mu.RLock()
 	defer ns.Mu.RUnlock()
@@ -79,14 +290,6 @@ func (ns *MapNamespace) Browse(bd *ua.BrowseDescription) *ua.BrowseResult {
 		ns.srv.cfg.logger.Debug("BrowseRequest: id=%s mask=%08b\n", bd.NodeID, bd.ResultMask)
 		ns.srv.cfg.logger.Debug("Browse req for %s", bd.NodeID.String())
 	}
-	if bd.NodeID.IntID() != id.RootFolder && bd.NodeID.IntID() != id.ObjectsFolder {
-		refs := make([]*ua.ReferenceDescription, 0)
-		return &ua.BrowseResult{
-			StatusCode: ua.StatusGood,
-			References: refs,
-		}
-		//return &ua.BrowseResult{StatusCode: ua.StatusBadNodeIDUnknown}
-	}
 
 	if bd.NodeID.IntID() == id.RootFolder {
 
@@ -108,31 +311,118 @@ func (ns *MapNamespace) Browse(bd *ua.BrowseDescription) *ua.BrowseResult {
 
 	}
 
-	refs := make([]*ua.ReferenceDescription, len(ns.Data))
-
-	keyid := 0
-	for k := range ns.Data {
-		key := k
-		refid := ua.NewNumericNodeID(0, id.HasComponent)
-		expnewid := ua.NewStringExpandedNodeID(ns.id, key)
-
-		refs[keyid] = &ua.ReferenceDescription{
-			ReferenceTypeID: refid,
-			IsForward:       true,
-			NodeID:          expnewid,
-			BrowseName:      &ua.QualifiedName{NamespaceIndex: ns.ID(), Name: key},
-			DisplayName:     &ua.LocalizedText{EncodingMask: ua.LocalizedTextText, Text: key},
-			NodeClass:       ua.NodeClassVariable, // when support is added for nested maps, this will be NodeClassObject
-			TypeDefinition:  expnewid,
+	var refs []*ua.ReferenceDescription
+	n := ns.resolve(bd.NodeID)
+	switch n.kind {
+	case mapNodeObjects:
+		refs = ns.childRefs("", n.folder)
+	case mapNodeFolder:
+		refs = append(refs, typeRef(id.FolderType, "FolderType", ua.NodeClassObjectType), ns.parentRef(id.Organizes, n.parent))
+		refs = append(refs, ns.childRefs(n.path, n.value().(map[string]any))...)
+	case mapNodeVariable:
+		refs = append(refs, typeRef(id.BaseDataVariableType, "BaseDataVariableType", ua.NodeClassVariableType), ns.parentRef(id.HasComponent, n.parent))
+		if md := ns.Metadata[n.path]; md != nil && md.EngineeringUnits != nil {
+			name := &ua.QualifiedName{Name: "EngineeringUnits"}
+			refs = append(refs, mapRef(id.HasProperty, true, ns.nodeID(n.path+engineeringUnitsSuffix), name, ua.NodeClassVariable, id.PropertyType))
 		}
-		keyid++
+	case mapNodeEngineeringUnits:
+		name := &ua.QualifiedName{NamespaceIndex: ns.id, Name: ns.keyName(n.parent)}
+		refs = append(refs,
+			typeRef(id.PropertyType, "PropertyType", ua.NodeClassVariableType),
+			mapRef(id.HasProperty, false, ns.nodeID(n.parent), name, ua.NodeClassVariable, id.BaseDataVariableType),
+		)
+	default:
+		return &ua.BrowseResult{StatusCode: ua.StatusBadNodeIDUnknown}
 	}
 
+	refs = slices.DeleteFunc(refs, func(r *ua.ReferenceDescription) bool { return !suitableRef(ns.srv, bd, r) })
 	return &ua.BrowseResult{
 		StatusCode: ua.StatusGood,
 		References: refs,
 	}
+}
+
+// mapValue converts the value to a type which is supported by a variant.
+func mapValue(v any) any {
+	switch tv := v.(type) {
+	case int:
+		// we can't use an int because it is of unspecified length.  I'm going to use int64 so that we don't
+		// have to worry about cutting data off. probably.
+		return int64(tv)
+	case []int:
+		a := make([]int64, len(tv))
+		for i, x := range tv {
+			a[i] = int64(x)
+		}
+		return a
+	}
+	return v
+}
+
+// mapDataType returns the built-in data type and the value rank of the
+// value. Values which are not supported by a variant have the
+// BaseDataType.
+func mapDataType(v any) (*ua.NodeID, int32) {
+	va, err := ua.NewVariant(mapValue(v))
+	if err != nil {
+		return ua.NewNumericNodeID(0, id.BaseDataType), -1
+	}
+	if va.Has(ua.VariantArrayValues) {
+		return ua.NewNumericNodeID(0, uint32(va.Type())), 1
+	}
+	return ua.NewNumericNodeID(0, uint32(va.Type())), -1
+}
+
+// dataType returns the data type and the value rank of the variable from
+// its metadata or its value.
+func (ns *MapNamespace) dataType(n *mapNode) (*ua.NodeID, int32) {
+	dt, rank := mapDataType(n.value())
+	if md := ns.Metadata[n.path]; md != nil {
+		if md.DataType != nil {
+			dt = md.DataType
+		}
+		if md.ValueRank != 0 {
+			rank = md.ValueRank
+		}
+	}
+	return dt, rank
+}
 
+func (ns *MapNamespace) accessLevel(path string) byte {
+	if md := ns.Metadata[path]; md != nil && md.AccessLevel != 0 {
+		return md.AccessLevel
+	}
+	return byte(ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite)
+}
+
+// writeValue converts the written value to the Go type of the current
+// value of the variable. It returns false if the value would change the
+// data type or the value rank of the variable.
+func (ns *MapNamespace) writeValue(n *mapNode, v any) (any, bool) {
+	dt, rank := ns.dataType(n)
+	vdt, vrank := mapDataType(v)
+	if !vdt.Equal(dt) {
+		return nil, false
+	}
+	if (rank == -1 && vrank != -1) || (rank > 0 && vrank == -1) {
+		return nil, false
+	}
+
+	switch n.value().(type) {
+	case int:
+		if x, ok := v.(int64); ok {
+			return int(x), true
+		}
+	case []int:
+		if x, ok := v.([]int64); ok {
+			a := make([]int, len(x))
+			for i := range x {
+				a[i] = int(x[i])
+			}
+			return a, true
+		}
+	}
+	return v, true
 }
 
 func (ns *MapNamespace) Attribute(n *ua.NodeID, a ua.AttributeID) *ua.DataValue {
@@ -140,16 +430,16 @@ func (ns *MapNamespace) Attribute(n *ua.NodeID, a ua.AttributeID) *ua.DataValue
 		ns.srv.cfg.logger.Debug("read: node=%s attr=%s", n.String(), a)
 	}
 
-	if n.IntID() != 0 {
-		// this is not one of our normal tags.
-		if n.IntID() != id.ObjectsFolder {
-			return &ua.DataValue{
-				EncodingMask:    ua.DataValueServerTimestamp | ua.DataValueStatusCode,
-				ServerTimestamp: time.Now(),
-				Status:          ua.StatusBadNodeIDInvalid,
-			}
+	node := ns.resolve(n)
+	switch node.kind {
+	case mapNodeUnknown:
+		return &ua.DataValue{
+			EncodingMask:    ua.DataValueServerTimestamp | ua.DataValueStatusCode,
+			ServerTimestamp: time.Now(),
+			Status:          ua.StatusBadNodeIDUnknown,
 		}
 
+	case mapNodeObjects:
 		attrval, err := ns.Objects().Attribute(a)
 		if err != nil {
 			return &ua.DataValue{
@@ -160,181 +450,125 @@ func (ns *MapNamespace) Attribute(n *ua.NodeID, a ua.AttributeID) *ua.DataValue
 		}
 
 		return attrval.Value
-
 	}
 
 	dv := &ua.DataValue{
 		EncodingMask:    ua.DataValueServerTimestamp | ua.DataValueStatusCode,
 		ServerTimestamp: time.Now(),
-		Status:          ua.StatusBad,
+		Status:          ua.StatusBadAttributeIDInvalid,
 	}
 
-	key := n.StringID()
-
-	var err error
 	if ns.srv.cfg.logger != nil {
-		ns.srv.cfg.logger.Debug("Read req for %s", key)
-		ns.srv.cfg.logger.Debug("'%s' Data at read: %v", ns.name, ns.Data)
+		ns.srv.cfg.logger.Debug("Read req for %s", node.path)
 	}
 
 	// because our data is native go types we don't have any of the ua "attributes" attached to it.
 	// so depending on what attribute the client wants, we'll inspect the data and return the appropriate
 	// thing
+	var v any
 	switch a {
-
 	case ua.AttributeIDNodeID:
-		dv.Status = ua.StatusOK
-		dv.EncodingMask |= ua.DataValueValue
-		dv.Value = ua.MustVariant(n)
-
-		// we are going to use the node id directly to look it up from our data map.
-	case ua.AttributeIDValue:
-		dv.Status = ua.StatusOK
-		dv.EncodingMask |= ua.DataValueValue
-		v, ok := ns.Data[key]
-		if !ok {
-			return &ua.DataValue{
-				EncodingMask:    ua.DataValueServerTimestamp | ua.DataValueStatusCode,
-				ServerTimestamp: time.Now(),
-				Status:          ua.StatusBadNodeIDUnknown,
-			}
-		}
-		switch tv := v.(type) {
-		case string:
-			dv.Value = ua.MustVariant(tv)
-		case int:
-			// we can't use an int because it is of unspecified length.  I'm going to use int64 so that we don't
-			// have to worry about cutting data off. probably.
-			dv.Value = ua.MustVariant(int64(tv))
-		case int32:
-			dv.Value = ua.MustVariant(tv)
-		case float32:
-			dv.Value = ua.MustVariant(tv)
-		case float64:
-			dv.Value = ua.MustVariant(tv)
-		case bool:
-			dv.Value = ua.MustVariant(tv)
-		default:
-			dv.Value = ua.MustVariant(tv)
-		}
-		// nothing in this namespace has an ID Description
-	case ua.AttributeIDDescription:
-		dv.Status = ua.StatusOK
-		dv.EncodingMask |= ua.DataValueValue
-		dv.Value = ua.MustVariant(&ua.LocalizedText{EncodingMask: ua.LocalizedTextText, Text: ""})
-
+		v = n
 	case ua.AttributeIDBrowseName:
-		dv.Status = ua.StatusOK
-		dv.EncodingMask |= ua.DataValueValue
-		dv.Value = ua.MustVariant(attrs.BrowseName(key))
+		if node.kind == mapNodeEngineeringUnits {
+			v = &ua.QualifiedName{Name: node.name}
+		} else {
+			v = &ua.QualifiedName{NamespaceIndex: ns.id, Name: node.name}
+		}
 	case ua.AttributeIDDisplayName:
-		dv.Status = ua.StatusOK
-		dv.EncodingMask |= ua.DataValueValue
-		dv.Value = ua.MustVariant(attrs.DisplayName(key, key))
-	case ua.AttributeIDAccessLevel:
-		dv.Status = ua.StatusOK
-		dv.EncodingMask |= ua.DataValueValue
-		level := byte(ua.AccessLevelExTypeCurrentWrite | ua.AccessLevelExTypeCurrentRead)
-		dv.Value = ua.MustVariant(level)
-
+		v = attrs.DisplayName(node.name, node.name)
+	case ua.AttributeIDDescription:
+		var text string
+		if md := ns.Metadata[node.path]; md != nil {
+			text = md.Description
+		}
+		v = &ua.LocalizedText{EncodingMask: ua.LocalizedTextText, Text: text}
 	case ua.AttributeIDNodeClass:
-		dv.Status = ua.StatusOK
-		dv.EncodingMask |= ua.DataValueValue
-		dv.Value = ua.MustVariant(int32(ua.NodeClassVariable))
-		// nothing in this namespace has event notifiers
+		if node.kind == mapNodeFolder {
+			v = int32(ua.NodeClassObject)
+		} else {
+			v = int32(ua.NodeClassVariable)
+		}
+	// nothing in this namespace has event notifiers
 	case ua.AttributeIDEventNotifier:
-		dv.Status = ua.StatusOK
-		dv.EncodingMask |= ua.DataValueValue
-		dv.Value = ua.MustVariant(int16(0))
-
-	// values are in section 5.1.2 of the standard.
-	// https://reference.opcfoundation.org/Core/Part6/v104/docs/5.1.2
-	case ua.AttributeIDDataType:
-		dv.Status = ua.StatusOK
-		dv.EncodingMask |= ua.DataValueValue
-		v := ns.Data[key]
-		switch v.(type) {
-		case string:
-			dv.Value, err = ua.NewVariant(ua.NewNumericNodeID(0, 12))
-			if err != nil {
-				if ns.srv.cfg.logger != nil {
-					ns.srv.cfg.logger.Warn("problem creating variant: %v", err)
-				}
-			}
-		case int:
-			// we can't use an int because it is of unspecified length.  I'm going to use int64 so that we don't
-			// have to worry about cutting data off.
-			dv.Value, err = ua.NewVariant(ua.NewNumericNodeID(0, 6))
-			if err != nil {
-				if ns.srv.cfg.logger != nil {
-					ns.srv.cfg.logger.Warn("problem creating variant: %v", err)
-				}
-			}
-		case int32:
-			dv.Value, err = ua.NewVariant(ua.NewNumericNodeID(0, 6))
-			if err != nil {
-				if ns.srv.cfg.logger != nil {
-					ns.srv.cfg.logger.Warn("problem creating variant: %v", err)
-				}
-			}
-		case float32:
-			dv.Value, err = ua.NewVariant(ua.NewNumericNodeID(0, 10))
-			if err != nil {
-				if ns.srv.cfg.logger != nil {
-					ns.srv.cfg.logger.Warn("problem creating variant: %v", err)
-				}
-			}
-		case float64:
-			dv.Value, err = ua.NewVariant(ua.NewNumericNodeID(0, 11))
-			if err != nil {
-				if ns.srv.cfg.logger != nil {
-					ns.srv.cfg.logger.Warn("problem creating variant: %v", err)
-				}
-			}
-		case bool:
-			dv.Value, err = ua.NewVariant(ua.NewNumericNodeID(0, 1))
-			if err != nil {
-				if ns.srv.cfg.logger != nil {
-					ns.srv.cfg.logger.Warn("problem creating variant: %v", err)
-				}
-			}
-		default:
-			dv.Value, err = ua.NewVariant(ua.NewNumericNodeID(0, 24))
-			if err != nil {
-				if ns.srv.cfg.logger != nil {
-					ns.srv.cfg.logger.Warn("problem creating variant: %v", err)
-				}
-			}
+		if node.kind == mapNodeFolder {
+			v = int16(0)
 		}
-
-		// when we support arrays this will have to change.
-	case ua.AttributeIDValueRank:
-		dv.Status = ua.StatusOK
-		dv.EncodingMask |= ua.DataValueValue
-		dv.Value = ua.MustVariant(int32(-1))
-
-	// when we support arrays this will have to change.
-	case ua.AttributeIDArrayDimensions:
-		dv.Status = ua.StatusOK
-		dv.EncodingMask |= ua.DataValueValue
-		dv.Value = ua.MustVariant([]uint32{})
 	default:
+		switch node.kind {
+		case mapNodeVariable:
+			v = ns.variableAttribute(node, a)
+		case mapNodeEngineeringUnits:
+			v = engineeringUnitsAttribute(ns.Metadata[node.parent].EngineeringUnits, a)
+		}
+	}
+	if v == nil {
 		return dv
 	}
 
-	if dv.Value == nil {
-		if ns.srv.cfg.logger != nil {
-			ns.srv.cfg.logger.Warn("bad dv value")
-		}
-	} else {
+	va, err := ua.NewVariant(v)
+	if err != nil {
 		if ns.srv.cfg.logger != nil {
-			ns.srv.cfg.logger.Debug("Read '%s' = '%v' (%v)", key, dv.Value, dv.Value.Value())
+			ns.srv.cfg.logger.Warn("problem creating variant: %v", err)
 		}
+		dv.Status = ua.StatusBadNotSupported
+		return dv
+	}
+	dv.Status = ua.StatusOK
+	dv.EncodingMask |= ua.DataValueValue
+	dv.Value = va
+
+	if ns.srv.cfg.logger != nil {
+		ns.srv.cfg.logger.Debug("Read '%s' = '%v' (%v)", node.path, dv.Value, dv.Value.Value())
 	}
 
 	return dv
 }
 
+// variableAttribute returns the value of the attribute of the variable
+// or nil if the variable does not have the attribute.
+func (ns *MapNamespace) variableAttribute(n *mapNode, a ua.AttributeID) any {
+	switch a {
+	case ua.AttributeIDValue:
+		return mapValue(n.value())
+	// values are in section 5.1.2 of the standard.
+	// https://reference.opcfoundation.org/Core/Part6/v104/docs/5.1.2
+	case ua.AttributeIDDataType:
+		dt, _ := ns.dataType(n)
+		return dt
+	case ua.AttributeIDValueRank:
+		_, rank := ns.dataType(n)
+		return rank
+	case ua.AttributeIDArrayDimensions:
+		if _, rank := ns.dataType(n); rank > 0 {
+			return make([]uint32, rank)
+		}
+		return []uint32{}
+	case ua.AttributeIDAccessLevel, ua.AttributeIDUserAccessLevel:
+		return ns.accessLevel(n.path)
+	}
+	return nil
+}
+
+// engineeringUnitsAttribute returns the value of the attribute of the
+// EngineeringUnits property or nil if the property does not have the
+// attribute.
+func engineeringUnitsAttribute(eu *ua.EUInformation, a ua.AttributeID) any {
+	switch a {
+	case ua.AttributeIDValue:
+		return ua.NewExtensionObject(eu)
+	case ua.AttributeIDDataType:
+		return ua.NewNumericNodeID(0, id.EUInformation)
+	case ua.AttributeIDValueRank:
+		return int32(-1)
+	case ua.AttributeIDArrayDimensions:
+		return []uint32{}
+	case ua.AttributeIDAccessLevel, ua.AttributeIDUserAccessLevel:
+		return byte(ua.AccessLevelTypeCurrentRead)
+	}
+	return nil
+}
+
 func (s *MapNamespace) SetAttribute(node *ua.NodeID, attr ua.AttributeID, val *ua.DataValue) ua.StatusCode {
 
 	s.Mu.Lock()
@@ -343,26 +577,55 @@ func (s *MapNamespace) SetAttribute(node *ua.NodeID, attr ua.AttributeID, val *u
 		s.srv.cfg.logger.Debug("'%s' Data pre-write: %v", s.name, s.Data)
 	}
 
-	key := node.StringID()
+	n := s.resolve(node)
+	switch {
+	case n.kind == mapNodeUnknown:
+		return ua.StatusBadNodeIDUnknown
+	case n.kind != mapNodeVariable || attr != ua.AttributeIDValue:
+		return ua.StatusBadNotWritable
+	case s.accessLevel(n.path)&byte(ua.AccessLevelTypeCurrentWrite) == 0:
+		return ua.StatusBadNotWritable
+	case val == nil || val.Value == nil:
+		return ua.StatusBadTypeMismatch
+	}
 
-	// we would normally look up the node in our actual address space, but since that's dumb, we're just
-	// going to use the node id directly to look it up from our data map.
-	if attr == ua.AttributeIDValue {
-		v := val.Value.Value()
-		s.Data[key] = v
+	v, ok := s.writeValue(n, val.Value.Value())
+	if !ok {
+		return ua.StatusBadTypeMismatch
 	}
+	n.folder[n.name] = v
 
 	// notify the opc ua server the value has changed.
 	s.srv.ChangeNotification(node)
 	// notify the non-opc application the value has changed.
 	select {
-	case s.ExternalNotification <- key:
+	case s.ExternalNotification <- n.path:
 	default:
 	}
 
 	return ua.StatusOK
 }
 
+// nodeIDs returns the ids of the variables of the namespace.
+func (ns *MapNamespace) nodeIDs() []*ua.NodeID {
+	ns.Mu.RLock()
+	defer ns.Mu.RUnlock()
+	var ids []*ua.NodeID
+	var walk func(path string, m map[string]any)
+	walk = func(path string, m map[string]any) {
+		for k, v := range m {
+			p := mapJoin(path, k)
+			if sub, ok := v.(map[string]any); ok {
+				walk(p, sub)
+				continue
+			}
+			ids = append(ids, ua.NewStringNodeID(ns.id, p))
+		}
+	}
+	walk("", ns.Data)
+	return ids
+}
+
 func (ns *MapNamespace) Name() string {
 	return ns.name
 }
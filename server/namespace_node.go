@@ -1,6 +1,7 @@
 package server
 
 import (
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,6 +21,9 @@ type NodeNameSpace struct {
 	id              uint16
 	nodeid_sequence uint32
 
+	onRead  ReadHook
+	onWrite WriteHook
+
 	ExternalNotification chan *ua.NodeID
 }
 
@@ -39,7 +43,12 @@ func NewNodeNameSpace(srv *Server, name string) *NodeNameSpace {
 		ExternalNotification: make(chan *ua.NodeID),
 	}
 	srv.AddNamespace(ns)
+	ns.addObjectsNode()
+	return ns
+}
 
+// addObjectsNode adds the Objects folder of the namespace.
+func (ns *NodeNameSpace) addObjectsNode() {
 	//objectsNode := NewFolderNode(ua.NewNumericNodeID(ns.id, id.ObjectsFolder), ns.name)
 	oid := ua.NewNumericNodeID(ns.ID(), id.ObjectsFolder)
 	//eoid := ua.NewNumericExpandedNodeID(ns.ID(), id.ObjectsFolder)
@@ -60,9 +69,6 @@ func NewNodeNameSpace(srv *Server, name string) *NodeNameSpace {
 	)
 
 	ns.AddNode(objectsNode)
-
-	return ns
-
 }
 
 // This function is to notify opc subscribers if a node was changed
@@ -98,6 +104,26 @@ func (as *NodeNameSpace) AddNode(n *Node) *Node {
 	k := n.ID().String()
 
 	as.m[k] = n
+	n.ns = as
+	as.typeChanged(n)
+	return n
+}
+
+// DeleteNode removes the node from the namespace and returns it.
+// It returns nil if the node does not exist. The references of other
+// nodes to the deleted node are not removed.
+func (as *NodeNameSpace) DeleteNode(id *ua.NodeID) *Node {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	k := id.String()
+	n := as.m[k]
+	if n == nil {
+		return nil
+	}
+	delete(as.m, k)
+	as.nodes = slices.DeleteFunc(as.nodes, func(x *Node) bool { return x.ID().String() == k })
+	as.typeChanged(n)
 	return n
 }
 
@@ -121,17 +147,28 @@ func (as *NodeNameSpace) Attribute(id *ua.NodeID, attr ua.AttributeID) *ua.DataV
 			Status:          ua.StatusBadNodeIDUnknown,
 		}
 	}
+	return nodeAttribute(n, attr)
+}
+
+// nodeAttribute returns the value of the attribute of the node.
+func nodeAttribute(n *Node, attr ua.AttributeID) *ua.DataValue {
 	var a *AttrValue
 	var err error
 
 	switch attr {
 	case ua.AttributeIDNodeID:
-		a = &AttrValue{Value: DataValueFromValue(id)}
+		a = &AttrValue{Value: DataValueFromValue(n.ID())}
 	case ua.AttributeIDEventNotifier:
-		// TODO: this is a hack to force the EventNotifier to false for everything.
+		// TODO: this is a hack to force the EventNotifier to false for everything
+		// except for the nodes which explicitly set a byte value, e.g. the Server object.
 		// If at some point someone or something needs to use this, this will have to go away and be
 		// fixed properly.
 		a = &AttrValue{Value: DataValueFromValue(byte(0))}
+		if v, err := n.Attribute(attr); err == nil && v.Value != nil && v.Value.Value != nil {
+			if _, ok := v.Value.Value.Value().(byte); ok {
+				a = v
+			}
+		}
 	case ua.AttributeIDNodeClass:
 		a, err = n.Attribute(attr)
 		if err != nil {
@@ -160,6 +197,17 @@ func (as *NodeNameSpace) Attribute(id *ua.NodeID, attr ua.AttributeID) *ua.DataV
 	return a.Value
 }
 
+// nodeIDs returns the ids of all nodes of the namespace.
+func (as *NodeNameSpace) nodeIDs() []*ua.NodeID {
+	as.mu.RLock()
+	defer as.mu.RUnlock()
+	ids := make([]*ua.NodeID, 0, len(as.nodes))
+	for _, n := range as.nodes {
+		ids = append(ids, n.ID())
+	}
+	return ids
+}
+
 func (as *NodeNameSpace) Node(id *ua.NodeID) *Node {
 	as.mu.RLock()
 	defer as.mu.RUnlock()
@@ -206,13 +254,6 @@ func (ns *NodeNameSpace) Browse(bd *ua.BrowseDescription) *ua.BrowseResult {
 			continue
 		}
 
-		// see if this is a ref the client was interested in.
-		if !suitableRef(ns.srv, bd, r) {
-			continue
-		}
-
-		td := ns.srv.Node(r.NodeID.NodeID)
-
 		rf := &ua.ReferenceDescription{
 			ReferenceTypeID: r.ReferenceTypeID,
 			IsForward:       r.IsForward,
@@ -220,7 +261,23 @@ func (ns *NodeNameSpace) Browse(bd *ua.BrowseDescription) *ua.BrowseResult {
 			BrowseName:      r.BrowseName,
 			DisplayName:     r.DisplayName,
 			NodeClass:       r.NodeClass,
-			TypeDefinition:  td.DataType(),
+			TypeDefinition:  ua.NewTwoByteExpandedNodeID(0),
+		}
+
+		// the node class and the type definition of the target may
+		// have changed since the reference was added.
+		if target := ns.srv.Node(r.NodeID.NodeID); target != nil {
+			rf.NodeClass = target.NodeClass()
+			if rf.NodeClass == ua.NodeClassObject || rf.NodeClass == ua.NodeClassVariable {
+				if td := typeDefinition(target); td != nil {
+					rf.TypeDefinition = ua.NewExpandedNodeID(td, "", 0)
+				}
+			}
+		}
+
+		// see if this is a ref the client was interested in.
+		if !suitableRef(ns.srv, bd, rf) {
+			continue
 		}
 
 		if rf.ReferenceTypeID.IntID() == id.HasTypeDefinition && rf.IsForward {
@@ -251,14 +308,10 @@ func (as *NodeNameSpace) SetAttribute(id *ua.NodeID, attr ua.AttributeID, val *u
 		return ua.StatusBadNodeIDUnknown
 	}
 
-	access, err := n.Attribute(ua.AttributeIDUserAccessLevel)
-	if err == nil {
-		x := access.Value.Value.Value()
-		_ = x
-		return ua.StatusBadUserAccessDenied
+	err := n.SetAttribute(attr, val)
+	if status, ok := err.(ua.StatusCode); ok {
+		return status
 	}
-
-	err = n.SetAttribute(attr, val)
 	if err != nil {
 		return ua.StatusBadAttributeIDInvalid
 	}
@@ -0,0 +1,537 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// StructNamespace exposes a Go struct value as a hierarchy of nodes.
+//
+// The exported fields of the struct become variables below the Objects
+// folder of the namespace. Nested structs and non-nil pointers to
+// structs become objects with their fields as components. Slices and
+// arrays of basic types become one-dimensional array variables. Fields
+// of other types are ignored.
+//
+// The nodes are configured with struct tags:
+//
+//	type Motor struct {
+//		Speed   float64 `opcua:"Speed,access=r" unit:"rpm" description:"Speed of the motor"`
+//		Enabled bool    `opcua:",nodeid=i=1001"`
+//		Debug   string  `opcua:"-"`
+//	}
+//
+// The first element of the opcua tag is the browse name which defaults
+// to the field name. A name of "-" ignores the field. The options are:
+//
+//	nodeid=<id>   node id without namespace, e.g. "i=1001" or "s=Speed".
+//	              Defaults to the string id with the path of the field,
+//	              e.g. "Motor.Speed".
+//	access=<mode> access level "r", "w" or "rw". Defaults to "rw".
+//
+// The unit tag adds an EngineeringUnits property and the description tag
+// sets the Description attribute.
+//
+// Clients write the values back into the struct. The struct must not be
+// changed directly after it has been bound. Use SetValue or Update to
+// change fields so that subscribers are notified.
+type StructNamespace struct {
+	*NodeNameSpace
+
+	// dataMu protects the struct value.
+	dataMu sync.RWMutex
+	v      reflect.Value
+
+	// fields contains the variables by node id and paths contains
+	// them by field path.
+	fields map[string]*structField
+	paths  map[string]*structField
+
+	// FieldNotification receives the paths of the fields which have
+	// been written by a client. It has a buffer of fieldNotificationSize
+	// paths and notifications are dropped when the buffer is full.
+	FieldNotification chan string
+}
+
+// fieldNotificationSize is the size of the FieldNotification buffer.
+const fieldNotificationSize = 100
+
+// structField is a struct field which is exposed as a variable.
+type structField struct {
+	node   *Node
+	path   string
+	index  []int
+	access byte
+}
+
+// NewStructNamespace creates a namespace for the struct which v points
+// to and adds it to the server.
+func NewStructNamespace(srv *Server, name string, v any) (*StructNamespace, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%T is not a pointer to a struct", v)
+	}
+
+	ns := &StructNamespace{
+		NodeNameSpace: &NodeNameSpace{
+			srv:                  srv,
+			name:                 name,
+			m:                    make(map[string]*Node),
+			ExternalNotification: make(chan *ua.NodeID),
+		},
+		v:                 rv.Elem(),
+		fields:            map[string]*structField{},
+		paths:             map[string]*structField{},
+		FieldNotification: make(chan string, fieldNotificationSize),
+	}
+	srv.AddNamespace(ns)
+	ns.addObjectsNode()
+
+	if err := ns.addFields(ns.Objects(), rv.Elem().Type(), nil, ""); err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+// addFields adds the nodes for the fields of the struct type t below parent.
+func (ns *StructNamespace) addFields(parent *Node, t reflect.Type, index []int, prefix string) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag, err := parseStructTag(f)
+		if err != nil {
+			return err
+		}
+		if tag.name == "-" {
+			continue
+		}
+
+		path := tag.name
+		if prefix != "" {
+			path = prefix + "." + tag.name
+		}
+		nid, err := ns.fieldNodeID(path, tag.nodeID)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", path, err)
+		}
+		if ns.Node(nid) != nil {
+			return fmt.Errorf("field %s: duplicate node id %s", path, nid)
+		}
+		fidx := append(append([]int{}, index...), i)
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer && ft.Elem().Kind() == reflect.Struct && ft.Elem() != timeType {
+			if ns.v.FieldByIndex(fidx).IsNil() {
+				continue
+			}
+			ft = ft.Elem()
+		}
+
+		if ft.Kind() == reflect.Struct && ft != timeType {
+			n := NewNode(nid, Attributes{
+				ua.AttributeIDNodeClass:   DataValueFromValue(uint32(ua.NodeClassObject)),
+				ua.AttributeIDBrowseName:  DataValueFromValue(&ua.QualifiedName{NamespaceIndex: ns.ID(), Name: tag.name}),
+				ua.AttributeIDDisplayName: DataValueFromValue(ua.NewLocalizedText(tag.name)),
+				ua.AttributeIDDescription: DataValueFromValue(ua.NewLocalizedText(tag.description)),
+			}, nil, nil)
+			ns.addChild(parent, n, id.HasComponent, id.BaseObjectType)
+			if err := ns.addFields(n, ft, fidx, path); err != nil {
+				return err
+			}
+			continue
+		}
+
+		dt, rank, ok := fieldDataType(ft)
+		if !ok {
+			continue
+		}
+		sf := &structField{path: path, index: fidx, access: tag.access}
+		attrs := Attributes{
+			ua.AttributeIDNodeClass:       DataValueFromValue(uint32(ua.NodeClassVariable)),
+			ua.AttributeIDBrowseName:      DataValueFromValue(&ua.QualifiedName{NamespaceIndex: ns.ID(), Name: tag.name}),
+			ua.AttributeIDDisplayName:     DataValueFromValue(ua.NewLocalizedText(tag.name)),
+			ua.AttributeIDDescription:     DataValueFromValue(ua.NewLocalizedText(tag.description)),
+			ua.AttributeIDDataType:        DataValueFromValue(ua.NewNumericExpandedNodeID(0, uint32(dt))),
+			ua.AttributeIDValueRank:       DataValueFromValue(rank),
+			ua.AttributeIDAccessLevel:     DataValueFromValue(tag.access),
+			ua.AttributeIDUserAccessLevel: DataValueFromValue(tag.access),
+		}
+		if rank == 1 {
+			var dim uint32
+			if ft.Kind() == reflect.Array {
+				dim = uint32(ft.Len())
+			}
+			attrs[ua.AttributeIDArrayDimensions] = DataValueFromValue([]uint32{dim})
+		}
+		sf.node = NewNode(nid, attrs, nil, func() *ua.DataValue { return ns.fieldValue(sf) })
+		ns.addChild(parent, sf.node, id.HasComponent, id.BaseDataVariableType)
+		ns.fields[nid.String()] = sf
+		ns.paths[path] = sf
+
+		if tag.unit != "" {
+			eu := &ua.EUInformation{
+				NamespaceURI: "http://www.opcfoundation.org/UA/units/un/cefact",
+				UnitID:       -1,
+				DisplayName:  ua.NewLocalizedText(tag.unit),
+				Description:  ua.NewLocalizedText(tag.unit),
+			}
+			prop := NewNode(ua.NewStringNodeID(ns.ID(), path+".EngineeringUnits"), Attributes{
+				ua.AttributeIDNodeClass:       DataValueFromValue(uint32(ua.NodeClassVariable)),
+				ua.AttributeIDBrowseName:      DataValueFromValue(&ua.QualifiedName{Name: "EngineeringUnits"}),
+				ua.AttributeIDDisplayName:     DataValueFromValue(ua.NewLocalizedText("EngineeringUnits")),
+				ua.AttributeIDDataType:        DataValueFromValue(ua.NewNumericExpandedNodeID(0, id.EUInformation)),
+				ua.AttributeIDValueRank:       DataValueFromValue(int32(-1)),
+				ua.AttributeIDAccessLevel:     DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead)),
+				ua.AttributeIDUserAccessLevel: DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead)),
+			}, nil, func() *ua.DataValue { return DataValueFromValue(ua.NewExtensionObject(eu)) })
+			ns.addChild(sf.node, prop, id.HasProperty, id.PropertyType)
+		}
+	}
+	return nil
+}
+
+// addChild adds the node with its type definition and the references
+// from and to the parent.
+func (ns *StructNamespace) addChild(parent, n *Node, rt RefType, typeDef uint32) {
+	if td := ns.srv.Node(ua.NewNumericNodeID(0, typeDef)); td != nil {
+		n.AddRef(td, id.HasTypeDefinition, true)
+	}
+	ns.AddNode(n)
+	parent.AddRef(n, rt, true)
+	n.AddRef(parent, rt, false)
+}
+
+// fieldNodeID returns the node id of the field from the nodeid option or
+// the string node id with the path.
+func (ns *StructNamespace) fieldNodeID(path, s string) (*ua.NodeID, error) {
+	if s == "" {
+		return ua.NewStringNodeID(ns.ID(), path), nil
+	}
+	if strings.Contains(s, "ns=") {
+		return nil, fmt.Errorf("node id %s must not have a namespace", s)
+	}
+	return ua.ParseNodeID(fmt.Sprintf("ns=%d;%s", ns.ID(), s))
+}
+
+// GetValue returns the value of the field with the path, e.g. "Motor.Speed".
+// It returns nil if the field does not exist or if a pointer to a struct
+// on its path is nil.
+func (ns *StructNamespace) GetValue(path string) any {
+	sf := ns.paths[path]
+	if sf == nil {
+		return nil
+	}
+	ns.dataMu.RLock()
+	defer ns.dataMu.RUnlock()
+	fv, err := ns.v.FieldByIndexErr(sf.index)
+	if err != nil {
+		return nil
+	}
+	return fv.Interface()
+}
+
+// SetValue sets the field with the path, e.g. "Motor.Speed", and
+// notifies the subscribers of the variable.
+func (ns *StructNamespace) SetValue(path string, value any) error {
+	sf := ns.paths[path]
+	if sf == nil {
+		return fmt.Errorf("field %s not found", path)
+	}
+
+	ns.dataMu.Lock()
+	fv, err := ns.v.FieldByIndexErr(sf.index)
+	if err != nil {
+		ns.dataMu.Unlock()
+		return fmt.Errorf("field %s: %w", path, err)
+	}
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || !v.Type().AssignableTo(fv.Type()) {
+		ns.dataMu.Unlock()
+		return fmt.Errorf("field %s: cannot assign %T to %s", path, value, fv.Type())
+	}
+	fv.Set(v)
+	ns.dataMu.Unlock()
+
+	ns.srv.ChangeNotification(sf.node.ID())
+	return nil
+}
+
+// Update calls f with the struct locked and notifies the subscribers of
+// all variables whose values have been changed by f.
+func (ns *StructNamespace) Update(f func()) {
+	ns.dataMu.Lock()
+	before := make(map[*structField]any, len(ns.fields))
+	for _, sf := range ns.fields {
+		before[sf] = ns.uaValue(sf)
+	}
+	f()
+	var changed []*structField
+	for _, sf := range ns.fields {
+		if !reflect.DeepEqual(before[sf], ns.uaValue(sf)) {
+			changed = append(changed, sf)
+		}
+	}
+	ns.dataMu.Unlock()
+
+	for _, sf := range changed {
+		ns.srv.ChangeNotification(sf.node.ID())
+	}
+}
+
+// ChangeNotification notifies the subscribers of the field with the path.
+func (ns *StructNamespace) ChangeNotification(path string) {
+	if sf := ns.paths[path]; sf != nil {
+		ns.srv.ChangeNotification(sf.node.ID())
+	}
+}
+
+// uaValue returns the value of the field for the variable or nil if a
+// pointer to a struct on its path is nil. The caller must hold dataMu.
+func (ns *StructNamespace) uaValue(sf *structField) any {
+	fv, err := ns.v.FieldByIndexErr(sf.index)
+	if err != nil {
+		return nil
+	}
+	return fieldUAValue(fv)
+}
+
+// fieldValue returns the value of the variable of the field. It returns
+// BadNoData if a pointer to a struct on the path of the field is nil.
+func (ns *StructNamespace) fieldValue(sf *structField) *ua.DataValue {
+	ns.dataMu.RLock()
+	defer ns.dataMu.RUnlock()
+	fv, err := ns.v.FieldByIndexErr(sf.index)
+	if err != nil {
+		return &ua.DataValue{
+			EncodingMask:    ua.DataValueServerTimestamp | ua.DataValueStatusCode,
+			ServerTimestamp: time.Now(),
+			Status:          ua.StatusBadNoData,
+		}
+	}
+	return DataValueFromValue(fieldUAValue(fv))
+}
+
+// SetAttribute writes the values of the variables back into the struct.
+// All other attributes are handled by the NodeNameSpace.
+func (ns *StructNamespace) SetAttribute(nid *ua.NodeID, attr ua.AttributeID, val *ua.DataValue) ua.StatusCode {
+	sf := ns.fields[nid.String()]
+	if sf == nil || attr != ua.AttributeIDValue {
+		return ns.NodeNameSpace.SetAttribute(nid, attr, val)
+	}
+	if sf.access&byte(ua.AccessLevelTypeCurrentWrite) == 0 {
+		return ua.StatusBadNotWritable
+	}
+	if val == nil || val.Value == nil {
+		return ua.StatusBadTypeMismatch
+	}
+
+	ns.dataMu.Lock()
+	fv, err := ns.v.FieldByIndexErr(sf.index)
+	if err != nil {
+		// the struct which contains the field has been removed
+		ns.dataMu.Unlock()
+		return ua.StatusBadNodeIDUnknown
+	}
+	v, ok := fieldGoValue(val.Value.Value(), fv.Type())
+	if !ok {
+		ns.dataMu.Unlock()
+		return ua.StatusBadTypeMismatch
+	}
+	fv.Set(v)
+	ns.dataMu.Unlock()
+
+	// notify the opc ua server the value has changed.
+	ns.srv.ChangeNotification(nid)
+	// notify the non-opc application the value has changed.
+	select {
+	case ns.FieldNotification <- sf.path:
+	default:
+	}
+	return ua.StatusOK
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// structTag contains the options of the struct tags of a field.
+type structTag struct {
+	name        string
+	nodeID      string
+	access      byte
+	unit        string
+	description string
+}
+
+func parseStructTag(f reflect.StructField) (structTag, error) {
+	tag := structTag{
+		name:        f.Name,
+		access:      byte(ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite),
+		unit:        f.Tag.Get("unit"),
+		description: f.Tag.Get("description"),
+	}
+	name, opts, _ := strings.Cut(f.Tag.Get("opcua"), ",")
+	if name != "" {
+		tag.name = name
+	}
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		k, v, _ := strings.Cut(opt, "=")
+		switch k {
+		case "nodeid":
+			tag.nodeID = v
+		case "access":
+			switch v {
+			case "r":
+				tag.access = byte(ua.AccessLevelTypeCurrentRead)
+			case "w":
+				tag.access = byte(ua.AccessLevelTypeCurrentWrite)
+			case "rw":
+				tag.access = byte(ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite)
+			default:
+				return tag, fmt.Errorf("field %s: invalid access %q", f.Name, v)
+			}
+		default:
+			return tag, fmt.Errorf("field %s: invalid option %q", f.Name, opt)
+		}
+	}
+	return tag, nil
+}
+
+// fieldDataType returns the built-in data type and the value rank of the
+// type or false if the type is not supported.
+func fieldDataType(t reflect.Type) (ua.TypeID, int32, bool) {
+	switch {
+	case t == timeType:
+		return ua.TypeIDDateTime, -1, true
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return ua.TypeIDByteString, -1, true
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		dt, rank, ok := fieldDataType(t.Elem())
+		if !ok || rank != -1 || t.Elem().Kind() == reflect.Slice {
+			return 0, 0, false
+		}
+		return dt, 1, true
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return ua.TypeIDBoolean, -1, true
+	case reflect.Int8:
+		return ua.TypeIDSByte, -1, true
+	case reflect.Uint8:
+		return ua.TypeIDByte, -1, true
+	case reflect.Int16:
+		return ua.TypeIDInt16, -1, true
+	case reflect.Uint16:
+		return ua.TypeIDUint16, -1, true
+	case reflect.Int32:
+		return ua.TypeIDInt32, -1, true
+	case reflect.Uint32:
+		return ua.TypeIDUint32, -1, true
+	case reflect.Int, reflect.Int64:
+		return ua.TypeIDInt64, -1, true
+	case reflect.Uint, reflect.Uint64:
+		return ua.TypeIDUint64, -1, true
+	case reflect.Float32:
+		return ua.TypeIDFloat, -1, true
+	case reflect.Float64:
+		return ua.TypeIDDouble, -1, true
+	case reflect.String:
+		return ua.TypeIDString, -1, true
+	}
+	return 0, 0, false
+}
+
+// fieldUAType returns the Go type which the variant uses for values of type t.
+func fieldUAType(t reflect.Type) reflect.Type {
+	switch {
+	case t == timeType:
+		return t
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return reflect.TypeOf([]byte{})
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return reflect.SliceOf(fieldUAType(t.Elem()))
+	}
+	// named types like "type State int32" are converted to the basic type
+	if bt, ok := basicTypes[t.Kind()]; ok {
+		return bt
+	}
+	return t
+}
+
+var basicTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:    reflect.TypeOf(false),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Int:     reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+	reflect.String:  reflect.TypeOf(""),
+}
+
+// fieldUAValue converts the field value to a value which can be stored in a
+// variant. Slices and arrays are copied.
+func fieldUAValue(v reflect.Value) any {
+	t := fieldUAType(v.Type())
+	switch {
+	case v.Type() == timeType:
+		return v.Interface()
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return append([]byte{}, v.Bytes()...)
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		s := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s.Index(i).Set(reflect.ValueOf(fieldUAValue(v.Index(i))))
+		}
+		return s.Interface()
+	}
+	return v.Convert(t).Interface()
+}
+
+// fieldGoValue converts a variant value to the field type t. It returns false
+// if the value does not have the data type of the field.
+func fieldGoValue(x any, t reflect.Type) (reflect.Value, bool) {
+	v := reflect.ValueOf(x)
+	if !v.IsValid() || v.Type() != fieldUAType(t) {
+		return reflect.Value{}, false
+	}
+	switch {
+	case t == timeType:
+		return v, true
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return v.Convert(t), true
+	case t.Kind() == reflect.Slice, t.Kind() == reflect.Array:
+		var s reflect.Value
+		if t.Kind() == reflect.Array {
+			if v.Len() != t.Len() {
+				return reflect.Value{}, false
+			}
+			s = reflect.New(t).Elem()
+		} else {
+			s = reflect.MakeSlice(t, v.Len(), v.Len())
+		}
+		for i := 0; i < v.Len(); i++ {
+			e, ok := fieldGoValue(v.Index(i).Interface(), t.Elem())
+			if !ok {
+				return reflect.Value{}, false
+			}
+			s.Index(i).Set(e)
+		}
+		return s, true
+	}
+	return v.Convert(t), true
+}
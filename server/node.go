@@ -44,10 +44,23 @@ type Node struct {
 	val  ValueFunc
 
 	ns NameSpace
+
+	onRead  ReadHook
+	onWrite WriteHook
+
+	// setValue stores the values which are written to the Value
+	// attribute if it is set, e.g. for a Variable.
+	setValue func(*ua.DataValue) ua.StatusCode
+
+	// written is set once the Value attribute has been written.
+	written bool
+
+	// call is the handler of a method node.
+	call MethodHandler
 }
 
 func NewNode(id *ua.NodeID, attr Attributes, refs References, val ValueFunc) *Node {
-	n := &Node{id, attr, refs, val, nil}
+	n := &Node{id: id, attr: attr, refs: refs, val: val}
 	n.sanitize()
 	return n
 }
@@ -167,7 +180,21 @@ func (n *Node) Attribute(id ua.AttributeID) (*AttrValue, error) {
 	}
 }
 func (n *Node) SetAttribute(id ua.AttributeID, val *ua.DataValue) error {
+	if id == ua.AttributeIDValue && n.setValue != nil {
+		if status := n.setValue(val); status != ua.StatusOK {
+			return status
+		}
+	}
+
+	// the snapshots of the persistence read the node under the lock
+	if ns, ok := n.ns.(*NodeNameSpace); ok {
+		ns.mu.Lock()
+		defer ns.mu.Unlock()
+	}
 	switch {
+	case id == ua.AttributeIDValue && n.setValue != nil:
+		n.written = true
+		return nil
 	case id == ua.AttributeIDValue:
 
 		// TODO: probably need to do some type checking here.
@@ -175,12 +202,13 @@ func (n *Node) SetAttribute(id ua.AttributeID, val *ua.DataValue) error {
 		n.val = func() *ua.DataValue {
 			return val
 		}
+		n.written = true
 
 		return nil
 	default:
 		n.attr[id] = val
+		return nil
 	}
-	return ua.StatusBadNodeAttributesInvalid
 }
 
 func (n *Node) BrowseName() *ua.QualifiedName {
@@ -303,11 +331,17 @@ const (
 )
 
 func (n *Node) AddRef(o *Node, rt RefType, forward bool) {
+	n.addReference(o, ua.NewNumericNodeID(0, uint32(rt)), forward)
+}
+
+// addReference is like AddRef but accepts reference types of any
+// namespace.
+func (n *Node) addReference(o *Node, rt *ua.NodeID, forward bool) {
 	//eoid := ua.NewNumericExpandedNodeID(o.ns.ID(), o.)
 	eoid := ua.NewExpandedNodeID(o.ID(), "", 0)
 
 	ref := ua.ReferenceDescription{
-		ReferenceTypeID: ua.NewNumericNodeID(0, uint32(rt)), //o.refs[0].ReferenceTypeID,
+		ReferenceTypeID: rt, //o.refs[0].ReferenceTypeID,
 		IsForward:       forward,
 		NodeID:          eoid,
 		BrowseName:      o.BrowseName(),
@@ -316,4 +350,7 @@ func (n *Node) AddRef(o *Node, rt RefType, forward bool) {
 		TypeDefinition:  o.DataType(),
 	}
 	n.refs = append(n.refs, &ref)
+	if ns, ok := n.ns.(*NodeNameSpace); ok && rt.Equal(hasSubtype) {
+		ns.typeChanged(n)
+	}
 }
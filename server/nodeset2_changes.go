@@ -0,0 +1,597 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/schema"
+	"github.com/gopcua/opcua/ua"
+)
+
+// verbs of the ModelChangeStructureDataType
+//
+// https://reference.opcfoundation.org/Core/Part5/v105/docs/12.16
+const (
+	modelChangeNodeAdded        = 1
+	modelChangeNodeDeleted      = 2
+	modelChangeReferenceAdded   = 4
+	modelChangeReferenceDeleted = 8
+)
+
+// ApplyNodeSetChanges adds and deletes the nodes and references of a
+// NodeSetChanges document while the server is running.
+//
+// The changes are applied in the order NodesToAdd, ReferencesToAdd,
+// NodesToDelete and ReferencesToDelete. The nodes to add are processed
+// by node class in the order of the UANodeSet import. The returned
+// status contains the result of every change in this order.
+//
+// If AcceptAllOrNothing is set and a single change is invalid then no
+// change is applied. The failed changes report their error, all other
+// changes report BadOperationAbandoned and an error is returned.
+// Otherwise the valid changes are applied and the invalid changes are
+// reported in the status.
+//
+// The applied changes are reported to the monitored items of the
+// affected nodes and as a GeneralModelChangeEvent on the Server object.
+//
+// https://reference.opcfoundation.org/Core/Part6/v105/docs/F.13
+func (srv *Server) ApplyNodeSetChanges(changes *schema.UANodeSetChanges) (*schema.UANodeSetChangesStatus, error) {
+	// the changes are checked and applied without other changes in
+	// between.
+	srv.nodeSetMu.Lock()
+	defer srv.nodeSetMu.Unlock()
+
+	// the namespaces are only created when the changes are applied.
+	nsm, missing := srv.resolveNamespaces(changes.NamespaceUris)
+
+	adds := changes.NodesToAdd
+	if adds == nil {
+		adds = &schema.NodesToAdd{}
+	}
+	ch := &nodeSetChanges{
+		srv:     srv,
+		nsm:     nsm,
+		imp:     srv.newRefImport(&schema.UANodeSet{Aliases: changes.Aliases, UAReferenceType: adds.UAReferenceType}, nsm),
+		added:   map[string]bool{},
+		deleted: map[string]bool{},
+		refs:    map[string]bool{},
+		missing: missing,
+		invalid: invalidAttributes(adds, nsm, nodeSetAliases(&schema.UANodeSet{Aliases: changes.Aliases})),
+	}
+
+	status := &schema.UANodeSetChangesStatus{
+		LastModifiedAttr:  time.Now().UTC().Format(time.RFC3339),
+		TransactionIdAttr: changes.TransactionIdAttr,
+	}
+
+	nodes := nodesToAdd(adds)
+	nodeIDs := make([]*ua.NodeID, len(nodes))
+	nodeCodes := make([]ua.StatusCode, len(nodes))
+	for i, n := range nodes {
+		nodeIDs[i], nodeCodes[i] = ch.checkAddNode(n)
+	}
+	// the references of the new nodes can refer to nodes which are
+	// added later in the document.
+	for i, n := range nodes {
+		if nodeCodes[i] != ua.StatusOK {
+			continue
+		}
+		if nodeCodes[i] = ch.checkNodeRefs(n); nodeCodes[i] != ua.StatusOK {
+			delete(ch.added, nodeIDs[i].String())
+		}
+	}
+
+	var refsToAdd, refsToDelete []*schema.ReferenceChange
+	if changes.ReferencesToAdd != nil {
+		refsToAdd = changes.ReferencesToAdd.Reference
+	}
+	if changes.ReferencesToDelete != nil {
+		refsToDelete = changes.ReferencesToDelete.Reference
+	}
+	var nodesToDelete []*schema.NodeToDelete
+	if changes.NodesToDelete != nil {
+		nodesToDelete = changes.NodesToDelete.Node
+	}
+
+	refAdds := make([]refChange, len(refsToAdd))
+	refAddCodes := make([]ua.StatusCode, len(refsToAdd))
+	for i, r := range refsToAdd {
+		refAdds[i], refAddCodes[i] = ch.checkAddRef(r)
+	}
+	nodeDeletes := make([]*ua.NodeID, len(nodesToDelete))
+	nodeDeleteCodes := make([]ua.StatusCode, len(nodesToDelete))
+	for i, n := range nodesToDelete {
+		nodeDeletes[i], nodeDeleteCodes[i] = ch.checkDeleteNode(n)
+	}
+	refDeletes := make([]refChange, len(refsToDelete))
+	refDeleteCodes := make([]ua.StatusCode, len(refsToDelete))
+	for i, r := range refsToDelete {
+		refDeletes[i], refDeleteCodes[i] = ch.checkDeleteRef(r)
+	}
+
+	allCodes := [][]ua.StatusCode{nodeCodes, refAddCodes, nodeDeleteCodes, refDeleteCodes}
+	setStatus := func() {
+		status.NodesToAdd = nodeSetStatusList(nodeCodes)
+		status.ReferencesToAdd = nodeSetStatusList(refAddCodes)
+		status.NodesToDelete = nodeSetStatusList(nodeDeleteCodes)
+		status.ReferencesToDelete = nodeSetStatusList(refDeleteCodes)
+	}
+
+	failed := 0
+	for _, codes := range allCodes {
+		for _, c := range codes {
+			if c != ua.StatusOK {
+				failed++
+			}
+		}
+	}
+	if failed > 0 && changes.AcceptAllOrNothingAttr {
+		abandon(allCodes)
+		setStatus()
+		return status, fmt.Errorf("nodeset changes %s rejected: %d changes are invalid", changes.TransactionIdAttr, failed)
+	}
+
+	if err := srv.createNamespaces(missing); err != nil {
+		return nil, fmt.Errorf("problem creating namespaces: %w", err)
+	}
+	if err := ch.addNodes(adds, nodes, nodeIDs, nodeCodes); err != nil {
+		// none of the changes has been applied other than the nodes
+		// which have been added before the error.
+		ch.removeNodes(nodeIDs, nodeCodes)
+		abandon(allCodes)
+		setStatus()
+		return status, fmt.Errorf("nodeset changes %s rejected: %w", changes.TransactionIdAttr, err)
+	}
+	for i, r := range refAdds {
+		if refAddCodes[i] == ua.StatusOK {
+			ch.addRef(r)
+		}
+	}
+	for i, n := range nodesToDelete {
+		if nodeDeleteCodes[i] == ua.StatusOK {
+			ch.deleteNode(nodeDeletes[i], n.DeleteReverseReferencesAttr)
+		}
+	}
+	for i, r := range refDeletes {
+		if refDeleteCodes[i] == ua.StatusOK {
+			ch.deleteRef(r)
+		}
+	}
+	ch.notify()
+
+	setStatus()
+	return status, nil
+}
+
+// abandon sets the codes of the valid changes to BadOperationAbandoned.
+func abandon(allCodes [][]ua.StatusCode) {
+	for _, codes := range allCodes {
+		for i, c := range codes {
+			if c == ua.StatusOK {
+				codes[i] = ua.StatusBadOperationAbandoned
+			}
+		}
+	}
+}
+
+// invalidAttributes returns the variables and variable types to add
+// whose attributes or value cannot be imported.
+func invalidAttributes(adds *schema.NodesToAdd, nsm namespaceMap, aliases map[string]string) map[*schema.UANode]bool {
+	invalid := map[*schema.UANode]bool{}
+	attrs := Attributes{}
+	for _, n := range adds.UAVariableType {
+		if err := nsm.variableAttributes(attrs, aliases, n.DataTypeAttr, n.ValueRankAttr, n.ArrayDimensionsAttr); err != nil {
+			invalid[n.UANode] = true
+		}
+	}
+	for _, n := range adds.UAVariable {
+		if err := nsm.variableAttributes(attrs, aliases, n.DataTypeAttr, n.ValueRankAttr, n.ArrayDimensionsAttr); err != nil {
+			invalid[n.UANode] = true
+			continue
+		}
+		if n.Value != nil {
+			if _, err := nsm.value(n.Value); err != nil {
+				invalid[n.UANode] = true
+			}
+		}
+	}
+	return invalid
+}
+
+// nodeSetChanges contains the state of ApplyNodeSetChanges.
+type nodeSetChanges struct {
+	srv *Server
+	nsm namespaceMap
+	imp *refImport
+
+	// missing are the namespaces of the document which are created when
+	// the changes are applied.
+	missing []newNamespace
+
+	// added and deleted contain the ids of the nodes which are added
+	// and deleted by the document.
+	added   map[string]bool
+	deleted map[string]bool
+
+	// refs contains the keys of the references which are added by the
+	// document.
+	refs map[string]bool
+
+	// invalid contains the nodes to add whose attributes are invalid.
+	invalid map[*schema.UANode]bool
+
+	// changes are the applied changes for the model change event.
+	changes []*ua.ModelChangeStructureDataType
+}
+
+// exists returns true if the node exists after the changes which have
+// been checked so far.
+func (ch *nodeSetChanges) exists(nid *ua.NodeID) bool {
+	k := nid.String()
+	if ch.deleted[k] {
+		return false
+	}
+	return ch.added[k] || ch.srv.Node(nid) != nil
+}
+
+// newNamespace returns true if the namespace is created by the changes.
+func (ch *nodeSetChanges) newNamespace(idx uint16) bool {
+	return slices.ContainsFunc(ch.missing, func(m newNamespace) bool { return m.idx == idx })
+}
+
+func (ch *nodeSetChanges) checkAddNode(n *schema.UANode) (*ua.NodeID, ua.StatusCode) {
+	nid, err := ch.nsm.parseNodeID(n.NodeIdAttr)
+	if err != nil {
+		return nil, ua.StatusBadNodeIDInvalid
+	}
+	if _, err := ch.srv.Namespace(int(nid.Namespace())); err != nil && !ch.newNamespace(nid.Namespace()) {
+		return nid, ua.StatusBadNodeIDInvalid
+	}
+	if ch.exists(nid) {
+		return nid, ua.StatusBadNodeIDExists
+	}
+	if ch.invalid[n] {
+		return nid, ua.StatusBadNodeAttributesInvalid
+	}
+	ch.added[nid.String()] = true
+	return nid, ua.StatusOK
+}
+
+// checkNodeRefs checks the references of a node which is added.
+func (ch *nodeSetChanges) checkNodeRefs(n *schema.UANode) ua.StatusCode {
+	if n.References == nil {
+		return ua.StatusOK
+	}
+	for _, ref := range n.References.Reference {
+		target, err := ch.nsm.parseNodeID(ref.Value)
+		if err != nil || !ch.exists(target) {
+			return ua.StatusBadTargetNodeIDInvalid
+		}
+		if _, _, ok := ch.imp.refType(ref.ReferenceTypeAttr); !ok {
+			return ua.StatusBadReferenceTypeIDInvalid
+		}
+	}
+	return ua.StatusOK
+}
+
+func (ch *nodeSetChanges) checkAddRef(r *schema.ReferenceChange) (refChange, ua.StatusCode) {
+	rc, code := ch.parseRef(r)
+	if code != ua.StatusOK {
+		return rc, code
+	}
+	k := refKey(rc.src, rc.rt, rc.target, rc.forward)
+	if ch.refs[k] || hasRef(ch.srv.Node(rc.src), rc.rt, rc.target, rc.forward) {
+		return rc, ua.StatusBadDuplicateReferenceNotAllowed
+	}
+	ch.refs[k] = true
+	return rc, ua.StatusOK
+}
+
+func (ch *nodeSetChanges) checkDeleteNode(n *schema.NodeToDelete) (*ua.NodeID, ua.StatusCode) {
+	nid, err := ch.nsm.parseNodeID(string(n.NodeId))
+	if err != nil {
+		return nil, ua.StatusBadNodeIDInvalid
+	}
+	if !ch.exists(nid) {
+		return nid, ua.StatusBadNodeIDUnknown
+	}
+	ns, err := ch.srv.Namespace(int(nid.Namespace()))
+	if err != nil {
+		return nid, ua.StatusBadNodeIDUnknown
+	}
+	if _, ok := ns.(*NodeNameSpace); !ok {
+		return nid, ua.StatusBadNotSupported
+	}
+	ch.deleted[nid.String()] = true
+	return nid, ua.StatusOK
+}
+
+// checkDeleteRef checks a reference which is deleted. The target of
+// the reference does not need to exist.
+func (ch *nodeSetChanges) checkDeleteRef(r *schema.ReferenceChange) (refChange, ua.StatusCode) {
+	rc, code := ch.parseRef(r)
+	switch {
+	case code == ua.StatusBadTargetNodeIDInvalid && rc.target != nil:
+	case code != ua.StatusOK:
+		return rc, code
+	}
+	if !ch.refs[refKey(rc.src, rc.rt, rc.target, rc.forward)] && !hasRef(ch.srv.Node(rc.src), rc.rt, rc.target, rc.forward) {
+		return rc, ua.StatusBadNotFound
+	}
+	return rc, ua.StatusOK
+}
+
+// refChange is a reference which is added or deleted.
+type refChange struct {
+	src, target, rt *ua.NodeID
+	symmetric       bool
+	forward         bool
+}
+
+// parseRef returns the reference with the ids of the server. It returns
+// BadTargetNodeIDInvalid with the target if the target does not exist.
+func (ch *nodeSetChanges) parseRef(r *schema.ReferenceChange) (refChange, ua.StatusCode) {
+	rc := refChange{forward: isForward(r.IsForwardAttr)}
+	src, err := ch.nsm.parseNodeID(r.SourceAttr)
+	if err != nil || !ch.exists(src) {
+		return rc, ua.StatusBadSourceNodeIDInvalid
+	}
+	rc.src = src
+	rt, symmetric, ok := ch.imp.refType(r.ReferenceTypeAttr)
+	if !ok {
+		return rc, ua.StatusBadReferenceTypeIDInvalid
+	}
+	rc.rt, rc.symmetric = rt, symmetric
+	target, err := ch.nsm.parseNodeID(string(r.NodeId))
+	if err != nil {
+		return rc, ua.StatusBadTargetNodeIDInvalid
+	}
+	rc.target = target
+	if !ch.exists(target) {
+		return rc, ua.StatusBadTargetNodeIDInvalid
+	}
+	return rc, ua.StatusOK
+}
+
+// addNodes adds the nodes which have been checked successfully and
+// their references. It returns an error if a node cannot be imported.
+// The references are only added if all nodes have been imported.
+func (ch *nodeSetChanges) addNodes(adds *schema.NodesToAdd, nodes []*schema.UANode, ids []*ua.NodeID, codes []ua.StatusCode) error {
+	ok := map[string]bool{}
+	for i, n := range nodes {
+		if codes[i] == ua.StatusOK {
+			ok[n.NodeIdAttr] = true
+		}
+	}
+	if len(ok) == 0 {
+		return nil
+	}
+
+	set := &schema.UANodeSet{
+		Aliases:         ch.imp.aliases,
+		UAReferenceType: slices.DeleteFunc(slices.Clone(adds.UAReferenceType), func(n *schema.UAReferenceType) bool { return !ok[n.NodeIdAttr] }),
+		UADataType:      slices.DeleteFunc(slices.Clone(adds.UADataType), func(n *schema.UADataType) bool { return !ok[n.NodeIdAttr] }),
+		UAObjectType:    slices.DeleteFunc(slices.Clone(adds.UAObjectType), func(n *schema.UAObjectType) bool { return !ok[n.NodeIdAttr] }),
+		UAVariableType:  slices.DeleteFunc(slices.Clone(adds.UAVariableType), func(n *schema.UAVariableType) bool { return !ok[n.NodeIdAttr] }),
+		UAVariable:      slices.DeleteFunc(slices.Clone(adds.UAVariable), func(n *schema.UAVariable) bool { return !ok[n.NodeIdAttr] }),
+		UAMethod:        slices.DeleteFunc(slices.Clone(adds.UAMethod), func(n *schema.UAMethod) bool { return !ok[n.NodeIdAttr] }),
+		UAObject:        slices.DeleteFunc(slices.Clone(adds.UAObject), func(n *schema.UAObject) bool { return !ok[n.NodeIdAttr] }),
+		UAView:          slices.DeleteFunc(slices.Clone(adds.UAView), func(n *schema.UAView) bool { return !ok[n.NodeIdAttr] }),
+	}
+	if err := ch.srv.nodesImportNodeSet(set, ch.nsm); err != nil {
+		return err
+	}
+
+	for i, n := range nodes {
+		if codes[i] != ua.StatusOK {
+			continue
+		}
+		ch.imp.addRefs(n.NodeIdAttr, n.BrowseNameAttr, n.References)
+		ch.changes = append(ch.changes, &ua.ModelChangeStructureDataType{
+			Affected:     ids[i],
+			AffectedType: typeDefinition(ch.srv.Node(ids[i])),
+			Verb:         modelChangeNodeAdded,
+		})
+	}
+	ch.srv.viewsImportNodeSet(set.UAView, ch.nsm)
+	return nil
+}
+
+// removeNodes removes the nodes which have been added when adding the
+// nodes fails. The nodes have no references yet.
+func (ch *nodeSetChanges) removeNodes(ids []*ua.NodeID, codes []ua.StatusCode) {
+	for i, nid := range ids {
+		if codes[i] != ua.StatusOK {
+			continue
+		}
+		if ns, err := ch.srv.Namespace(int(nid.Namespace())); err == nil {
+			if ns, ok := ns.(*NodeNameSpace); ok {
+				ns.DeleteNode(nid)
+			}
+		}
+	}
+}
+
+func (ch *nodeSetChanges) addRef(rc refChange) {
+	src, target := ch.srv.Node(rc.src), ch.srv.Node(rc.target)
+	if src == nil || target == nil {
+		// the node has been deleted by the document
+		return
+	}
+	addRef(src, target, rc.rt, rc.symmetric, rc.forward)
+	ch.changes = append(ch.changes, &ua.ModelChangeStructureDataType{
+		Affected:     rc.src,
+		AffectedType: typeDefinition(src),
+		Verb:         modelChangeReferenceAdded,
+	})
+}
+
+func (ch *nodeSetChanges) deleteNode(nid *ua.NodeID, deleteReverseRefs bool) {
+	ns, err := ch.srv.Namespace(int(nid.Namespace()))
+	if err != nil {
+		return
+	}
+	node := ns.(*NodeNameSpace).DeleteNode(nid)
+	if node == nil {
+		return
+	}
+	if deleteReverseRefs {
+		for _, ref := range node.refs {
+			if ref.NodeID == nil {
+				continue
+			}
+			if target := ch.srv.Node(ref.NodeID.NodeID); target != nil {
+				deleteRefs(target, func(r *ua.ReferenceDescription) bool {
+					return r.NodeID != nil && r.NodeID.NodeID.Equal(nid)
+				})
+			}
+		}
+	}
+	ch.changes = append(ch.changes, &ua.ModelChangeStructureDataType{
+		Affected:     nid,
+		AffectedType: typeDefinition(node),
+		Verb:         modelChangeNodeDeleted,
+	})
+}
+
+// deleteRef deletes the reference and the inverse reference of the target.
+func (ch *nodeSetChanges) deleteRef(rc refChange) {
+	src := ch.srv.Node(rc.src)
+	if src == nil {
+		return
+	}
+	deleteRefs(src, func(ref *ua.ReferenceDescription) bool {
+		return matchRef(ref, rc.rt, rc.target, rc.forward)
+	})
+	if target := ch.srv.Node(rc.target); target != nil {
+		deleteRefs(target, func(ref *ua.ReferenceDescription) bool {
+			return matchRef(ref, rc.rt, rc.src, !rc.forward)
+		})
+	}
+	ch.changes = append(ch.changes, &ua.ModelChangeStructureDataType{
+		Affected:     rc.src,
+		AffectedType: typeDefinition(src),
+		Verb:         modelChangeReferenceDeleted,
+	})
+}
+
+// deleteRefs removes the references of the node for which del returns
+// true. The references are replaced by a new slice under the lock of the
+// namespace since Browse iterates them concurrently.
+func deleteRefs(n *Node, del func(*ua.ReferenceDescription) bool) {
+	subtypes := false
+	refs := slices.DeleteFunc(slices.Clone(n.refs), func(r *ua.ReferenceDescription) bool {
+		if !del(r) {
+			return false
+		}
+		subtypes = subtypes || r.ReferenceTypeID != nil && r.ReferenceTypeID.Equal(hasSubtype)
+		return true
+	})
+	ns, ok := n.ns.(*NodeNameSpace)
+	if !ok {
+		n.refs = refs
+		return
+	}
+	ns.mu.Lock()
+	n.refs = refs
+	ns.mu.Unlock()
+	if subtypes {
+		ns.typeChanged(n)
+	}
+}
+
+// notify sends change notifications for the affected nodes and reports
+// a GeneralModelChangeEvent with all changes.
+func (ch *nodeSetChanges) notify() {
+	if len(ch.changes) == 0 {
+		return
+	}
+	changes := make([]*ua.ExtensionObject, len(ch.changes))
+	for i, c := range ch.changes {
+		ch.srv.ChangeNotification(c.Affected)
+		changes[i] = ua.NewExtensionObject(c)
+	}
+	ch.srv.ReportEvent(ua.NewNumericNodeID(0, id.Server), &Event{
+		EventType:  ua.NewNumericNodeID(0, id.GeneralModelChangeEventType),
+		SourceNode: ua.NewNumericNodeID(0, id.Server),
+		SourceName: "Server",
+		Severity:   100,
+		Message:    "The address space has changed",
+		Fields: map[string]any{
+			"Changes": changes,
+		},
+	})
+}
+
+// nodesToAdd returns the nodes of all node classes in the order in
+// which they are imported.
+func nodesToAdd(a *schema.NodesToAdd) []*schema.UANode {
+	var nodes []*schema.UANode
+	for _, n := range a.UAReferenceType {
+		nodes = append(nodes, n.UANode)
+	}
+	for _, n := range a.UADataType {
+		nodes = append(nodes, n.UANode)
+	}
+	for _, n := range a.UAObjectType {
+		nodes = append(nodes, n.UANode)
+	}
+	for _, n := range a.UAVariableType {
+		nodes = append(nodes, n.UANode)
+	}
+	for _, n := range a.UAVariable {
+		nodes = append(nodes, n.UANode)
+	}
+	for _, n := range a.UAMethod {
+		nodes = append(nodes, n.UANode)
+	}
+	for _, n := range a.UAObject {
+		nodes = append(nodes, n.UANode)
+	}
+	for _, n := range a.UAView {
+		nodes = append(nodes, n.UANode)
+	}
+	return nodes
+}
+
+func isForward(v *bool) bool {
+	return v == nil || *v
+}
+
+func refKey(src, rt, target *ua.NodeID, forward bool) string {
+	return fmt.Sprintf("%s|%s|%s|%t", src, rt, target, forward)
+}
+
+func matchRef(ref *ua.ReferenceDescription, rt, target *ua.NodeID, forward bool) bool {
+	return ref.IsForward == forward && ref.NodeID != nil && ref.ReferenceTypeID != nil &&
+		ref.ReferenceTypeID.Equal(rt) && ref.NodeID.NodeID.Equal(target)
+}
+
+// hasRef returns true if the node has the reference.
+func hasRef(n *Node, rt, target *ua.NodeID, forward bool) bool {
+	if n == nil {
+		return false
+	}
+	return slices.ContainsFunc(n.refs, func(ref *ua.ReferenceDescription) bool {
+		return matchRef(ref, rt, target, forward)
+	})
+}
+
+func nodeSetStatusList(codes []ua.StatusCode) *schema.NodeSetStatusList {
+	if len(codes) == 0 {
+		return nil
+	}
+	l := &schema.NodeSetStatusList{}
+	for _, c := range codes {
+		name := "Good"
+		if d, ok := ua.StatusCodes[c]; ok && c != ua.StatusOK {
+			name = strings.TrimPrefix(d.Name, "Status")
+		}
+		l.Status = append(l.Status, &schema.NodeSetStatus{CodeAttr: uint32(c), Value: name})
+	}
+	return l
+}
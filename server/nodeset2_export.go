@@ -0,0 +1,590 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/schema"
+	"github.com/gopcua/opcua/ua"
+)
+
+// ExportNodeSet returns the nodes of the namespaces with the uris as a
+// NodeSet2 document which can be marshaled with encoding/xml.
+//
+// The namespace table of the document contains the exported namespaces
+// followed by all other namespaces which the nodes reference. Reference
+// types and data types of namespace 0 are written as aliases. Only
+// namespaces of type *NodeNameSpace can be exported.
+func (srv *Server) ExportNodeSet(namespaceURIs ...string) (*schema.UANodeSet, error) {
+	exp := &nodeSetExport{
+		srv:     srv,
+		nsidx:   map[uint16]int{0: 0},
+		aliases: map[string]string{},
+		nodes:   map[string]bool{},
+	}
+
+	var spaces []*NodeNameSpace
+	for _, uri := range namespaceURIs {
+		idx := slices.IndexFunc(srv.Namespaces(), func(ns NameSpace) bool { return ns.Name() == uri })
+		if idx < 0 {
+			return nil, fmt.Errorf("namespace %s not found", uri)
+		}
+		ns, ok := srv.Namespaces()[idx].(*NodeNameSpace)
+		if !ok {
+			return nil, fmt.Errorf("namespace %s cannot be exported", uri)
+		}
+		exp.namespaceIndex(uint16(idx))
+		spaces = append(spaces, ns)
+	}
+
+	var nodes []*Node
+	for _, ns := range spaces {
+		for _, n := range ns.exportNodes() {
+			exp.nodes[n.ID().String()] = true
+			nodes = append(nodes, n)
+		}
+	}
+
+	set := &schema.UANodeSet{LastModifiedAttr: time.Now().UTC().Format(time.RFC3339)}
+	for _, n := range nodes {
+		exp.addNode(set, n)
+	}
+
+	if len(exp.uris) > 0 {
+		set.NamespaceUris = &schema.UriTable{Uri: exp.uris}
+	}
+	set.Models = exp.models(spaces)
+	if len(exp.aliases) > 0 {
+		set.Aliases = &schema.AliasTable{}
+		for _, name := range slices.Sorted(maps.Keys(exp.aliases)) {
+			set.Aliases.Alias = append(set.Aliases.Alias, &schema.NodeIdAlias{AliasAttr: name, Value: exp.aliases[name]})
+		}
+	}
+	return set, nil
+}
+
+// exportNodes returns the nodes of the namespace in the order in which
+// they have been added. Nodes which have been replaced are skipped.
+func (ns *NodeNameSpace) exportNodes() []*Node {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	var nodes []*Node
+	for _, n := range ns.nodes {
+		if ns.m[n.ID().String()] == n {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// nodeSetExport contains the state of ExportNodeSet.
+type nodeSetExport struct {
+	srv *Server
+
+	// nsidx maps the namespace indices of the server onto the
+	// namespace indices of the nodeset.
+	nsidx map[uint16]int
+	uris  []string
+
+	// aliases maps the alias names onto the node ids.
+	aliases map[string]string
+
+	// nodes contains the ids of the exported nodes.
+	nodes map[string]bool
+}
+
+// namespaceIndex returns the index of the server namespace in the
+// nodeset and adds the namespace to the namespace table if necessary.
+func (e *nodeSetExport) namespaceIndex(idx uint16) int {
+	if i, ok := e.nsidx[idx]; ok {
+		return i
+	}
+	uri := fmt.Sprintf("urn:gopcua:unknown:%d", idx)
+	if ns, err := e.srv.Namespace(int(idx)); err == nil {
+		uri = ns.Name()
+	}
+	e.uris = append(e.uris, uri)
+	e.nsidx[idx] = len(e.uris)
+	return len(e.uris)
+}
+
+// nodeID returns the node id with the namespace index of the nodeset.
+func (e *nodeSetExport) nodeID(nid *ua.NodeID) string {
+	if nid.Namespace() == 0 {
+		return nid.String()
+	}
+	_, rest, _ := strings.Cut(nid.String(), ";")
+	return fmt.Sprintf("ns=%d;%s", e.namespaceIndex(nid.Namespace()), rest)
+}
+
+// browseName returns the browse name in the "n:Name" format with the
+// namespace index of the nodeset.
+func (e *nodeSetExport) browseName(qn *ua.QualifiedName) string {
+	if qn.NamespaceIndex == 0 {
+		return qn.Name
+	}
+	return fmt.Sprintf("%d:%s", e.namespaceIndex(qn.NamespaceIndex), qn.Name)
+}
+
+// alias returns the alias for a reference type or data type of
+// namespace 0 and the node id for all other nodes.
+func (e *nodeSetExport) alias(nid *ua.NodeID) string {
+	if nid.Namespace() != 0 {
+		return e.nodeID(nid)
+	}
+	n := e.srv.Node(nid)
+	if n == nil || n.BrowseName().Name == "" {
+		return nid.String()
+	}
+	name := n.BrowseName().Name
+	if v, ok := e.aliases[name]; ok && v != nid.String() {
+		return nid.String()
+	}
+	e.aliases[name] = nid.String()
+	return name
+}
+
+// models returns the model table with an entry for every exported
+// namespace. Models which have been imported keep their version and
+// publication date. The other namespaces of the namespace table are
+// listed as required models if they have been imported.
+func (e *nodeSetExport) models(spaces []*NodeNameSpace) *schema.ModelTable {
+	var required []*schema.ModelTableEntry
+	uris := append([]string{e.srv.Namespaces()[0].Name()}, e.uris...)
+	for _, uri := range uris {
+		if slices.ContainsFunc(spaces, func(ns *NodeNameSpace) bool { return ns.Name() == uri }) {
+			continue
+		}
+		if m := e.srv.model(uri); m != nil {
+			required = append(required, &schema.ModelTableEntry{
+				ModelUriAttr:        m.ModelUriAttr,
+				VersionAttr:         m.VersionAttr,
+				PublicationDateAttr: m.PublicationDateAttr,
+			})
+		}
+	}
+
+	t := &schema.ModelTable{}
+	for _, ns := range spaces {
+		if ns.ID() == 0 {
+			continue
+		}
+		m := &schema.ModelTableEntry{ModelUriAttr: ns.Name()}
+		if have := e.srv.model(ns.Name()); have != nil {
+			m.VersionAttr = have.VersionAttr
+			m.PublicationDateAttr = have.PublicationDateAttr
+		}
+		m.RequiredModel = required
+		t.Model = append(t.Model, m)
+	}
+	if len(t.Model) == 0 {
+		return nil
+	}
+	return t
+}
+
+// addNode adds the node to the nodeset according to its node class.
+func (e *nodeSetExport) addNode(set *schema.UANodeSet, n *Node) {
+	un := e.uaNode(n)
+	switch n.NodeClass() {
+	case ua.NodeClassObject:
+		set.UAObject = append(set.UAObject, &schema.UAObject{
+			EventNotifierAttr: uint8(attrUint(n, ua.AttributeIDEventNotifier)),
+			UAInstance:        e.uaInstance(n, un),
+		})
+	case ua.NodeClassVariable:
+		v := &schema.UAVariable{
+			DataTypeAttr:                e.dataType(n),
+			ValueRankAttr:               e.valueRank(n),
+			ArrayDimensionsAttr:         arrayDimensions(n),
+			AccessLevelAttr:             uint32(attrUint(n, ua.AttributeIDAccessLevel)),
+			UserAccessLevelAttr:         uint32(attrUint(n, ua.AttributeIDUserAccessLevel)),
+			MinimumSamplingIntervalAttr: attrValue[float64](n, ua.AttributeIDMinimumSamplingInterval),
+			HistorizingAttr:             attrValue[bool](n, ua.AttributeIDHistorizing),
+			Value:                       e.value(n),
+			UAInstance:                  e.uaInstance(n, un),
+		}
+		set.UAVariable = append(set.UAVariable, v)
+	case ua.NodeClassMethod:
+		set.UAMethod = append(set.UAMethod, &schema.UAMethod{
+			ExecutableAttr:     attrValue[bool](n, ua.AttributeIDExecutable),
+			UserExecutableAttr: attrValue[bool](n, ua.AttributeIDUserExecutable),
+			UAInstance:         e.uaInstance(n, un),
+		})
+	case ua.NodeClassView:
+		set.UAView = append(set.UAView, &schema.UAView{
+			ContainsNoLoopsAttr: attrValue[bool](n, ua.AttributeIDContainsNoLoops),
+			EventNotifierAttr:   uint8(attrUint(n, ua.AttributeIDEventNotifier)),
+			UAInstance:          e.uaInstance(n, un),
+		})
+	case ua.NodeClassObjectType:
+		set.UAObjectType = append(set.UAObjectType, &schema.UAObjectType{
+			UAType: uaType(n, un),
+		})
+	case ua.NodeClassVariableType:
+		set.UAVariableType = append(set.UAVariableType, &schema.UAVariableType{
+			DataTypeAttr:        e.dataType(n),
+			ValueRankAttr:       e.valueRank(n),
+			ArrayDimensionsAttr: arrayDimensions(n),
+			Value:               e.value(n),
+			UAType:              uaType(n, un),
+		})
+	case ua.NodeClassDataType:
+		set.UADataType = append(set.UADataType, &schema.UADataType{
+			Definition: e.definition(n),
+			UAType:     uaType(n, un),
+		})
+	case ua.NodeClassReferenceType:
+		rt := &schema.UAReferenceType{
+			SymmetricAttr: attrValue[bool](n, ua.AttributeIDSymmetric),
+			UAType:        uaType(n, un),
+		}
+		if lt := attrValue[*ua.LocalizedText](n, ua.AttributeIDInverseName); lt != nil && lt.Text != "" {
+			rt.InverseName = []*schema.LocalizedText{{LocaleAttr: lt.Locale, Value: lt.Text}}
+		}
+		set.UAReferenceType = append(set.UAReferenceType, rt)
+	}
+}
+
+// uaNode returns the attributes and references which all node classes have.
+func (e *nodeSetExport) uaNode(n *Node) *schema.UANode {
+	un := &schema.UANode{
+		NodeIdAttr:        e.nodeID(n.ID()),
+		BrowseNameAttr:    e.browseName(n.BrowseName()),
+		WriteMaskAttr:     uint32(attrUint(n, ua.AttributeIDWriteMask)),
+		UserWriteMaskAttr: uint32(attrUint(n, ua.AttributeIDUserWriteMask)),
+	}
+	if lt := attrValue[*ua.LocalizedText](n, ua.AttributeIDDisplayName); lt != nil {
+		un.DisplayName = []*schema.LocalizedText{{LocaleAttr: lt.Locale, Value: lt.Text}}
+	}
+	if lt := attrValue[*ua.LocalizedText](n, ua.AttributeIDDescription); lt != nil && lt.Text != "" {
+		un.Description = []*schema.LocalizedText{{LocaleAttr: lt.Locale, Value: lt.Text}}
+	}
+
+	var refs []*schema.Reference
+	for _, ref := range n.refs {
+		if ref.NodeID == nil || ref.ReferenceTypeID == nil || e.mirrored(n, ref) {
+			continue
+		}
+		r := &schema.Reference{
+			ReferenceTypeAttr: e.alias(ref.ReferenceTypeID),
+			Value:             e.nodeID(ref.NodeID.NodeID),
+		}
+		if !ref.IsForward {
+			r.IsForwardAttr = new(bool)
+		}
+		refs = append(refs, r)
+	}
+	if len(refs) > 0 {
+		un.References = &schema.ListOfReferences{Reference: refs}
+	}
+	return un
+}
+
+// mirrored returns true if ref is an inverse reference and the exported
+// target node has the matching forward reference. The import adds the
+// inverse reference for every reference so it is only written once.
+func (e *nodeSetExport) mirrored(n *Node, ref *ua.ReferenceDescription) bool {
+	if ref.IsForward || !e.nodes[ref.NodeID.NodeID.String()] {
+		return false
+	}
+	target := e.srv.Node(ref.NodeID.NodeID)
+	if target == nil {
+		return false
+	}
+	return slices.ContainsFunc(target.refs, func(r *ua.ReferenceDescription) bool {
+		return r.IsForward && r.NodeID != nil && r.ReferenceTypeID != nil &&
+			r.NodeID.NodeID.Equal(n.ID()) && r.ReferenceTypeID.Equal(ref.ReferenceTypeID)
+	})
+}
+
+// uaInstance returns the node with the parent node id which is the
+// source of the inverse aggregating reference of the node.
+func (e *nodeSetExport) uaInstance(n *Node, un *schema.UANode) *schema.UAInstance {
+	inst := &schema.UAInstance{UANode: un}
+	aggregates := ua.NewNumericNodeID(0, id.Aggregates)
+	for _, ref := range n.refs {
+		if !ref.IsForward && ref.NodeID != nil && e.srv.IsSubtype(ref.ReferenceTypeID, aggregates) {
+			inst.ParentNodeIdAttr = e.nodeID(ref.NodeID.NodeID)
+			break
+		}
+	}
+	return inst
+}
+
+func uaType(n *Node, un *schema.UANode) *schema.UAType {
+	return &schema.UAType{
+		IsAbstractAttr: attrValue[bool](n, ua.AttributeIDIsAbstract),
+		UANode:         un,
+	}
+}
+
+// definition returns the DataTypeDefinition attribute of a data type or
+// nil if it has none.
+func (e *nodeSetExport) definition(n *Node) *schema.DataTypeDefinition {
+	eo := attrValue[*ua.ExtensionObject](n, ua.AttributeIDDataTypeDefinition)
+	if eo == nil {
+		return nil
+	}
+	def := &schema.DataTypeDefinition{NameAttr: e.browseName(n.BrowseName())}
+	text := func(lt *ua.LocalizedText) []*schema.LocalizedText {
+		if lt == nil || lt.Text == "" {
+			return nil
+		}
+		return []*schema.LocalizedText{{LocaleAttr: lt.Locale, Value: lt.Text}}
+	}
+	switch x := eo.Value.(type) {
+	case *ua.StructureDefinition:
+		def.IsUnionAttr = x.StructureType == ua.StructureTypeUnion || x.StructureType == ua.StructureTypeUnionWithSubtypedValues
+		subtyped := x.StructureType == ua.StructureTypeStructureWithSubtypedValues || x.StructureType == ua.StructureTypeUnionWithSubtypedValues
+		for _, f := range x.Fields {
+			df := &schema.DataTypeField{
+				NameAttr:            f.Name,
+				DataTypeAttr:        e.alias(f.DataType),
+				ValueRankAttr:       int(f.ValueRank),
+				MaxStringLengthAttr: f.MaxStringLength,
+				Description:         text(f.Description),
+			}
+			dims := make([]string, len(f.ArrayDimensions))
+			for i, d := range f.ArrayDimensions {
+				dims[i] = strconv.FormatUint(uint64(d), 10)
+			}
+			df.ArrayDimensionsAttr = strings.Join(dims, ",")
+			if subtyped {
+				df.AllowSubTypesAttr = f.IsOptional
+			} else {
+				df.IsOptionalAttr = f.IsOptional
+			}
+			def.Field = append(def.Field, df)
+		}
+	case *ua.EnumDefinition:
+		def.IsOptionSetAttr = !e.srv.IsSubtype(n.ID(), ua.NewNumericNodeID(0, id.Enumeration))
+		for _, f := range x.Fields {
+			def.Field = append(def.Field, &schema.DataTypeField{
+				NameAttr:    f.Name,
+				ValueAttr:   int(f.Value),
+				DisplayName: text(f.DisplayName),
+				Description: text(f.Description),
+			})
+		}
+	default:
+		return nil
+	}
+	return def
+}
+
+// dataType returns the DataType attribute of a variable or variable
+// type. Nodes whose DataType attribute does not refer to a data type
+// use the type of their value.
+func (e *nodeSetExport) dataType(n *Node) string {
+	var nid *ua.NodeID
+	switch x := attrValue[any](n, ua.AttributeIDDataType).(type) {
+	case *ua.NodeID:
+		nid = x
+	case *ua.ExpandedNodeID:
+		nid = x.NodeID
+	}
+	if dt := e.srv.Node(nid); dt != nil && dt.NodeClass() == ua.NodeClassDataType {
+		return e.alias(nid)
+	}
+	if v := n.Value(); v != nil && v.Value != nil && v.Value.Type() != ua.TypeIDNull {
+		return e.alias(ua.NewNumericNodeID(0, uint32(v.Value.Type())))
+	}
+	if nid != nil {
+		return e.alias(nid)
+	}
+	return ""
+}
+
+// valueRank returns the ValueRank attribute or the rank of the value.
+func (e *nodeSetExport) valueRank(n *Node) int {
+	if v, ok := n.attr[ua.AttributeIDValueRank]; ok && v != nil && v.Value != nil {
+		if r, ok := v.Value.Value().(int32); ok {
+			return int(r)
+		}
+	}
+	if v := n.Value(); v != nil && v.Value != nil && v.Value.ArrayLength() > 0 {
+		return max(len(v.Value.ArrayDimensions()), 1)
+	}
+	return -1
+}
+
+func arrayDimensions(n *Node) string {
+	dims := attrValue[[]uint32](n, ua.AttributeIDArrayDimensions)
+	s := make([]string, len(dims))
+	for i, d := range dims {
+		s[i] = strconv.FormatUint(uint64(d), 10)
+	}
+	return strings.Join(s, ",")
+}
+
+// value returns the current value of the node as NodeSet2 XML. Values
+// of types which cannot be written are skipped.
+func (e *nodeSetExport) value(n *Node) *schema.Value {
+	v := n.Value()
+	if v == nil || v.Value == nil || v.Value.Type() == ua.TypeIDNull {
+		return nil
+	}
+	if v.Value.ArrayLength() > 0 {
+		// multi-dimensional arrays are not supported
+		if len(v.Value.ArrayDimensions()) > 1 {
+			return nil
+		}
+		rv := reflect.ValueOf(v.Value.Value())
+		var items strings.Builder
+		for i := 0; i < rv.Len(); i++ {
+			item, ok := e.scalarXML(rv.Index(i).Interface())
+			if !ok {
+				return nil
+			}
+			items.WriteString(item)
+		}
+		name := "ListOf" + typeName(v.Value.Type())
+		return &schema.Value{InnerXML: fmt.Sprintf(`<%s xmlns="%s">%s</%s>`, name, schema.TypesNamespace, items.String(), name)}
+	}
+
+	s, ok := e.scalarXML(v.Value.Value())
+	if !ok {
+		return nil
+	}
+	// add the namespace to the outer element
+	name, rest, _ := strings.Cut(s[1:], ">")
+	return &schema.Value{InnerXML: fmt.Sprintf(`<%s xmlns="%s">%s`, name, schema.TypesNamespace, rest)}
+}
+
+// scalarXML returns the XML element of a value in the types namespace.
+func (e *nodeSetExport) scalarXML(v any) (string, bool) {
+	el := func(name, s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return fmt.Sprintf("<%s>%s</%s>", name, b.String(), name)
+	}
+	switch x := v.(type) {
+	case bool:
+		return el("Boolean", strconv.FormatBool(x)), true
+	case int8:
+		return el("SByte", strconv.FormatInt(int64(x), 10)), true
+	case uint8:
+		return el("Byte", strconv.FormatUint(uint64(x), 10)), true
+	case int16:
+		return el("Int16", strconv.FormatInt(int64(x), 10)), true
+	case uint16:
+		return el("UInt16", strconv.FormatUint(uint64(x), 10)), true
+	case int32:
+		return el("Int32", strconv.FormatInt(int64(x), 10)), true
+	case uint32:
+		return el("UInt32", strconv.FormatUint(uint64(x), 10)), true
+	case int64:
+		return el("Int64", strconv.FormatInt(x, 10)), true
+	case uint64:
+		return el("UInt64", strconv.FormatUint(x, 10)), true
+	case float32:
+		return el("Float", formatFloat(float64(x), 32)), true
+	case float64:
+		return el("Double", formatFloat(x, 64)), true
+	case string:
+		return el("String", x), true
+	case time.Time:
+		return el("DateTime", x.UTC().Format(time.RFC3339Nano)), true
+	case []byte:
+		return el("ByteString", base64.StdEncoding.EncodeToString(x)), true
+	case *ua.GUID:
+		return "<Guid>" + el("String", x.String()) + "</Guid>", true
+	case *ua.NodeID:
+		return "<NodeId>" + el("Identifier", e.nodeID(x)) + "</NodeId>", true
+	case *ua.ExpandedNodeID:
+		return "<ExpandedNodeId>" + el("Identifier", e.nodeID(x.NodeID)) + "</ExpandedNodeId>", true
+	case *ua.QualifiedName:
+		idx := 0
+		if x.NamespaceIndex != 0 {
+			idx = e.namespaceIndex(x.NamespaceIndex)
+		}
+		return "<QualifiedName>" + el("NamespaceIndex", strconv.Itoa(idx)) + el("Name", x.Name) + "</QualifiedName>", true
+	case *ua.LocalizedText:
+		return "<LocalizedText>" + el("Locale", x.Locale) + el("Text", x.Text) + "</LocalizedText>", true
+	default:
+		return "", false
+	}
+}
+
+// typeName returns the name of the built-in type in the types namespace.
+func typeName(t ua.TypeID) string {
+	switch t {
+	case ua.TypeIDGUID:
+		return "Guid"
+	case ua.TypeIDNodeID:
+		return "NodeId"
+	case ua.TypeIDExpandedNodeID:
+		return "ExpandedNodeId"
+	case ua.TypeIDSByte:
+		return "SByte"
+	case ua.TypeIDByte:
+		return "Byte"
+	case ua.TypeIDUint16:
+		return "UInt16"
+	case ua.TypeIDUint32:
+		return "UInt32"
+	case ua.TypeIDUint64:
+		return "UInt64"
+	}
+	return strings.TrimPrefix(t.String(), "TypeID")
+}
+
+func formatFloat(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "INF"
+	case math.IsInf(f, -1):
+		return "-INF"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bits)
+}
+
+// attrValue returns the value of the attribute if it has the type T
+// and the zero value otherwise.
+func attrValue[T any](n *Node, aid ua.AttributeID) T {
+	var zero T
+	v := n.attr[aid]
+	if v == nil || v.Value == nil {
+		return zero
+	}
+	x, ok := v.Value.Value().(T)
+	if !ok {
+		return zero
+	}
+	return x
+}
+
+// attrUint returns the value of a numeric attribute. Nodes which are
+// built in code store some attributes with different integer types.
+func attrUint(n *Node, aid ua.AttributeID) uint64 {
+	switch x := attrValue[any](n, aid).(type) {
+	case uint8:
+		return uint64(x)
+	case uint16:
+		return uint64(x)
+	case uint32:
+		return uint64(x)
+	case uint64:
+		return x
+	case int16:
+		return uint64(max(x, 0))
+	case int32:
+		return uint64(max(x, 0))
+	case int64:
+		return uint64(max(x, 0))
+	}
+	return 0
+}
@@ -1,43 +1,273 @@
 package server
 
 import (
+	"encoding/base64"
+	"encoding/xml"
 	"fmt"
 	"log"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gopcua/opcua/id"
 	"github.com/gopcua/opcua/schema"
 	"github.com/gopcua/opcua/ua"
 )
 
+// ImportNodeSet imports the nodes of a NodeSet2 file into the server.
+//
+// The namespace indices of the file are mapped onto the namespace array
+// of the server. Namespaces which already exist are reused and new
+// namespaces are added to the server. The models which are required by
+// the nodeset must have been imported before with at least the required
+// version and publication date.
+//
+// The values of variables are imported if they have a built-in type
+// other than ExtensionObject.
 func (srv *Server) ImportNodeSet(nodes *schema.UANodeSet) error {
-	err := srv.namespacesImportNodeSet(nodes)
+	srv.nodeSetMu.Lock()
+	defer srv.nodeSetMu.Unlock()
+
+	if err := srv.checkRequiredModels(nodes); err != nil {
+		return err
+	}
+	nsm, err := srv.namespacesImportNodeSet(nodes)
 	if err != nil {
 		return fmt.Errorf("problem creating namespaces: %w", err)
 	}
-	err = srv.nodesImportNodeSet(nodes)
+	err = srv.nodesImportNodeSet(nodes, nsm)
 	if err != nil {
 		return fmt.Errorf("problem creating nodes: %w", err)
 	}
-	srv.refsImportNodeSet(nodes)
+	err = srv.refsImportNodeSet(nodes, nsm)
 	if err != nil {
 		return fmt.Errorf("problem creating references: %w", err)
 	}
+	err = srv.definitionsImportNodeSet(nodes, nsm)
+	if err != nil {
+		return fmt.Errorf("problem creating data type definitions: %w", err)
+	}
+	srv.viewsImportNodeSet(nodes.UAView, nsm)
+
+	if nodes.Models != nil {
+		srv.mu.Lock()
+		for _, m := range nodes.Models.Model {
+			srv.models[m.ModelUriAttr] = m
+		}
+		srv.mu.Unlock()
+	}
 	return nil
 }
 
-func (srv *Server) namespacesImportNodeSet(nodes *schema.UANodeSet) error {
-	if nodes.NamespaceUris == nil {
+// ImportNodeSets imports several NodeSet2 files in the order of their
+// dependencies, i.e. a nodeset is imported after the nodesets which
+// contain the models it requires. It returns an error if a required
+// model is neither part of the nodesets nor has been imported before.
+func (srv *Server) ImportNodeSets(sets ...*schema.UANodeSet) error {
+	pending := slices.Clone(sets)
+	for len(pending) > 0 {
+		progress := false
+		for i := 0; i < len(pending); {
+			if !srv.requiredModelsLoaded(pending[i]) {
+				i++
+				continue
+			}
+			if err := srv.ImportNodeSet(pending[i]); err != nil {
+				return err
+			}
+			pending = slices.Delete(pending, i, i+1)
+			progress = true
+		}
+		if !progress {
+			// the remaining nodesets require models which are neither
+			// loaded nor part of the nodesets or depend on each other.
+			if err := srv.checkRequiredModels(pending[0]); err != nil {
+				return err
+			}
+			return fmt.Errorf("cannot resolve the required models of %d nodesets", len(pending))
+		}
+	}
+	return nil
+}
+
+// model returns the imported model with the uri or nil.
+func (srv *Server) model(uri string) *schema.ModelTableEntry {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.models[uri]
+}
+
+// requiredModelsLoaded returns true if all models which are required
+// by the nodeset have been imported.
+func (srv *Server) requiredModelsLoaded(nodes *schema.UANodeSet) bool {
+	if nodes.Models == nil {
+		return true
+	}
+	for _, m := range nodes.Models.Model {
+		for _, req := range m.RequiredModel {
+			if srv.model(req.ModelUriAttr) == nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkRequiredModels returns an error if a model which is required by
+// the nodeset has not been imported or is older than required.
+func (srv *Server) checkRequiredModels(nodes *schema.UANodeSet) error {
+	if nodes.Models == nil {
 		return nil
 	}
-	for i := range nodes.NamespaceUris.Uri {
-		_ = NewNodeNameSpace(srv, nodes.NamespaceUris.Uri[i])
+	for _, m := range nodes.Models.Model {
+		for _, req := range m.RequiredModel {
+			have := srv.model(req.ModelUriAttr)
+			if have == nil {
+				return fmt.Errorf("model %s requires model %s which has not been imported", m.ModelUriAttr, req.ModelUriAttr)
+			}
+			if compareVersions(have.VersionAttr, req.VersionAttr) < 0 {
+				return fmt.Errorf("model %s requires model %s version %s but version %s has been imported",
+					m.ModelUriAttr, req.ModelUriAttr, req.VersionAttr, have.VersionAttr)
+			}
+			if req.PublicationDateAttr == "" || have.PublicationDateAttr == "" {
+				continue
+			}
+			reqDate, err := time.Parse(time.RFC3339, req.PublicationDateAttr)
+			if err != nil {
+				return fmt.Errorf("model %s: invalid publication date of required model %s: %w", m.ModelUriAttr, req.ModelUriAttr, err)
+			}
+			haveDate, err := time.Parse(time.RFC3339, have.PublicationDateAttr)
+			if err != nil {
+				return fmt.Errorf("model %s: invalid publication date: %w", req.ModelUriAttr, err)
+			}
+			if haveDate.Before(reqDate) {
+				return fmt.Errorf("model %s requires model %s published on %s but the imported model was published on %s",
+					m.ModelUriAttr, req.ModelUriAttr, req.PublicationDateAttr, have.PublicationDateAttr)
+			}
+		}
+	}
+	return nil
+}
+
+// compareVersions compares two dotted version strings like "1.04.7"
+// numerically. Versions which are empty are considered equal to all
+// other versions.
+func compareVersions(a, b string) int {
+	if a == "" || b == "" {
+		return 0
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// namespaceMap maps the namespace indices of a nodeset file
+// onto the namespace indices of the server.
+type namespaceMap []uint16
+
+// parseNodeID parses a node id of the nodeset file and returns it with
+// the namespace index of the server. It returns an error if the node id
+// is invalid.
+func (m namespaceMap) parseNodeID(s string) (*ua.NodeID, error) {
+	if rest, ok := strings.CutPrefix(s, "ns="); ok {
+		idx, id, found := strings.Cut(rest, ";")
+		if n, err := strconv.Atoi(idx); found && err == nil && n < len(m) {
+			s = fmt.Sprintf("ns=%d;%s", m[n], id)
+		}
+	}
+	return ua.ParseNodeID(s)
+}
+
+// browseName parses a browse name of the nodeset file like "1:Name" and
+// returns it with the namespace index of the server.
+func (m namespaceMap) browseName(s string) *ua.QualifiedName {
+	idx, name, found := strings.Cut(s, ":")
+	if !found {
+		return &ua.QualifiedName{Name: s}
+	}
+	n, err := strconv.Atoi(idx)
+	if err != nil || n >= len(m) {
+		return &ua.QualifiedName{Name: s}
+	}
+	return &ua.QualifiedName{NamespaceIndex: m[n], Name: name}
+}
+
+// newNamespace is a namespace of a nodeset which does not exist yet and
+// the index it gets when it is created.
+type newNamespace struct {
+	uri string
+	idx uint16
+}
+
+// resolveNamespaces maps the namespace uris onto the namespaces of the
+// server without creating them. The namespaces which do not exist are
+// mapped onto the indexes they get when they are created in the returned
+// order.
+func (srv *Server) resolveNamespaces(uris *schema.UriTable) (namespaceMap, []newNamespace) {
+	// index 0 is always the OPC UA namespace
+	nsm := namespaceMap{0}
+	if uris == nil {
+		return nsm, nil
+	}
+	var names []string
+	for _, ns := range srv.Namespaces() {
+		names = append(names, ns.Name())
+	}
+	var missing []newNamespace
+	for _, uri := range uris.Uri {
+		idx := slices.Index(names, uri)
+		if idx < 0 {
+			idx = len(names)
+			names = append(names, uri)
+			missing = append(missing, newNamespace{uri: uri, idx: uint16(idx)})
+		}
+		nsm = append(nsm, uint16(idx))
+	}
+	return nsm, missing
+}
+
+// namespacesImportNodeSet maps the namespaces of the nodeset onto the
+// namespaces of the server and creates the namespaces which do not exist.
+func (srv *Server) namespacesImportNodeSet(nodes *schema.UANodeSet) (namespaceMap, error) {
+	nsm, missing := srv.resolveNamespaces(nodes.NamespaceUris)
+	if err := srv.createNamespaces(missing); err != nil {
+		return nil, err
+	}
+	return nsm, nil
+}
+
+// createNamespaces creates the namespaces which have been resolved by
+// resolveNamespaces. It returns an error if a namespace does not get the
+// index it has been mapped onto because another namespace has been added
+// in the meantime.
+func (srv *Server) createNamespaces(missing []newNamespace) error {
+	for _, m := range missing {
+		if idx := NewNodeNameSpace(srv, m.uri).ID(); idx != m.idx {
+			return fmt.Errorf("namespace %s has index %d instead of %d", m.uri, idx, m.idx)
+		}
 	}
 	return nil
 }
 
-func (srv *Server) nodesImportNodeSet(nodes *schema.UANodeSet) error {
+func (srv *Server) nodesImportNodeSet(nodes *schema.UANodeSet, nsm namespaceMap) error {
 
 	log.Printf("New Node Set: %s", nodes.LastModifiedAttr)
 
+	aliases := nodeSetAliases(nodes)
+
 	reftypes := make(map[string]*schema.UAReferenceType)
 
 	// the first thing we have to do is go thorugh and define all the nodes.
@@ -47,11 +277,14 @@ func (srv *Server) nodesImportNodeSet(nodes *schema.UANodeSet) error {
 		reftypes[rt.BrowseNameAttr] = rt // sometimes they use browse name
 		reftypes[rt.NodeIdAttr] = rt     // sometimes they use node id
 
-		nid := ua.MustParseNodeID(rt.NodeIdAttr)
+		nid, err := nsm.parseNodeID(rt.NodeIdAttr)
+		if err != nil {
+			return fmt.Errorf("invalid node id %s: %w", rt.NodeIdAttr, err)
+		}
 
 		var attrs Attributes = make(map[ua.AttributeID]*ua.DataValue)
 		attrs[ua.AttributeIDAccessRestrictions] = DataValueFromValue(rt.AccessRestrictionsAttr)
-		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(&ua.QualifiedName{NamespaceIndex: nid.Namespace(), Name: rt.BrowseNameAttr})
+		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(nsm.browseName(rt.BrowseNameAttr))
 		attrs[ua.AttributeIDIsAbstract] = DataValueFromValue(rt.IsAbstractAttr)
 		attrs[ua.AttributeIDUserWriteMask] = DataValueFromValue(rt.UserWriteMaskAttr)
 		attrs[ua.AttributeIDSymmetric] = DataValueFromValue(rt.SymmetricAttr)
@@ -86,11 +319,14 @@ func (srv *Server) nodesImportNodeSet(nodes *schema.UANodeSet) error {
 	// set up the data types.
 	for i := range nodes.UADataType {
 		dt := nodes.UADataType[i]
-		nid := ua.MustParseNodeID(dt.NodeIdAttr)
+		nid, err := nsm.parseNodeID(dt.NodeIdAttr)
+		if err != nil {
+			return fmt.Errorf("invalid node id %s: %w", dt.NodeIdAttr, err)
+		}
 
 		var attrs Attributes = make(map[ua.AttributeID]*ua.DataValue)
 		attrs[ua.AttributeIDAccessRestrictions] = DataValueFromValue(dt.AccessRestrictionsAttr)
-		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(&ua.QualifiedName{NamespaceIndex: nid.Namespace(), Name: dt.BrowseNameAttr})
+		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(nsm.browseName(dt.BrowseNameAttr))
 		attrs[ua.AttributeIDIsAbstract] = DataValueFromValue(dt.IsAbstractAttr)
 		attrs[ua.AttributeIDUserWriteMask] = DataValueFromValue(dt.UserWriteMaskAttr)
 		attrs[ua.AttributeIDWriteMask] = DataValueFromValue(dt.WriteMaskAttr)
@@ -120,10 +356,13 @@ func (srv *Server) nodesImportNodeSet(nodes *schema.UANodeSet) error {
 	// set up the object types
 	for i := range nodes.UAObjectType {
 		ot := nodes.UAObjectType[i]
-		nid := ua.MustParseNodeID(ot.NodeIdAttr)
+		nid, err := nsm.parseNodeID(ot.NodeIdAttr)
+		if err != nil {
+			return fmt.Errorf("invalid node id %s: %w", ot.NodeIdAttr, err)
+		}
 		var attrs Attributes = make(map[ua.AttributeID]*ua.DataValue)
 		attrs[ua.AttributeIDAccessRestrictions] = DataValueFromValue(ot.AccessRestrictionsAttr)
-		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(&ua.QualifiedName{NamespaceIndex: nid.Namespace(), Name: ot.BrowseNameAttr})
+		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(nsm.browseName(ot.BrowseNameAttr))
 		attrs[ua.AttributeIDIsAbstract] = DataValueFromValue(ot.IsAbstractAttr)
 		attrs[ua.AttributeIDUserWriteMask] = DataValueFromValue(ot.UserWriteMaskAttr)
 		attrs[ua.AttributeIDWriteMask] = DataValueFromValue(ot.WriteMaskAttr)
@@ -152,10 +391,13 @@ func (srv *Server) nodesImportNodeSet(nodes *schema.UANodeSet) error {
 	// set up the variable Types
 	for i := range nodes.UAVariableType {
 		ot := nodes.UAVariableType[i]
-		nid := ua.MustParseNodeID(ot.NodeIdAttr)
+		nid, err := nsm.parseNodeID(ot.NodeIdAttr)
+		if err != nil {
+			return fmt.Errorf("invalid node id %s: %w", ot.NodeIdAttr, err)
+		}
 		var attrs Attributes = make(map[ua.AttributeID]*ua.DataValue)
 		attrs[ua.AttributeIDAccessRestrictions] = DataValueFromValue(ot.AccessRestrictionsAttr)
-		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(&ua.QualifiedName{NamespaceIndex: nid.Namespace(), Name: ot.BrowseNameAttr})
+		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(nsm.browseName(ot.BrowseNameAttr))
 		attrs[ua.AttributeIDUserWriteMask] = DataValueFromValue(ot.UserWriteMaskAttr)
 		attrs[ua.AttributeIDWriteMask] = DataValueFromValue(ot.WriteMaskAttr)
 		if len(ot.DisplayName) > 0 {
@@ -165,6 +407,10 @@ func (srv *Server) nodesImportNodeSet(nodes *schema.UANodeSet) error {
 			attrs[ua.AttributeIDDescription] = DataValueFromValue(ua.NewLocalizedText(ot.Description[0].Value))
 		}
 		attrs[ua.AttributeIDNodeClass] = DataValueFromValue(uint32(ua.NodeClassVariableType))
+		attrs[ua.AttributeIDIsAbstract] = DataValueFromValue(ot.IsAbstractAttr)
+		if err := nsm.variableAttributes(attrs, aliases, ot.DataTypeAttr, ot.ValueRankAttr, ot.ArrayDimensionsAttr); err != nil {
+			return fmt.Errorf("variable type %s: %w", ot.NodeIdAttr, err)
+		}
 
 		var refs References = make([]*ua.ReferenceDescription, 0)
 
@@ -183,10 +429,13 @@ func (srv *Server) nodesImportNodeSet(nodes *schema.UANodeSet) error {
 	// set up the variables
 	for i := range nodes.UAVariable {
 		ot := nodes.UAVariable[i]
-		nid := ua.MustParseNodeID(ot.NodeIdAttr)
+		nid, err := nsm.parseNodeID(ot.NodeIdAttr)
+		if err != nil {
+			return fmt.Errorf("invalid node id %s: %w", ot.NodeIdAttr, err)
+		}
 		var attrs Attributes = make(map[ua.AttributeID]*ua.DataValue)
 		attrs[ua.AttributeIDAccessRestrictions] = DataValueFromValue(ot.AccessRestrictionsAttr)
-		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(&ua.QualifiedName{NamespaceIndex: nid.Namespace(), Name: ot.BrowseNameAttr})
+		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(nsm.browseName(ot.BrowseNameAttr))
 		attrs[ua.AttributeIDUserWriteMask] = DataValueFromValue(ot.UserWriteMaskAttr)
 		attrs[ua.AttributeIDWriteMask] = DataValueFromValue(ot.WriteMaskAttr)
 		if len(ot.DisplayName) > 0 {
@@ -196,10 +445,29 @@ func (srv *Server) nodesImportNodeSet(nodes *schema.UANodeSet) error {
 			attrs[ua.AttributeIDDescription] = DataValueFromValue(ua.NewLocalizedText(ot.Description[0].Value))
 		}
 		attrs[ua.AttributeIDNodeClass] = DataValueFromValue(uint32(ua.NodeClassVariable))
+		if err := nsm.variableAttributes(attrs, aliases, ot.DataTypeAttr, ot.ValueRankAttr, ot.ArrayDimensionsAttr); err != nil {
+			return fmt.Errorf("variable %s: %w", ot.NodeIdAttr, err)
+		}
+		attrs[ua.AttributeIDAccessLevel] = DataValueFromValue(accessLevel(ot.AccessLevelAttr))
+		attrs[ua.AttributeIDUserAccessLevel] = DataValueFromValue(accessLevel(ot.UserAccessLevelAttr))
+		attrs[ua.AttributeIDMinimumSamplingInterval] = DataValueFromValue(ot.MinimumSamplingIntervalAttr)
+		attrs[ua.AttributeIDHistorizing] = DataValueFromValue(ot.HistorizingAttr)
+
+		var val ValueFunc
+		if ot.Value != nil {
+			v, err := nsm.value(ot.Value)
+			if err != nil {
+				return fmt.Errorf("variable %s: invalid value: %w", ot.NodeIdAttr, err)
+			}
+			if v != nil {
+				dv := DataValueFromValue(v)
+				val = func() *ua.DataValue { return dv }
+			}
+		}
 
 		var refs References = make([]*ua.ReferenceDescription, 0)
 
-		n := NewNode(nid, attrs, refs, nil)
+		n := NewNode(nid, attrs, refs, val)
 		ns, err := srv.Namespace(int(nid.Namespace()))
 		if err != nil {
 			// This namespace doesn't exist.
@@ -214,10 +482,13 @@ func (srv *Server) nodesImportNodeSet(nodes *schema.UANodeSet) error {
 	// set up the methods
 	for i := range nodes.UAMethod {
 		ot := nodes.UAMethod[i]
-		nid := ua.MustParseNodeID(ot.NodeIdAttr)
+		nid, err := nsm.parseNodeID(ot.NodeIdAttr)
+		if err != nil {
+			return fmt.Errorf("invalid node id %s: %w", ot.NodeIdAttr, err)
+		}
 		var attrs Attributes = make(map[ua.AttributeID]*ua.DataValue)
 		attrs[ua.AttributeIDAccessRestrictions] = DataValueFromValue(ot.AccessRestrictionsAttr)
-		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(&ua.QualifiedName{NamespaceIndex: nid.Namespace(), Name: ot.BrowseNameAttr})
+		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(nsm.browseName(ot.BrowseNameAttr))
 		attrs[ua.AttributeIDUserWriteMask] = DataValueFromValue(ot.UserWriteMaskAttr)
 		attrs[ua.AttributeIDWriteMask] = DataValueFromValue(ot.WriteMaskAttr)
 		if len(ot.DisplayName) > 0 {
@@ -245,13 +516,16 @@ func (srv *Server) nodesImportNodeSet(nodes *schema.UANodeSet) error {
 	// set up the objects
 	for i := range nodes.UAObject {
 		ot := nodes.UAObject[i]
-		nid := ua.MustParseNodeID(ot.NodeIdAttr)
+		nid, err := nsm.parseNodeID(ot.NodeIdAttr)
+		if err != nil {
+			return fmt.Errorf("invalid node id %s: %w", ot.NodeIdAttr, err)
+		}
 		if ot.NodeIdAttr == "i=85" {
 			log.Printf("doing objects.")
 		}
 		var attrs Attributes = make(map[ua.AttributeID]*ua.DataValue)
 		attrs[ua.AttributeIDAccessRestrictions] = DataValueFromValue(ot.AccessRestrictionsAttr)
-		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(&ua.QualifiedName{NamespaceIndex: nid.Namespace(), Name: ot.BrowseNameAttr})
+		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(nsm.browseName(ot.BrowseNameAttr))
 		attrs[ua.AttributeIDUserWriteMask] = DataValueFromValue(ot.UserWriteMaskAttr)
 		attrs[ua.AttributeIDWriteMask] = DataValueFromValue(ot.WriteMaskAttr)
 		if len(ot.DisplayName) > 0 {
@@ -277,13 +551,251 @@ func (srv *Server) nodesImportNodeSet(nodes *schema.UANodeSet) error {
 		ns.AddNode(n)
 	}
 
+	// set up the views
+	for i := range nodes.UAView {
+		ot := nodes.UAView[i]
+		nid, err := nsm.parseNodeID(ot.NodeIdAttr)
+		if err != nil {
+			return fmt.Errorf("invalid node id %s: %w", ot.NodeIdAttr, err)
+		}
+		var attrs Attributes = make(map[ua.AttributeID]*ua.DataValue)
+		attrs[ua.AttributeIDAccessRestrictions] = DataValueFromValue(ot.AccessRestrictionsAttr)
+		attrs[ua.AttributeIDBrowseName] = DataValueFromValue(nsm.browseName(ot.BrowseNameAttr))
+		attrs[ua.AttributeIDUserWriteMask] = DataValueFromValue(ot.UserWriteMaskAttr)
+		attrs[ua.AttributeIDWriteMask] = DataValueFromValue(ot.WriteMaskAttr)
+		attrs[ua.AttributeIDContainsNoLoops] = DataValueFromValue(ot.ContainsNoLoopsAttr)
+		attrs[ua.AttributeIDEventNotifier] = DataValueFromValue(ot.EventNotifierAttr)
+		if len(ot.DisplayName) > 0 {
+			attrs[ua.AttributeIDDisplayName] = DataValueFromValue(ua.NewLocalizedText(ot.DisplayName[0].Value))
+		}
+		if len(ot.Description) > 0 {
+			attrs[ua.AttributeIDDescription] = DataValueFromValue(ua.NewLocalizedText(ot.Description[0].Value))
+		}
+		attrs[ua.AttributeIDNodeClass] = DataValueFromValue(uint32(ua.NodeClassView))
+
+		var refs References = make([]*ua.ReferenceDescription, 0)
+
+		n := NewNode(nid, attrs, refs, nil)
+		ns, err := srv.Namespace(int(nid.Namespace()))
+		if err != nil {
+			// This namespace doesn't exist.
+			if srv.cfg.logger != nil {
+				srv.cfg.logger.Warn("Could Not Find Namespace %d", nid.Namespace())
+			}
+			return err
+		}
+		ns.AddNode(n)
+	}
+
 	return nil
 }
-func (srv *Server) refsImportNodeSet(nodes *schema.UANodeSet) error {
+
+// refsImportNodeSet adds the references of the nodes in the nodeset.
+func (srv *Server) refsImportNodeSet(nodes *schema.UANodeSet, nsm namespaceMap) error {
 
 	log.Printf("New Node Set: %s", nodes.LastModifiedAttr)
 
-	failures := 0
+	imp := srv.newRefImport(nodes, nsm)
+
+	// the first thing we have to do is go thorugh and define all the nodes.
+	// set up the reference types.
+	for _, rt := range nodes.UAReferenceType {
+		imp.addRefs(rt.NodeIdAttr, rt.BrowseNameAttr, rt.References)
+	}
+
+	// set up the data types.
+	for _, dt := range nodes.UADataType {
+		if dt.NodeIdAttr == "i=24" {
+			log.Printf("doing BaseDataType")
+		}
+		imp.addRefs(dt.NodeIdAttr, dt.BrowseNameAttr, dt.References)
+	}
+
+	// set up the object types
+	for _, ot := range nodes.UAObjectType {
+		imp.addRefs(ot.NodeIdAttr, ot.BrowseNameAttr, ot.References)
+	}
+
+	// set up the variable Types
+	for _, ot := range nodes.UAVariableType {
+		imp.addRefs(ot.NodeIdAttr, ot.BrowseNameAttr, ot.References)
+	}
+
+	// set up the variables
+	for _, ot := range nodes.UAVariable {
+		imp.addRefs(ot.NodeIdAttr, ot.BrowseNameAttr, ot.References)
+	}
+
+	// set up the methods
+	for _, ot := range nodes.UAMethod {
+		imp.addRefs(ot.NodeIdAttr, ot.BrowseNameAttr, ot.References)
+	}
+
+	// set up the objects
+	for _, ot := range nodes.UAObject {
+		if ot.NodeIdAttr == "i=84" {
+			log.Printf("doing root.")
+		}
+		imp.addRefs(ot.NodeIdAttr, ot.BrowseNameAttr, ot.References)
+	}
+
+	// set up the views
+	for _, ot := range nodes.UAView {
+		imp.addRefs(ot.NodeIdAttr, ot.BrowseNameAttr, ot.References)
+	}
+
+	if imp.failures > 0 && srv.cfg.logger != nil {
+		srv.cfg.logger.Warn("%d references could not be imported", imp.failures)
+	}
+	return nil
+}
+
+// definitionsImportNodeSet sets the DataTypeDefinition attribute of the
+// data types with a definition and adds the descriptors of the structures
+// to the server so that their values can be decoded as
+// ua.DynamicStructure. It runs after the references have been imported
+// since the encodings and the base types are found by their references.
+func (srv *Server) definitionsImportNodeSet(nodes *schema.UANodeSet, nsm namespaceMap) error {
+	aliases := nodeSetAliases(nodes)
+	dataType := func(s string) (*ua.NodeID, error) {
+		return nsm.dataType(aliases, s)
+	}
+
+	var added []*ua.StructureDescriptor
+	for _, dt := range nodes.UADataType {
+		if dt.Definition == nil {
+			continue
+		}
+		nid, err := nsm.parseNodeID(dt.NodeIdAttr)
+		if err != nil {
+			return fmt.Errorf("invalid node id %s: %w", dt.NodeIdAttr, err)
+		}
+		n := srv.Node(nid)
+		if n == nil {
+			return fmt.Errorf("data type %s not found", dt.NodeIdAttr)
+		}
+
+		if dt.Definition.IsOptionSetAttr || srv.IsSubtype(nid, ua.NewNumericNodeID(0, id.Enumeration)) {
+			def := &ua.EnumDefinition{}
+			for _, f := range dt.Definition.Field {
+				ef := &ua.EnumField{
+					Name:        f.NameAttr,
+					Value:       int64(f.ValueAttr),
+					DisplayName: ua.NewLocalizedText(f.NameAttr),
+					Description: ua.NewLocalizedText(""),
+				}
+				if len(f.DisplayName) > 0 {
+					ef.DisplayName = ua.NewLocalizedText(f.DisplayName[0].Value)
+				}
+				if len(f.Description) > 0 {
+					ef.Description = ua.NewLocalizedText(f.Description[0].Value)
+				}
+				def.Fields = append(def.Fields, ef)
+			}
+			n.SetAttribute(ua.AttributeIDDataTypeDefinition, DataValueFromValue(ua.NewExtensionObject(def)))
+			continue
+		}
+
+		enc := defaultBinaryEncoding(n)
+		def := &ua.StructureDefinition{
+			DefaultEncodingID: enc,
+			BaseDataType:      srv.superType(nid),
+			StructureType:     ua.StructureTypeStructure,
+		}
+		if enc == nil {
+			// abstract structures have no encoding
+			def.DefaultEncodingID = ua.NewTwoByteNodeID(0)
+		}
+		if def.BaseDataType == nil {
+			def.BaseDataType = ua.NewNumericNodeID(0, id.Structure)
+		}
+		var optional, subtyped bool
+		for _, f := range dt.Definition.Field {
+			t, err := dataType(f.DataTypeAttr)
+			if err != nil {
+				return fmt.Errorf("field %s of %s: %w", f.NameAttr, dt.BrowseNameAttr, err)
+			}
+			sf := &ua.StructureField{
+				Name:            f.NameAttr,
+				Description:     ua.NewLocalizedText(""),
+				DataType:        t,
+				ValueRank:       int32(f.ValueRankAttr),
+				MaxStringLength: f.MaxStringLengthAttr,
+				IsOptional:      f.IsOptionalAttr || f.AllowSubTypesAttr,
+			}
+			// the default value rank is -1 but an absent attribute
+			// cannot be told apart from 0.
+			if sf.ValueRank == 0 {
+				sf.ValueRank = -1
+			}
+			if len(f.Description) > 0 {
+				sf.Description = ua.NewLocalizedText(f.Description[0].Value)
+			}
+			for _, d := range strings.Split(f.ArrayDimensionsAttr, ",") {
+				if x, err := strconv.ParseUint(strings.TrimSpace(d), 10, 32); err == nil {
+					sf.ArrayDimensions = append(sf.ArrayDimensions, uint32(x))
+				}
+			}
+			optional = optional || f.IsOptionalAttr
+			subtyped = subtyped || f.AllowSubTypesAttr
+			def.Fields = append(def.Fields, sf)
+		}
+		switch {
+		case dt.Definition.IsUnionAttr && subtyped:
+			def.StructureType = ua.StructureTypeUnionWithSubtypedValues
+		case dt.Definition.IsUnionAttr:
+			def.StructureType = ua.StructureTypeUnion
+		case subtyped:
+			def.StructureType = ua.StructureTypeStructureWithSubtypedValues
+		case optional:
+			def.StructureType = ua.StructureTypeStructureWithOptionalFields
+		}
+		n.SetAttribute(ua.AttributeIDDataTypeDefinition, DataValueFromValue(ua.NewExtensionObject(def)))
+
+		if enc != nil {
+			d := ua.NewStructureDescriptor(n.BrowseName().Name, nid, def, srv.builtinType)
+			srv.addStructure(d)
+			added = append(added, d)
+		}
+	}
+
+	// structures which are encoded in place are resolved once all
+	// structures of the nodeset have been added since they can refer
+	// to each other.
+	for _, d := range added {
+		for _, f := range d.Fields {
+			if f.BuiltinType == ua.TypeIDNull && f.Structure == nil {
+				f.Structure = srv.StructureByDataTypeID(f.DataType)
+			}
+		}
+	}
+	return nil
+}
+
+// defaultBinaryEncoding returns the id of the Default Binary encoding of
+// the data type or nil.
+func defaultBinaryEncoding(n *Node) *ua.NodeID {
+	for _, r := range n.refs {
+		if r.IsForward && r.ReferenceTypeID.IntID() == id.HasEncoding && r.NodeID != nil &&
+			r.BrowseName != nil && r.BrowseName.Name == "Default Binary" {
+			return r.NodeID.NodeID
+		}
+	}
+	return nil
+}
+
+// refImport adds the references of the nodes of a nodeset.
+type refImport struct {
+	srv      *Server
+	nsm      namespaceMap
+	reftypes map[string]*schema.UAReferenceType
+	aliases  *schema.AliasTable
+	failures int
+}
+
+// newRefImport returns a refImport which resolves the reference types
+// of the nodeset and its aliases.
+func (srv *Server) newRefImport(nodes *schema.UANodeSet, nsm namespaceMap) *refImport {
 	reftypes := make(map[string]*schema.UAReferenceType)
 	for i := range nodes.UAReferenceType {
 		rt := nodes.UAReferenceType[i]
@@ -291,16 +803,18 @@ func (srv *Server) refsImportNodeSet(nodes *schema.UANodeSet) error {
 		reftypes[rt.NodeIdAttr] = rt     // sometimes they use node id
 	}
 
-	aliases := make(map[string]string)
-	for i := range nodes.Aliases.Alias {
-		alias := nodes.Aliases.Alias[i]
-		aliases[alias.AliasAttr] = alias.Value
-	}
+	aliases := nodeSetAliases(nodes)
 
 	// any of the aliases could be reference types, so we have to check them all and add them to the reftypes map
 	// if they are.
 	for alias := range aliases {
-		aliasID := ua.MustParseNodeID(aliases[alias])
+		aliasID, err := nsm.parseNodeID(aliases[alias])
+		if err != nil {
+			if srv.cfg.logger != nil {
+				srv.cfg.logger.Warn("invalid alias %s: %s", alias, err)
+			}
+			continue
+		}
 		refnode := srv.Node(aliasID)
 		if refnode == nil {
 			if srv.cfg.logger != nil {
@@ -340,219 +854,309 @@ func (srv *Server) refsImportNodeSet(nodes *schema.UANodeSet) error {
 
 	}
 
-	// the first thing we have to do is go thorugh and define all the nodes.
-	// set up the reference types.
-	for i := range nodes.UAReferenceType {
-		rt := nodes.UAReferenceType[i]
-
-		nodeid := ua.MustParseNodeID(rt.NodeIdAttr)
-		node := srv.Node(nodeid)
-		if node == nil {
-			log.Printf("Error loading node %s", rt.NodeIdAttr)
-		}
-
-		for rid := range rt.References.Reference {
-			ref := rt.References.Reference[rid]
-			refnodeid := ua.MustParseNodeID(ref.Value)
-			n := srv.Node(refnodeid)
-			if n == nil {
-				log.Printf("can't find node %s as %s reference to %s", ref.Value, ref.ReferenceTypeAttr, rt.BrowseNameAttr)
-				failures++
-				continue
-			}
+	return &refImport{srv: srv, nsm: nsm, reftypes: reftypes, aliases: nodes.Aliases}
+}
 
-			if ref.IsForwardAttr == nil {
-				v := true
-				ref.IsForwardAttr = &v
-			}
-			reftypeid := ua.MustParseNodeID(reftypes[ref.ReferenceTypeAttr].NodeIdAttr)
-			node.AddRef(n, RefType(reftypeid.IntID()), *ref.IsForwardAttr)
-			if !reftypes[ref.ReferenceTypeAttr].SymmetricAttr {
-				n.AddRef(node, RefType(reftypeid.IntID()), !*ref.IsForwardAttr)
-			}
+// refType returns the node id of the reference type and whether it is
+// symmetric. The reference type is either an alias, the browse name of
+// a reference type in the nodeset or the node id of a reference type
+// which has already been imported.
+func (imp *refImport) refType(name string) (*ua.NodeID, bool, bool) {
+	if rt, ok := imp.reftypes[name]; ok {
+		nid, err := imp.nsm.parseNodeID(rt.NodeIdAttr)
+		if err != nil {
+			return nil, false, false
 		}
-
+		return nid, rt.SymmetricAttr, true
+	}
+	nid, err := imp.nsm.parseNodeID(name)
+	if err != nil {
+		return nil, false, false
+	}
+	n := imp.srv.Node(nid)
+	if n == nil {
+		return nil, false, false
+	}
+	var symmetric bool
+	if v, err := n.Attribute(ua.AttributeIDSymmetric); err == nil {
+		symmetric, _ = v.Value.Value.Value().(bool)
 	}
+	return nid, symmetric, true
+}
 
-	// set up the data types.
-	for i := range nodes.UADataType {
-		dt := nodes.UADataType[i]
-		nid := ua.MustParseNodeID(dt.NodeIdAttr)
-		node := srv.Node(nid)
+// addRefs adds the references to the node with the id and the inverse
+// references to the target nodes.
+func (imp *refImport) addRefs(nodeID, browseName string, refs *schema.ListOfReferences) {
+	if refs == nil {
+		return
+	}
+	nid, err := imp.nsm.parseNodeID(nodeID)
+	if err != nil {
+		log.Printf("invalid node id %s: %s", nodeID, err)
+		imp.failures++
+		return
+	}
+	node := imp.srv.Node(nid)
+	if node == nil {
+		log.Printf("Error loading node %s", nodeID)
+		imp.failures++
+		return
+	}
 
-		if nid.IntID() == 24 {
-			log.Printf("doing BaseDataType")
+	for _, ref := range refs.Reference {
+		target, err := imp.nsm.parseNodeID(ref.Value)
+		if err != nil {
+			log.Printf("invalid node id %s of %s reference to %s: %s", ref.Value, ref.ReferenceTypeAttr, browseName, err)
+			imp.failures++
+			continue
+		}
+		n := imp.srv.Node(target)
+		if n == nil {
+			log.Printf("can't find node %s as %s reference to %s", ref.Value, ref.ReferenceTypeAttr, browseName)
+			imp.failures++
+			continue
+		}
+		reftypeid, symmetric, ok := imp.refType(ref.ReferenceTypeAttr)
+		if !ok {
+			log.Printf("can't find reference type %s of reference to %s", ref.ReferenceTypeAttr, browseName)
+			imp.failures++
+			continue
 		}
 
-		for rid := range dt.References.Reference {
-			ref := dt.References.Reference[rid]
-			refnodeid := ua.MustParseNodeID(ref.Value)
-			n := srv.Node(refnodeid)
-			if n == nil {
-				log.Printf("can't find node %s as %s reference to %s", ref.Value, ref.ReferenceTypeAttr, dt.BrowseNameAttr)
-				failures++
-				continue
-			}
-
-			if ref.IsForwardAttr == nil {
-				v := true
-				ref.IsForwardAttr = &v
-			}
+		if ref.IsForwardAttr == nil {
+			v := true
+			ref.IsForwardAttr = &v
+		}
+		addRef(node, n, reftypeid, symmetric, *ref.IsForwardAttr)
+	}
+}
 
-			reftypeid := ua.MustParseNodeID(reftypes[ref.ReferenceTypeAttr].NodeIdAttr)
-			node.AddRef(n, RefType(reftypeid.IntID()), *ref.IsForwardAttr)
-			if !reftypes[ref.ReferenceTypeAttr].SymmetricAttr {
-				n.AddRef(node, RefType(reftypeid.IntID()), !*ref.IsForwardAttr)
-			}
+// addRef adds the reference from node to target and the inverse
+// reference unless the reference type is symmetric.
+func addRef(node, target *Node, reftypeid *ua.NodeID, symmetric, forward bool) {
+	node.addReference(target, reftypeid, forward)
+	if !symmetric {
+		target.addReference(node, reftypeid, !forward)
+	}
+}
 
+// nodeSetAliases returns the node ids of the aliases of the nodeset.
+func nodeSetAliases(nodes *schema.UANodeSet) map[string]string {
+	aliases := make(map[string]string)
+	if nodes.Aliases != nil {
+		for _, alias := range nodes.Aliases.Alias {
+			aliases[alias.AliasAttr] = alias.Value
 		}
+	}
+	return aliases
+}
 
+// dataType parses a DataType attribute of the nodeset which is either a
+// node id or an alias. An empty attribute is the default BaseDataType.
+func (m namespaceMap) dataType(aliases map[string]string, s string) (*ua.NodeID, error) {
+	if s == "" {
+		return ua.NewNumericNodeID(0, id.BaseDataType), nil
 	}
+	if a, ok := aliases[s]; ok {
+		s = a
+	}
+	return m.parseNodeID(s)
+}
 
-	// set up the object types
-	for i := range nodes.UAObjectType {
-		ot := nodes.UAObjectType[i]
-		nid := ua.MustParseNodeID(ot.NodeIdAttr)
-		node := srv.Node(nid)
-
-		for rid := range ot.References.Reference {
-			ref := ot.References.Reference[rid]
-			refnodeid := ua.MustParseNodeID(ref.Value)
-			n := srv.Node(refnodeid)
-			if n == nil {
-				log.Printf("can't find node %s as %s reference to %s", ref.Value, ref.ReferenceTypeAttr, ot.BrowseNameAttr)
-				failures++
-				continue
-			}
-			if ref.IsForwardAttr == nil {
-				v := true
-				ref.IsForwardAttr = &v
-			}
-			reftypeid := ua.MustParseNodeID(reftypes[ref.ReferenceTypeAttr].NodeIdAttr)
-			node.AddRef(n, RefType(reftypeid.IntID()), *ref.IsForwardAttr)
-			if !reftypes[ref.ReferenceTypeAttr].SymmetricAttr {
-				n.AddRef(node, RefType(reftypeid.IntID()), !*ref.IsForwardAttr)
-			}
-		}
+// variableAttributes adds the DataType, ValueRank and ArrayDimensions
+// attributes of a variable or a variable type to attrs. Like for the
+// fields of structures an absent ValueRank cannot be told apart from 0
+// and is set to the default -1.
+func (m namespaceMap) variableAttributes(attrs Attributes, aliases map[string]string, dataType string, valueRank int, dims string) error {
+	dt, err := m.dataType(aliases, dataType)
+	if err != nil {
+		return fmt.Errorf("invalid data type %s: %w", dataType, err)
 	}
+	attrs[ua.AttributeIDDataType] = DataValueFromValue(ua.NewExpandedNodeID(dt, "", 0))
 
-	// set up the variable Types
-	for i := range nodes.UAVariableType {
-		ot := nodes.UAVariableType[i]
-		nid := ua.MustParseNodeID(ot.NodeIdAttr)
-		node := srv.Node(nid)
-
-		for rid := range ot.References.Reference {
-			ref := ot.References.Reference[rid]
-			refnodeid := ua.MustParseNodeID(ref.Value)
-			n := srv.Node(refnodeid)
-			if n == nil {
-				log.Printf("can't find node %s as %s reference to %s", ref.Value, ref.ReferenceTypeAttr, ot.BrowseNameAttr)
-				failures++
-				continue
-			}
-			if ref.IsForwardAttr == nil {
-				v := true
-				ref.IsForwardAttr = &v
-			}
-			reftypeid := ua.MustParseNodeID(reftypes[ref.ReferenceTypeAttr].NodeIdAttr)
-			node.AddRef(n, RefType(reftypeid.IntID()), *ref.IsForwardAttr)
-			if !reftypes[ref.ReferenceTypeAttr].SymmetricAttr {
-				n.AddRef(node, RefType(reftypeid.IntID()), !*ref.IsForwardAttr)
-			}
+	rank := int32(valueRank)
+	if rank == 0 {
+		rank = -1
+	}
+	attrs[ua.AttributeIDValueRank] = DataValueFromValue(rank)
 
+	d := []uint32{}
+	for _, s := range strings.Split(dims, ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
 		}
+		x, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid array dimensions %q", dims)
+		}
+		d = append(d, uint32(x))
+	}
+	attrs[ua.AttributeIDArrayDimensions] = DataValueFromValue(d)
+	return nil
+}
 
+// accessLevel returns the AccessLevel or the UserAccessLevel attribute
+// of a variable. An absent attribute cannot be told apart from 0 and is
+// set to the default CurrentRead.
+func accessLevel(level uint32) byte {
+	if level == 0 {
+		return byte(ua.AccessLevelTypeCurrentRead)
 	}
+	return byte(level)
+}
 
-	// set up the variables
-	for i := range nodes.UAVariable {
-		ot := nodes.UAVariable[i]
-		nid := ua.MustParseNodeID(ot.NodeIdAttr)
-		node := srv.Node(nid)
-
-		for rid := range ot.References.Reference {
-			ref := ot.References.Reference[rid]
-			refnodeid := ua.MustParseNodeID(ref.Value)
-			n := srv.Node(refnodeid)
-			if n == nil {
-				log.Printf("can't find node %s as %s reference to %s", ref.Value, ref.ReferenceTypeAttr, ot.BrowseNameAttr)
-				failures++
-				continue
-			}
-			if ref.IsForwardAttr == nil {
-				v := true
-				ref.IsForwardAttr = &v
-			}
-			reftypeid := ua.MustParseNodeID(reftypes[ref.ReferenceTypeAttr].NodeIdAttr)
-			node.AddRef(n, RefType(reftypeid.IntID()), *ref.IsForwardAttr)
-			if !reftypes[ref.ReferenceTypeAttr].SymmetricAttr {
-				n.AddRef(node, RefType(reftypeid.IntID()), !*ref.IsForwardAttr)
-			}
+// xmlValueTypes are the Go types of the built-in types whose values can
+// be imported from a NodeSet2 file by their element name.
+var xmlValueTypes = map[string]reflect.Type{
+	"Boolean":        reflect.TypeFor[bool](),
+	"SByte":          reflect.TypeFor[int8](),
+	"Byte":           reflect.TypeFor[uint8](),
+	"Int16":          reflect.TypeFor[int16](),
+	"UInt16":         reflect.TypeFor[uint16](),
+	"Int32":          reflect.TypeFor[int32](),
+	"UInt32":         reflect.TypeFor[uint32](),
+	"Int64":          reflect.TypeFor[int64](),
+	"UInt64":         reflect.TypeFor[uint64](),
+	"Float":          reflect.TypeFor[float32](),
+	"Double":         reflect.TypeFor[float64](),
+	"String":         reflect.TypeFor[string](),
+	"DateTime":       reflect.TypeFor[time.Time](),
+	"ByteString":     reflect.TypeFor[[]byte](),
+	"Guid":           reflect.TypeFor[*ua.GUID](),
+	"NodeId":         reflect.TypeFor[*ua.NodeID](),
+	"ExpandedNodeId": reflect.TypeFor[*ua.ExpandedNodeID](),
+	"QualifiedName":  reflect.TypeFor[*ua.QualifiedName](),
+	"LocalizedText":  reflect.TypeFor[*ua.LocalizedText](),
+}
 
+// xmlElement is an element of a value in a NodeSet2 file.
+type xmlElement struct {
+	XMLName  xml.Name
+	Content  string       `xml:",chardata"`
+	Children []xmlElement `xml:",any"`
+}
+
+// child returns the content of the child element with the name.
+func (e *xmlElement) child(name string) string {
+	for _, c := range e.Children {
+		if c.XMLName.Local == name {
+			return c.Content
 		}
+	}
+	return ""
+}
 
+// value parses the value of a variable in a NodeSet2 file. It returns
+// nil if the value is empty or if it is neither a built-in type in
+// xmlValueTypes nor a one-dimensional array of one, e.g. an
+// ExtensionObject.
+func (m namespaceMap) value(v *schema.Value) (any, error) {
+	if strings.TrimSpace(v.InnerXML) == "" {
+		return nil, nil
+	}
+	var el xmlElement
+	if err := xml.Unmarshal([]byte(v.InnerXML), &el); err != nil {
+		return nil, err
 	}
 
-	// set up the methods
-	for i := range nodes.UAMethod {
-		ot := nodes.UAMethod[i]
-		nid := ua.MustParseNodeID(ot.NodeIdAttr)
-		node := srv.Node(nid)
-
-		for rid := range ot.References.Reference {
-			ref := ot.References.Reference[rid]
-			refnodeid := ua.MustParseNodeID(ref.Value)
-			n := srv.Node(refnodeid)
-			if n == nil {
-				log.Printf("can't find node %s as %s reference to %s", ref.Value, ref.ReferenceTypeAttr, ot.BrowseNameAttr)
-				failures++
-				continue
-			}
-			if ref.IsForwardAttr == nil {
-				v := true
-				ref.IsForwardAttr = &v
+	name := el.XMLName.Local
+	if typ, ok := strings.CutPrefix(name, "ListOf"); ok {
+		t, ok := xmlValueTypes[typ]
+		if !ok {
+			return nil, nil
+		}
+		list := reflect.MakeSlice(reflect.SliceOf(t), 0, len(el.Children))
+		for i := range el.Children {
+			item := &el.Children[i]
+			if item.XMLName.Local != typ {
+				return nil, fmt.Errorf("unexpected element %s in %s", item.XMLName.Local, name)
 			}
-			reftypeid := ua.MustParseNodeID(reftypes[ref.ReferenceTypeAttr].NodeIdAttr)
-			node.AddRef(n, RefType(reftypeid.IntID()), *ref.IsForwardAttr)
-			if !reftypes[ref.ReferenceTypeAttr].SymmetricAttr {
-				n.AddRef(node, RefType(reftypeid.IntID()), !*ref.IsForwardAttr)
+			x, err := m.scalarValue(item)
+			if err != nil {
+				return nil, err
 			}
+			list = reflect.Append(list, reflect.ValueOf(x))
 		}
-
+		return list.Interface(), nil
 	}
+	if _, ok := xmlValueTypes[name]; !ok {
+		return nil, nil
+	}
+	return m.scalarValue(&el)
+}
 
-	// set up the objects
-	for i := range nodes.UAObject {
-		ot := nodes.UAObject[i]
-		nid := ua.MustParseNodeID(ot.NodeIdAttr)
-		node := srv.Node(nid)
-		if ot.NodeIdAttr == "i=84" {
-			log.Printf("doing root.")
+// scalarValue parses the element of a built-in type in xmlValueTypes.
+// Node ids and qualified names are returned with the namespace indices
+// of the server.
+func (m namespaceMap) scalarValue(el *xmlElement) (any, error) {
+	s := strings.TrimSpace(el.Content)
+	switch el.XMLName.Local {
+	case "Boolean":
+		return strconv.ParseBool(s)
+	case "SByte":
+		n, err := strconv.ParseInt(s, 10, 8)
+		return int8(n), err
+	case "Byte":
+		n, err := strconv.ParseUint(s, 10, 8)
+		return uint8(n), err
+	case "Int16":
+		n, err := strconv.ParseInt(s, 10, 16)
+		return int16(n), err
+	case "UInt16":
+		n, err := strconv.ParseUint(s, 10, 16)
+		return uint16(n), err
+	case "Int32":
+		n, err := strconv.ParseInt(s, 10, 32)
+		return int32(n), err
+	case "UInt32":
+		n, err := strconv.ParseUint(s, 10, 32)
+		return uint32(n), err
+	case "Int64":
+		return strconv.ParseInt(s, 10, 64)
+	case "UInt64":
+		return strconv.ParseUint(s, 10, 64)
+	case "Float":
+		f, err := strconv.ParseFloat(s, 32)
+		return float32(f), err
+	case "Double":
+		return strconv.ParseFloat(s, 64)
+	case "String":
+		return el.Content, nil
+	case "DateTime":
+		return time.Parse(time.RFC3339Nano, s)
+	case "ByteString":
+		// the base64 encoded value may be split into several lines
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+	case "Guid":
+		g := ua.NewGUID(strings.TrimSpace(el.child("String")))
+		if g == nil {
+			return nil, fmt.Errorf("invalid guid %q", el.child("String"))
 		}
-
-		for rid := range ot.References.Reference {
-			ref := ot.References.Reference[rid]
-			refnodeid := ua.MustParseNodeID(ref.Value)
-			n := srv.Node(refnodeid)
-			if n == nil {
-				log.Printf("can't find node %s as %s reference to %s", ref.Value, ref.ReferenceTypeAttr, ot.BrowseNameAttr)
-				failures++
-				continue
-			}
-			if ref.IsForwardAttr == nil {
-				v := true
-				ref.IsForwardAttr = &v
+		return g, nil
+	case "NodeId":
+		return m.parseNodeID(strings.TrimSpace(el.child("Identifier")))
+	case "ExpandedNodeId":
+		nid, err := m.parseNodeID(strings.TrimSpace(el.child("Identifier")))
+		if err != nil {
+			return nil, err
+		}
+		return ua.NewExpandedNodeID(nid, "", 0), nil
+	case "QualifiedName":
+		var idx uint16
+		if s := strings.TrimSpace(el.child("NamespaceIndex")); s != "" {
+			n, err := strconv.ParseUint(s, 10, 16)
+			if err != nil {
+				return nil, err
 			}
-			reftypeid := ua.MustParseNodeID(reftypes[ref.ReferenceTypeAttr].NodeIdAttr)
-			node.AddRef(n, RefType(reftypeid.IntID()), *ref.IsForwardAttr)
-			if !reftypes[ref.ReferenceTypeAttr].SymmetricAttr {
-				n.AddRef(node, RefType(reftypeid.IntID()), !*ref.IsForwardAttr)
+			idx = uint16(n)
+			if int(idx) < len(m) {
+				idx = m[idx]
 			}
-
 		}
-
+		return &ua.QualifiedName{NamespaceIndex: idx, Name: el.child("Name")}, nil
+	case "LocalizedText":
+		return ua.NewLocalizedTextWithLocale(el.child("Text"), el.child("Locale")), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", el.XMLName.Local)
 	}
-
-	return nil
 }
@@ -0,0 +1,384 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// Store stores the snapshots of the address space of the server.
+// See the Persistence option.
+type Store interface {
+	// Load returns the last saved snapshot or nil if there is none.
+	Load() (*Snapshot, error)
+
+	// Save replaces the stored snapshot.
+	Save(*Snapshot) error
+}
+
+// Snapshot contains the values of the writable variables and the
+// writable attributes of the persisted namespaces.
+type Snapshot struct {
+	// Time is the time the snapshot was taken.
+	Time time.Time
+
+	Namespaces []*NamespaceSnapshot
+}
+
+// NamespaceSnapshot contains the attributes of the nodes of a namespace.
+// Namespaces are identified by their uri since the namespace index can
+// change between restarts.
+type NamespaceSnapshot struct {
+	URI        string
+	Attributes []*AttributeSnapshot
+}
+
+// AttributeSnapshot is the value of an attribute of a node. The NodeID
+// has no namespace index, e.g. "s=Setpoint" or "i=1001".
+type AttributeSnapshot struct {
+	NodeID      string
+	AttributeID ua.AttributeID
+	Value       *ua.DataValue
+}
+
+// nodeLister is implemented by the namespaces whose nodes can be
+// persisted.
+type nodeLister interface {
+	nodeIDs() []*ua.NodeID
+}
+
+// Persistence stores the values of the writable variables and the
+// attributes which can be written according to the WriteMask of the
+// namespaces with the given uris in the store. If no uris are given
+// all namespaces except namespace 0 are persisted.
+//
+// The snapshot is restored by Start, i.e. after the nodesets have been
+// imported and the application has set up its namespaces. Attributes
+// which cannot be restored are logged and skipped. The snapshot is
+// saved every interval while the server is running and by Close. An
+// interval of zero only saves the snapshot when the server is closed.
+func Persistence(store Store, interval time.Duration, namespaces ...string) Option {
+	return func(s *serverConfig) {
+		s.store = store
+		s.persistInterval = interval
+		s.persistNamespaces = namespaces
+	}
+}
+
+// persisted returns the namespaces which are persisted.
+func (s *Server) persisted() []NameSpace {
+	var list []NameSpace
+	for i, ns := range s.Namespaces() {
+		switch {
+		case len(s.cfg.persistNamespaces) == 0 && i == 0:
+		case len(s.cfg.persistNamespaces) == 0 || slices.Contains(s.cfg.persistNamespaces, ns.Name()):
+			list = append(list, ns)
+		}
+	}
+	return list
+}
+
+// Snapshot returns the current values of the persisted attributes.
+func (s *Server) Snapshot() *Snapshot {
+	snap := &Snapshot{Time: time.Now()}
+	for _, ns := range s.persisted() {
+		nss := &NamespaceSnapshot{URI: ns.Name()}
+		if nns, ok := ns.(*NodeNameSpace); ok {
+			nss.Attributes = nns.snapshotAttributes()
+			snap.Namespaces = append(snap.Namespaces, nss)
+			continue
+		}
+		l, ok := ns.(nodeLister)
+		if !ok {
+			if s.cfg.logger != nil {
+				s.cfg.logger.Warn("persistence: cannot list the nodes of namespace %s", ns.Name())
+			}
+			continue
+		}
+		for _, nid := range l.nodeIDs() {
+			attr := func(a ua.AttributeID) *ua.DataValue { return ns.Attribute(nid, a) }
+			nss.Attributes = append(nss.Attributes, persistedAttributes(nid, attr, true)...)
+		}
+		snap.Namespaces = append(snap.Namespaces, nss)
+	}
+	return snap
+}
+
+// snapshotAttributes returns the persisted attributes of the nodes of the
+// namespace. The nodes are read under the lock of the namespace so that
+// the attributes cannot be written while the snapshot is taken.
+func (as *NodeNameSpace) snapshotAttributes() []*AttributeSnapshot {
+	as.mu.RLock()
+	defer as.mu.RUnlock()
+	var list []*AttributeSnapshot
+	for _, n := range as.nodes {
+		attr := func(a ua.AttributeID) *ua.DataValue { return nodeAttribute(n, a) }
+		list = append(list, persistedAttributes(n.ID(), attr, n.written)...)
+	}
+	return list
+}
+
+// persistedAttributes returns the attributes of the node which can be
+// written. attr returns the value of an attribute of the node. Values are
+// only persisted once they have been written since the values of the
+// other nodes are provided by the application, e.g. by a ValueFunc, and
+// restoring them would replace the live value with a constant.
+func persistedAttributes(nid *ua.NodeID, attr func(ua.AttributeID) *ua.DataValue, written bool) []*AttributeSnapshot {
+	local := *nid
+	local.SetNamespace(0)
+
+	var list []*AttributeSnapshot
+	add := func(a ua.AttributeID) {
+		dv := attr(a)
+		if dv == nil || dv.Status != ua.StatusOK || dv.Value == nil {
+			return
+		}
+		list = append(list, &AttributeSnapshot{NodeID: local.String(), AttributeID: a, Value: dv})
+	}
+
+	nc, _ := attrInt(attr(ua.AttributeIDNodeClass))
+	if ua.NodeClass(nc) == ua.NodeClassVariable {
+		// variables without an access level are writable, see checkAccessLevel
+		level := ua.AccessLevelTypeCurrentWrite
+		if x, ok := attrInt(attr(ua.AttributeIDAccessLevel)); ok {
+			level = ua.AccessLevelType(x)
+		}
+		if level&ua.AccessLevelTypeCurrentWrite != 0 && written {
+			add(ua.AttributeIDValue)
+		}
+	}
+
+	mask, _ := attrInt(attr(ua.AttributeIDWriteMask))
+	for a := ua.AttributeIDNodeID; a <= ua.AttributeIDAccessLevelEx; a++ {
+		if bit, ok := writeMaskBits[a]; ok && ua.AttributeWriteMask(mask)&bit != 0 {
+			add(a)
+		}
+	}
+	return list
+}
+
+// Restore writes the attributes of the snapshot back into the namespaces.
+// Namespaces which are not persisted, nodes which no longer exist and
+// values whose type has changed are skipped.
+func (s *Server) Restore(snap *Snapshot) error {
+	if snap == nil {
+		return nil
+	}
+	var errs []error
+	for _, ns := range s.persisted() {
+		for _, nss := range snap.Namespaces {
+			if nss.URI != ns.Name() {
+				continue
+			}
+			for _, a := range nss.Attributes {
+				s.decodeStructures(a.Value)
+				if err := restoreAttribute(ns, a); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// restoreAttribute writes the value of the attribute if the node still
+// has the attribute with a value of the same type.
+func restoreAttribute(ns NameSpace, a *AttributeSnapshot) error {
+	nid, err := ua.ParseNodeID(a.NodeID)
+	if err != nil {
+		return fmt.Errorf("persistence: invalid node id %q: %w", a.NodeID, err)
+	}
+	switch nid.Type() {
+	case ua.NodeIDTypeTwoByte, ua.NodeIDTypeFourByte, ua.NodeIDTypeNumeric:
+		// the short encodings cannot hold every namespace index
+		nid = ua.NewNumericNodeID(ns.ID(), nid.IntID())
+	default:
+		nid.SetNamespace(ns.ID())
+	}
+
+	cur := ns.Attribute(nid, a.AttributeID)
+	if cur == nil || cur.Status != ua.StatusOK || cur.Value == nil || a.Value == nil || a.Value.Value == nil {
+		return nil
+	}
+	if reflect.TypeOf(cur.Value.Value()) != reflect.TypeOf(a.Value.Value.Value()) {
+		return nil
+	}
+	if status := ns.SetAttribute(nid, a.AttributeID, a.Value); status != ua.StatusOK {
+		return fmt.Errorf("persistence: cannot restore %s of %s: %w", a.AttributeID, nid, status)
+	}
+	return nil
+}
+
+// SaveSnapshot saves the current values of the persisted attributes in
+// the store of the Persistence option.
+func (s *Server) SaveSnapshot() error {
+	if s.cfg.store == nil {
+		return nil
+	}
+	return s.cfg.store.Save(s.Snapshot())
+}
+
+// startPersistence restores the stored snapshot and starts saving the
+// snapshots periodically.
+func (s *Server) startPersistence() error {
+	if s.cfg.store == nil {
+		return nil
+	}
+	snap, err := s.cfg.store.Load()
+	if err != nil {
+		return fmt.Errorf("persistence: %w", err)
+	}
+	// a value which cannot be restored must not keep the server from
+	// starting
+	if err := s.Restore(snap); err != nil {
+		if s.cfg.logger != nil {
+			s.cfg.logger.Error("persistence: %v", err)
+		} else {
+			log.Printf("persistence: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.mu.Lock()
+	s.persistCancel = cancel
+	s.persistDone = done
+	s.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		if s.cfg.persistInterval <= 0 {
+			return
+		}
+		tick := time.NewTicker(s.cfg.persistInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				if err := s.SaveSnapshot(); err != nil && s.cfg.logger != nil {
+					s.cfg.logger.Error("persistence: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// stopPersistence stops the periodic snapshots and saves the final
+// snapshot. Nothing is saved if the snapshot was not restored before
+// since that would overwrite the stored values with the defaults.
+func (s *Server) stopPersistence() error {
+	s.mu.Lock()
+	cancel, done := s.persistCancel, s.persistDone
+	s.persistCancel, s.persistDone = nil, nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	<-done
+	return s.SaveSnapshot()
+}
+
+// FileStore stores the snapshots in a JSON file. The values are stored
+// in the OPC UA binary encoding.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore returns a store which saves the snapshots in the file.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+type fileSnapshot struct {
+	Time       time.Time        `json:"time"`
+	Namespaces []*fileNamespace `json:"namespaces"`
+}
+
+type fileNamespace struct {
+	URI        string           `json:"uri"`
+	Attributes []*fileAttribute `json:"attributes"`
+}
+
+type fileAttribute struct {
+	NodeID      string         `json:"nodeId"`
+	AttributeID ua.AttributeID `json:"attributeId"`
+	Value       []byte         `json:"value"`
+}
+
+// Load returns the snapshot in the file or nil if the file does not exist.
+func (f *FileStore) Load() (*Snapshot, error) {
+	b, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var fs fileSnapshot
+	if err := json.Unmarshal(b, &fs); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.path, err)
+	}
+	snap := &Snapshot{Time: fs.Time}
+	for _, fns := range fs.Namespaces {
+		nss := &NamespaceSnapshot{URI: fns.URI}
+		for _, fa := range fns.Attributes {
+			dv := new(ua.DataValue)
+			if _, err := ua.Decode(fa.Value, dv); err != nil {
+				return nil, fmt.Errorf("%s: %s of %s: %w", f.path, fa.AttributeID, fa.NodeID, err)
+			}
+			nss.Attributes = append(nss.Attributes, &AttributeSnapshot{NodeID: fa.NodeID, AttributeID: fa.AttributeID, Value: dv})
+		}
+		snap.Namespaces = append(snap.Namespaces, nss)
+	}
+	return snap, nil
+}
+
+// Save writes the snapshot to a temporary file which then replaces the
+// file so that a crash does not leave a partially written file behind.
+func (f *FileStore) Save(snap *Snapshot) error {
+	fs := fileSnapshot{Time: snap.Time}
+	for _, nss := range snap.Namespaces {
+		fns := &fileNamespace{URI: nss.URI}
+		for _, a := range nss.Attributes {
+			b, err := ua.Encode(a.Value)
+			if err != nil {
+				return fmt.Errorf("%s of %s: %w", a.AttributeID, a.NodeID, err)
+			}
+			fns.Attributes = append(fns.Attributes, &fileAttribute{NodeID: a.NodeID, AttributeID: a.AttributeID, Value: b})
+		}
+		fs.Namespaces = append(fs.Namespaces, fns)
+	}
+	b, err := json.MarshalIndent(fs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
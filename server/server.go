@@ -11,7 +11,10 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/url"
+	"reflect"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -25,12 +28,8 @@ import (
 
 //go:generate go run ../cmd/predefined-nodes/main.go
 
-const defaultListenAddr = "opc.tcp://localhost:0"
-
 // Server is a high-level OPC-UA Server
 type Server struct {
-	url string
-
 	cfg *serverConfig
 
 	mu         sync.Mutex
@@ -38,7 +37,33 @@ type Server struct {
 	endpoints  []*ua.EndpointDescription
 	namespaces []NameSpace
 
-	l  *uacp.Listener
+	// models are the imported information models by model uri.
+	models map[string]*schema.ModelTableEntry
+
+	// nodeSetMu serializes the imports and the changes of nodesets so
+	// that the changes are applied to the address space they have been
+	// checked against.
+	nodeSetMu sync.Mutex
+
+	// dataTypes contains the DataTypes of the Go types which have been
+	// added with AddStructureDataType or AddEnumDataType.
+	dataTypes map[reflect.Type]*ua.NodeID
+
+	// types caches the type hierarchy of the nodes.
+	types typeTree
+
+	// views contains the views by the node id of their View node.
+	views map[string]*View
+
+	// structures contains the descriptors of the structures of the
+	// imported nodesets.
+	structures structureTable
+
+	// listeners are the listeners for the configured endpoints and
+	// urls are the endpoint urls under which they can be reached.
+	listeners []*uacp.Listener
+	urls      []string
+
 	cb *channelBroker
 	sb *sessionBroker
 
@@ -48,8 +73,19 @@ type Server struct {
 	// All services should have a method here.
 	handlers map[uint16]Handler
 
+	// unsupported is the handler for services without a registered
+	// handler. It and the handlers are wrapped with the middleware
+	// when the server is started.
+	unsupported Handler
+	started     bool
+
 	SubscriptionService  *SubscriptionService
 	MonitoredItemService *MonitoredItemService
+
+	// persistCancel stops the periodic snapshots and persistDone is
+	// closed when they have stopped.
+	persistCancel context.CancelFunc
+	persistDone   chan struct{}
 }
 
 type serverConfig struct {
@@ -69,6 +105,19 @@ type serverConfig struct {
 
 	cap ServerCapabilities
 
+	middleware []Middleware
+
+	// types is the registry for the extension objects of the clients.
+	types *ua.TypeRegistry
+
+	auditing bool
+
+	// store, persistInterval and persistNamespaces are set by the
+	// Persistence option.
+	store             Store
+	persistInterval   time.Duration
+	persistNamespaces []string
+
 	logger Logger
 }
 
@@ -108,17 +157,18 @@ func New(opts ...Option) *Server {
 	for _, opt := range opts {
 		opt(cfg)
 	}
-	url := ""
-	if len(cfg.endpoints) != 0 {
-		url = cfg.endpoints[0]
-	}
-
 	s := &Server{
-		url:      url,
-		cfg:      cfg,
-		cb:       newChannelBroker(cfg.logger),
-		sb:       newSessionBroker(cfg.logger),
-		handlers: make(map[uint16]Handler),
+		cfg:       cfg,
+		cb:        newChannelBroker(cfg.logger),
+		sb:        newSessionBroker(cfg.logger),
+		handlers:  make(map[uint16]Handler),
+		models:    make(map[string]*schema.ModelTableEntry),
+		dataTypes: make(map[reflect.Type]*ua.NodeID),
+		views:     make(map[string]*View),
+		structures: structureTable{
+			byDataType: make(map[string]*ua.StructureDescriptor),
+			byEncoding: make(map[string]*ua.StructureDescriptor),
+		},
 		namespaces: []NameSpace{
 			NewNameSpace("http://opcfoundation.org/UA/"), // ns:0
 		},
@@ -163,6 +213,15 @@ func New(opts ...Option) *Server {
 	for _, n := range ServerCapabilitiesNodes(s) {
 		s.namespaces[0].AddNode(n)
 	}
+	s.namespaces[0].AddNode(AuditingNode(s))
+
+	// events are reported on the Server object
+	if n := s.namespaces[0].Node(ua.NewNumericNodeID(0, id.Server)); n != nil {
+		n.SetAttribute(ua.AttributeIDEventNotifier, DataValueFromValue(byte(ua.EventNotifierTypeSubscribeToEvents)))
+	}
+	if cfg.auditing {
+		s.initAuditing()
+	}
 
 	return s
 }
@@ -186,7 +245,21 @@ func (s *Server) Namespaces() []NameSpace {
 	return s.namespaces
 }
 
+// namespaceURIs returns the namespace array of the server.
+func (s *Server) namespaceURIs() []string {
+	n := s.Namespaces()
+	ns := make([]string, len(n))
+	for i := range ns {
+		ns[i] = n[i].Name()
+	}
+	return ns
+}
+
 func (s *Server) ChangeNotification(n *ua.NodeID) {
+	// the handlers are registered in Start
+	if s.MonitoredItemService == nil {
+		return
+	}
 	s.MonitoredItemService.ChangeNotification(n)
 }
 
@@ -204,6 +277,8 @@ func (s *Server) AddNamespace(ns NameSpace) int {
 	}
 	ns.SetID(uint16(len(s.namespaces)))
 	s.namespaces = append(s.namespaces, ns)
+	// the namespace can contain types
+	s.types.invalidate()
 
 	if ns.ID() == 0 {
 		return 0
@@ -229,31 +304,55 @@ func (s *Server) Status() *ua.ServerStatusDataType {
 	return status
 }
 
-// URLs returns opc endpoint that the server is listening on.
+// URLs returns the opc endpoint urls that the server is listening on.
+//
+// Before Start these are the configured endpoints. After Start the
+// ports are resolved and endpoints on a wildcard address are replaced
+// with one url per address of the local network interfaces.
 func (s *Server) URLs() []string {
-	return s.cfg.endpoints
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.urls == nil {
+		return s.cfg.endpoints
+	}
+	return slices.Clone(s.urls)
 }
 
-// Start initializes and starts a Server listening on addr
-// If s was not initialized with NewServer(), addr defaults
-// to localhost:0 to let the OS select a random port
+// Start initializes and starts a Server listening on all configured
+// endpoints.
 func (s *Server) Start(ctx context.Context) error {
-	var err error
-
 	if len(s.cfg.endpoints) == 0 {
 		return fmt.Errorf("cannot start server: no endpoints defined")
 	}
 
 	// Register all service handlers
 	s.initHandlers()
+	s.chainHandlers()
 
-	if s.url == "" {
-		s.url = defaultListenAddr
-	}
-	s.l, err = uacp.Listen(s.url, nil)
-	if err != nil {
+	// restore the persisted values before the first client connects
+	if err := s.startPersistence(); err != nil {
 		return err
 	}
+
+	var urls []string
+	for _, ep := range s.cfg.endpoints {
+		l, err := uacp.Listen(ep, nil)
+		if err != nil {
+			s.closeListeners()
+			return err
+		}
+		s.listeners = append(s.listeners, l)
+
+		lurls, err := listenerURLs(l)
+		if err != nil {
+			s.closeListeners()
+			return err
+		}
+		urls = append(urls, lurls...)
+	}
+	s.mu.Lock()
+	s.urls = urls
+	s.mu.Unlock()
 	log.Printf("Started listening on %v", s.URLs())
 
 	s.initEndpoints()
@@ -263,7 +362,9 @@ func (s *Server) Start(ctx context.Context) error {
 		s.cb = newChannelBroker(s.cfg.logger)
 	}
 
-	go s.acceptAndRegister(ctx, s.l)
+	for _, l := range s.listeners {
+		go s.acceptAndRegister(ctx, l)
+	}
 	go s.monitorConnections(ctx)
 
 	return nil
@@ -275,18 +376,232 @@ func (s *Server) setServerState(state ua.ServerState) {
 	s.mu.Unlock()
 }
 
-// Close gracefully shuts the server down by closing all open connections,
-// and stops listening on all endpoints
+// shutdownDrainTimeout is the maximum time Shutdown waits for the
+// subscriptions to send their final notifications.
+const shutdownDrainTimeout = 5 * time.Second
+
+// Shutdown gracefully shuts the server down.
+//
+// The server state changes to Shutdown and new sessions are rejected.
+// SecondsTillShutdown counts down from delay every second and the
+// ShutdownReason is set to reason so that subscribed clients can see the
+// countdown. After the delay every subscription sends its pending
+// notifications together with a StatusChangeNotification with
+// BadShutdown with the next publish request. Then the server is closed.
+//
+// If ctx is done before the shutdown is complete the remaining steps
+// are skipped, the server is closed and the error of ctx is returned.
+func (s *Server) Shutdown(ctx context.Context, delay time.Duration, reason string) error {
+	s.mu.Lock()
+	s.status.State = ua.ServerStateShutdown
+	s.status.ShutdownReason = ua.NewLocalizedText(reason)
+	s.mu.Unlock()
+
+	deadline := time.Now().Add(delay)
+	s.setSecondsTillShutdown(delay)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+
+countdown:
+	for {
+		select {
+		case <-ctx.Done():
+			break countdown
+		case <-timer.C:
+			break countdown
+		case <-tick.C:
+			s.setSecondsTillShutdown(time.Until(deadline))
+		}
+	}
+	s.setSecondsTillShutdown(0)
+
+	if s.SubscriptionService != nil && ctx.Err() == nil {
+		s.SubscriptionService.Mu.Lock()
+		subs := make([]*Subscription, 0, len(s.SubscriptionService.Subs))
+		for _, sub := range s.SubscriptionService.Subs {
+			subs = append(subs, sub)
+		}
+		s.SubscriptionService.Mu.Unlock()
+
+		for _, sub := range subs {
+			select {
+			case sub.StatusChannel <- ua.StatusBadShutdown:
+			default:
+			}
+		}
+
+		drain := time.NewTimer(shutdownDrainTimeout)
+		defer drain.Stop()
+	wait:
+		for _, sub := range subs {
+			select {
+			case <-sub.done:
+			case <-drain.C:
+				break wait
+			case <-ctx.Done():
+				break wait
+			}
+		}
+		// stop the subscriptions which are still waiting for a publish request
+		for _, sub := range subs {
+			s.SubscriptionService.DeleteSubscription(sub.ID)
+		}
+	}
+
+	err := ctx.Err()
+	if cerr := s.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// setSecondsTillShutdown updates the SecondsTillShutdown of the server
+// status and notifies the clients which monitor the server status.
+func (s *Server) setSecondsTillShutdown(d time.Duration) {
+	secs := uint32(0)
+	if d > 0 {
+		secs = uint32((d + time.Second - 1) / time.Second)
+	}
+	s.mu.Lock()
+	s.status.SecondsTillShutdown = secs
+	s.mu.Unlock()
+
+	if s.MonitoredItemService == nil {
+		return
+	}
+	for _, nid := range []uint32{
+		id.Server_ServerStatus,
+		id.Server_ServerStatus_State,
+		id.Server_ServerStatus_SecondsTillShutdown,
+		id.Server_ServerStatus_ShutdownReason,
+	} {
+		s.ChangeNotification(ua.NewNumericNodeID(0, nid))
+	}
+}
+
+// Close shuts the server down immediately by closing all open connections,
+// and stops listening on all endpoints. See Shutdown for a graceful shutdown.
 func (s *Server) Close() error {
 	s.setServerState(ua.ServerStateShutdown)
 
-	// Close the listener, preventing new sessions from starting
-	if s.l != nil {
-		s.l.Close()
-	}
+	// Close the listeners, preventing new sessions from starting
+	s.closeListeners()
 
 	// Shut down all secure channels and UACP connections
-	return s.cb.Close()
+	err := s.cb.Close()
+
+	if perr := s.stopPersistence(); err == nil {
+		err = perr
+	}
+	return err
+}
+
+func (s *Server) closeListeners() {
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	s.listeners = nil
+}
+
+// listenerURLs returns the endpoint urls under which clients can reach
+// the listener. A listener on a wildcard address, e.g. "0.0.0.0" or "::",
+// can be reached under the addresses of all network interfaces.
+func listenerURLs(l *uacp.Listener) ([]string, error) {
+	u, err := url.Parse(l.Endpoint())
+	if err != nil {
+		return nil, err
+	}
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+
+	host := u.Hostname()
+	ip := net.ParseIP(host)
+	if host != "" && (ip == nil || !ip.IsUnspecified()) {
+		u.Host = net.JoinHostPort(host, port)
+		return []string{u.String()}, nil
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	ipv4Only := ip != nil && ip.To4() != nil
+	var urls []string
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		// link-local addresses need a zone which we do not know here
+		if ipnet.IP.IsLinkLocalUnicast() || (ipv4Only && ipnet.IP.To4() == nil) {
+			continue
+		}
+		u.Host = net.JoinHostPort(ipnet.IP.String(), port)
+		urls = append(urls, u.String())
+	}
+	return urls, nil
+}
+
+// endpointsForURL returns the endpoints for the url which the client
+// used to connect to the server.
+//
+// The client may reach the server under a different name than the
+// server knows itself, e.g. through a DNS alias or a NAT. Therefore,
+// the endpoints which listen on the same port are returned with the
+// host of the url if no endpoint matches the url exactly. If no
+// endpoint listens on the port then all endpoints are returned.
+func (s *Server) endpointsForURL(endpointURL string) []*ua.EndpointDescription {
+	eps := s.Endpoints()
+	reqURL, err := url.Parse(endpointURL)
+	if endpointURL == "" || err != nil {
+		return eps
+	}
+
+	var exact, other []*ua.EndpointDescription
+	for _, ep := range eps {
+		u, err := url.Parse(ep.EndpointURL)
+		if err != nil || urlPort(u) != urlPort(reqURL) {
+			continue
+		}
+		if strings.EqualFold(u.Hostname(), reqURL.Hostname()) {
+			exact = append(exact, ep)
+			continue
+		}
+
+		u.Host = net.JoinHostPort(reqURL.Hostname(), urlPort(u))
+		rewritten := *ep
+		rewritten.EndpointURL = u.String()
+
+		// the endpoints of the different interfaces are the
+		// same after the host has been rewritten.
+		dup := slices.ContainsFunc(other, func(o *ua.EndpointDescription) bool {
+			return o.EndpointURL == rewritten.EndpointURL &&
+				o.SecurityPolicyURI == rewritten.SecurityPolicyURI &&
+				o.SecurityMode == rewritten.SecurityMode
+		})
+		if !dup {
+			other = append(other, &rewritten)
+		}
+	}
+
+	switch {
+	case len(exact) > 0:
+		return exact
+	case len(other) > 0:
+		return other
+	default:
+		return eps
+	}
+}
+
+// urlPort returns the port of the url or the default port 4840.
+func urlPort(u *url.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+	return "4840"
 }
 
 type temporary interface {
@@ -371,11 +686,11 @@ func (s *Server) monitorConnections(ctx context.Context) {
 func (s *Server) initEndpoints() {
 	var endpoints []*ua.EndpointDescription
 	for _, sec := range s.cfg.enabledSec {
-		for _, url := range s.cfg.endpoints {
+		for _, endpointURL := range s.URLs() {
 			secLevel := uapolicy.SecurityLevel(sec.secPolicy, sec.secMode)
 
 			ep := &ua.EndpointDescription{
-				EndpointURL:   url, // todo: be able to listen on multiple adapters
+				EndpointURL:   endpointURL,
 				SecurityLevel: secLevel,
 				Server: &ua.ApplicationDescription{
 					ApplicationURI: s.cfg.applicationURI,
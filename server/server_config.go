@@ -7,8 +7,9 @@ package server
 import (
 	"crypto/rsa"
 	"crypto/x509"
-	"fmt"
 	"log"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,13 +28,16 @@ func PrivateKey(key *rsa.PrivateKey) Option {
 	}
 }
 
-// EndPointHostName adds an additional endpoint to the server based on the host name
+// EndPoint adds an additional endpoint to the server on which it listens
+// for connections. The host can be a host name, an IPv4 or an IPv6 address.
+// An empty host, "0.0.0.0" or "::" listens on all network interfaces.
+// A port of 0 lets the OS select a random port.
 func EndPoint(host string, port int) Option {
 	return func(s *serverConfig) {
 		if s.endpoints == nil {
 			s.endpoints = make([]string, 0)
 		}
-		ep := fmt.Sprintf("opc.tcp://%s:%d", host, port)
+		ep := "opc.tcp://" + net.JoinHostPort(host, strconv.Itoa(port))
 		s.endpoints = append(s.endpoints, ep)
 	}
 }
@@ -149,6 +153,21 @@ func SoftwareVersion(name string) Option {
 	}
 }
 
+// TypeRegistry sets the registry for the extension objects which are
+// exchanged with the clients.
+//
+// Extension objects in requests whose type is registered with r are
+// decoded with this type and extension objects in responses get the id of
+// the type of their value in r. Types which are registered with
+// r.RegisterURI are resolved with the namespace array of the server. Use
+// ua.NewScopedTypeRegistry to fall back to the types registered with
+// ua.RegisterExtensionObject.
+func TypeRegistry(r *ua.TypeRegistry) Option {
+	return func(s *serverConfig) {
+		s.types = r
+	}
+}
+
 // this logger interface is used to allow the user to provide their own logger
 // it is compatible with slog.Logger
 type Logger interface {
@@ -28,14 +28,19 @@ func NamespacesNode(s *Server) *Node {
 			ua.AttributeIDNodeClass:  DataValueFromValue(uint32(ua.NodeClassObject)),
 		},
 		nil,
-		func() *ua.DataValue {
-			n := s.Namespaces()
-			ns := make([]string, len(n))
-			for i := range ns {
-				ns[i] = n[i].Name()
-			}
-			return DataValueFromValue(ns)
+		func() *ua.DataValue { return DataValueFromValue(s.namespaceURIs()) },
+	)
+}
+
+func AuditingNode(s *Server) *Node {
+	return NewNode(
+		ua.NewNumericNodeID(0, id.Server_Auditing),
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDBrowseName: DataValueFromValue(attrs.BrowseName("Auditing")),
+			ua.AttributeIDNodeClass:  DataValueFromValue(uint32(ua.NodeClassVariable)),
 		},
+		nil,
+		func() *ua.DataValue { return DataValueFromValue(s.cfg.auditing) },
 	)
 }
 
@@ -218,7 +223,7 @@ func ServerStatusNodes(s *Server, ServerNode *Node) []*Node {
 			ua.AttributeIDNodeClass:  DataValueFromValue(uint32(ua.NodeClassVariable)),
 		},
 		nil,
-		func() *ua.DataValue { return DataValueFromValue(int32(0)) },
+		func() *ua.DataValue { return DataValueFromValue(s.Status().SecondsTillShutdown) },
 	)
 	sReason := NewNode(
 		ua.NewNumericNodeID(0, id.Server_ServerStatus_ShutdownReason),
@@ -227,7 +232,7 @@ func ServerStatusNodes(s *Server, ServerNode *Node) []*Node {
 			ua.AttributeIDNodeClass:  DataValueFromValue(uint32(ua.NodeClassVariable)),
 		},
 		nil,
-		func() *ua.DataValue { return DataValueFromValue(int32(0)) },
+		func() *ua.DataValue { return DataValueFromValue(s.Status().ShutdownReason) },
 	)
 
 	nodes := []*Node{sState, mName, pName, pURI, sVersion, bNumber, bDate, timeStart, timeCurrent, bInfo, sTillShutdown, sReason}
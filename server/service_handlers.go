@@ -110,16 +110,20 @@ func (s *Server) handleService(ctx context.Context, sc *uasc.SecureChannel, reqI
 
 	typeID := ua.ServiceTypeID(req)
 	h, ok := s.handlers[typeID]
-	if ok {
-		resp, err = h(sc, req, reqID)
-	} else {
+	if !ok {
 		if typeID == 0 {
 			if s.cfg.logger != nil {
 				s.cfg.logger.Warn("unknown service %T. Did you call register?", req)
 			}
 		}
-		err = ua.StatusBadServiceUnsupported
+		h = s.unsupported
+	}
+	if s.cfg.types != nil {
+		s.cfg.types.DecodeExtensionObjects(req, s.namespaceURIs())
 	}
+	// structures of the imported nodesets without a Go type
+	s.decodeStructures(req)
+	resp, err = h(sc, req, reqID)
 
 	if err != nil {
 		if statusCode, ok := err.(ua.StatusCode); ok {
@@ -133,6 +137,7 @@ func (s *Server) handleService(ctx context.Context, sc *uasc.SecureChannel, reqI
 		return
 	}
 
+	s.setTypeIDs(resp)
 	err = sc.SendResponseWithContext(ctx, reqID, resp)
 	if err != nil {
 		if s.cfg.logger != nil {
@@ -181,3 +186,11 @@ func safeReq[T ua.Request](r ua.Request) (T, error) {
 // 	// No response required
 // 	return nil, nil
 // }
+
+// setTypeIDs sets the ids of the extension objects in the response whose
+// type is registered with the TypeRegistry of the server.
+func (s *Server) setTypeIDs(resp ua.Response) {
+	if s.cfg.types != nil {
+		s.cfg.types.SetTypeIDs(resp, s.namespaceURIs())
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"crypto/x509"
 	mrand "math/rand"
 	"sync"
 	"time"
@@ -17,10 +18,31 @@ type session struct {
 	AuthTokenID       *ua.NodeID
 	serverNonce       []byte
 	remoteCertificate []byte
+	userIdentity      *ua.ExtensionObject
 
 	PublishRequests chan PubReq
 }
 
+// clientUserID returns the identity of the user of the session
+// as reported in the ClientUserId field of audit events.
+func (s *session) clientUserID() string {
+	if s.userIdentity == nil {
+		return ""
+	}
+	switch tok := s.userIdentity.Value.(type) {
+	case *ua.UserNameIdentityToken:
+		return tok.UserName
+	case *ua.X509IdentityToken:
+		cert, err := x509.ParseCertificate(tok.CertificateData)
+		if err != nil {
+			return ""
+		}
+		return cert.Subject.String()
+	default:
+		return ""
+	}
+}
+
 type sessionConfig struct {
 	sessionTimeout time.Duration
 }
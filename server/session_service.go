@@ -35,6 +35,11 @@ func (s *SessionService) CreateSession(sc *uasc.SecureChannel, r ua.Request, req
 		return nil, err
 	}
 
+	// no new sessions while the server is shutting down
+	if s.srv.Status().State == ua.ServerStateShutdown {
+		return nil, ua.StatusBadShutdown
+	}
+
 	// New session
 	sess := s.srv.sb.NewSession()
 
@@ -58,14 +63,6 @@ func (s *SessionService) CreateSession(sc *uasc.SecureChannel, r ua.Request, req
 		return nil, ua.StatusBadInternalError
 	}
 
-	matching_endpoints := make([]*ua.EndpointDescription, 0)
-	for i := range s.srv.endpoints {
-		ep := s.srv.endpoints[i]
-		if ep.EndpointURL == req.EndpointURL {
-			matching_endpoints = append(matching_endpoints, ep)
-		}
-	}
-
 	response := &ua.CreateSessionResponse{
 		ResponseHeader:        responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
 		SessionID:             sess.ID,
@@ -78,7 +75,7 @@ func (s *SessionService) CreateSession(sc *uasc.SecureChannel, r ua.Request, req
 		},
 		ServerCertificate: s.srv.cfg.certificate,
 		ServerNonce:       nonce,
-		ServerEndpoints:   matching_endpoints,
+		ServerEndpoints:   s.srv.endpointsForURL(req.EndpointURL),
 	}
 
 	return response, nil
@@ -114,6 +111,7 @@ func (s *SessionService) ActivateSession(sc *uasc.SecureChannel, r ua.Request, r
 		return nil, ua.StatusBadInternalError
 	}
 	sess.serverNonce = nonce
+	sess.userIdentity = req.UserIdentityToken
 
 	response := &ua.ActivateSessionResponse{
 		ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
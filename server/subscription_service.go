@@ -274,22 +274,33 @@ type Subscription struct {
 	T *time.Ticker
 
 	NotifyChannel chan *ua.MonitoredItemNotification
+	EventChannel  chan *ua.EventFieldList
 	ModifyChannel chan *ua.ModifySubscriptionRequest
 
+	// StatusChannel is used to send a final StatusChangeNotification
+	// to the client. The subscription is deleted afterwards.
+	StatusChannel chan ua.StatusCode
+
 	// the running flag and shutdown channel are used to signal the background task that it should stop.
 	// multiple places can kill the subscription so make sure you check the running flag using the mutex
 	// before closing the shutdown channel.
 	Mu       sync.Mutex
 	running  bool
 	shutdown chan struct{}
+
+	// done is closed when the background task has stopped.
+	done chan struct{}
 }
 
 func NewSubscription() *Subscription {
 	return &Subscription{
 		//SeqNums:       map[uint32]struct{}{},
 		NotifyChannel: make(chan *ua.MonitoredItemNotification, 100),
+		EventChannel:  make(chan *ua.EventFieldList, 100),
 		ModifyChannel: make(chan *ua.ModifySubscriptionRequest, 2),
+		StatusChannel: make(chan ua.StatusCode, 1),
 		shutdown:      make(chan struct{}),
+		done:          make(chan struct{}),
 	}
 }
 
@@ -329,6 +340,7 @@ func (s *Subscription) keepalive(pubreq PubReq) error {
 		Results:                  []ua.StatusCode{},
 		DiagnosticInfos:          []*ua.DiagnosticInfo{},
 	}
+	s.srv.srv.setTypeIDs(response)
 	err := s.Channel.SendResponseWithContext(context.Background(), pubreq.ID, response)
 	if err != nil {
 		return err
@@ -346,6 +358,7 @@ func (s *Subscription) run() {
 			s.srv.srv.cfg.logger.Info("Subscription %d shutting down.", s.ID)
 		}
 		s.srv.DeleteSubscription(s.ID)
+		close(s.done)
 	}()
 
 	keepalive_counter := 0
@@ -369,6 +382,8 @@ func (s *Subscription) run() {
 	for {
 		// we don't need to do anything if we don't have at least one thing to publish so lets get that first
 		publishQueue := make(map[uint32]*ua.MonitoredItemNotification)
+		// events are not merged by client handle since every event has to be delivered
+		var eventQueue []*ua.EventFieldList
 
 		// Collect notifications until our publication interval is ready
 	L0:
@@ -378,8 +393,13 @@ func (s *Subscription) run() {
 				return
 			case newNotification := <-s.NotifyChannel:
 				publishQueue[newNotification.ClientHandle] = newNotification
+			case newEvent := <-s.EventChannel:
+				eventQueue = append(eventQueue, newEvent)
+			case status := <-s.StatusChannel:
+				s.publishStatus(status, publishQueue, eventQueue)
+				return
 			case <-s.T.C:
-				if len(publishQueue) == 0 {
+				if len(publishQueue) == 0 && len(eventQueue) == 0 {
 					// nothing to publish, increment the keepalive counter and send a keepalive if it
 					// has been enough intervals.
 					keepalive_counter++
@@ -425,6 +445,11 @@ func (s *Subscription) run() {
 				break L2
 			case newNotification := <-s.NotifyChannel:
 				publishQueue[newNotification.ClientHandle] = newNotification
+			case newEvent := <-s.EventChannel:
+				eventQueue = append(eventQueue, newEvent)
+			case status := <-s.StatusChannel:
+				s.publishStatus(status, publishQueue, eventQueue)
+				return
 
 			case <-s.T.C:
 				// we had another tick without a publish request.
@@ -440,60 +465,7 @@ func (s *Subscription) run() {
 		lifetime_counter = 0
 		keepalive_counter = 0
 
-		s.SequenceID++
-		if s.SequenceID == 0 {
-			// per the spec, the sequence ID cannot be 0
-			s.SequenceID = 1
-		}
-		if s.srv.srv.cfg.logger != nil {
-			s.srv.srv.cfg.logger.Debug("Got publish req on sub #%d.  Sequence %d", s.ID, s.SequenceID)
-		}
-		// then get all the tags and send them back to the client
-
-		//for x := range pubreq.Req.SubscriptionAcknowledgements {
-		//a := pubreq.Req.SubscriptionAcknowledgements[x]
-		//delete(s.SeqNums, a.SequenceNumber)
-		//}
-
-		final_items := make([]*ua.MonitoredItemNotification, len(publishQueue))
-		i := 0
-		for k := range publishQueue {
-			final_items[i] = publishQueue[k]
-			i++
-		}
-
-		dcn := ua.DataChangeNotification{
-			MonitoredItems:  final_items,
-			DiagnosticInfos: []*ua.DiagnosticInfo{},
-		}
-		eo := make([]*ua.ExtensionObject, 1)
-		eo[0] = ua.NewExtensionObject(&dcn)
-		eo[0].UpdateMask()
-
-		msg := ua.NotificationMessage{
-			SequenceNumber:   s.SequenceID,
-			PublishTime:      time.Now(),
-			NotificationData: eo,
-		}
-		//s.SeqNums[s.SequenceID] = struct{}{}
-
-		response := &ua.PublishResponse{
-			ResponseHeader: &ua.ResponseHeader{
-				Timestamp:          time.Now(),
-				RequestHandle:      pubreq.Req.RequestHeader.RequestHandle,
-				ServiceResult:      ua.StatusOK,
-				ServiceDiagnostics: &ua.DiagnosticInfo{},
-				StringTable:        []string{},
-				AdditionalHeader:   ua.NewExtensionObject(nil),
-			},
-			SubscriptionID:           s.ID,
-			MoreNotifications:        false,
-			NotificationMessage:      &msg,
-			AvailableSequenceNumbers: []uint32{}, // an empty array indicates taht we don't support retransmission of messages
-			Results:                  []ua.StatusCode{},
-			DiagnosticInfos:          []*ua.DiagnosticInfo{},
-		}
-		err := s.Channel.SendResponseWithContext(context.Background(), pubreq.ID, response)
+		err := s.publish(pubreq, notificationData(publishQueue, eventQueue))
 		if err != nil {
 			if s.srv.srv.cfg.logger != nil {
 				s.srv.srv.cfg.logger.Error("problem sending channel response: %v", err)
@@ -502,10 +474,104 @@ func (s *Subscription) run() {
 			return
 		}
 		if s.srv.srv.cfg.logger != nil {
-			s.srv.srv.cfg.logger.Debug("Published %d items OK for %d", len(publishQueue), s.ID)
+			s.srv.srv.cfg.logger.Debug("Published %d items and %d events OK for %d", len(publishQueue), len(eventQueue), s.ID)
 		}
 		// wait till we've got a publish request.
 	}
 }
 
+// publishStatus sends the pending notifications together with a
+// StatusChangeNotification to the client with the next publish request.
+func (s *Subscription) publishStatus(status ua.StatusCode, publishQueue map[uint32]*ua.MonitoredItemNotification, eventQueue []*ua.EventFieldList) {
+	var pubreq PubReq
+	select {
+	case <-s.shutdown:
+		return
+	case pubreq = <-s.Session.PublishRequests:
+	}
+
+	eo := notificationData(publishQueue, eventQueue)
+	eo = append(eo, ua.NewExtensionObject(&ua.StatusChangeNotification{
+		Status:         status,
+		DiagnosticInfo: &ua.DiagnosticInfo{},
+	}))
+	if err := s.publish(pubreq, eo); err != nil && s.srv.srv.cfg.logger != nil {
+		s.srv.srv.cfg.logger.Warn("problem sending status change to subscription #%d: %v", s.ID, err)
+	}
+}
+
+// notificationData returns the notifications for the pending data changes and events.
+func notificationData(publishQueue map[uint32]*ua.MonitoredItemNotification, eventQueue []*ua.EventFieldList) []*ua.ExtensionObject {
+	final_items := make([]*ua.MonitoredItemNotification, len(publishQueue))
+	i := 0
+	for k := range publishQueue {
+		final_items[i] = publishQueue[k]
+		i++
+	}
+
+	eo := make([]*ua.ExtensionObject, 0, 3)
+	if len(final_items) > 0 {
+		dcn := ua.DataChangeNotification{
+			MonitoredItems:  final_items,
+			DiagnosticInfos: []*ua.DiagnosticInfo{},
+		}
+		eo = append(eo, ua.NewExtensionObject(&dcn))
+	}
+	if len(eventQueue) > 0 {
+		enl := ua.EventNotificationList{
+			Events: eventQueue,
+		}
+		eo = append(eo, ua.NewExtensionObject(&enl))
+	}
+	return eo
+}
+
+// publish sends the notifications as the response to the publish request.
+func (s *Subscription) publish(pubreq PubReq, eo []*ua.ExtensionObject) error {
+	s.SequenceID++
+	if s.SequenceID == 0 {
+		// per the spec, the sequence ID cannot be 0
+		s.SequenceID = 1
+	}
+	if s.srv.srv.cfg.logger != nil {
+		s.srv.srv.cfg.logger.Debug("Got publish req on sub #%d.  Sequence %d", s.ID, s.SequenceID)
+	}
+	// then get all the tags and send them back to the client
+
+	//for x := range pubreq.Req.SubscriptionAcknowledgements {
+	//a := pubreq.Req.SubscriptionAcknowledgements[x]
+	//delete(s.SeqNums, a.SequenceNumber)
+	//}
+
+	for i := range eo {
+		eo[i].UpdateMask()
+	}
+
+	msg := ua.NotificationMessage{
+		SequenceNumber:   s.SequenceID,
+		PublishTime:      time.Now(),
+		NotificationData: eo,
+	}
+	//s.SeqNums[s.SequenceID] = struct{}{}
+
+	response := &ua.PublishResponse{
+		ResponseHeader: &ua.ResponseHeader{
+			Timestamp:          time.Now(),
+			RequestHandle:      pubreq.Req.RequestHeader.RequestHandle,
+			ServiceResult:      ua.StatusOK,
+			ServiceDiagnostics: &ua.DiagnosticInfo{},
+			StringTable:        []string{},
+			AdditionalHeader:   ua.NewExtensionObject(nil),
+		},
+		SubscriptionID:           s.ID,
+		MoreNotifications:        false,
+		NotificationMessage:      &msg,
+		AvailableSequenceNumbers: []uint32{}, // an empty array indicates taht we don't support retransmission of messages
+		Results:                  []ua.StatusCode{},
+		DiagnosticInfos:          []*ua.DiagnosticInfo{},
+	}
+	s.srv.srv.setTypeIDs(response)
+	return s.Channel.SendResponseWithContext(context.Background(), pubreq.ID, response)
+}
+
 //PublishRequest_Encoding_DefaultBinary
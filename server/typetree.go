@@ -0,0 +1,176 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// typeTree caches the HasSubtype hierarchy of the reference types,
+// object types, variable types and data types of a server.
+type typeTree struct {
+	// changes is incremented when type nodes are added or removed or
+	// when HasSubtype references change so that the tree is rebuilt
+	// before it is used the next time.
+	changes atomic.Uint64
+
+	mu      sync.RWMutex
+	built   bool
+	version uint64
+
+	// super and subs contain the supertype and the direct subtypes of
+	// the types by node id.
+	super map[string]*ua.NodeID
+	subs  map[string][]*ua.NodeID
+}
+
+// typeNodeClasses are the node classes of the nodes in the type tree.
+const typeNodeClasses = ua.NodeClassReferenceType | ua.NodeClassObjectType | ua.NodeClassVariableType | ua.NodeClassDataType
+
+// invalidate marks the type tree as outdated.
+func (t *typeTree) invalidate() {
+	t.changes.Add(1)
+}
+
+// typeChanged marks the type tree of the server as outdated if n is a
+// type node.
+func (ns *NodeNameSpace) typeChanged(n *Node) {
+	if ns.srv != nil && n.NodeClass()&typeNodeClasses != 0 {
+		ns.srv.types.invalidate()
+	}
+}
+
+// typeTree returns the type tree of the server and rebuilds it if nodes
+// have changed since it was built.
+func (s *Server) typeTree() *typeTree {
+	t := &s.types
+	v := t.changes.Load()
+	t.mu.RLock()
+	ok := t.built && t.version == v
+	t.mu.RUnlock()
+	if ok {
+		return t
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.built && t.version == v {
+		return t
+	}
+	t.super = map[string]*ua.NodeID{}
+	t.subs = map[string][]*ua.NodeID{}
+	for _, ns := range s.Namespaces() {
+		nns, ok := ns.(*NodeNameSpace)
+		if !ok {
+			// other namespaces do not contain types
+			continue
+		}
+		nns.mu.RLock()
+		nodes := append([]*Node(nil), nns.nodes...)
+		nns.mu.RUnlock()
+		for _, n := range nodes {
+			if n.NodeClass()&typeNodeClasses == 0 {
+				continue
+			}
+			for _, r := range n.refs {
+				if r.NodeID == nil || r.ReferenceTypeID == nil || !r.ReferenceTypeID.Equal(hasSubtype) {
+					continue
+				}
+				if r.IsForward {
+					t.add(n.ID(), r.NodeID.NodeID)
+				} else {
+					t.add(r.NodeID.NodeID, n.ID())
+				}
+			}
+		}
+	}
+	t.built, t.version = true, v
+	return t
+}
+
+// add adds the HasSubtype reference from super to sub. Types have only
+// one supertype and the first reference wins.
+func (t *typeTree) add(super, sub *ua.NodeID) {
+	k := sub.String()
+	if _, ok := t.super[k]; ok {
+		return
+	}
+	t.super[k] = super
+	t.subs[super.String()] = append(t.subs[super.String()], sub)
+}
+
+// superType returns the supertype of the type or nil.
+func (t *typeTree) superType(typ *ua.NodeID) *ua.NodeID {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.super[typ.String()]
+}
+
+// isSubtype returns true if typ is base or one of its subtypes.
+func (t *typeTree) isSubtype(typ, base *ua.NodeID) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	k := base.String()
+	// the depth limits loops in broken hierarchies
+	for i := 0; typ != nil && i < 100; i++ {
+		if typ.String() == k {
+			return true
+		}
+		typ = t.super[typ.String()]
+	}
+	return false
+}
+
+// subtypes returns the direct and indirect subtypes of the type.
+func (t *typeTree) subtypes(base *ua.NodeID) []*ua.NodeID {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var types []*ua.NodeID
+	seen := map[string]bool{base.String(): true}
+	queue := []*ua.NodeID{base}
+	for len(queue) > 0 {
+		for _, sub := range t.subs[queue[0].String()] {
+			if !seen[sub.String()] {
+				seen[sub.String()] = true
+				types = append(types, sub)
+				queue = append(queue, sub)
+			}
+		}
+		queue = queue[1:]
+	}
+	return types
+}
+
+// IsSubtype returns true if typ is the type base or one of its subtypes.
+// It works for reference types, object types, variable types and data
+// types, e.g. to check that an event type is a BaseEventType.
+func (s *Server) IsSubtype(typ, base *ua.NodeID) bool {
+	if typ == nil || base == nil {
+		return false
+	}
+	return s.typeTree().isSubtype(typ, base)
+}
+
+// Subtypes returns the direct and indirect subtypes of the type.
+func (s *Server) Subtypes(typ *ua.NodeID) []*ua.NodeID {
+	if typ == nil {
+		return nil
+	}
+	return s.typeTree().subtypes(typ)
+}
+
+// superType returns the type of which typ is a subtype or nil.
+func (s *Server) superType(typ *ua.NodeID) *ua.NodeID {
+	if typ == nil {
+		return nil
+	}
+	return s.typeTree().superType(typ)
+}
+
+// isEventType returns true if the node is BaseEventType or one of its
+// subtypes.
+func (s *Server) isEventType(typ *ua.NodeID) bool {
+	return s.IsSubtype(typ, ua.NewNumericNodeID(0, id.BaseEventType))
+}
@@ -0,0 +1,330 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// Variable is a variable node whose value has the Go type T.
+//
+// The DataType, the ValueRank and the ArrayDimensions of the node are
+// derived from T. Supported are the Go types which are supported by the
+// StructNamespace, the built-in types of the ua package, e.g.
+// *ua.NodeID or *ua.LocalizedText, and pointers to structures which are
+// registered with the TypeRegistry of the server or with
+// ua.RegisterExtensionObject, as well as slices of them.
+//
+//	speed, err := server.NewVariable(ns, ns.Objects(), nil, "Speed", 0.0)
+//	speed.Set(42)
+//	speed.Set(0, server.WithStatus(ua.StatusBadSensorFailure))
+type Variable[T any] struct {
+	*Node
+
+	srv  *Server
+	conv *valueConv
+
+	mu sync.RWMutex
+	v  T
+	dv *ua.DataValue
+}
+
+// NewVariable creates a variable with the browse name and the initial
+// value v and adds it to the namespace. If nodeID is nil the variable gets
+// the next numeric node id of the namespace. If parent is not nil the
+// variable becomes a component of parent or is organized by it if parent
+// is a folder.
+//
+// The variable can be read and written by clients. Use SetAccessLevel to
+// change this.
+func NewVariable[T any](ns *NodeNameSpace, parent *Node, nodeID *ua.NodeID, name string, v T) (*Variable[T], error) {
+	conv, err := ns.srv.valueConv(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	if nodeID == nil {
+		nodeID = ua.NewNumericNodeID(ns.ID(), ns.GetNextNodeID())
+	}
+	if ns.Node(nodeID) != nil {
+		return nil, fmt.Errorf("duplicate node id %s", nodeID)
+	}
+
+	access := byte(ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite)
+	attrs := Attributes{
+		ua.AttributeIDNodeClass:       DataValueFromValue(uint32(ua.NodeClassVariable)),
+		ua.AttributeIDBrowseName:      DataValueFromValue(&ua.QualifiedName{NamespaceIndex: ns.ID(), Name: name}),
+		ua.AttributeIDDisplayName:     DataValueFromValue(ua.NewLocalizedText(name)),
+		ua.AttributeIDDataType:        DataValueFromValue(ua.NewExpandedNodeID(conv.dataType, "", 0)),
+		ua.AttributeIDValueRank:       DataValueFromValue(conv.rank),
+		ua.AttributeIDAccessLevel:     DataValueFromValue(access),
+		ua.AttributeIDUserAccessLevel: DataValueFromValue(access),
+	}
+	if conv.rank == 1 {
+		attrs[ua.AttributeIDArrayDimensions] = DataValueFromValue([]uint32{conv.dim})
+	}
+
+	vr := &Variable[T]{srv: ns.srv, conv: conv}
+	vr.Set(v)
+	vr.Node = NewNode(nodeID, attrs, nil, vr.dataValue)
+	vr.Node.setValue = vr.write
+	if td := ns.srv.Node(ua.NewNumericNodeID(0, id.BaseDataVariableType)); td != nil {
+		vr.Node.AddRef(td, id.HasTypeDefinition, true)
+	}
+	ns.AddNode(vr.Node)
+	ns.linkChild(parent, vr.Node)
+	return vr, nil
+}
+
+// SetOption is an option for Variable.Set.
+type SetOption func(*ua.DataValue)
+
+// WithStatus sets the status code of the value. The default is
+// ua.StatusOK.
+func WithStatus(status ua.StatusCode) SetOption {
+	return func(dv *ua.DataValue) {
+		dv.Status = status
+		if status != ua.StatusOK {
+			dv.EncodingMask |= ua.DataValueStatusCode
+		} else {
+			dv.EncodingMask &^= ua.DataValueStatusCode
+		}
+	}
+}
+
+// WithSourceTimestamp sets the source timestamp of the value. The
+// default is the time of the call to Set.
+func WithSourceTimestamp(t time.Time) SetOption {
+	return func(dv *ua.DataValue) {
+		dv.SourceTimestamp = t
+	}
+}
+
+// Get returns the current value of the variable.
+func (vr *Variable[T]) Get() T {
+	vr.mu.RLock()
+	defer vr.mu.RUnlock()
+	return vr.v
+}
+
+// Set sets the value of the variable and notifies the subscribers. The
+// source and the server timestamp are set to the current time.
+func (vr *Variable[T]) Set(v T, opts ...SetOption) {
+	now := time.Now()
+	dv := &ua.DataValue{
+		EncodingMask:    ua.DataValueValue | ua.DataValueSourceTimestamp | ua.DataValueServerTimestamp,
+		Value:           ua.MustVariant(vr.conv.toUA(reflect.ValueOf(&v).Elem())),
+		SourceTimestamp: now,
+		ServerTimestamp: now,
+	}
+	for _, opt := range opts {
+		opt(dv)
+	}
+
+	vr.mu.Lock()
+	vr.v, vr.dv = v, dv
+	vr.mu.Unlock()
+
+	// the variable is not yet part of the address space when it is
+	// created.
+	if vr.Node != nil {
+		vr.srv.ChangeNotification(vr.ID())
+	}
+}
+
+// SetAccessLevel sets the AccessLevel and the UserAccessLevel of the
+// variable, e.g. to ua.AccessLevelTypeCurrentRead for read-only values.
+func (vr *Variable[T]) SetAccessLevel(l ua.AccessLevelType) {
+	vr.Node.SetAttribute(ua.AttributeIDAccessLevel, DataValueFromValue(byte(l)))
+	vr.Node.SetAttribute(ua.AttributeIDUserAccessLevel, DataValueFromValue(byte(l)))
+}
+
+func (vr *Variable[T]) dataValue() *ua.DataValue {
+	vr.mu.RLock()
+	defer vr.mu.RUnlock()
+	return vr.dv
+}
+
+// write stores a value which has been written by a client.
+func (vr *Variable[T]) write(dv *ua.DataValue) ua.StatusCode {
+	if dv == nil || dv.Value == nil {
+		return ua.StatusBadTypeMismatch
+	}
+	rv, ok := vr.conv.fromUA(dv.Value.Value())
+	if !ok {
+		return ua.StatusBadTypeMismatch
+	}
+	vr.mu.Lock()
+	vr.v, vr.dv = rv.Interface().(T), dv
+	vr.mu.Unlock()
+	return ua.StatusOK
+}
+
+// valueConv converts the values of a Go type from and to the values of
+// variants.
+type valueConv struct {
+	t        reflect.Type
+	dataType *ua.NodeID
+	rank     int32
+	dim      uint32
+
+	// builtin is set for the types of the ua package which are stored
+	// in variants as they are and structure is set for the types which
+	// are stored in extension objects. Values of all other types are
+	// converted like the fields of a StructNamespace.
+	builtin   bool
+	structure bool
+}
+
+// builtinTypes are the DataTypes of the built-in types of the ua package
+// which are stored in variants as they are.
+var builtinTypes = map[reflect.Type]uint32{
+	reflect.TypeOf(&ua.GUID{}):           id.GUID,
+	reflect.TypeOf(ua.XMLElement("")):    id.XMLElement,
+	reflect.TypeOf(&ua.NodeID{}):         id.NodeID,
+	reflect.TypeOf(&ua.ExpandedNodeID{}): id.ExpandedNodeID,
+	reflect.TypeOf(ua.StatusCode(0)):     id.StatusCode,
+	reflect.TypeOf(&ua.QualifiedName{}):  id.QualifiedName,
+	reflect.TypeOf(&ua.LocalizedText{}):  id.LocalizedText,
+	reflect.TypeOf(&ua.DataValue{}):      id.DataValue,
+	reflect.TypeOf(&ua.Variant{}):        id.BaseDataType,
+	reflect.TypeOf(&ua.DiagnosticInfo{}): id.DiagnosticInfo,
+}
+
+// valueConv returns the converter for values of type t or an error if
+// the type is not supported.
+func (s *Server) valueConv(t reflect.Type) (*valueConv, error) {
+	c := &valueConv{t: t, rank: -1}
+	et := t
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 || t.Kind() == reflect.Array {
+		et, c.rank = t.Elem(), 1
+		if t.Kind() == reflect.Array {
+			c.dim = uint32(t.Len())
+		}
+	}
+
+	if dt, ok := builtinTypes[et]; ok {
+		c.dataType, c.builtin = ua.NewNumericNodeID(0, dt), true
+		return c, nil
+	}
+	if et.Kind() == reflect.Pointer && et.Elem().Kind() == reflect.Struct {
+		dt := s.structureDataType(reflect.New(et.Elem()).Interface())
+		if dt == nil {
+			return nil, fmt.Errorf("%s is not a registered structure", et)
+		}
+		c.dataType, c.structure = dt, true
+		return c, nil
+	}
+	dt, rank, ok := fieldDataType(t)
+	if !ok {
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+	c.dataType, c.rank = ua.NewNumericNodeID(0, uint32(dt)), rank
+	return c, nil
+}
+
+// structureDataType returns the DataType of the structure v from the
+// HasEncoding reference of its encoding or Structure if the encoding is
+// not part of the address space. It returns nil if the type of v is not
+// registered.
+func (s *Server) structureDataType(v any) *ua.NodeID {
+	enc := s.encodingID(v)
+	if enc == nil {
+		return nil
+	}
+	if dt := s.encodedDataType(enc); dt != nil {
+		return dt
+	}
+	return ua.NewNumericNodeID(0, id.Structure)
+}
+
+// encodingID returns the id of the Default Binary encoding of the type
+// of v in the type registry of the server or in the types registered with
+// ua.RegisterExtensionObject. It returns nil if the type is not registered.
+func (s *Server) encodingID(v any) *ua.NodeID {
+	if s.cfg.types != nil {
+		if enc := s.cfg.types.LookupWithNamespaces(v, s.namespaceURIs()); enc != nil {
+			return enc
+		}
+	}
+	if eid := ua.ExtensionObjectTypeID(v); eid != nil && eid.NodeID.IntID() != 0 {
+		return eid.NodeID
+	}
+	return nil
+}
+
+// toUA returns the value of a variant for v.
+func (c *valueConv) toUA(v reflect.Value) any {
+	switch {
+	case c.structure && c.rank == 1:
+		eos := make([]*ua.ExtensionObject, v.Len())
+		for i := range eos {
+			eos[i] = ua.NewExtensionObject(v.Index(i).Interface())
+		}
+		return eos
+	case c.structure:
+		if v.IsNil() {
+			return nil
+		}
+		return ua.NewExtensionObject(v.Interface())
+	case c.builtin && c.rank == 1 && v.Kind() == reflect.Array:
+		s := reflect.MakeSlice(reflect.SliceOf(c.t.Elem()), v.Len(), v.Len())
+		reflect.Copy(s, v)
+		return s.Interface()
+	case c.builtin:
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return nil
+		}
+		return v.Interface()
+	default:
+		return fieldUAValue(v)
+	}
+}
+
+// fromUA converts the value of a variant to the Go type. It returns false
+// if the value has a different type.
+func (c *valueConv) fromUA(x any) (reflect.Value, bool) {
+	switch {
+	case c.structure && c.rank == 1:
+		eos, ok := x.([]*ua.ExtensionObject)
+		if !ok || c.t.Kind() == reflect.Array && len(eos) != c.t.Len() {
+			return reflect.Value{}, false
+		}
+		s := reflect.New(c.t).Elem()
+		if c.t.Kind() == reflect.Slice {
+			s = reflect.MakeSlice(c.t, len(eos), len(eos))
+		}
+		for i, eo := range eos {
+			if eo == nil || reflect.TypeOf(eo.Value) != c.t.Elem() {
+				return reflect.Value{}, false
+			}
+			s.Index(i).Set(reflect.ValueOf(eo.Value))
+		}
+		return s, true
+	case c.structure:
+		eo, ok := x.(*ua.ExtensionObject)
+		if !ok || eo == nil || reflect.TypeOf(eo.Value) != c.t {
+			return reflect.Value{}, false
+		}
+		return reflect.ValueOf(eo.Value), true
+	case c.builtin && c.rank == 1 && c.t.Kind() == reflect.Array:
+		v := reflect.ValueOf(x)
+		if !v.IsValid() || v.Type() != reflect.SliceOf(c.t.Elem()) || v.Len() != c.t.Len() {
+			return reflect.Value{}, false
+		}
+		a := reflect.New(c.t).Elem()
+		reflect.Copy(a, v)
+		return a, true
+	case c.builtin:
+		v := reflect.ValueOf(x)
+		if !v.IsValid() || v.Type() != c.t {
+			return reflect.Value{}, false
+		}
+		return v, true
+	default:
+		return fieldGoValue(x, c.t)
+	}
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/schema"
+	"github.com/gopcua/opcua/ua"
+)
+
+// View is a subset of the nodes and references of the address space.
+// Browse and TranslateBrowsePathsToNodeIDs requests for a view only
+// return the nodes and references of the view.
+//
+// A reference is part of the view if its source and its target node are
+// part of the view or if it has been added with AddReference, e.g. to
+// include the HasTypeDefinition references to the types.
+//
+// https://reference.opcfoundation.org/Core/Part3/v105/docs/5.4
+type View struct {
+	node *Node
+	srv  *Server
+
+	mu    sync.RWMutex
+	nodes map[string]bool
+	refs  map[string]bool
+}
+
+// AddView adds a view with the browse name to the namespace and organizes
+// it in the Views folder. The view contains only its own node until nodes
+// are added.
+func (ns *NodeNameSpace) AddView(name string) *View {
+	n := NewNode(
+		ua.NewNumericNodeID(ns.ID(), ns.GetNextNodeID()),
+		Attributes{
+			ua.AttributeIDNodeClass:       DataValueFromValue(uint32(ua.NodeClassView)),
+			ua.AttributeIDBrowseName:      DataValueFromValue(&ua.QualifiedName{NamespaceIndex: ns.ID(), Name: name}),
+			ua.AttributeIDDisplayName:     DataValueFromValue(ua.NewLocalizedText(name)),
+			ua.AttributeIDContainsNoLoops: DataValueFromValue(false),
+			ua.AttributeIDEventNotifier:   DataValueFromValue(byte(0)),
+		},
+		nil,
+		nil,
+	)
+	ns.AddNode(n)
+	if folder := ns.srv.Node(ua.NewNumericNodeID(0, id.ViewsFolder)); folder != nil {
+		folder.AddRef(n, id.Organizes, true)
+		n.AddRef(folder, id.Organizes, false)
+	}
+	return ns.srv.addView(n)
+}
+
+// addView registers the view with the node.
+func (s *Server) addView(n *Node) *View {
+	v := &View{
+		node:  n,
+		srv:   s,
+		nodes: map[string]bool{n.ID().String(): true},
+		refs:  map[string]bool{},
+	}
+	s.mu.Lock()
+	s.views[n.ID().String()] = v
+	s.mu.Unlock()
+	return v
+}
+
+// View returns the view with the node id or nil if there is no view with
+// this id.
+func (s *Server) View(nid *ua.NodeID) *View {
+	if nid == nil {
+		return nil
+	}
+	s.mu.Lock()
+	v := s.views[nid.String()]
+	s.mu.Unlock()
+	if v == nil || s.Node(nid) == nil {
+		// the view node has been deleted
+		return nil
+	}
+	return v
+}
+
+// Node returns the View node of the view.
+func (v *View) Node() *Node {
+	return v.node
+}
+
+// AddNodes adds the nodes to the view. The nodes which have no parent
+// in the view are organized by the View node so that they can be found
+// by browsing the view from its View node.
+func (v *View) AddNodes(nodes ...*Node) {
+	v.mu.Lock()
+	for _, n := range nodes {
+		v.nodes[n.ID().String()] = true
+	}
+	var roots []*Node
+	for _, n := range nodes {
+		if !v.hasParent(n) {
+			roots = append(roots, n)
+		}
+	}
+	v.mu.Unlock()
+
+	for _, n := range roots {
+		v.organize(n)
+	}
+}
+
+// hasParent returns true if the node is the target of a hierarchical
+// reference from a node of the view. The caller must hold v.mu.
+func (v *View) hasParent(n *Node) bool {
+	hierarchical := ua.NewNumericNodeID(0, id.HierarchicalReferences)
+	for _, r := range n.refs {
+		if !r.IsForward && r.NodeID != nil && v.nodes[r.NodeID.NodeID.String()] && v.srv.IsSubtype(r.ReferenceTypeID, hierarchical) {
+			return true
+		}
+	}
+	return false
+}
+
+// organize adds an Organizes reference from the View node to the node
+// unless it exists already.
+func (v *View) organize(n *Node) {
+	if n == v.node || hasRef(v.node, ua.NewNumericNodeID(0, id.Organizes), n.ID(), true) {
+		return
+	}
+	v.node.AddRef(n, id.Organizes, true)
+	n.AddRef(v.node, id.Organizes, false)
+}
+
+// AddTree adds the node and all nodes which can be reached from it by
+// forward hierarchical references to the view, e.g. the folder of a
+// production line with all of its machines. Like with AddNodes the node
+// is organized by the View node if it has no parent in the view.
+func (v *View) AddTree(root *Node) {
+	hierarchical := ua.NewNumericNodeID(0, id.HierarchicalReferences)
+	var nodes []*Node
+	seen := map[string]bool{root.ID().String(): true}
+	queue := []*Node{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		nodes = append(nodes, n)
+		for _, r := range n.refs {
+			if !r.IsForward || r.NodeID == nil || seen[r.NodeID.NodeID.String()] || !v.srv.IsSubtype(r.ReferenceTypeID, hierarchical) {
+				continue
+			}
+			seen[r.NodeID.NodeID.String()] = true
+			if t := v.srv.Node(r.NodeID.NodeID); t != nil {
+				queue = append(queue, t)
+			}
+		}
+	}
+	v.AddNodes(nodes...)
+}
+
+// AddReference adds the reference of the type rt from src to target and
+// its inverse reference to the view. The nodes are not added to the view.
+func (v *View) AddReference(src *Node, rt RefType, target *Node) {
+	rtID := ua.NewNumericNodeID(0, uint32(rt))
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.refs[refKey(src.ID(), rtID, target.ID(), true)] = true
+	v.refs[refKey(target.ID(), rtID, src.ID(), false)] = true
+}
+
+// Contains returns true if the node is part of the view.
+func (v *View) Contains(nid *ua.NodeID) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.nodes[nid.String()]
+}
+
+// containsRef returns true if the reference of the node is part of the
+// view.
+func (v *View) containsRef(src *ua.NodeID, r *ua.ReferenceDescription) bool {
+	if r.NodeID == nil {
+		return false
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.nodes[src.String()] && v.nodes[r.NodeID.NodeID.String()] {
+		return true
+	}
+	return v.refs[refKey(src, r.ReferenceTypeID, r.NodeID.NodeID, r.IsForward)]
+}
+
+// viewsImportNodeSet registers the views of the nodeset. A view contains
+// the nodes which can be reached from the View node by hierarchical
+// references. It runs after the references have been imported.
+func (srv *Server) viewsImportNodeSet(views []*schema.UAView, nsm namespaceMap) {
+	for _, uv := range views {
+		nid, err := nsm.parseNodeID(uv.NodeIdAttr)
+		if err != nil {
+			continue
+		}
+		n := srv.Node(nid)
+		if n == nil {
+			continue
+		}
+		srv.addView(n).AddTree(n)
+	}
+}
+
+// browseView returns the view of the browse request. The status code is
+// ua.StatusOK if no view is requested.
+func (s *Server) browseView(vd *ua.ViewDescription) (*View, ua.StatusCode) {
+	if vd == nil || vd.ViewID == nil || vd.ViewID.Equal(ua.NewNumericNodeID(0, 0)) {
+		return nil, ua.StatusOK
+	}
+	v := s.View(vd.ViewID)
+	switch {
+	case v == nil:
+		return nil, ua.StatusBadViewIDUnknown
+	case !vd.Timestamp.IsZero():
+		// views have no history
+		return nil, ua.StatusBadViewTimestampInvalid
+	case vd.ViewVersion != 0:
+		return nil, ua.StatusBadViewVersionInvalid
+	}
+	return v, ua.StatusOK
+}
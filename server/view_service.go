@@ -1,7 +1,7 @@
 package server
 
 import (
-	"slices"
+	"math"
 	"time"
 
 	"github.com/gopcua/opcua/id"
@@ -45,6 +45,11 @@ func (s *ViewService) Browse(sc *uasc.SecureChannel, r ua.Request, reqID uint32)
 		DiagnosticInfos: []*ua.DiagnosticInfo{{}},
 	}
 
+	view, status := s.srv.browseView(req.View)
+	if status != ua.StatusOK {
+		return nil, status
+	}
+
 	for i := range req.NodesToBrowse {
 		br := req.NodesToBrowse[i]
 		if s.srv.cfg.logger != nil {
@@ -55,7 +60,32 @@ func (s *ViewService) Browse(sc *uasc.SecureChannel, r ua.Request, reqID uint32)
 			resp.Results[i] = &ua.BrowseResult{StatusCode: ua.StatusBad}
 			continue
 		}
-		resp.Results[i] = ns.Browse(br)
+		if view != nil && !view.Contains(br.NodeID) {
+			resp.Results[i] = &ua.BrowseResult{StatusCode: ua.StatusBadNodeNotInView}
+			continue
+		}
+		res := ns.Browse(br)
+		if res != nil && view != nil {
+			var refs []*ua.ReferenceDescription
+			for _, r := range res.References {
+				if view.containsRef(br.NodeID, r) {
+					refs = append(refs, r)
+				}
+			}
+			res.References = refs
+		}
+		if res != nil && br.ResultMask != uint32(ua.BrowseResultMaskAll) {
+			// the namespaces return references which may be shared
+			// with the nodes.
+			refs := make([]*ua.ReferenceDescription, len(res.References))
+			for j, r := range res.References {
+				x := *r
+				refs[j] = &x
+			}
+			applyResultMask(refs, br.ResultMask)
+			res.References = refs
+		}
+		resp.Results[i] = res
 	}
 
 	return resp, nil
@@ -97,43 +127,46 @@ func suitableDirection(bd ua.BrowseDirection, isForward bool) bool {
 	}
 }
 
+// suitableRefType returns true if the reference type ref2 is the
+// reference type ref1 of the browse description or, if subtypes is set,
+// one of its subtypes.
 func suitableRefType(srv *Server, ref1, ref2 *ua.NodeID, subtypes bool) bool {
-	if ref1.Equal(ua.NewNumericNodeID(0, 0)) {
+	if ref1 == nil || ref1.Equal(ua.NewNumericNodeID(0, 0)) {
 		// refType is not specified in browse description. Return all types
 		return true
 	}
 	if ref1.Equal(ref2) {
 		return true
 	}
-	hasRef2Fn := func(nid *ua.NodeID) bool { return nid.Equal(ref2) }
-	hasSubtypeFn := func(nid *ua.NodeID) bool { return nid.Equal(hasSubtype) }
-	oktypes := getSubRefs(srv, ref1)
-	if !subtypes && slices.ContainsFunc(oktypes, hasSubtypeFn) {
-		for n := slices.IndexFunc(oktypes, hasSubtypeFn); n > 0; {
-			slices.Delete(oktypes, n, n+1)
-		}
-	}
-	return slices.ContainsFunc(oktypes, hasRef2Fn)
+	return subtypes && srv.IsSubtype(ref2, ref1)
 }
 
-func getSubRefs(srv *Server, nid *ua.NodeID) []*ua.NodeID {
-	var refs []*ua.NodeID
-	ns, err := srv.Namespace(int(nid.Namespace()))
-	if err != nil {
-		// TODO: return error
-		return nil
-	}
-	node := ns.Node(nid)
-	if node == nil {
-		return nil
-	}
-	for _, ref := range node.refs {
-		if ref.ReferenceTypeID.Equal(hasSubtype) && ref.IsForward && ref.NodeID != nil {
-			refs = append(refs, ref.NodeID.NodeID)
-			refs = append(refs, getSubRefs(srv, ref.NodeID.NodeID)...)
+// applyResultMask resets the fields of the references which are not
+// requested by the result mask of the browse description.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/5.8.2.2
+func applyResultMask(refs []*ua.ReferenceDescription, mask uint32) {
+	m := ua.BrowseResultMask(mask)
+	for _, r := range refs {
+		if m&ua.BrowseResultMaskReferenceTypeID == 0 {
+			r.ReferenceTypeID = ua.NewTwoByteNodeID(0)
+		}
+		if m&ua.BrowseResultMaskIsForward == 0 {
+			r.IsForward = false
+		}
+		if m&ua.BrowseResultMaskNodeClass == 0 {
+			r.NodeClass = ua.NodeClassUnspecified
+		}
+		if m&ua.BrowseResultMaskBrowseName == 0 {
+			r.BrowseName = &ua.QualifiedName{}
+		}
+		if m&ua.BrowseResultMaskDisplayName == 0 {
+			r.DisplayName = &ua.LocalizedText{}
+		}
+		if m&ua.BrowseResultMaskTypeDefinition == 0 {
+			r.TypeDefinition = ua.NewTwoByteExpandedNodeID(0)
 		}
 	}
-	return refs
 }
 
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.8.3
@@ -159,7 +192,120 @@ func (s *ViewService) TranslateBrowsePathsToNodeIDs(sc *uasc.SecureChannel, r ua
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+	if len(req.BrowsePaths) == 0 {
+		return &ua.TranslateBrowsePathsToNodeIDsResponse{ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadNothingToDo)}, nil
+	}
+
+	results := make([]*ua.BrowsePathResult, len(req.BrowsePaths))
+	for i, bp := range req.BrowsePaths {
+		results[i] = s.translateBrowsePath(bp)
+	}
+	return &ua.TranslateBrowsePathsToNodeIDsResponse{
+		ResponseHeader:  responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		Results:         results,
+		DiagnosticInfos: []*ua.DiagnosticInfo{},
+	}, nil
+}
+
+// translateBrowsePath returns the nodes which can be reached from the
+// starting node by the relative path. If the starting node is a View node
+// then the path is followed only through the nodes and references of the
+// view.
+func (s *ViewService) translateBrowsePath(bp *ua.BrowsePath) *ua.BrowsePathResult {
+	res := &ua.BrowsePathResult{Targets: []*ua.BrowsePathTarget{}}
+	if bp == nil || bp.StartingNode == nil {
+		res.StatusCode = ua.StatusBadNodeIDInvalid
+		return res
+	}
+	if bp.RelativePath == nil || len(bp.RelativePath.Elements) == 0 {
+		res.StatusCode = ua.StatusBadNothingToDo
+		return res
+	}
+	start := s.srv.Node(bp.StartingNode)
+	if start == nil {
+		res.StatusCode = ua.StatusBadNodeIDUnknown
+		return res
+	}
+	var view *View
+	if start.NodeClass() == ua.NodeClassView {
+		if view = s.srv.View(start.ID()); view == nil {
+			res.StatusCode = ua.StatusBadViewIDUnknown
+			return res
+		}
+	}
+
+	elems := bp.RelativePath.Elements
+	nodes := []*ua.NodeID{start.ID()}
+	for i, el := range elems {
+		if el == nil || el.TargetName == nil || el.TargetName.Name == "" {
+			// only the last element may omit the target name
+			if i < len(elems)-1 || el == nil {
+				res.StatusCode = ua.StatusBadBrowseNameInvalid
+				return res
+			}
+		}
+		dir := ua.BrowseDirectionForward
+		if el.IsInverse {
+			dir = ua.BrowseDirectionInverse
+		}
+
+		var next []*ua.NodeID
+		seen := map[string]bool{}
+		for _, nid := range nodes {
+			ns, err := s.srv.Namespace(int(nid.Namespace()))
+			if err != nil {
+				continue
+			}
+			br := ns.Browse(&ua.BrowseDescription{
+				NodeID:          nid,
+				BrowseDirection: dir,
+				ReferenceTypeID: el.ReferenceTypeID,
+				IncludeSubtypes: el.IncludeSubtypes,
+				ResultMask:      uint32(ua.BrowseResultMaskAll),
+			})
+			if br == nil {
+				continue
+			}
+			for _, r := range br.References {
+				if r.NodeID == nil || seen[r.NodeID.NodeID.String()] {
+					continue
+				}
+				if view != nil && !view.containsRef(nid, r) {
+					continue
+				}
+				if el.TargetName != nil && el.TargetName.Name != "" && !sameBrowseName(s.srv.browseName(r), el.TargetName) {
+					continue
+				}
+				seen[r.NodeID.NodeID.String()] = true
+				next = append(next, r.NodeID.NodeID)
+			}
+		}
+		if len(next) == 0 {
+			res.StatusCode = ua.StatusBadNoMatch
+			return res
+		}
+		nodes = next
+	}
+
+	for _, nid := range nodes {
+		res.Targets = append(res.Targets, &ua.BrowsePathTarget{
+			TargetID:           ua.NewExpandedNodeID(nid, "", 0),
+			RemainingPathIndex: math.MaxUint32,
+		})
+	}
+	return res
+}
+
+// browseName returns the browse name of the target of the reference.
+func (s *Server) browseName(r *ua.ReferenceDescription) *ua.QualifiedName {
+	if n := s.Node(r.NodeID.NodeID); n != nil {
+		return n.BrowseName()
+	}
+	return r.BrowseName
+}
+
+func sameBrowseName(a, b *ua.QualifiedName) bool {
+	return a != nil && b != nil && a.NamespaceIndex == b.NamespaceIndex && a.Name == b.Name
 }
 
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.8.5
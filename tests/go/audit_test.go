@@ -0,0 +1,191 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAuditEvents verifies that the server reports an audit event
+// on the Server object when a client writes a value and that the old
+// value is left out when the client is not allowed to read it.
+func TestAuditEvents(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer(server.EnableAuditing())
+	defer srv.Close()
+
+	ns := server.NewNodeNameSpace(srv, "urn:gopcua:test:audit")
+	denied := ns.AddNewVariableStringNode("Denied", int32(7))
+	denied.OnRead(func(a *server.AttributeAccess, v *ua.DataValue) (*ua.DataValue, ua.StatusCode) {
+		return nil, ua.StatusBadUserAccessDenied
+	})
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	v, err := c.Node(ua.NewNumericNodeID(0, id.Server_Auditing)).Value(ctx)
+	require.NoError(t, err, "Read Auditing failed")
+	require.Equal(t, true, v.Value())
+
+	notifyCh := make(chan *opcua.PublishNotificationData, 10)
+	sub, err := c.Subscribe(ctx, &opcua.SubscriptionParameters{Interval: 50 * time.Millisecond}, notifyCh)
+	require.NoError(t, err, "Subscribe failed")
+	defer sub.Cancel(ctx)
+
+	fields := []string{"EventType", "SourceNode", "Status", "NewValue", "OldValue"}
+	selects := make([]*ua.SimpleAttributeOperand, len(fields))
+	for i, name := range fields {
+		selects[i] = &ua.SimpleAttributeOperand{
+			TypeDefinitionID: ua.NewNumericNodeID(0, id.BaseEventType),
+			BrowsePath:       []*ua.QualifiedName{{Name: name}},
+			AttributeID:      ua.AttributeIDValue,
+		}
+	}
+	filter := &ua.EventFilter{
+		SelectClauses: selects,
+		WhereClause: &ua.ContentFilter{
+			Elements: []*ua.ContentFilterElement{{
+				FilterOperator: ua.FilterOperatorOfType,
+				FilterOperands: []*ua.ExtensionObject{
+					ua.NewExtensionObject(&ua.LiteralOperand{
+						Value: ua.MustVariant(ua.NewNumericNodeID(0, id.AuditWriteUpdateEventType)),
+					}),
+				},
+			}},
+		},
+	}
+	res, err := sub.Monitor(ctx, ua.TimestampsToReturnBoth, &ua.MonitoredItemCreateRequest{
+		ItemToMonitor: &ua.ReadValueID{
+			NodeID:       ua.NewNumericNodeID(0, id.Server),
+			AttributeID:  ua.AttributeIDEventNotifier,
+			DataEncoding: &ua.QualifiedName{},
+		},
+		MonitoringMode: ua.MonitoringModeReporting,
+		RequestedParameters: &ua.MonitoringParameters{
+			ClientHandle: 42,
+			Filter:       ua.NewExtensionObject(filter),
+			QueueSize:    10,
+		},
+	})
+	require.NoError(t, err, "Monitor failed")
+	require.Equal(t, ua.StatusOK, res.Results[0].StatusCode)
+
+	nodeID := ua.NewStringNodeID(1, "rw_int32")
+	_, err = c.Write(ctx, &ua.WriteRequest{
+		NodesToWrite: []*ua.WriteValue{{
+			NodeID:      nodeID,
+			AttributeID: ua.AttributeIDValue,
+			Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(int32(9))},
+		}, {
+			NodeID:      denied.ID(),
+			AttributeID: ua.AttributeIDValue,
+			Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(int32(8))},
+		}},
+	})
+	require.NoError(t, err, "Write failed")
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case <-timeout:
+			t.Fatal("timeout waiting for audit event")
+		case msg := <-notifyCh:
+			require.NoError(t, msg.Error)
+			evs, ok := msg.Value.(*ua.EventNotificationList)
+			if !ok {
+				continue
+			}
+			require.Len(t, evs.Events, 2)
+			ev := evs.Events[0].EventFields
+			require.Equal(t, ua.NewNumericNodeID(0, id.AuditWriteUpdateEventType), ev[0].Value())
+			require.Equal(t, nodeID, ev[1].Value())
+			require.Equal(t, true, ev[2].Value())
+			require.Equal(t, int32(9), ev[3].Value())
+			require.Equal(t, int32(5), ev[4].Value())
+
+			ev = evs.Events[1].EventFields
+			require.Equal(t, denied.ID(), ev[1].Value())
+			require.Equal(t, int32(8), ev[3].Value())
+			require.Nil(t, ev[4].Value(), "old value of a denied read")
+			return
+		}
+	}
+}
+
+// TestEventFilterElementOperands verifies that event filters whose
+// element operands refer to the same or an earlier element are rejected.
+func TestEventFilterElementOperands(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	sub, err := c.Subscribe(ctx, &opcua.SubscriptionParameters{Interval: 50 * time.Millisecond}, make(chan *opcua.PublishNotificationData, 10))
+	require.NoError(t, err, "Subscribe failed")
+	defer sub.Cancel(ctx)
+
+	monitor := func(indexes ...uint32) ua.StatusCode {
+		t.Helper()
+		ofType := &ua.ContentFilterElement{
+			FilterOperator: ua.FilterOperatorOfType,
+			FilterOperands: []*ua.ExtensionObject{
+				ua.NewExtensionObject(&ua.LiteralOperand{Value: ua.MustVariant(ua.NewNumericNodeID(0, id.BaseEventType))}),
+			},
+		}
+		and := &ua.ContentFilterElement{FilterOperator: ua.FilterOperatorAnd}
+		for _, idx := range indexes {
+			and.FilterOperands = append(and.FilterOperands, ua.NewExtensionObject(&ua.ElementOperand{Index: idx}))
+		}
+		filter := &ua.EventFilter{
+			SelectClauses: []*ua.SimpleAttributeOperand{{
+				TypeDefinitionID: ua.NewNumericNodeID(0, id.BaseEventType),
+				BrowsePath:       []*ua.QualifiedName{{Name: "EventType"}},
+				AttributeID:      ua.AttributeIDValue,
+			}},
+			WhereClause: &ua.ContentFilter{Elements: []*ua.ContentFilterElement{and, ofType}},
+		}
+		res, err := sub.Monitor(ctx, ua.TimestampsToReturnBoth, &ua.MonitoredItemCreateRequest{
+			ItemToMonitor: &ua.ReadValueID{
+				NodeID:       ua.NewNumericNodeID(0, id.Server),
+				AttributeID:  ua.AttributeIDEventNotifier,
+				DataEncoding: &ua.QualifiedName{},
+			},
+			MonitoringMode: ua.MonitoringModeReporting,
+			RequestedParameters: &ua.MonitoringParameters{
+				ClientHandle: 1,
+				Filter:       ua.NewExtensionObject(filter),
+				QueueSize:    10,
+			},
+		})
+		require.NoError(t, err, "Monitor failed")
+		return res.Results[0].StatusCode
+	}
+
+	require.Equal(t, ua.StatusBadEventFilterInvalid, monitor(0, 1), "self reference")
+	require.Equal(t, ua.StatusBadEventFilterInvalid, monitor(1, 2), "index out of range")
+	require.Equal(t, ua.StatusOK, monitor(1, 1))
+
+	// events are still evaluated after the invalid filters have been rejected
+	srv.ReportEvent(ua.NewNumericNodeID(0, id.Server), &server.Event{Message: "test"})
+	_, err = c.Node(ua.NewNumericNodeID(0, id.Server_ServerStatus_State)).Value(ctx)
+	require.NoError(t, err, "Read failed")
+}
@@ -0,0 +1,173 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// addTestDataType adds a structure DataType with the definition and its
+// Default Binary encoding. The structure is not registered with the ua
+// package so the client has to resolve it from the server. A nil
+// definition adds a DataType without the DataTypeDefinition attribute.
+func addTestDataType(srv *server.Server, ns *server.NodeNameSpace, name string, dt, enc *ua.NodeID, def *ua.StructureDefinition) *server.Node {
+	attrs := server.Attributes{
+		ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassDataType)),
+		ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: ns.ID(), Name: name}),
+		ua.AttributeIDDisplayName: server.DataValueFromValue(ua.NewLocalizedText(name)),
+		ua.AttributeIDIsAbstract:  server.DataValueFromValue(false),
+	}
+	if def != nil {
+		def.DefaultEncodingID = enc
+		def.BaseDataType = ua.NewNumericNodeID(0, id.Structure)
+		attrs[ua.AttributeIDDataTypeDefinition] = server.DataValueFromValue(ua.NewExtensionObject(def))
+	}
+	n := server.NewNode(dt, attrs, nil, nil)
+	ns.AddNode(n)
+	base := srv.Node(ua.NewNumericNodeID(0, id.Structure))
+	base.AddRef(n, id.HasSubtype, true)
+	n.AddRef(base, id.HasSubtype, false)
+
+	e := server.NewNode(enc, server.Attributes{
+		ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassObject)),
+		ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{Name: "Default Binary"}),
+		ua.AttributeIDDisplayName: server.DataValueFromValue(ua.NewLocalizedText("Default Binary")),
+	}, nil, nil)
+	e.AddRef(srv.Node(ua.NewNumericNodeID(0, id.DataTypeEncodingType)), id.HasTypeDefinition, true)
+	ns.AddNode(e)
+	n.AddRef(e, id.HasEncoding, true)
+	e.AddRef(n, id.HasEncoding, false)
+	return e
+}
+
+// TestClientResolvesStructures verifies that the client decodes the
+// values of structures it does not know with the DataTypeDefinition from
+// the server and that it can write them back.
+func TestClientResolvesStructures(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	ns := server.NewNodeNameSpace(srv, "urn:gopcua:test:clienttypes")
+	idx := ns.ID()
+	pointID, shapeID := ua.NewNumericNodeID(idx, 6000), ua.NewNumericNodeID(idx, 6010)
+	pointDef := &ua.StructureDefinition{
+		StructureType: ua.StructureTypeStructure,
+		Fields: []*ua.StructureField{
+			{Name: "X", Description: ua.NewLocalizedText(""), DataType: ua.NewNumericNodeID(0, id.Double), ValueRank: -1},
+			{Name: "Y", Description: ua.NewLocalizedText(""), DataType: ua.NewNumericNodeID(0, id.Double), ValueRank: -1},
+		},
+	}
+	shapeDef := &ua.StructureDefinition{
+		StructureType: ua.StructureTypeStructureWithOptionalFields,
+		Fields: []*ua.StructureField{
+			{Name: "Name", Description: ua.NewLocalizedText(""), DataType: ua.NewNumericNodeID(0, id.String), ValueRank: -1},
+			{Name: "Origin", Description: ua.NewLocalizedText(""), DataType: pointID, ValueRank: -1, IsOptional: true},
+			{Name: "Duration", Description: ua.NewLocalizedText(""), DataType: ua.NewNumericNodeID(0, id.Duration), ValueRank: -1},
+		},
+	}
+	addTestDataType(srv, ns, "Point", pointID, ua.NewNumericNodeID(idx, 6001), pointDef)
+	addTestDataType(srv, ns, "Shape", shapeID, ua.NewNumericNodeID(idx, 6011), shapeDef)
+
+	// the server encodes the value with descriptors which are not
+	// registered so that the client cannot decode them by itself.
+	builtin := func(dt *ua.NodeID) ua.TypeID {
+		switch {
+		case dt.Equal(ua.NewNumericNodeID(0, id.Duration)):
+			return ua.TypeIDDouble
+		case dt.Namespace() == 0:
+			return ua.TypeID(dt.IntID())
+		}
+		return ua.TypeIDNull
+	}
+	point := ua.NewStructureDescriptor("Point", pointID, pointDef, builtin)
+	shape := ua.NewStructureDescriptor("Shape", shapeID, shapeDef, builtin)
+	shape.Fields[1].Structure = point
+	value := ua.NewDynamicStructure(shape)
+	require.NoError(t, value.Set("Name", "circle"))
+	require.NoError(t, value.Set("Duration", 2.5))
+
+	nid := ua.NewStringNodeID(idx, "Shape1")
+	ns.AddNode(server.NewNode(nid, server.Attributes{
+		ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassVariable)),
+		ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: idx, Name: "Shape1"}),
+		ua.AttributeIDDataType:    server.DataValueFromValue(ua.NewExpandedNodeID(shapeID, "", 0)),
+		ua.AttributeIDValueRank:   server.DataValueFromValue(int32(-1)),
+		ua.AttributeIDAccessLevel: server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite)),
+	}, nil, func() *ua.DataValue { return server.DataValueFromValue(ua.NewExtensionObject(value)) }))
+	getShape, err := ns.Objects().AddMethod("GetShape", nil,
+		[]server.Argument{{Name: "Shape", DataType: shapeID, ValueRank: -1}},
+		func(*server.MethodCall, []*ua.Variant) ([]*ua.Variant, ua.StatusCode) {
+			return []*ua.Variant{ua.MustVariant(ua.NewExtensionObject(value))}, ua.StatusOK
+		},
+	)
+	require.NoError(t, err, "AddMethod failed")
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	v, err := c.Node(nid).Value(ctx)
+	require.NoError(t, err, "Read failed")
+	got, ok := v.Value().(*ua.ExtensionObject).Value.(*ua.DynamicStructure)
+	require.True(t, ok, "got %T", v.Value().(*ua.ExtensionObject).Value)
+	require.Equal(t, "Shape", got.Type.Name)
+	require.Equal(t, map[string]any{"Name": "circle", "Duration": 2.5}, got.Map())
+
+	// the output arguments of methods are resolved
+	res, err := c.Call(ctx, &ua.CallMethodRequest{ObjectID: ns.Objects().ID(), MethodID: getShape.ID()})
+	require.NoError(t, err, "Call failed")
+	require.Equal(t, ua.StatusOK, res.StatusCode)
+	out, ok := res.OutputArguments[0].Value().(*ua.ExtensionObject).Value.(*ua.DynamicStructure)
+	require.True(t, ok, "got %T", res.OutputArguments[0].Value().(*ua.ExtensionObject).Value)
+	require.Equal(t, "circle", out.Get("Name"))
+
+	// the values of data change notifications are resolved
+	notifyCh := make(chan *opcua.PublishNotificationData, 4)
+	sub, err := c.Subscribe(ctx, &opcua.SubscriptionParameters{Interval: 50 * time.Millisecond}, notifyCh)
+	require.NoError(t, err, "Subscribe failed")
+	defer sub.Cancel(ctx)
+	_, err = sub.Monitor(ctx, ua.TimestampsToReturnBoth, opcua.NewMonitoredItemCreateRequestWithDefaults(nid, ua.AttributeIDValue, 1))
+	require.NoError(t, err, "Monitor failed")
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for data change")
+	case msg := <-notifyCh:
+		require.NoError(t, msg.Error)
+		dc, ok := msg.Value.(*ua.DataChangeNotification)
+		require.True(t, ok, "got %T", msg.Value)
+		eo := dc.MonitoredItems[0].Value.Value.Value().(*ua.ExtensionObject)
+		_, ok = eo.Value.(*ua.DynamicStructure)
+		require.True(t, ok, "got %T", eo.Value)
+	}
+
+	// write the value back with the optional field
+	origin := ua.NewDynamicStructure(got.Type.Fields[1].Structure)
+	require.NoError(t, origin.Set("X", 1.0))
+	require.NoError(t, origin.Set("Y", 2.0))
+	require.NoError(t, got.Set("Origin", origin))
+	testWrite(t, ctx, c, ua.StatusOK, &ua.WriteRequest{
+		NodesToWrite: []*ua.WriteValue{{
+			NodeID:      nid,
+			AttributeID: ua.AttributeIDValue,
+			Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(ua.NewExtensionObject(got))},
+		}},
+	})
+
+	v, err = c.Node(nid).Value(ctx)
+	require.NoError(t, err, "Read failed")
+	got = v.Value().(*ua.ExtensionObject).Value.(*ua.DynamicStructure)
+	require.Equal(t, 2.0, got.Get("Origin").(*ua.DynamicStructure).Get("Y"))
+	require.Equal(t, "circle", got.Get("Name"))
+}
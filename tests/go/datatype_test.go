@@ -0,0 +1,178 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+type testColor int32
+
+type testPoint struct {
+	X, Y float64
+}
+
+type testCounter struct {
+	Count int32
+}
+
+type testMotor struct {
+	Name     string `description:"name of the motor"`
+	Speed    float64
+	Color    testColor
+	Position testPoint
+	Tags     []string
+	Status   ua.StatusCode
+}
+
+// TestStructureDataType verifies that structures and enumerations are
+// served with their DataTypeDefinition, encodings and enum properties.
+func TestStructureDataType(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	ns := server.NewNodeNameSpace(srv, "urn:gopcua:test:datatypes")
+	idx := ns.ID()
+	ua.RegisterExtensionObject(ua.NewNumericNodeID(idx, 5001), new(testPoint))
+	ua.RegisterExtensionObject(ua.NewNumericNodeID(idx, 5011), new(testMotor))
+	ua.RegisterExtensionObject(ua.NewNumericNodeID(idx, 5021), new(testCounter))
+
+	_, err := ns.AddStructureDataType("Motor", nil, new(testMotor))
+	require.Error(t, err, "fields of unknown types must be rejected")
+	_, err = ns.AddStructureDataType("Unregistered", nil, new(struct{ X int32 }))
+	require.Error(t, err, "unregistered structures must be rejected")
+
+	color, err := ns.AddEnumDataType("Color", ua.NewNumericNodeID(idx, 4000), testColor(0), []*ua.EnumField{
+		{Value: 0, Name: "Red"},
+		{Value: 1, Name: "Green", DisplayName: ua.NewLocalizedText("green")},
+	})
+	require.NoError(t, err, "AddEnumDataType failed")
+	mode, err := ns.AddEnumDataType("Mode", ua.NewNumericNodeID(idx, 4010), int32(0), []*ua.EnumField{
+		{Value: 1, Name: "Auto"},
+		{Value: 5, Name: "Manual"},
+	})
+	require.NoError(t, err, "AddEnumDataType failed")
+	point, err := ns.AddStructureDataType("Point", ua.NewNumericNodeID(idx, 5000), new(testPoint))
+	require.NoError(t, err, "AddStructureDataType failed")
+	motor, err := ns.AddStructureDataType("Motor", ua.NewNumericNodeID(idx, 5010), new(testMotor))
+	require.NoError(t, err, "AddStructureDataType failed")
+
+	// int32 fields are not described by the enumeration of int32
+	counter, err := ns.AddStructureDataType("Counter", ua.NewNumericNodeID(idx, 5020), new(testCounter))
+	require.NoError(t, err, "AddStructureDataType failed")
+	cdef, err := counter.Attribute(ua.AttributeIDDataTypeDefinition)
+	require.NoError(t, err)
+	require.Equal(t, "i=6", cdef.Value.Value.Value().(*ua.ExtensionObject).Value.(*ua.StructureDefinition).Fields[0].DataType.String())
+
+	value := &testMotor{Name: "M1", Speed: 1500, Color: 1, Position: testPoint{X: 1, Y: 2}, Tags: []string{"a"}, Status: ua.StatusUncertain}
+	v := server.NewNode(ua.NewStringNodeID(idx, "Motor1"), server.Attributes{
+		ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassVariable)),
+		ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: idx, Name: "Motor1"}),
+		ua.AttributeIDDataType:    server.DataValueFromValue(ua.NewExpandedNodeID(motor.ID(), "", 0)),
+		ua.AttributeIDValueRank:   server.DataValueFromValue(int32(-1)),
+		ua.AttributeIDAccessLevel: server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite)),
+	}, nil, func() *ua.DataValue { return server.DataValueFromValue(ua.NewExtensionObject(value)) })
+	ns.AddNode(v)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	def, err := c.Node(motor.ID()).Attribute(ctx, ua.AttributeIDDataTypeDefinition)
+	require.NoError(t, err, "Read failed")
+	sd, ok := def.Value().(*ua.ExtensionObject).Value.(*ua.StructureDefinition)
+	require.True(t, ok, "got %T", def.Value())
+	require.Equal(t, ua.NewNumericNodeID(idx, 5011).String(), sd.DefaultEncodingID.String())
+	require.Equal(t, "i=22", sd.BaseDataType.String())
+	type field struct {
+		name string
+		dt   string
+		rank int32
+	}
+	var fields []field
+	for _, f := range sd.Fields {
+		fields = append(fields, field{f.Name, f.DataType.String(), f.ValueRank})
+	}
+	require.Equal(t, []field{
+		{"Name", "i=12", -1},
+		{"Speed", "i=11", -1},
+		{"Color", color.ID().String(), -1},
+		{"Position", point.ID().String(), -1},
+		{"Tags", "i=12", 1},
+		{"Status", "i=19", -1},
+	}, fields)
+	require.Equal(t, "name of the motor", sd.Fields[0].Description.Text)
+
+	def, err = c.Node(color.ID()).Attribute(ctx, ua.AttributeIDDataTypeDefinition)
+	require.NoError(t, err, "Read failed")
+	ed, ok := def.Value().(*ua.ExtensionObject).Value.(*ua.EnumDefinition)
+	require.True(t, ok, "got %T", def.Value())
+	require.Len(t, ed.Fields, 2)
+
+	encs, err := c.Node(motor.ID()).References(ctx, id.HasEncoding, ua.BrowseDirectionForward, ua.NodeClassAll, false)
+	require.NoError(t, err, "Browse failed")
+	require.Len(t, encs, 1)
+	require.Equal(t, "Default Binary", encs[0].BrowseName.Name)
+	require.Equal(t, ua.NewNumericNodeID(idx, 5011).String(), encs[0].NodeID.NodeID.String())
+
+	subtypes, err := c.Node(ua.NewNumericNodeID(0, id.Structure)).ReferencedNodes(ctx, id.HasSubtype, ua.BrowseDirectionForward, ua.NodeClassAll, false)
+	require.NoError(t, err, "Browse failed")
+	require.True(t, containsNode(subtypes, motor.ID()), "Motor is not a subtype of Structure")
+
+	property := func(dt *ua.NodeID) (string, any) {
+		t.Helper()
+		props, err := c.Node(dt).ReferencedNodes(ctx, id.HasProperty, ua.BrowseDirectionForward, ua.NodeClassAll, false)
+		require.NoError(t, err, "Browse failed")
+		require.Len(t, props, 1)
+		name, err := props[0].BrowseName(ctx)
+		require.NoError(t, err, "Read failed")
+		v, err := props[0].Value(ctx)
+		require.NoError(t, err, "Read failed")
+		return name.Name, v.Value()
+	}
+	name, strs := property(color.ID())
+	require.Equal(t, "EnumStrings", name)
+	require.Equal(t, []*ua.LocalizedText{ua.NewLocalizedText("Red"), ua.NewLocalizedText("green")}, strs)
+	name, vals := property(mode.ID())
+	require.Equal(t, "EnumValues", name)
+	require.Equal(t, int64(5), vals.([]*ua.ExtensionObject)[1].Value.(*ua.EnumValueType).Value)
+
+	got, err := c.Node(v.ID()).Value(ctx)
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, value, got.Value().(*ua.ExtensionObject).Value)
+
+	testWrite(t, ctx, c, ua.StatusOK, &ua.WriteRequest{
+		NodesToWrite: []*ua.WriteValue{{
+			NodeID:      v.ID(),
+			AttributeID: ua.AttributeIDValue,
+			Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(ua.NewExtensionObject(&testMotor{Name: "M2"}))},
+		}},
+	})
+	testWrite(t, ctx, c, ua.StatusBadTypeMismatch, &ua.WriteRequest{
+		NodesToWrite: []*ua.WriteValue{{
+			NodeID:      v.ID(),
+			AttributeID: ua.AttributeIDValue,
+			Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(ua.NewExtensionObject(&testPoint{}))},
+		}},
+	})
+}
+
+func containsNode(nodes []*opcua.Node, nid *ua.NodeID) bool {
+	for _, n := range nodes {
+		if n.ID.Equal(nid) {
+			return true
+		}
+	}
+	return false
+}
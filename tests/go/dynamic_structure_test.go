@@ -0,0 +1,163 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+const structureNodeSet = `<?xml version="1.0" encoding="utf-8"?>
+<UANodeSet xmlns="http://opcfoundation.org/UA/2011/03/UANodeSet.xsd">
+  <NamespaceUris>
+    <Uri>urn:gopcua:test:structures</Uri>
+  </NamespaceUris>
+  <Aliases>
+    <Alias Alias="Double">i=11</Alias>
+    <Alias Alias="String">i=12</Alias>
+    <Alias Alias="HasSubtype">i=45</Alias>
+    <Alias Alias="HasEncoding">i=38</Alias>
+    <Alias Alias="HasTypeDefinition">i=40</Alias>
+  </Aliases>
+  <UADataType NodeId="ns=1;i=3000" BrowseName="1:Point">
+    <DisplayName>Point</DisplayName>
+    <References>
+      <Reference ReferenceType="HasSubtype" IsForward="false">i=22</Reference>
+      <Reference ReferenceType="HasEncoding">ns=1;i=3001</Reference>
+    </References>
+    <Definition Name="1:Point">
+      <Field Name="X" DataType="Double" />
+      <Field Name="Y" DataType="Double" />
+    </Definition>
+  </UADataType>
+  <UADataType NodeId="ns=1;i=3010" BrowseName="1:Shape">
+    <DisplayName>Shape</DisplayName>
+    <References>
+      <Reference ReferenceType="HasSubtype" IsForward="false">i=22</Reference>
+      <Reference ReferenceType="HasEncoding">ns=1;i=3011</Reference>
+    </References>
+    <Definition Name="1:Shape">
+      <Field Name="Name" DataType="String" />
+      <Field Name="Origin" DataType="ns=1;i=3000" IsOptional="true" />
+      <Field Name="Points" DataType="ns=1;i=3000" ValueRank="1" />
+    </Definition>
+  </UADataType>
+  <UADataType NodeId="ns=1;i=3020" BrowseName="1:Mode">
+    <DisplayName>Mode</DisplayName>
+    <References>
+      <Reference ReferenceType="HasSubtype" IsForward="false">i=29</Reference>
+    </References>
+    <Definition Name="1:Mode">
+      <Field Name="Auto" Value="1" />
+      <Field Name="Manual" Value="2" />
+    </Definition>
+  </UADataType>
+  <UAObject NodeId="ns=1;i=3001" BrowseName="Default Binary">
+    <DisplayName>Default Binary</DisplayName>
+    <References>
+      <Reference ReferenceType="HasTypeDefinition">i=76</Reference>
+    </References>
+  </UAObject>
+  <UAObject NodeId="ns=1;i=3011" BrowseName="Default Binary">
+    <DisplayName>Default Binary</DisplayName>
+    <References>
+      <Reference ReferenceType="HasTypeDefinition">i=76</Reference>
+    </References>
+  </UAObject>
+</UANodeSet>`
+
+// TestDynamicStructure verifies that the structures of an imported
+// nodeset are served with their DataTypeDefinition and that their values
+// can be read and written without a Go type.
+func TestDynamicStructure(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	require.NoError(t, srv.ImportNodeSet(parseNodeSet(t, structureNodeSet)), "ImportNodeSet failed")
+	idx := namespaceIndex(t, srv, "urn:gopcua:test:structures")
+
+	shape := srv.StructureByDataTypeID(ua.NewNumericNodeID(idx, 3010))
+	require.NotNil(t, shape, "Shape is not registered")
+	require.Equal(t, ua.NewNumericNodeID(idx, 3011).String(), shape.EncodingID.String())
+	require.Equal(t, shape, srv.StructureByEncodingID(ua.NewNumericNodeID(idx, 3011)))
+	require.Nil(t, srv.StructureByDataTypeID(ua.NewNumericNodeID(idx, 3020)), "enumerations are not structures")
+	point := srv.StructureByDataTypeID(ua.NewNumericNodeID(idx, 3000))
+	require.NotNil(t, point, "Point is not registered")
+	require.Equal(t, point, shape.Fields[1].Structure)
+
+	// the descriptors belong to the server and are not shared with the
+	// clients of the process.
+	require.Nil(t, ua.StructureByDataTypeID(ua.NewNumericNodeID(idx, 3010)))
+	require.Nil(t, ua.StructureByEncodingID(ua.NewNumericNodeID(idx, 3011)))
+
+	newPoint := func(x, y float64) *ua.DynamicStructure {
+		p := ua.NewDynamicStructure(point)
+		require.NoError(t, p.Set("X", x))
+		require.NoError(t, p.Set("Y", y))
+		return p
+	}
+	value := ua.NewDynamicStructure(shape)
+	require.NoError(t, value.Set("Name", "line"))
+	require.NoError(t, value.Set("Points", []*ua.DynamicStructure{newPoint(0, 0), newPoint(1, 1)}))
+
+	ns, err := srv.Namespace(int(idx))
+	require.NoError(t, err)
+	nid := ua.NewStringNodeID(idx, "Shape1")
+	ns.AddNode(server.NewNode(nid, server.Attributes{
+		ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassVariable)),
+		ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: idx, Name: "Shape1"}),
+		ua.AttributeIDDataType:    server.DataValueFromValue(ua.NewExpandedNodeID(ua.NewNumericNodeID(idx, 3010), "", 0)),
+		ua.AttributeIDValueRank:   server.DataValueFromValue(int32(-1)),
+		ua.AttributeIDAccessLevel: server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite)),
+	}, nil, func() *ua.DataValue { return server.DataValueFromValue(ua.NewExtensionObject(value)) }))
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	def, err := c.Node(ua.NewNumericNodeID(idx, 3010)).Attribute(ctx, ua.AttributeIDDataTypeDefinition)
+	require.NoError(t, err, "Read failed")
+	sd, ok := def.Value().(*ua.ExtensionObject).Value.(*ua.StructureDefinition)
+	require.True(t, ok, "got %T", def.Value())
+	require.Equal(t, ua.StructureTypeStructureWithOptionalFields, sd.StructureType)
+	require.Len(t, sd.Fields, 3)
+	require.True(t, sd.Fields[1].IsOptional)
+	require.Equal(t, int32(1), sd.Fields[2].ValueRank)
+
+	def, err = c.Node(ua.NewNumericNodeID(idx, 3020)).Attribute(ctx, ua.AttributeIDDataTypeDefinition)
+	require.NoError(t, err, "Read failed")
+	ed, ok := def.Value().(*ua.ExtensionObject).Value.(*ua.EnumDefinition)
+	require.True(t, ok, "got %T", def.Value())
+	require.Equal(t, int64(2), ed.Fields[1].Value)
+
+	v, err := c.Node(nid).Value(ctx)
+	require.NoError(t, err, "Read failed")
+	got, ok := v.Value().(*ua.ExtensionObject).Value.(*ua.DynamicStructure)
+	require.True(t, ok, "got %T", v.Value().(*ua.ExtensionObject).Value)
+	require.Equal(t, "line", got.Get("Name"))
+	require.Nil(t, got.Get("Origin"))
+	require.Len(t, got.Get("Points"), 2)
+
+	require.NoError(t, got.Set("Origin", newPoint(2, 3)))
+	testWrite(t, ctx, c, ua.StatusOK, &ua.WriteRequest{
+		NodesToWrite: []*ua.WriteValue{{
+			NodeID:      nid,
+			AttributeID: ua.AttributeIDValue,
+			Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(ua.NewExtensionObject(got))},
+		}},
+	})
+
+	v, err = c.Node(nid).Value(ctx)
+	require.NoError(t, err, "Read failed")
+	got = v.Value().(*ua.ExtensionObject).Value.(*ua.DynamicStructure)
+	require.Equal(t, 3.0, got.Get("Origin").(*ua.DynamicStructure).Get("Y"))
+}
@@ -0,0 +1,51 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMultipleEndpoints verifies that the server listens on all
+// configured endpoints and returns the endpoint urls with the host
+// name which the client used.
+func TestMultipleEndpoints(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer(server.EndPoint("", 4841))
+	defer srv.Close()
+
+	require.Contains(t, srv.URLs(), "opc.tcp://localhost:4840")
+	require.Contains(t, srv.URLs(), "opc.tcp://127.0.0.1:4841")
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"opc.tcp://localhost:4840", "opc.tcp://localhost:4840"},
+		{"opc.tcp://127.0.0.1:4841", "opc.tcp://127.0.0.1:4841"},
+		{"opc.tcp://localhost:4841", "opc.tcp://localhost:4841"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			eps, err := opcua.GetEndpoints(ctx, tt.url)
+			require.NoError(t, err, "GetEndpoints failed")
+			require.NotEmpty(t, eps)
+			for _, ep := range eps {
+				require.Equal(t, tt.want, ep.EndpointURL)
+			}
+
+			c, err := opcua.NewClient(tt.url, opcua.SecurityMode(ua.MessageSecurityModeNone))
+			require.NoError(t, err, "NewClient failed")
+			require.NoError(t, c.Connect(ctx), "Connect failed")
+			require.NoError(t, c.Close(ctx), "Close failed")
+		})
+	}
+}
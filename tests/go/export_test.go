@@ -0,0 +1,148 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/schema"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExportNodeSet verifies that a namespace which has been built in
+// code can be exported as NodeSet2 XML and imported into another server.
+func TestExportNodeSet(t *testing.T) {
+	srv := server.New(server.EndPoint("localhost", 4840))
+
+	server.NewNodeNameSpace(srv, "urn:gopcua:test:other")
+	ns := server.NewNodeNameSpace(srv, "urn:gopcua:test:export")
+	objects := srv.Node(ua.NewNumericNodeID(0, id.ObjectsFolder))
+	objects.AddRef(ns.Objects(), id.Organizes, true)
+
+	n := ns.AddNewVariableStringNode("temperature", float64(21.5))
+	ns.Objects().AddRef(n, id.HasComponent, true)
+	n.AddRef(ns.Objects(), id.HasComponent, false)
+	arr := ns.AddNewVariableNode("setpoints", []int32{1, 2, 3})
+	ns.Objects().AddRef(arr, id.HasComponent, true)
+	label := ns.AddNewVariableStringNode("label", ua.NewLocalizedTextWithLocale("Pump", "en"))
+	ns.Objects().AddRef(label, id.HasComponent, true)
+	ref := ns.AddNewVariableStringNode("ref", ns.Objects().ID())
+	ns.Objects().AddRef(ref, id.HasComponent, true)
+	n.SetAttribute(ua.AttributeIDAccessLevel, server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead|ua.AccessLevelTypeCurrentWrite)))
+	n.SetAttribute(ua.AttributeIDUserAccessLevel, server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead)))
+	n.SetAttribute(ua.AttributeIDMinimumSamplingInterval, server.DataValueFromValue(float64(250)))
+	n.SetAttribute(ua.AttributeIDHistorizing, server.DataValueFromValue(true))
+
+	shapeDef := &ua.StructureDefinition{
+		StructureType: ua.StructureTypeStructureWithOptionalFields,
+		Fields: []*ua.StructureField{
+			{Name: "Name", Description: ua.NewLocalizedText("name of the shape"), DataType: ua.NewNumericNodeID(0, id.String), ValueRank: -1},
+			{Name: "Points", Description: ua.NewLocalizedText(""), DataType: ua.NewNumericNodeID(0, id.Double), ValueRank: 1, ArrayDimensions: []uint32{4}, IsOptional: true},
+		},
+	}
+	addTestDataType(srv, ns, "Shape", ua.NewStringNodeID(ns.ID(), "Shape"), ua.NewStringNodeID(ns.ID(), "Shape_Encoding_DefaultBinary"), shapeDef)
+	_, err := ns.AddEnumDataType("Color", ua.NewStringNodeID(ns.ID(), "Color"), int32(0), []*ua.EnumField{
+		{Value: 0, Name: "Red"},
+		{Value: 1, Name: "Green", DisplayName: ua.NewLocalizedText("green")},
+	})
+	require.NoError(t, err, "AddEnumDataType failed")
+
+	set, err := srv.ExportNodeSet("urn:gopcua:test:export")
+	require.NoError(t, err, "ExportNodeSet failed")
+	require.Equal(t, []string{"urn:gopcua:test:export"}, set.NamespaceUris.Uri)
+	require.Equal(t, "urn:gopcua:test:export", set.Models.Model[0].ModelUriAttr)
+
+	b, err := xml.MarshalIndent(set, "", "  ")
+	require.NoError(t, err, "Marshal failed")
+	doc := string(b)
+	require.Contains(t, doc, `<UANodeSet xmlns="http://opcfoundation.org/UA/2011/03/UANodeSet.xsd"`)
+	require.Contains(t, doc, `<Alias Alias="HasComponent">i=47</Alias>`)
+	require.Contains(t, doc, `<UAVariable DataType="Double" ValueRank="-1" AccessLevel="3" UserAccessLevel="1" MinimumSamplingInterval="250" Historizing="true" ParentNodeId="ns=1;i=85" NodeId="ns=1;s=temperature" BrowseName="temperature">`)
+	require.Contains(t, doc, `<UAVariable DataType="Int32" ValueRank="1" NodeId="ns=1;i=101" BrowseName="setpoints">`)
+	require.Contains(t, doc, `<Double xmlns="http://opcfoundation.org/UA/2008/02/Types.xsd">21.5</Double>`)
+	require.Contains(t, doc, `<Field Name="Points" DataType="Double" ValueRank="1" ArrayDimensions="4" IsOptional="true"></Field>`)
+	require.Contains(t, doc, `<ListOfInt32 xmlns="http://opcfoundation.org/UA/2008/02/Types.xsd"><Int32>1</Int32><Int32>2</Int32><Int32>3</Int32></ListOfInt32>`)
+
+	var nodes schema.UANodeSet
+	require.NoError(t, xml.Unmarshal(b, &nodes), "Unmarshal failed")
+
+	dst := server.New(server.EndPoint("localhost", 4840))
+	require.NoError(t, dst.ImportNodeSet(&nodes), "ImportNodeSet failed")
+
+	idx := namespaceIndex(t, dst, "urn:gopcua:test:export")
+	imported := dst.Node(ua.NewStringNodeID(idx, "temperature"))
+	require.NotNil(t, imported)
+	require.Equal(t, ua.NodeClassVariable, imported.NodeClass())
+
+	dstNS, err := dst.Namespace(int(idx))
+	require.NoError(t, err)
+	res := dstNS.Browse(&ua.BrowseDescription{
+		NodeID:          ua.NewNumericNodeID(idx, id.ObjectsFolder),
+		BrowseDirection: ua.BrowseDirectionForward,
+		ReferenceTypeID: ua.NewNumericNodeID(0, id.HasComponent),
+		ResultMask:      uint32(ua.BrowseResultMaskAll),
+	})
+	require.Equal(t, ua.StatusGood, res.StatusCode)
+	require.Len(t, res.References, 4)
+
+	// the values are imported with the namespace indices of dst
+	value := func(nid *ua.NodeID) any {
+		t.Helper()
+		n := dst.Node(nid)
+		require.NotNil(t, n, "node %s not found", nid)
+		require.NotNil(t, n.Value(), "node %s has no value", nid)
+		return n.Value().Value.Value()
+	}
+	require.Equal(t, float64(21.5), value(imported.ID()))
+	require.Equal(t, []int32{1, 2, 3}, value(ua.NewNumericNodeID(idx, 101)))
+	require.Equal(t, ua.NewLocalizedTextWithLocale("Pump", "en"), value(ua.NewStringNodeID(idx, "label")))
+	require.Equal(t, ua.NewNumericNodeID(idx, id.ObjectsFolder).String(), value(ua.NewStringNodeID(idx, "ref")).(*ua.NodeID).String())
+
+	// the attributes of the variables and the definitions of the data
+	// types are imported with the namespace indices of dst
+	attr := func(nid *ua.NodeID, aid ua.AttributeID) any {
+		t.Helper()
+		v, err := dst.Node(nid).Attribute(aid)
+		require.NoError(t, err, "attribute %s of %s", aid, nid)
+		return v.Value.Value.Value()
+	}
+	require.Equal(t, "i=11", attr(imported.ID(), ua.AttributeIDDataType).(*ua.ExpandedNodeID).NodeID.String())
+	require.Equal(t, int32(-1), attr(imported.ID(), ua.AttributeIDValueRank))
+	require.Equal(t, byte(ua.AccessLevelTypeCurrentRead|ua.AccessLevelTypeCurrentWrite), attr(imported.ID(), ua.AttributeIDAccessLevel))
+	require.Equal(t, byte(ua.AccessLevelTypeCurrentRead), attr(imported.ID(), ua.AttributeIDUserAccessLevel))
+	require.Equal(t, float64(250), attr(imported.ID(), ua.AttributeIDMinimumSamplingInterval))
+	require.Equal(t, true, attr(imported.ID(), ua.AttributeIDHistorizing))
+
+	setpointsID := ua.NewNumericNodeID(idx, 101)
+	require.Equal(t, "i=6", attr(setpointsID, ua.AttributeIDDataType).(*ua.ExpandedNodeID).NodeID.String())
+	require.Equal(t, int32(1), attr(setpointsID, ua.AttributeIDValueRank))
+	require.Equal(t, byte(ua.AccessLevelTypeCurrentRead), attr(setpointsID, ua.AttributeIDAccessLevel), "default access level")
+
+	shape := attr(ua.NewStringNodeID(idx, "Shape"), ua.AttributeIDDataTypeDefinition).(*ua.ExtensionObject).Value.(*ua.StructureDefinition)
+	require.Equal(t, ua.StructureTypeStructureWithOptionalFields, shape.StructureType)
+	require.Equal(t, ua.NewStringNodeID(idx, "Shape_Encoding_DefaultBinary").String(), shape.DefaultEncodingID.String())
+	require.Len(t, shape.Fields, len(shapeDef.Fields))
+	for i, f := range shape.Fields {
+		want := shapeDef.Fields[i]
+		require.Equal(t, want.Name, f.Name)
+		require.Equal(t, want.Description.Text, f.Description.Text)
+		require.Equal(t, want.DataType.String(), f.DataType.String())
+		require.Equal(t, want.ValueRank, f.ValueRank)
+		require.Equal(t, want.ArrayDimensions, f.ArrayDimensions)
+		require.Equal(t, want.IsOptional, f.IsOptional)
+	}
+
+	color := attr(ua.NewStringNodeID(idx, "Color"), ua.AttributeIDDataTypeDefinition).(*ua.ExtensionObject).Value.(*ua.EnumDefinition)
+	require.Len(t, color.Fields, 2)
+	require.Equal(t, "Green", color.Fields[1].Name)
+	require.Equal(t, int64(1), color.Fields[1].Value)
+	require.Equal(t, "green", color.Fields[1].DisplayName.Text)
+
+	_, err = srv.ExportNodeSet("urn:gopcua:test:missing")
+	require.ErrorContains(t, err, "namespace urn:gopcua:test:missing not found")
+}
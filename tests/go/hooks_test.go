@@ -0,0 +1,164 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAttributeHooks verifies that the read and write hooks of a node and
+// its namespace are called with the session of the client and that they
+// can reject or replace values. Hooks which return a nil value leave the
+// value unchanged.
+func TestAttributeHooks(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	ns := server.NewNodeNameSpace(srv, "urn:gopcua:test:hooks")
+	setpoint := ns.AddNewVariableStringNode("Setpoint", int32(10))
+	ns.Objects().AddRef(setpoint, server.RefTypeIDHasComponent, true)
+
+	// the node only accepts setpoints up to 100
+	setpoint.OnWrite(func(a *server.AttributeAccess, v *ua.DataValue) (*ua.DataValue, ua.StatusCode) {
+		x, ok := v.Value.Value().(int32)
+		switch {
+		case !ok:
+			return nil, ua.StatusBadTypeMismatch
+		case x > 100:
+			return nil, ua.StatusBadOutOfRange
+		}
+		return nil, ua.StatusOK
+	})
+
+	// the namespace doubles all written values and records the sessions
+	var mu sync.Mutex
+	var accesses []*server.AttributeAccess
+	ns.OnWrite(func(a *server.AttributeAccess, v *ua.DataValue) (*ua.DataValue, ua.StatusCode) {
+		mu.Lock()
+		accesses = append(accesses, a)
+		mu.Unlock()
+		return server.DataValueFromValue(2 * v.Value.Value().(int32)), ua.StatusOK
+	})
+	ns.OnRead(func(a *server.AttributeAccess, v *ua.DataValue) (*ua.DataValue, ua.StatusCode) {
+		if a.IndexRange != "" {
+			return nil, ua.StatusBadUserAccessDenied
+		}
+		return nil, ua.StatusOK
+	})
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	write := func(val any) ua.StatusCode {
+		t.Helper()
+		res, err := c.Write(ctx, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      setpoint.ID(),
+				AttributeID: ua.AttributeIDValue,
+				Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(val)},
+			}},
+		})
+		require.NoError(t, err, "Write failed")
+		return res.Results[0]
+	}
+	require.Equal(t, ua.StatusBadOutOfRange, write(int32(101)))
+	require.Equal(t, ua.StatusBadTypeMismatch, write("ten"))
+	require.Equal(t, ua.StatusOK, write(int32(21)))
+
+	v, err := c.Node(setpoint.ID()).Value(ctx)
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, int32(42), v.Value())
+
+	res, err := c.Read(ctx, &ua.ReadRequest{
+		NodesToRead: []*ua.ReadValueID{{NodeID: setpoint.ID(), AttributeID: ua.AttributeIDValue, IndexRange: "0"}},
+	})
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, ua.StatusBadUserAccessDenied, res.Results[0].Status)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, accesses, 1, "namespace hook called for rejected writes")
+	require.NotNil(t, accesses[0].SessionID)
+	require.True(t, accesses[0].NodeID.Equal(setpoint.ID()))
+	require.Equal(t, ua.AttributeIDValue, accesses[0].AttributeID)
+}
+
+// TestReadHooksSubscription verifies that the read hooks are called for
+// the values of monitored items so that subscriptions cannot bypass them.
+func TestReadHooksSubscription(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	ns := server.NewNodeNameSpace(srv, "urn:gopcua:test:hooks:sub")
+	masked := ns.AddNewVariableStringNode("Masked", int32(1234))
+	denied := ns.AddNewVariableStringNode("Denied", int32(5678))
+
+	var mu sync.Mutex
+	var sessions []*ua.NodeID
+	masked.OnRead(func(a *server.AttributeAccess, v *ua.DataValue) (*ua.DataValue, ua.StatusCode) {
+		mu.Lock()
+		sessions = append(sessions, a.SessionID)
+		mu.Unlock()
+		return server.DataValueFromValue(int32(0)), ua.StatusOK
+	})
+	denied.OnRead(func(a *server.AttributeAccess, v *ua.DataValue) (*ua.DataValue, ua.StatusCode) {
+		return nil, ua.StatusBadUserAccessDenied
+	})
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	notifyCh := make(chan *opcua.PublishNotificationData, 10)
+	sub, err := c.Subscribe(ctx, &opcua.SubscriptionParameters{Interval: 50 * time.Millisecond}, notifyCh)
+	require.NoError(t, err, "Subscribe failed")
+	defer sub.Cancel(ctx)
+
+	_, err = sub.Monitor(ctx, ua.TimestampsToReturnBoth,
+		opcua.NewMonitoredItemCreateRequestWithDefaults(masked.ID(), ua.AttributeIDValue, 1),
+		opcua.NewMonitoredItemCreateRequestWithDefaults(denied.ID(), ua.AttributeIDValue, 2),
+	)
+	require.NoError(t, err, "Monitor failed")
+
+	// the initial values are sent when the items are created
+	got := map[uint32]*ua.DataValue{}
+	timeout := time.After(5 * time.Second)
+	for len(got) < 2 {
+		select {
+		case <-timeout:
+			t.Fatal("timeout waiting for data change")
+		case msg := <-notifyCh:
+			require.NoError(t, msg.Error)
+			dc, ok := msg.Value.(*ua.DataChangeNotification)
+			if !ok {
+				continue
+			}
+			for _, item := range dc.MonitoredItems {
+				got[item.ClientHandle] = item.Value
+			}
+		}
+	}
+	require.Equal(t, int32(0), got[1].Value.Value(), "masked value")
+	require.Equal(t, ua.StatusBadUserAccessDenied, got[2].Status, "denied value")
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, sessions)
+	require.NotNil(t, sessions[0], "session of the subscription")
+}
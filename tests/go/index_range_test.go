@@ -0,0 +1,139 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIndexRange verifies that index ranges select the elements of array
+// and string values in reads, writes and monitored items.
+func TestIndexRange(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	ns := server.NewNodeNameSpace(srv, "urn:gopcua:test:range")
+	variable := func(name string, dt uint32, rank int32, v any) *ua.NodeID {
+		attrs := server.Attributes{
+			ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassVariable)),
+			ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: ns.ID(), Name: name}),
+			ua.AttributeIDDataType:    server.DataValueFromValue(ua.NewNumericExpandedNodeID(0, dt)),
+			ua.AttributeIDValueRank:   server.DataValueFromValue(rank),
+			ua.AttributeIDAccessLevel: server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite)),
+		}
+		n := server.NewNode(ua.NewStringNodeID(ns.ID(), name), attrs, nil, func() *ua.DataValue { return server.DataValueFromValue(v) })
+		ns.AddNode(n)
+		return n.ID()
+	}
+	array := variable("Array", id.Int32, 1, []int32{1, 2, 3, 4, 5})
+	matrix := variable("Matrix", id.Int32, 2, [][]int32{{1, 2, 3}, {4, 5, 6}})
+	names := variable("Names", id.String, 1, []string{"alpha", "beta"})
+	scalar := variable("Scalar", id.Double, -1, 1.0)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	read := func(nid *ua.NodeID, r string) *ua.DataValue {
+		t.Helper()
+		res, err := c.Read(ctx, &ua.ReadRequest{
+			NodesToRead: []*ua.ReadValueID{{NodeID: nid, AttributeID: ua.AttributeIDValue, IndexRange: r}},
+		})
+		require.NoError(t, err, "Read failed")
+		return res.Results[0]
+	}
+	readTests := []struct {
+		name   string
+		id     *ua.NodeID
+		r      string
+		status ua.StatusCode
+		v      any
+	}{
+		{"element", array, "1", ua.StatusOK, []int32{2}},
+		{"range", array, "1:3", ua.StatusOK, []int32{2, 3, 4}},
+		{"truncated", array, "3:9", ua.StatusOK, []int32{4, 5}},
+		{"matrix", matrix, "0:1,1:2", ua.StatusOK, [][]int32{{2, 3}, {5, 6}}},
+		{"substring", names, "1,1:2", ua.StatusOK, []string{"et"}},
+		{"invalid", array, "3:1", ua.StatusBadIndexRangeInvalid, nil},
+		{"syntax", array, "a", ua.StatusBadIndexRangeInvalid, nil},
+		{"out of bounds", array, "5:6", ua.StatusBadIndexRangeNoData, nil},
+		{"scalar", scalar, "0", ua.StatusBadIndexRangeNoData, nil},
+		{"dimensions", array, "0,0", ua.StatusBadIndexRangeNoData, nil},
+	}
+	for _, tt := range readTests {
+		t.Run("read "+tt.name, func(t *testing.T) {
+			dv := read(tt.id, tt.r)
+			require.Equal(t, tt.status, dv.Status)
+			if tt.v != nil {
+				require.Equal(t, tt.v, dv.Value.Value())
+			}
+		})
+	}
+
+	write := func(nid *ua.NodeID, r string, v any) ua.StatusCode {
+		t.Helper()
+		res, err := c.Write(ctx, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      nid,
+				AttributeID: ua.AttributeIDValue,
+				IndexRange:  r,
+				Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(v)},
+			}},
+		})
+		require.NoError(t, err, "Write failed")
+		return res.Results[0]
+	}
+	require.Equal(t, ua.StatusOK, write(array, "1:2", []int32{7, 8}))
+	require.Equal(t, []int32{1, 7, 8, 4, 5}, read(array, "").Value.Value())
+	require.Equal(t, ua.StatusOK, write(matrix, "1,0", [][]int32{{9}}))
+	require.Equal(t, [][]int32{{1, 2, 3}, {9, 5, 6}}, read(matrix, "").Value.Value())
+	require.Equal(t, ua.StatusBadIndexRangeInvalid, write(array, "0:1", []int32{1}))
+	require.Equal(t, ua.StatusBadIndexRangeInvalid, write(array, "x", []int32{1}))
+	require.Equal(t, ua.StatusBadIndexRangeNoData, write(array, "4:5", []int32{1, 2}))
+	require.Equal(t, ua.StatusBadTypeMismatch, write(array, "0", []float64{1}))
+	require.Equal(t, []int32{1, 7, 8, 4, 5}, read(array, "").Value.Value())
+
+	notifyCh := make(chan *opcua.PublishNotificationData, 10)
+	sub, err := c.Subscribe(ctx, &opcua.SubscriptionParameters{Interval: 50 * time.Millisecond}, notifyCh)
+	require.NoError(t, err, "Subscribe failed")
+	defer sub.Cancel(ctx)
+
+	item := opcua.NewMonitoredItemCreateRequestWithDefaults(array, ua.AttributeIDValue, 42)
+	item.ItemToMonitor.IndexRange = "0:1"
+	invalid := opcua.NewMonitoredItemCreateRequestWithDefaults(array, ua.AttributeIDValue, 43)
+	invalid.ItemToMonitor.IndexRange = "1:0"
+	res, err := sub.Monitor(ctx, ua.TimestampsToReturnBoth, item, invalid)
+	require.NoError(t, err, "Monitor failed")
+	require.Equal(t, ua.StatusOK, res.Results[0].StatusCode)
+	require.Equal(t, ua.StatusBadIndexRangeInvalid, res.Results[1].StatusCode)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case <-timeout:
+			t.Fatal("timeout waiting for data change")
+		case msg := <-notifyCh:
+			require.NoError(t, msg.Error)
+			dc, ok := msg.Value.(*ua.DataChangeNotification)
+			if !ok {
+				continue
+			}
+			for _, item := range dc.MonitoredItems {
+				require.Equal(t, []int32{1, 7}, item.Value.Value.Value())
+				return
+			}
+		}
+	}
+}
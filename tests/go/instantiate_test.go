@@ -0,0 +1,206 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+const deviceNodeSet = `<?xml version="1.0" encoding="utf-8"?>
+<UANodeSet xmlns="http://opcfoundation.org/UA/2011/03/UANodeSet.xsd">
+  <NamespaceUris>
+    <Uri>urn:gopcua:test:device</Uri>
+  </NamespaceUris>
+  <Aliases>
+    <Alias Alias="HasSubtype">i=45</Alias>
+    <Alias Alias="HasComponent">i=47</Alias>
+    <Alias Alias="HasProperty">i=46</Alias>
+    <Alias Alias="HasTypeDefinition">i=40</Alias>
+    <Alias Alias="HasModellingRule">i=37</Alias>
+  </Aliases>
+  <UAObjectType NodeId="ns=1;i=1001" BrowseName="1:DeviceType">
+    <DisplayName>DeviceType</DisplayName>
+    <References>
+      <Reference ReferenceType="HasSubtype" IsForward="false">i=58</Reference>
+      <Reference ReferenceType="HasComponent">ns=1;i=1002</Reference>
+      <Reference ReferenceType="HasProperty">ns=1;i=1003</Reference>
+      <Reference ReferenceType="HasComponent">ns=1;i=1004</Reference>
+    </References>
+  </UAObjectType>
+  <UAVariable NodeId="ns=1;i=1002" BrowseName="1:Status" ParentNodeId="ns=1;i=1001">
+    <DisplayName>Status</DisplayName>
+    <References>
+      <Reference ReferenceType="HasTypeDefinition">i=63</Reference>
+      <Reference ReferenceType="HasModellingRule">i=78</Reference>
+    </References>
+  </UAVariable>
+  <UAVariable NodeId="ns=1;i=1003" BrowseName="1:SerialNumber" ParentNodeId="ns=1;i=1001">
+    <DisplayName>SerialNumber</DisplayName>
+    <References>
+      <Reference ReferenceType="HasTypeDefinition">i=68</Reference>
+      <Reference ReferenceType="HasModellingRule">i=80</Reference>
+    </References>
+  </UAVariable>
+  <UAVariable NodeId="ns=1;i=1004" BrowseName="1:Description" ParentNodeId="ns=1;i=1001">
+    <DisplayName>Description</DisplayName>
+    <References>
+      <Reference ReferenceType="HasTypeDefinition">i=63</Reference>
+      <Reference ReferenceType="HasModellingRule">i=80</Reference>
+    </References>
+  </UAVariable>
+  <UAObjectType NodeId="ns=1;i=2001" BrowseName="1:MachineType">
+    <DisplayName>MachineType</DisplayName>
+    <References>
+      <Reference ReferenceType="HasSubtype" IsForward="false">ns=1;i=1001</Reference>
+      <Reference ReferenceType="HasComponent">ns=1;i=2002</Reference>
+    </References>
+  </UAObjectType>
+  <UAVariable NodeId="ns=1;i=2002" BrowseName="1:Speed" ParentNodeId="ns=1;i=2001">
+    <DisplayName>Speed</DisplayName>
+    <References>
+      <Reference ReferenceType="HasTypeDefinition">i=63</Reference>
+      <Reference ReferenceType="HasModellingRule">i=78</Reference>
+    </References>
+  </UAVariable>
+</UANodeSet>`
+
+// TestInstantiate verifies that an instance of an ObjectType contains
+// the mandatory and the requested optional children of the type and its
+// supertypes and that the values of the children can be bound.
+func TestInstantiate(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	require.NoError(t, srv.ImportNodeSet(parseNodeSet(t, deviceNodeSet)), "ImportNodeSet failed")
+	nsi := namespaceIndex(t, srv, "urn:gopcua:test:device")
+	ns, err := srv.Namespace(int(nsi))
+	require.NoError(t, err)
+	nodeNS, ok := ns.(*server.NodeNameSpace)
+	require.True(t, ok)
+
+	_, err = nodeNS.Instantiate(ua.NewNumericNodeID(nsi, 1002), nil, "Status")
+	require.ErrorContains(t, err, "is not an ObjectType")
+
+	objects := srv.Node(ua.NewNumericNodeID(0, id.ObjectsFolder))
+	inst, err := nodeNS.Instantiate(ua.NewNumericNodeID(nsi, 2001), objects, "Machine1", server.WithOptional("SerialNumber"))
+	require.NoError(t, err, "Instantiate failed")
+	require.Equal(t, []string{"SerialNumber", "Speed", "Status"}, inst.Children())
+	require.Nil(t, inst.Child("Description"))
+
+	require.NoError(t, inst.SetValueFunc("Speed", func() *ua.DataValue { return server.DataValueFromValue(float64(42)) }))
+	require.Error(t, inst.SetValueFunc("Description", nil))
+
+	res := ns.Browse(&ua.BrowseDescription{
+		NodeID:          inst.Child("SerialNumber").ID(),
+		BrowseDirection: ua.BrowseDirectionForward,
+		ReferenceTypeID: ua.NewNumericNodeID(0, id.HasTypeDefinition),
+		ResultMask:      uint32(ua.BrowseResultMaskAll),
+	})
+	require.Equal(t, ua.StatusGood, res.StatusCode)
+	require.Len(t, res.References, 1)
+	require.True(t, res.References[0].NodeID.NodeID.Equal(ua.NewNumericNodeID(0, id.PropertyType)))
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	v, err := c.Node(inst.Child("Speed").ID()).Value(ctx)
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, float64(42), v.Value())
+
+	refs, err := c.Node(ua.NewNumericNodeID(0, id.ObjectsFolder)).ReferencedNodes(ctx, id.Organizes, ua.BrowseDirectionForward, ua.NodeClassAll, true)
+	require.NoError(t, err, "Browse failed")
+	var found bool
+	for _, n := range refs {
+		if n.ID.Equal(inst.Node().ID()) {
+			found = true
+		}
+	}
+	require.True(t, found, "instance is not organized by the Objects folder")
+}
+
+const loopNodeSet = `<?xml version="1.0" encoding="utf-8"?>
+<UANodeSet xmlns="http://opcfoundation.org/UA/2011/03/UANodeSet.xsd">
+  <NamespaceUris>
+    <Uri>urn:gopcua:test:loop</Uri>
+  </NamespaceUris>
+  <Aliases>
+    <Alias Alias="HasSubtype">i=45</Alias>
+    <Alias Alias="HasComponent">i=47</Alias>
+    <Alias Alias="HasTypeDefinition">i=40</Alias>
+    <Alias Alias="HasModellingRule">i=37</Alias>
+  </Aliases>
+  <UAObjectType NodeId="ns=1;i=1001" BrowseName="1:LoopType">
+    <DisplayName>LoopType</DisplayName>
+    <References>
+      <Reference ReferenceType="HasSubtype" IsForward="false">i=58</Reference>
+      <Reference ReferenceType="HasComponent">ns=1;i=1002</Reference>
+    </References>
+  </UAObjectType>
+  <UAObject NodeId="ns=1;i=1002" BrowseName="1:Next" ParentNodeId="ns=1;i=1001">
+    <DisplayName>Next</DisplayName>
+    <References>
+      <Reference ReferenceType="HasTypeDefinition">ns=1;i=1001</Reference>
+      <Reference ReferenceType="HasModellingRule">i=78</Reference>
+    </References>
+  </UAObject>
+  <UAObject NodeId="ns=1;i=78" BrowseName="1:Rule">
+    <DisplayName>Rule</DisplayName>
+  </UAObject>
+  <UAObjectType NodeId="ns=1;i=2001" BrowseName="1:ValveType">
+    <DisplayName>ValveType</DisplayName>
+    <References>
+      <Reference ReferenceType="HasSubtype" IsForward="false">i=58</Reference>
+      <Reference ReferenceType="HasComponent">ns=1;i=2002</Reference>
+      <Reference ReferenceType="HasComponent">ns=1;i=2003</Reference>
+    </References>
+  </UAObjectType>
+  <UAVariable NodeId="ns=1;i=2002" BrowseName="1:Position" ParentNodeId="ns=1;i=2001">
+    <DisplayName>Position</DisplayName>
+    <References>
+      <Reference ReferenceType="HasTypeDefinition">i=63</Reference>
+      <Reference ReferenceType="HasModellingRule">i=78</Reference>
+    </References>
+  </UAVariable>
+  <UAVariable NodeId="ns=1;i=2003" BrowseName="1:Other" ParentNodeId="ns=1;i=2001">
+    <DisplayName>Other</DisplayName>
+    <References>
+      <Reference ReferenceType="HasTypeDefinition">i=63</Reference>
+      <Reference ReferenceType="HasModellingRule">ns=1;i=78</Reference>
+    </References>
+  </UAVariable>
+</UANodeSet>`
+
+// TestInstantiateLoop verifies that a type which contains itself is
+// rejected and that only the modelling rules of namespace 0 are used.
+func TestInstantiateLoop(t *testing.T) {
+	srv := server.New(server.EndPoint("localhost", 4840))
+	require.NoError(t, srv.ImportNodeSet(parseNodeSet(t, loopNodeSet)), "ImportNodeSet failed")
+	nsi := namespaceIndex(t, srv, "urn:gopcua:test:loop")
+	ns, err := srv.Namespace(int(nsi))
+	require.NoError(t, err)
+	nodeNS := ns.(*server.NodeNameSpace)
+
+	objects := srv.Node(ua.NewNumericNodeID(0, id.ObjectsFolder))
+	next := nodeNS.GetNextNodeID()
+	_, err = nodeNS.Instantiate(ua.NewNumericNodeID(nsi, 1001), objects, "Loop")
+	require.ErrorContains(t, err, "child Next has the type definition")
+	// no node of the instance is left in the namespace
+	require.Nil(t, srv.Node(ua.NewNumericNodeID(nsi, next+1)), "instance of a rejected type added")
+	require.Nil(t, srv.Node(ua.NewNumericNodeID(nsi, next+2)), "child of a rejected type added")
+
+	inst, err := nodeNS.Instantiate(ua.NewNumericNodeID(nsi, 2001), objects, "Valve")
+	require.NoError(t, err, "Instantiate failed")
+	require.Equal(t, []string{"Position"}, inst.Children())
+}
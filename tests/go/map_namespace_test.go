@@ -0,0 +1,117 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMapNamespaceFolders verifies that nested maps are browsed as
+// folders, that the metadata of a key is reported and that writes which
+// change the data type of a variable are rejected.
+func TestMapNamespaceFolders(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	ns := server.NewMapNamespace(srv, "urn:gopcua:test:map")
+	ns.Data["Count"] = 42
+	ns.Data["Line1"] = map[string]any{
+		"Speed":     12.5,
+		"Setpoints": []int32{1, 2},
+		"Motor": map[string]any{
+			"Running": true,
+		},
+	}
+	ns.SetMetadata("Line1.Speed", &server.MapMetadata{
+		Description:      "speed of the conveyor",
+		EngineeringUnits: &ua.EUInformation{UnitID: -1, DisplayName: ua.NewLocalizedText("m/s"), Description: ua.NewLocalizedText("m/s")},
+	})
+	ns.SetMetadata("Line1.Motor.Running", &server.MapMetadata{AccessLevel: byte(ua.AccessLevelTypeCurrentRead)})
+	idx := ns.ID()
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	browse := func(nid *ua.NodeID) []string {
+		t.Helper()
+		res := ns.Browse(&ua.BrowseDescription{
+			NodeID:          nid,
+			BrowseDirection: ua.BrowseDirectionForward,
+			ReferenceTypeID: ua.NewNumericNodeID(0, 0),
+			ResultMask:      uint32(ua.BrowseResultMaskAll),
+		})
+		require.Equal(t, ua.StatusGood, res.StatusCode)
+		var names []string
+		for _, r := range res.References {
+			names = append(names, r.BrowseName.Name)
+		}
+		return names
+	}
+	require.Equal(t, []string{"Count", "Line1"}, browse(ua.NewNumericNodeID(idx, id.ObjectsFolder)))
+	require.Equal(t, []string{"FolderType", "Motor", "Setpoints", "Speed"}, browse(ua.NewStringNodeID(idx, "Line1")))
+	require.Equal(t, []string{"BaseDataVariableType", "EngineeringUnits"}, browse(ua.NewStringNodeID(idx, "Line1.Speed")))
+
+	speed := c.Node(ua.NewStringNodeID(idx, "Line1.Speed"))
+	v, err := speed.Value(ctx)
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, 12.5, v.Value())
+
+	desc, err := speed.Description(ctx)
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, "speed of the conveyor", desc.Text)
+
+	v, err = c.Node(ua.NewStringNodeID(idx, "Line1.Speed.EngineeringUnits")).Value(ctx)
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, "m/s", v.Value().(*ua.ExtensionObject).Value.(*ua.EUInformation).DisplayName.Text)
+
+	nc, err := c.Node(ua.NewStringNodeID(idx, "Line1.Motor")).NodeClass(ctx)
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, ua.NodeClassObject, nc)
+
+	dt, err := c.Node(ua.NewStringNodeID(idx, "Count")).Attribute(ctx, ua.AttributeIDDataType)
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, ua.NewNumericNodeID(0, id.Int64), dt.NodeID())
+
+	rank, err := c.Node(ua.NewStringNodeID(idx, "Line1.Setpoints")).Attribute(ctx, ua.AttributeIDValueRank)
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, int32(1), rank.Value())
+
+	write := func(key string, val any) ua.StatusCode {
+		t.Helper()
+		res, err := c.Write(ctx, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      ua.NewStringNodeID(idx, key),
+				AttributeID: ua.AttributeIDValue,
+				Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(val)},
+			}},
+		})
+		require.NoError(t, err, "Write failed")
+		return res.Results[0]
+	}
+	require.Equal(t, ua.StatusOK, write("Line1.Speed", 13.5))
+	require.Equal(t, 13.5, ns.GetValue("Line1.Speed"))
+	require.Equal(t, ua.StatusOK, write("Count", int64(43)))
+	require.Equal(t, 43, ns.GetValue("Count"))
+	require.Equal(t, ua.StatusOK, write("Line1.Setpoints", []int32{3, 4, 5}))
+	require.Equal(t, []int32{3, 4, 5}, ns.GetValue("Line1.Setpoints"))
+
+	require.Equal(t, ua.StatusBadTypeMismatch, write("Line1.Speed", "fast"))
+	require.Equal(t, ua.StatusBadTypeMismatch, write("Count", int32(1)))
+	require.Equal(t, ua.StatusBadTypeMismatch, write("Line1.Setpoints", int32(1)))
+	require.Equal(t, ua.StatusBadNotWritable, write("Line1.Motor.Running", false))
+	require.Equal(t, ua.StatusBadAttributeIDInvalid, write("Line1", int32(1)))
+	require.Equal(t, ua.StatusBadNodeIDUnknown, write("Line2.Speed", 1.0))
+	require.Equal(t, true, ns.GetValue("Line1.Motor.Running"))
+}
@@ -0,0 +1,158 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMethod verifies that methods added with AddMethod have argument
+// properties and that calls are checked and dispatched to the handler.
+func TestMethod(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	ns := server.NewNodeNameSpace(srv, "urn:gopcua:test:method")
+	idx := ns.ID()
+	pump := server.NewNode(ua.NewStringNodeID(idx, "Pump"), server.Attributes{
+		ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassObject)),
+		ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: idx, Name: "Pump"}),
+		ua.AttributeIDDisplayName: server.DataValueFromValue(ua.NewLocalizedText("Pump")),
+	}, nil, nil)
+	ns.AddNode(pump)
+
+	_, err := server.NewNode(ua.NewStringNodeID(idx, "Detached"), nil, nil, nil).AddMethod("Start", nil, nil, nil)
+	require.ErrorContains(t, err, "is not part of a namespace")
+	_, err = pump.AddMethod("Invalid", []server.Argument{server.Arg[map[string]int]("Map", "")}, nil, func(*server.MethodCall, []*ua.Variant) ([]*ua.Variant, ua.StatusCode) {
+		return nil, ua.StatusOK
+	})
+	require.ErrorContains(t, err, "argument Map: unsupported type")
+
+	var caller *server.MethodCall
+	scale, err := pump.AddMethod("Scale",
+		[]server.Argument{
+			server.Arg[[]float64]("Values", "values to scale"),
+			server.Arg[float64]("Factor", "scale factor"),
+		},
+		[]server.Argument{
+			server.Arg[[]float64]("Result", "scaled values"),
+		},
+		func(c *server.MethodCall, in []*ua.Variant) ([]*ua.Variant, ua.StatusCode) {
+			caller = c
+			factor := in[1].Float()
+			if factor == 0 {
+				return nil, ua.StatusBadInvalidArgument
+			}
+			var res []float64
+			for _, v := range in[0].Value().([]float64) {
+				res = append(res, v*factor)
+			}
+			return []*ua.Variant{ua.MustVariant(res)}, ua.StatusOK
+		},
+	)
+	require.NoError(t, err, "AddMethod failed")
+	require.Equal(t, ua.NodeClassMethod, scale.NodeClass())
+
+	// methods of object types are instantiated with their handler
+	pumpType := server.NewNode(ua.NewStringNodeID(idx, "PumpType"), server.Attributes{
+		ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassObjectType)),
+		ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: idx, Name: "PumpType"}),
+		ua.AttributeIDDisplayName: server.DataValueFromValue(ua.NewLocalizedText("PumpType")),
+		ua.AttributeIDIsAbstract:  server.DataValueFromValue(false),
+	}, nil, nil)
+	ns.AddNode(pumpType)
+	baseObjectType := srv.Node(ua.NewNumericNodeID(0, id.BaseObjectType))
+	baseObjectType.AddRef(pumpType, id.HasSubtype, true)
+	pumpType.AddRef(baseObjectType, id.HasSubtype, false)
+	_, err = pumpType.AddMethod("Double",
+		[]server.Argument{server.Arg[int32]("Value", "")},
+		[]server.Argument{server.Arg[int32]("Result", "")},
+		func(c *server.MethodCall, in []*ua.Variant) ([]*ua.Variant, ua.StatusCode) {
+			return []*ua.Variant{ua.MustVariant(2 * in[0].Value().(int32))}, ua.StatusOK
+		},
+	)
+	require.NoError(t, err, "AddMethod failed")
+	pump2, err := ns.Instantiate(pumpType.ID(), srv.Node(ua.NewNumericNodeID(0, id.ObjectsFolder)), "Pump2")
+	require.NoError(t, err, "Instantiate failed")
+	require.Equal(t, []string{"Double", "Double/InputArguments", "Double/OutputArguments"}, pump2.Children())
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	refs, err := c.Node(scale.ID()).References(ctx, id.HasProperty, ua.BrowseDirectionForward, ua.NodeClassVariable, false)
+	require.NoError(t, err, "Browse failed")
+	require.Len(t, refs, 2)
+	for _, r := range refs {
+		v, err := c.Node(r.NodeID.NodeID).Value(ctx)
+		require.NoError(t, err, "Read failed")
+		eos := v.Value().([]*ua.ExtensionObject)
+		arg := eos[0].Value.(*ua.Argument)
+		switch r.BrowseName.Name {
+		case "InputArguments":
+			require.Len(t, eos, 2)
+			require.Equal(t, "Values", arg.Name)
+		case "OutputArguments":
+			require.Len(t, eos, 1)
+			require.Equal(t, "Result", arg.Name)
+		default:
+			t.Fatalf("unexpected property %s", r.BrowseName.Name)
+		}
+		require.Equal(t, ua.NewNumericNodeID(0, id.Double).String(), arg.DataType.String())
+		require.Equal(t, int32(1), arg.ValueRank)
+	}
+
+	exec, err := c.Node(scale.ID()).Attributes(ctx, ua.AttributeIDExecutable, ua.AttributeIDUserExecutable)
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, true, exec[0].Value.Value())
+	require.Equal(t, true, exec[1].Value.Value())
+
+	call := func(obj *ua.NodeID, args ...any) *ua.CallMethodResult {
+		t.Helper()
+		req := &ua.CallMethodRequest{ObjectID: obj, MethodID: scale.ID()}
+		for _, a := range args {
+			req.InputArguments = append(req.InputArguments, ua.MustVariant(a))
+		}
+		res, err := c.Call(ctx, req)
+		require.NoError(t, err, "Call failed")
+		return res
+	}
+
+	res := call(pump.ID(), []float64{1, 2}, 3.0)
+	require.Equal(t, ua.StatusOK, res.StatusCode)
+	require.Len(t, res.OutputArguments, 1)
+	require.Equal(t, []float64{3, 6}, res.OutputArguments[0].Value())
+	require.NotNil(t, caller)
+	require.Equal(t, pump.ID().String(), caller.ObjectID.String())
+	require.NotNil(t, caller.SessionID)
+
+	require.Equal(t, ua.StatusBadInvalidArgument, call(pump.ID(), []float64{1}, 0.0).StatusCode)
+	require.Equal(t, ua.StatusBadArgumentsMissing, call(pump.ID(), []float64{1}).StatusCode)
+	require.Equal(t, ua.StatusBadTooManyArguments, call(pump.ID(), []float64{1}, 1.0, 1.0).StatusCode)
+	require.Equal(t, ua.StatusBadMethodInvalid, call(ua.NewNumericNodeID(0, id.ObjectsFolder), []float64{1}, 1.0).StatusCode)
+	require.Equal(t, ua.StatusBadNodeIDUnknown, call(ua.NewStringNodeID(idx, "Unknown"), []float64{1}, 1.0).StatusCode)
+
+	res = call(pump.ID(), 1.0, "x")
+	require.Equal(t, ua.StatusBadInvalidArgument, res.StatusCode)
+	require.Equal(t, []ua.StatusCode{ua.StatusBadTypeMismatch, ua.StatusBadTypeMismatch}, res.InputArgumentResults)
+
+	res, err = c.Call(ctx, &ua.CallMethodRequest{
+		ObjectID:       pump2.Node().ID(),
+		MethodID:       pump2.Child("Double").ID(),
+		InputArguments: []*ua.Variant{ua.MustVariant(int32(21))},
+	})
+	require.NoError(t, err, "Call failed")
+	require.Equal(t, ua.StatusOK, res.StatusCode)
+	require.Equal(t, []*ua.Variant{ua.MustVariant(int32(42))}, res.OutputArguments)
+}
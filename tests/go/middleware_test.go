@@ -0,0 +1,84 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uasc"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMiddleware verifies that the service middleware sees every
+// request, can look up the session of the request and can reject
+// requests before they reach the handler.
+func TestMiddleware(t *testing.T) {
+	var (
+		srv  *server.Server
+		mu   sync.Mutex
+		seen []string
+		info *server.RequestInfo
+	)
+	record := func(next server.Handler) server.Handler {
+		return func(sc *uasc.SecureChannel, req ua.Request, reqID uint32) (ua.Response, error) {
+			resp, err := next(sc, req, reqID)
+			mu.Lock()
+			seen = append(seen, fmt.Sprintf("%T", req))
+			mu.Unlock()
+			return resp, err
+		}
+	}
+	denyWrite := func(next server.Handler) server.Handler {
+		return func(sc *uasc.SecureChannel, req ua.Request, reqID uint32) (ua.Response, error) {
+			if _, ok := req.(*ua.WriteRequest); ok {
+				mu.Lock()
+				info = srv.RequestInfo(req)
+				mu.Unlock()
+				return nil, ua.StatusBadUserAccessDenied
+			}
+			return next(sc, req, reqID)
+		}
+	}
+
+	ctx := context.Background()
+
+	// srv is read by the middleware
+	mu.Lock()
+	srv = startServer(server.ServiceMiddleware(record, denyWrite))
+	mu.Unlock()
+	defer srv.Close()
+
+	require.Error(t, srv.Use(record), "Use after Start")
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	_, err = c.Write(ctx, &ua.WriteRequest{
+		NodesToWrite: []*ua.WriteValue{{
+			NodeID:      ua.NewStringNodeID(1, "rw_int32"),
+			AttributeID: ua.AttributeIDValue,
+			Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(int32(1))},
+		}},
+	})
+	require.ErrorIs(t, err, ua.StatusBadUserAccessDenied)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Contains(t, seen, "*ua.CreateSessionRequest")
+	require.Contains(t, seen, "*ua.ActivateSessionRequest")
+	require.Contains(t, seen, "*ua.WriteRequest")
+	require.NotNil(t, info, "request info")
+	require.NotNil(t, info.SessionID, "session id")
+	require.Equal(t, "", info.User, "anonymous user")
+}
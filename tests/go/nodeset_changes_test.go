@@ -0,0 +1,226 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/schema"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+const nodeSetChanges = `<?xml version="1.0" encoding="utf-8"?>
+<UANodeSetChanges xmlns="http://opcfoundation.org/UA/2011/03/UANodeSet.xsd" TransactionId="tx-1" AcceptAllOrNothing="%s">
+  <NamespaceUris>
+    <Uri>urn:gopcua:test:base</Uri>
+  </NamespaceUris>
+  <Aliases>
+    <Alias Alias="HasComponent">i=47</Alias>
+    <Alias Alias="HasTypeDefinition">i=40</Alias>
+  </Aliases>
+  <NodesToAdd>
+    <UAVariable NodeId="ns=1;i=2001" BrowseName="1:Speed">
+      <DisplayName>Speed</DisplayName>
+      <References>
+        <Reference ReferenceType="HasTypeDefinition">i=63</Reference>
+        <Reference ReferenceType="HasComponent" IsForward="false">ns=1;i=1001</Reference>
+      </References>
+    </UAVariable>
+    <UAObject NodeId="ns=1;i=2002" BrowseName="1:Motor">
+      <DisplayName>Motor</DisplayName>
+    </UAObject>
+  </NodesToAdd>
+  <ReferencesToAdd>
+    <Reference Source="ns=1;i=2002" ReferenceType="HasComponent">ns=1;i=2001</Reference>
+  </ReferencesToAdd>
+  <NodesToDelete>
+    <Node DeleteReverseReferences="true">%s</Node>
+  </NodesToDelete>
+</UANodeSetChanges>`
+
+func parseNodeSetChanges(t *testing.T, s string) *schema.UANodeSetChanges {
+	t.Helper()
+	var changes schema.UANodeSetChanges
+	require.NoError(t, xml.Unmarshal([]byte(s), &changes))
+	return &changes
+}
+
+func baseServer(t *testing.T) (*server.Server, uint16) {
+	t.Helper()
+	srv := server.New(server.EndPoint("localhost", 4840))
+	require.NoError(t, srv.ImportNodeSet(parseNodeSet(t, baseNodeSet)))
+	idx := namespaceIndex(t, srv, "urn:gopcua:test:base")
+
+	ns, err := srv.Namespace(int(idx))
+	require.NoError(t, err)
+	obsolete := server.NewNode(ua.NewNumericNodeID(idx, 3001), nil, nil, nil)
+	obsolete.SetBrowseName("Obsolete")
+	ns.AddNode(obsolete)
+	return srv, idx
+}
+
+func statusNames(l *schema.NodeSetStatusList) []string {
+	var names []string
+	for _, s := range l.Status {
+		names = append(names, s.Value)
+	}
+	return names
+}
+
+// TestApplyNodeSetChanges verifies that nodes and references are added
+// and deleted by a NodeSetChanges document.
+func TestApplyNodeSetChanges(t *testing.T) {
+	srv, idx := baseServer(t)
+
+	status, err := srv.ApplyNodeSetChanges(parseNodeSetChanges(t, fmt.Sprintf(nodeSetChanges, "true", "ns=1;i=3001")))
+	require.NoError(t, err, "ApplyNodeSetChanges failed")
+	require.Equal(t, "tx-1", status.TransactionIdAttr)
+	require.Equal(t, []string{"Good", "Good"}, statusNames(status.NodesToAdd))
+	require.Equal(t, []string{"Good"}, statusNames(status.ReferencesToAdd))
+	require.Equal(t, []string{"Good"}, statusNames(status.NodesToDelete))
+
+	speed := srv.Node(ua.NewNumericNodeID(idx, 2001))
+	require.NotNil(t, speed)
+	require.Equal(t, &ua.QualifiedName{NamespaceIndex: idx, Name: "Speed"}, speed.BrowseName())
+	require.Nil(t, srv.Node(ua.NewNumericNodeID(idx, 3001)))
+
+	ns, err := srv.Namespace(int(idx))
+	require.NoError(t, err)
+	res := ns.Browse(&ua.BrowseDescription{
+		NodeID:          ua.NewNumericNodeID(idx, 2002),
+		BrowseDirection: ua.BrowseDirectionForward,
+		ReferenceTypeID: ua.NewNumericNodeID(0, id.HasComponent),
+		ResultMask:      uint32(ua.BrowseResultMaskAll),
+	})
+	require.Equal(t, ua.StatusGood, res.StatusCode)
+	require.Len(t, res.References, 1)
+	require.True(t, res.References[0].NodeID.NodeID.Equal(ua.NewNumericNodeID(idx, 2001)))
+
+	// applying the same document again fails since the nodes exist
+	status, err = srv.ApplyNodeSetChanges(parseNodeSetChanges(t, fmt.Sprintf(nodeSetChanges, "false", "ns=1;i=3001")))
+	require.NoError(t, err)
+	require.Equal(t, []string{"BadNodeIDExists", "BadNodeIDExists"}, statusNames(status.NodesToAdd))
+	require.Equal(t, []string{"BadDuplicateReferenceNotAllowed"}, statusNames(status.ReferencesToAdd))
+	require.Equal(t, []string{"BadNodeIDUnknown"}, statusNames(status.NodesToDelete))
+}
+
+// TestApplyNodeSetChangesAllOrNothing verifies that no change is applied
+// if a single change fails and AcceptAllOrNothing is set.
+func TestApplyNodeSetChangesAllOrNothing(t *testing.T) {
+	srv, idx := baseServer(t)
+
+	status, err := srv.ApplyNodeSetChanges(parseNodeSetChanges(t, fmt.Sprintf(nodeSetChanges, "true", "ns=1;i=4711")))
+	require.ErrorContains(t, err, "nodeset changes tx-1 rejected: 1 changes are invalid")
+	require.Equal(t, []string{"BadOperationAbandoned", "BadOperationAbandoned"}, statusNames(status.NodesToAdd))
+	require.Equal(t, []string{"BadNodeIDUnknown"}, statusNames(status.NodesToDelete))
+	require.Nil(t, srv.Node(ua.NewNumericNodeID(idx, 2001)))
+	require.NotNil(t, srv.Node(ua.NewNumericNodeID(idx, 3001)))
+}
+
+const newNamespaceChanges = `<?xml version="1.0" encoding="utf-8"?>
+<UANodeSetChanges xmlns="http://opcfoundation.org/UA/2011/03/UANodeSet.xsd" TransactionId="tx-2" AcceptAllOrNothing="true">
+  <NamespaceUris>
+    <Uri>urn:gopcua:test:changes:new</Uri>
+  </NamespaceUris>
+  <NodesToAdd>
+    <UAObject NodeId="ns=1;i=%d" BrowseName="1:Pump">
+      <DisplayName>Pump</DisplayName>
+    </UAObject>
+  </NodesToAdd>
+  %s
+</UANodeSetChanges>`
+
+// TestApplyNodeSetChangesNamespaces verifies that the namespaces of a
+// document are only created when its changes are applied and that
+// concurrent documents are checked against each other.
+func TestApplyNodeSetChangesNamespaces(t *testing.T) {
+	srv, _ := baseServer(t)
+	count := len(srv.Namespaces())
+
+	invalid := `<NodesToDelete><Node>i=4711</Node></NodesToDelete>`
+	_, err := srv.ApplyNodeSetChanges(parseNodeSetChanges(t, fmt.Sprintf(newNamespaceChanges, 1, invalid)))
+	require.Error(t, err, "ApplyNodeSetChanges succeeded")
+	require.Len(t, srv.Namespaces(), count, "namespace of a rejected document created")
+
+	status, err := srv.ApplyNodeSetChanges(parseNodeSetChanges(t, fmt.Sprintf(newNamespaceChanges, 1, "")))
+	require.NoError(t, err, "ApplyNodeSetChanges failed")
+	require.Equal(t, []string{"Good"}, statusNames(status.NodesToAdd))
+	idx := namespaceIndex(t, srv, "urn:gopcua:test:changes:new")
+	require.Equal(t, count, int(idx))
+	require.NotNil(t, srv.Node(ua.NewNumericNodeID(idx, 1)))
+
+	// only one of the documents which add the same node is applied
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = srv.ApplyNodeSetChanges(parseNodeSetChanges(t, fmt.Sprintf(newNamespaceChanges, 2, "")))
+		}(i)
+	}
+	wg.Wait()
+	applied := 0
+	for _, err := range errs {
+		if err == nil {
+			applied++
+		}
+	}
+	require.Equal(t, 1, applied)
+	require.Len(t, srv.Namespaces(), count+1)
+}
+
+const invalidNodeSetChanges = `<?xml version="1.0" encoding="utf-8"?>
+<UANodeSetChanges xmlns="http://opcfoundation.org/UA/2011/03/UANodeSet.xsd" TransactionId="tx-3" AcceptAllOrNothing="%s">
+  <NamespaceUris>
+    <Uri>urn:gopcua:test:base</Uri>
+  </NamespaceUris>
+  <Aliases>
+    <Alias Alias="Broken">i=abc</Alias>
+    <Alias Alias="HasComponent">i=47</Alias>
+  </Aliases>
+  <NodesToAdd>
+    <UAVariable NodeId="ns=1;i=5001" BrowseName="1:Pressure" DataType="ns=1;i=abc">
+      <DisplayName>Pressure</DisplayName>
+    </UAVariable>
+    <UAObject NodeId="ns=1;i=5002" BrowseName="1:Valve">
+      <DisplayName>Valve</DisplayName>
+    </UAObject>
+    <UAObject NodeId="ns=1;i=abc" BrowseName="1:Broken">
+      <DisplayName>Broken</DisplayName>
+    </UAObject>
+  </NodesToAdd>
+  <ReferencesToAdd>
+    <Reference Source="ns=1;i=5002" ReferenceType="Broken">ns=1;i=1001</Reference>
+    <Reference Source="ns=1;i=5002" ReferenceType="HasComponent">ns=1;i=abc</Reference>
+  </ReferencesToAdd>
+  <NodesToDelete>
+    <Node>ns=1;i=abc</Node>
+  </NodesToDelete>
+</UANodeSetChanges>`
+
+// TestApplyNodeSetChangesInvalid verifies that malformed node ids and
+// invalid attributes of a document are reported for the entry.
+func TestApplyNodeSetChangesInvalid(t *testing.T) {
+	srv, idx := baseServer(t)
+
+	status, err := srv.ApplyNodeSetChanges(parseNodeSetChanges(t, fmt.Sprintf(invalidNodeSetChanges, "true")))
+	require.ErrorContains(t, err, "nodeset changes tx-3 rejected: 5 changes are invalid")
+	require.Equal(t, []string{"BadNodeAttributesInvalid", "BadOperationAbandoned", "BadNodeIDInvalid"}, statusNames(status.NodesToAdd))
+	require.Nil(t, srv.Node(ua.NewNumericNodeID(idx, 5002)))
+
+	status, err = srv.ApplyNodeSetChanges(parseNodeSetChanges(t, fmt.Sprintf(invalidNodeSetChanges, "false")))
+	require.NoError(t, err, "ApplyNodeSetChanges failed")
+	require.Equal(t, []string{"BadNodeAttributesInvalid", "Good", "BadNodeIDInvalid"}, statusNames(status.NodesToAdd))
+	require.Equal(t, []string{"BadReferenceTypeIDInvalid", "BadTargetNodeIDInvalid"}, statusNames(status.ReferencesToAdd))
+	require.Equal(t, []string{"BadNodeIDInvalid"}, statusNames(status.NodesToDelete))
+	require.Nil(t, srv.Node(ua.NewNumericNodeID(idx, 5001)))
+	require.NotNil(t, srv.Node(ua.NewNumericNodeID(idx, 5002)))
+}
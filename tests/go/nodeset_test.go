@@ -0,0 +1,173 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"encoding/xml"
+	"os"
+	"testing"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/schema"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+const baseNodeSet = `<?xml version="1.0" encoding="utf-8"?>
+<UANodeSet xmlns="http://opcfoundation.org/UA/2011/03/UANodeSet.xsd">
+  <NamespaceUris>
+    <Uri>urn:gopcua:test:base</Uri>
+  </NamespaceUris>
+  <Models>
+    <Model ModelUri="urn:gopcua:test:base" Version="1.1.0" PublicationDate="2024-01-01T00:00:00Z">
+      <RequiredModel ModelUri="http://opcfoundation.org/UA/" Version="1.05.00" PublicationDate="2021-01-01T00:00:00Z" />
+    </Model>
+  </Models>
+  <Aliases>
+    <Alias Alias="HasSubtype">i=45</Alias>
+  </Aliases>
+  <UAObjectType NodeId="ns=1;i=1001" BrowseName="1:DeviceType">
+    <DisplayName>DeviceType</DisplayName>
+    <References>
+      <Reference ReferenceType="HasSubtype" IsForward="false">i=58</Reference>
+    </References>
+  </UAObjectType>
+</UANodeSet>`
+
+// the namespace table of the companion nodeset lists the namespaces
+// in a different order than the server.
+const companionNodeSet = `<?xml version="1.0" encoding="utf-8"?>
+<UANodeSet xmlns="http://opcfoundation.org/UA/2011/03/UANodeSet.xsd">
+  <NamespaceUris>
+    <Uri>urn:gopcua:test:companion</Uri>
+    <Uri>urn:gopcua:test:base</Uri>
+  </NamespaceUris>
+  <Models>
+    <Model ModelUri="urn:gopcua:test:companion" Version="1.0.0" PublicationDate="2024-06-01T00:00:00Z">
+      <RequiredModel ModelUri="urn:gopcua:test:base" Version="1.0.0" PublicationDate="2023-01-01T00:00:00Z" />
+    </Model>
+  </Models>
+  <Aliases>
+    <Alias Alias="HasSubtype">i=45</Alias>
+  </Aliases>
+  <UAObjectType NodeId="ns=1;i=1001" BrowseName="1:MachineType">
+    <DisplayName>MachineType</DisplayName>
+    <References>
+      <Reference ReferenceType="HasSubtype" IsForward="false">ns=2;i=1001</Reference>
+    </References>
+  </UAObjectType>
+</UANodeSet>`
+
+func parseNodeSet(t *testing.T, s string) *schema.UANodeSet {
+	t.Helper()
+	var nodes schema.UANodeSet
+	require.NoError(t, xml.Unmarshal([]byte(s), &nodes))
+	return &nodes
+}
+
+func namespaceIndex(t *testing.T, srv *server.Server, uri string) uint16 {
+	t.Helper()
+	for i, ns := range srv.Namespaces() {
+		if ns.Name() == uri {
+			return uint16(i)
+		}
+	}
+	t.Fatalf("namespace %s not found", uri)
+	return 0
+}
+
+// TestImportNodeSets verifies that the namespace indices of the nodeset
+// files are mapped onto the namespaces of the server and that the
+// nodesets are imported in the order of their required models.
+func TestImportNodeSets(t *testing.T) {
+	srv := server.New(server.EndPoint("localhost", 4840))
+	srv.AddNamespace(server.NewNameSpace("urn:gopcua:test:other"))
+
+	err := srv.ImportNodeSets(parseNodeSet(t, companionNodeSet), parseNodeSet(t, baseNodeSet))
+	require.NoError(t, err, "ImportNodeSets failed")
+
+	base := namespaceIndex(t, srv, "urn:gopcua:test:base")
+	companion := namespaceIndex(t, srv, "urn:gopcua:test:companion")
+	require.NotEqual(t, base, companion)
+
+	baseType := srv.Node(ua.NewNumericNodeID(base, 1001))
+	require.NotNil(t, baseType)
+	require.Equal(t, &ua.QualifiedName{NamespaceIndex: base, Name: "DeviceType"}, baseType.BrowseName())
+
+	machineType := srv.Node(ua.NewNumericNodeID(companion, 1001))
+	require.NotNil(t, machineType)
+	require.Equal(t, &ua.QualifiedName{NamespaceIndex: companion, Name: "MachineType"}, machineType.BrowseName())
+
+	ns, err := srv.Namespace(int(base))
+	require.NoError(t, err)
+	res := ns.Browse(&ua.BrowseDescription{
+		NodeID:          ua.NewNumericNodeID(base, 1001),
+		BrowseDirection: ua.BrowseDirectionForward,
+		ReferenceTypeID: ua.NewNumericNodeID(0, id.HasSubtype),
+		ResultMask:      uint32(ua.BrowseResultMaskAll),
+	})
+	require.Equal(t, ua.StatusGood, res.StatusCode)
+	var found bool
+	for _, ref := range res.References {
+		if ref.NodeID.NodeID.Equal(ua.NewNumericNodeID(companion, 1001)) {
+			found = true
+		}
+	}
+	require.True(t, found, "MachineType is not a subtype of DeviceType")
+
+	// importing the base model again reuses the namespace
+	require.NoError(t, srv.ImportNodeSet(parseNodeSet(t, baseNodeSet)))
+	require.Equal(t, base, namespaceIndex(t, srv, "urn:gopcua:test:base"))
+}
+
+func TestImportNodeSetRequiredModels(t *testing.T) {
+	t.Run("missing", func(t *testing.T) {
+		srv := server.New(server.EndPoint("localhost", 4840))
+		err := srv.ImportNodeSet(parseNodeSet(t, companionNodeSet))
+		require.ErrorContains(t, err, "requires model urn:gopcua:test:base which has not been imported")
+	})
+	t.Run("version", func(t *testing.T) {
+		srv := server.New(server.EndPoint("localhost", 4840))
+		base := parseNodeSet(t, baseNodeSet)
+		base.Models.Model[0].VersionAttr = "0.9"
+		require.NoError(t, srv.ImportNodeSet(base))
+		err := srv.ImportNodeSet(parseNodeSet(t, companionNodeSet))
+		require.ErrorContains(t, err, "requires model urn:gopcua:test:base version 1.0.0 but version 0.9 has been imported")
+	})
+	t.Run("publication date", func(t *testing.T) {
+		srv := server.New(server.EndPoint("localhost", 4840))
+		base := parseNodeSet(t, baseNodeSet)
+		base.Models.Model[0].PublicationDateAttr = "2022-01-01T00:00:00Z"
+		require.NoError(t, srv.ImportNodeSet(base))
+		err := srv.ImportNodeSet(parseNodeSet(t, companionNodeSet))
+		require.ErrorContains(t, err, "published on 2023-01-01T00:00:00Z")
+	})
+}
+
+// TestImportDINodeSet verifies that references with a reference type of
+// a companion namespace keep the namespace of the reference type.
+func TestImportDINodeSet(t *testing.T) {
+	b, err := os.ReadFile("../../examples/server/NodeSet2_server/Opc.Ua.Di.NodeSet2.xml")
+	require.NoError(t, err)
+
+	srv := server.New(server.EndPoint("localhost", 4840))
+	srv.AddNamespace(server.NewNameSpace("urn:gopcua:test:other"))
+	require.NoError(t, srv.ImportNodeSet(parseNodeSet(t, string(b))), "ImportNodeSet failed")
+	di := namespaceIndex(t, srv, "http://opcfoundation.org/UA/DI/")
+
+	ns, err := srv.Namespace(int(di))
+	require.NoError(t, err)
+	// NetworkType ConnectsTo ConnectionPoint
+	res := ns.Browse(&ua.BrowseDescription{
+		NodeID:          ua.NewNumericNodeID(di, 6247),
+		BrowseDirection: ua.BrowseDirectionForward,
+		ReferenceTypeID: ua.NewNumericNodeID(di, 6030),
+		ResultMask:      uint32(ua.BrowseResultMaskAll),
+	})
+	require.Equal(t, ua.StatusGood, res.StatusCode)
+	require.Len(t, res.References, 1)
+	require.True(t, res.References[0].ReferenceTypeID.Equal(ua.NewNumericNodeID(di, 6030)))
+	require.True(t, res.References[0].NodeID.NodeID.Equal(ua.NewNumericNodeID(di, 6248)))
+}
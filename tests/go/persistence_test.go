@@ -0,0 +1,166 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPersistence verifies that written values and attributes are
+// restored after a restart of the server.
+func TestPersistence(t *testing.T) {
+	ctx := context.Background()
+	store := server.NewFileStore(filepath.Join(t.TempDir(), "snapshot.json"))
+
+	// start sets up the application and starts the server like an
+	// application would do it after every restart.
+	live := int32(1)
+	start := func() (*server.Server, *server.MapNamespace, *ua.NodeID, *ua.NodeID) {
+		t.Helper()
+		srv := server.New(
+			server.EnableSecurity("None", ua.MessageSecurityModeNone),
+			server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+			server.EndPoint("localhost", 4840),
+			server.Persistence(store, 0, "urn:gopcua:test:persist", "urn:gopcua:test:persist:map"),
+		)
+		ns := server.NewNodeNameSpace(srv, "urn:gopcua:test:persist")
+		setpoint := ns.AddNewVariableStringNode("Setpoint", int32(10))
+		setpoint.SetAttribute(ua.AttributeIDWriteMask, server.DataValueFromValue(uint32(ua.AttributeWriteMaskDisplayName)))
+		readOnly := ns.AddNewVariableStringNode("ReadOnly", int32(1))
+		readOnly.SetAttribute(ua.AttributeIDAccessLevel, server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead)))
+		// the value comes from the application and is not persisted
+		ns.AddNewVariableStringNode("Live", func() *ua.DataValue { return server.DataValueFromValue(live) })
+
+		mns := server.NewMapNamespace(srv, "urn:gopcua:test:persist:map")
+		mns.Data["Line1"] = map[string]any{"Speed": 12.5}
+
+		// not persisted
+		other := server.NewNodeNameSpace(srv, "urn:gopcua:test:other")
+		other.AddNewVariableStringNode("Value", int32(1))
+
+		require.NoError(t, srv.Start(ctx), "Start failed")
+		return srv, mns, setpoint.ID(), readOnly.ID()
+	}
+
+	srv, mns, setpoint, readOnly := start()
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+
+	res, err := c.Write(ctx, &ua.WriteRequest{
+		NodesToWrite: []*ua.WriteValue{
+			{NodeID: setpoint, AttributeID: ua.AttributeIDValue, Value: &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(int32(42))}},
+			{NodeID: setpoint, AttributeID: ua.AttributeIDDisplayName, Value: &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(ua.NewLocalizedText("Target"))}},
+		},
+	})
+	require.NoError(t, err, "Write failed")
+	require.Equal(t, []ua.StatusCode{ua.StatusOK, ua.StatusOK}, res.Results)
+	mns.SetValue("Line1.Speed", 13.5)
+	c.Close(ctx)
+
+	// readOnly is not writable and keeps its default after the restart
+	ns, err := srv.Namespace(int(readOnly.Namespace()))
+	require.NoError(t, err)
+	require.Equal(t, ua.StatusOK, ns.SetAttribute(readOnly, ua.AttributeIDValue, server.DataValueFromValue(int32(2))))
+	require.NoError(t, srv.Close(), "Close failed")
+
+	srv, mns, setpoint, readOnly = start()
+	defer srv.Close()
+
+	ns, err = srv.Namespace(int(setpoint.Namespace()))
+	require.NoError(t, err)
+	require.Equal(t, int32(42), ns.Attribute(setpoint, ua.AttributeIDValue).Value.Value())
+	require.Equal(t, "Target", ns.Attribute(setpoint, ua.AttributeIDDisplayName).Value.Value().(*ua.LocalizedText).Text)
+	require.Equal(t, int32(1), ns.Attribute(readOnly, ua.AttributeIDValue).Value.Value())
+	require.Equal(t, 13.5, mns.GetValue("Line1.Speed"))
+
+	live = 2
+	liveID := ua.NewStringNodeID(setpoint.Namespace(), "Live")
+	require.Equal(t, int32(2), ns.Attribute(liveID, ua.AttributeIDValue).Value.Value(), "live value replaced by the snapshot")
+
+	snap, err := store.Load()
+	require.NoError(t, err, "Load failed")
+	var uris []string
+	for _, nss := range snap.Namespaces {
+		uris = append(uris, nss.URI)
+	}
+	require.Equal(t, []string{"urn:gopcua:test:persist", "urn:gopcua:test:persist:map"}, uris)
+}
+
+// memStore keeps the snapshot in memory.
+type memStore struct {
+	mu   sync.Mutex
+	snap *server.Snapshot
+}
+
+func (m *memStore) Load() (*server.Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.snap, nil
+}
+
+func (m *memStore) Save(snap *server.Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snap = snap
+	return nil
+}
+
+// TestPersistenceRestoreError verifies that attributes which cannot be
+// restored do not keep the server from starting and that the periodic
+// snapshots can be taken while clients write values.
+func TestPersistenceRestoreError(t *testing.T) {
+	ctx := context.Background()
+	const uri = "urn:gopcua:test:persist:error"
+	store := &memStore{snap: &server.Snapshot{
+		Namespaces: []*server.NamespaceSnapshot{{
+			URI: uri,
+			Attributes: []*server.AttributeSnapshot{
+				{NodeID: "i=invalid", AttributeID: ua.AttributeIDValue, Value: server.DataValueFromValue(int32(1))},
+				{NodeID: "s=Setpoint", AttributeID: ua.AttributeIDValue, Value: server.DataValueFromValue(int32(42))},
+			},
+		}},
+	}}
+
+	srv := server.New(
+		server.EnableSecurity("None", ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+		server.EndPoint("localhost", 4840),
+		server.Persistence(store, 5*time.Millisecond, uri),
+	)
+	ns := server.NewNodeNameSpace(srv, uri)
+	setpoint := ns.AddNewVariableStringNode("Setpoint", int32(10))
+	require.NoError(t, srv.Start(ctx), "Start failed")
+	defer srv.Close()
+
+	require.Equal(t, int32(42), ns.Attribute(setpoint.ID(), ua.AttributeIDValue).Value.Value())
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	for i := int32(0); i < 20; i++ {
+		res, err := c.Write(ctx, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      setpoint.ID(),
+				AttributeID: ua.AttributeIDValue,
+				Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(i)},
+			}},
+		})
+		require.NoError(t, err, "Write failed")
+		require.Equal(t, ua.StatusOK, res.Results[0])
+		time.Sleep(time.Millisecond)
+	}
+}
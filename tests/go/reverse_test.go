@@ -0,0 +1,103 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacp"
+	"github.com/stretchr/testify/require"
+)
+
+// reverseRelay opens the connection to the reverse connect listener of
+// the client at clientAddr, announces the server with a ReverseHello and
+// then relays the connection to the server at srvAddr. This is what a
+// server with reverse connect support does. The returned function closes
+// both connections.
+func reverseRelay(ctx context.Context, clientAddr, srvAddr, serverURI, endpoint string) (func(), error) {
+	var c net.Conn
+	for {
+		var err error
+		// the client listens once Connect has been called
+		if c, err = net.Dial("tcp", clientAddr); err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	conn, err := uacp.NewConn(c.(*net.TCPConn), nil)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := conn.Send("RHEF", &uacp.ReverseHello{ServerURI: serverURI, EndpointURL: endpoint}); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	s, err := net.Dial("tcp", srvAddr)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	go io.Copy(s, c)
+	go io.Copy(c, s)
+	return func() {
+		c.Close()
+		s.Close()
+	}, nil
+}
+
+// TestReverseConnect verifies that a client in reverse connect mode
+// accepts the connection of the server after the ReverseHello, runs the
+// HEL/ACK handshake over it and can use the session.
+func TestReverseConnect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	srv := startServer()
+	defer srv.Close()
+
+	const (
+		serverURI  = "urn:gopcua:test:reverse"
+		endpoint   = "opc.tcp://localhost:4840"
+		clientAddr = "localhost:4843"
+	)
+	c, err := opcua.NewClient(endpoint,
+		opcua.SecurityMode(ua.MessageSecurityModeNone),
+		opcua.ReverseConnect("opc.tcp://"+clientAddr, serverURI),
+	)
+	require.NoError(t, err, "NewClient failed")
+
+	type relay struct {
+		close func()
+		err   error
+	}
+	relayCh := make(chan relay, 1)
+	go func() {
+		closeFn, err := reverseRelay(ctx, clientAddr, "localhost:4840", serverURI, endpoint)
+		relayCh <- relay{closeFn, err}
+	}()
+
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	r := <-relayCh
+	require.NoError(t, r.err, "reverse connection failed")
+	defer r.close()
+
+	v, err := c.Node(ua.NewNumericNodeID(0, id.Server_ServerStatus_State)).Value(ctx)
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, int32(ua.ServerStateRunning), v.Value())
+}
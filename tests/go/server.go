@@ -13,7 +13,7 @@ import (
 	"github.com/gopcua/opcua/ua"
 )
 
-func startServer() *server.Server {
+func startServer(extra ...server.Option) *server.Server {
 	var opts []server.Option
 	port := 4840
 
@@ -41,6 +41,7 @@ func startServer() *server.Server {
 	opts = append(opts,
 		server.EndPoint("localhost", port),
 	)
+	opts = append(opts, extra...)
 
 	s := server.New(opts...)
 
@@ -0,0 +1,68 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestShutdown verifies that subscribed clients see the shutdown
+// countdown and receive a StatusChangeNotification before the server
+// closes the connection.
+func TestShutdown(t *testing.T) {
+	ctx := context.Background()
+
+	// the server is closed by Shutdown
+	srv := startServer()
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	notifyCh := make(chan *opcua.PublishNotificationData, 10)
+	sub, err := c.Subscribe(ctx, &opcua.SubscriptionParameters{Interval: 50 * time.Millisecond}, notifyCh)
+	require.NoError(t, err, "Subscribe failed")
+
+	nid := ua.NewNumericNodeID(0, id.Server_ServerStatus_SecondsTillShutdown)
+	res, err := sub.Monitor(ctx, ua.TimestampsToReturnBoth, opcua.NewMonitoredItemCreateRequestWithDefaults(nid, ua.AttributeIDValue, 42))
+	require.NoError(t, err, "Monitor failed")
+	require.Equal(t, ua.StatusOK, res.Results[0].StatusCode)
+
+	errch := make(chan error, 1)
+	go func() {
+		errch <- srv.Shutdown(ctx, 2*time.Second, "maintenance")
+	}()
+
+	var countdown []uint32
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case <-timeout:
+			t.Fatal("timeout waiting for status change notification")
+		case msg := <-notifyCh:
+			require.NoError(t, msg.Error)
+			switch x := msg.Value.(type) {
+			case *ua.DataChangeNotification:
+				for _, item := range x.MonitoredItems {
+					countdown = append(countdown, item.Value.Value.Value().(uint32))
+				}
+			case *ua.StatusChangeNotification:
+				require.Equal(t, ua.StatusBadShutdown, x.Status)
+				require.Contains(t, countdown, uint32(2))
+				require.NoError(t, <-errch, "Shutdown failed")
+				return
+			}
+		}
+	}
+}
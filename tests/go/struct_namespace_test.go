@@ -0,0 +1,149 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+type plant struct {
+	Name  string `opcua:",access=r" description:"Name of the plant"`
+	Motor struct {
+		Speed   float64 `opcua:"Speed" unit:"rpm"`
+		Enabled bool    `opcua:",nodeid=i=1001"`
+	}
+	Setpoints []int32
+	Debug     string `opcua:"-"`
+	internal  int
+}
+
+// TestStructNamespace verifies that the fields of a struct are exposed
+// as variables, that client writes update the struct and that changes
+// made with SetValue are reported to subscribers.
+func TestStructNamespace(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	var p plant
+	p.Name = "plant1"
+	p.Motor.Speed = 1200
+	p.Setpoints = []int32{1, 2, 3}
+
+	ns, err := server.NewStructNamespace(srv, "urn:gopcua:test:plant", &p)
+	require.NoError(t, err, "NewStructNamespace failed")
+	root, err := srv.Namespace(0)
+	require.NoError(t, err)
+	root.Objects().AddRef(ns.Objects(), id.HasComponent, true)
+
+	_, err = server.NewStructNamespace(srv, "urn:gopcua:test:invalid", p)
+	require.ErrorContains(t, err, "is not a pointer to a struct")
+
+	idx := ns.ID()
+	require.Nil(t, ns.Node(ua.NewStringNodeID(idx, "Debug")))
+	require.NotNil(t, ns.Node(ua.NewStringNodeID(idx, "Motor.Speed.EngineeringUnits")))
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	speedID := ua.NewStringNodeID(idx, "Motor.Speed")
+	v, err := c.Node(speedID).Value(ctx)
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, float64(1200), v.Value())
+
+	v, err = c.Node(ua.NewStringNodeID(idx, "Setpoints")).Value(ctx)
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, []int32{1, 2, 3}, v.Value())
+
+	write := func(nid *ua.NodeID, val any) ua.StatusCode {
+		t.Helper()
+		res, err := c.Write(ctx, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      nid,
+				AttributeID: ua.AttributeIDValue,
+				Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(val)},
+			}},
+		})
+		require.NoError(t, err, "Write failed")
+		return res.Results[0]
+	}
+	require.Equal(t, ua.StatusOK, write(ua.NewNumericNodeID(idx, 1001), true))
+	require.Equal(t, true, ns.GetValue("Motor.Enabled"))
+	require.Equal(t, ua.StatusOK, write(ua.NewStringNodeID(idx, "Setpoints"), []int32{4, 5}))
+	require.Equal(t, []int32{4, 5}, ns.GetValue("Setpoints"))
+	require.Equal(t, ua.StatusBadNotWritable, write(ua.NewStringNodeID(idx, "Name"), "plant2"))
+	require.Equal(t, ua.StatusBadTypeMismatch, write(speedID, int32(1)))
+	require.Equal(t, "plant1", ns.GetValue("Name"))
+
+	// the writes are buffered until the application receives them
+	require.Equal(t, "Motor.Enabled", <-ns.FieldNotification)
+	require.Equal(t, "Setpoints", <-ns.FieldNotification)
+	require.Empty(t, ns.FieldNotification)
+
+	notifyCh := make(chan *opcua.PublishNotificationData, 10)
+	sub, err := c.Subscribe(ctx, &opcua.SubscriptionParameters{Interval: 50 * time.Millisecond}, notifyCh)
+	require.NoError(t, err, "Subscribe failed")
+	defer sub.Cancel(ctx)
+
+	_, err = sub.Monitor(ctx, ua.TimestampsToReturnBoth, opcua.NewMonitoredItemCreateRequestWithDefaults(speedID, ua.AttributeIDValue, 42))
+	require.NoError(t, err, "Monitor failed")
+
+	require.Error(t, ns.SetValue("Motor.Speed", "fast"))
+	require.NoError(t, ns.SetValue("Motor.Speed", float64(1500)))
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case <-timeout:
+			t.Fatal("timeout waiting for data change")
+		case msg := <-notifyCh:
+			require.NoError(t, msg.Error)
+			dc, ok := msg.Value.(*ua.DataChangeNotification)
+			if !ok {
+				continue
+			}
+			for _, item := range dc.MonitoredItems {
+				if item.Value.Value.Value() == float64(1500) {
+					return
+				}
+			}
+		}
+	}
+}
+
+type line struct {
+	Pump *struct {
+		Flow float64
+	}
+}
+
+// TestStructNamespaceNilPointer verifies that the fields of a struct
+// which is removed after binding report an error instead of panicking.
+func TestStructNamespaceNilPointer(t *testing.T) {
+	srv := server.New(server.EndPoint("localhost", 4840))
+
+	var l line
+	l.Pump = &struct{ Flow float64 }{Flow: 3.5}
+	ns, err := server.NewStructNamespace(srv, "urn:gopcua:test:line", &l)
+	require.NoError(t, err, "NewStructNamespace failed")
+	flowID := ua.NewStringNodeID(ns.ID(), "Pump.Flow")
+	require.Equal(t, 3.5, ns.GetValue("Pump.Flow"))
+
+	ns.Update(func() { l.Pump = nil })
+	require.Nil(t, ns.GetValue("Pump.Flow"))
+	require.ErrorContains(t, ns.SetValue("Pump.Flow", 1.0), "field Pump.Flow")
+	require.Equal(t, ua.StatusBadNoData, ns.Attribute(flowID, ua.AttributeIDValue).Status)
+	require.Equal(t, ua.StatusBadNodeIDUnknown, ns.SetAttribute(flowID, ua.AttributeIDValue, server.DataValueFromValue(1.0)))
+}
@@ -0,0 +1,116 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+const testTypeDictionary = `<?xml version="1.0" encoding="utf-8"?>
+<opc:TypeDictionary xmlns:opc="http://opcfoundation.org/BinarySchema/" xmlns:ua="http://opcfoundation.org/UA/" xmlns:tns="urn:gopcua:test:dictionary" DefaultByteOrder="LittleEndian" TargetNamespace="urn:gopcua:test:dictionary">
+  <opc:Import Namespace="http://opcfoundation.org/UA/" Location="Opc.Ua.BinarySchema.bsd" />
+  <opc:EnumeratedType Name="Mode" LengthInBits="32">
+    <opc:EnumeratedValue Name="Auto" Value="1" />
+    <opc:EnumeratedValue Name="Manual" Value="2" />
+  </opc:EnumeratedType>
+  <opc:StructuredType Name="Point" BaseType="ua:ExtensionObject">
+    <opc:Field Name="X" TypeName="opc:Double" />
+    <opc:Field Name="Y" TypeName="opc:Double" />
+  </opc:StructuredType>
+  <opc:StructuredType Name="Path" BaseType="ua:ExtensionObject">
+    <opc:Field Name="OriginSpecified" TypeName="opc:Bit" />
+    <opc:Field Name="Reserved1" TypeName="opc:Bit" Length="31" />
+    <opc:Field Name="Name" TypeName="opc:String" />
+    <opc:Field Name="Mode" TypeName="tns:Mode" />
+    <opc:Field Name="Origin" TypeName="tns:Point" SwitchField="OriginSpecified" />
+    <opc:Field Name="NoOfPoints" TypeName="opc:Int32" />
+    <opc:Field Name="Points" TypeName="tns:Point" LengthField="NoOfPoints" />
+  </opc:StructuredType>
+</opc:TypeDictionary>`
+
+// addTestVariable adds a readable variable with the value.
+func addTestVariable(ns *server.NodeNameSpace, nid, dt *ua.NodeID, typedef uint32, v any) *server.Node {
+	n := server.NewNode(nid, server.Attributes{
+		ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassVariable)),
+		ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: ns.ID(), Name: nid.StringID()}),
+		ua.AttributeIDDisplayName: server.DataValueFromValue(ua.NewLocalizedText(nid.StringID())),
+		ua.AttributeIDDataType:    server.DataValueFromValue(ua.NewExpandedNodeID(dt, "", 0)),
+		ua.AttributeIDValueRank:   server.DataValueFromValue(int32(-1)),
+		ua.AttributeIDAccessLevel: server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead)),
+	}, nil, func() *ua.DataValue { return server.DataValueFromValue(v) })
+	if td := ns.Node(ua.NewNumericNodeID(0, typedef)); td != nil {
+		n.AddRef(td, id.HasTypeDefinition, true)
+	}
+	ns.AddNode(n)
+	return n
+}
+
+// TestTypeDictionary verifies that the client decodes structures which
+// are only described by an OPC Binary type dictionary.
+func TestTypeDictionary(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	ns := server.NewNodeNameSpace(srv, "urn:gopcua:test:dictionary")
+	idx := ns.ID()
+	pathID, encID := ua.NewNumericNodeID(idx, 7000), ua.NewNumericNodeID(idx, 7001)
+	enc := addTestDataType(srv, ns, "Path", pathID, encID, nil)
+
+	dict := addTestVariable(ns, ua.NewStringNodeID(idx, "Dictionary"), ua.NewNumericNodeID(0, id.ByteString), id.DataTypeDictionaryType, []byte(testTypeDictionary))
+	srv.Node(ua.NewNumericNodeID(0, id.OPCBinarySchema_TypeSystem)).AddRef(dict, id.HasComponent, true)
+	dict.AddRef(srv.Node(ua.NewNumericNodeID(0, id.OPCBinarySchema_TypeSystem)), id.HasComponent, false)
+	desc := addTestVariable(ns, ua.NewStringNodeID(idx, "PathDescription"), ua.NewNumericNodeID(0, id.String), id.DataTypeDescriptionType, "Path")
+	dict.AddRef(desc, id.HasComponent, true)
+	desc.AddRef(dict, id.HasComponent, false)
+	enc.AddRef(desc, id.HasDescription, true)
+	desc.AddRef(enc, id.HasDescription, false)
+
+	// the server encodes the value with a descriptor which is not
+	// registered so that the client cannot decode it by itself.
+	point := &ua.StructureDescriptor{Name: "Point", Fields: []*ua.FieldDescriptor{
+		{Name: "X", BuiltinType: ua.TypeIDDouble, ValueRank: -1},
+		{Name: "Y", BuiltinType: ua.TypeIDDouble, ValueRank: -1},
+	}}
+	path := &ua.StructureDescriptor{Name: "Path", DataTypeID: pathID, EncodingID: encID, StructureType: ua.StructureTypeStructureWithOptionalFields, Fields: []*ua.FieldDescriptor{
+		{Name: "Name", BuiltinType: ua.TypeIDString, ValueRank: -1},
+		{Name: "Mode", BuiltinType: ua.TypeIDInt32, ValueRank: -1},
+		{Name: "Origin", Structure: point, ValueRank: -1, IsOptional: true},
+		{Name: "Points", Structure: point, ValueRank: 1},
+	}}
+	newPoint := func(x, y float64) *ua.DynamicStructure {
+		return &ua.DynamicStructure{Type: point, Values: []any{x, y}}
+	}
+	value := &ua.DynamicStructure{Type: path, Values: []any{"path", int32(2), newPoint(1, 2), []*ua.DynamicStructure{newPoint(3, 4)}}}
+	nid := ua.NewStringNodeID(idx, "Path1")
+	addTestVariable(ns, nid, pathID, id.BaseDataVariableType, ua.NewExtensionObject(value))
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	require.NoError(t, c.LoadTypeDictionaries(ctx), "LoadTypeDictionaries failed")
+
+	v, err := c.Node(nid).Value(ctx)
+	require.NoError(t, err, "Read failed")
+	got, ok := v.Value().(*ua.ExtensionObject).Value.(*ua.DynamicStructure)
+	require.True(t, ok, "got %T", v.Value().(*ua.ExtensionObject).Value)
+	require.Equal(t, "Path", got.Type.Name)
+	require.Equal(t, pathID.String(), got.Type.DataTypeID.String())
+	require.Equal(t, "path", got.Get("Name"))
+	require.Equal(t, int32(2), got.Get("Mode"))
+	require.Equal(t, 2.0, got.Get("Origin").(*ua.DynamicStructure).Get("Y"))
+	points := got.Get("Points").([]*ua.DynamicStructure)
+	require.Len(t, points, 1)
+	require.Equal(t, 3.0, points[0].Get("X"))
+}
@@ -0,0 +1,100 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+type registryPoint struct {
+	X, Y float64
+}
+
+// TestTypeRegistry verifies that clients and servers decode extension
+// objects with their own type registries which are keyed by the
+// namespace uri.
+func TestTypeRegistry(t *testing.T) {
+	ctx := context.Background()
+
+	const uri = "urn:gopcua:test:registry"
+	srvTypes := ua.NewScopedTypeRegistry()
+	require.NoError(t, srvTypes.RegisterURI(uri, ua.NewNumericNodeID(0, 5901), new(registryPoint)))
+
+	srv := startServer(server.TypeRegistry(srvTypes))
+	defer srv.Close()
+
+	ns := server.NewNodeNameSpace(srv, uri)
+	nid := ua.NewStringNodeID(ns.ID(), "Point")
+	value := ua.NewExtensionObject(&registryPoint{X: 1, Y: 2})
+	ns.AddNode(server.NewNode(nid, server.Attributes{
+		ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassVariable)),
+		ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: ns.ID(), Name: "Point"}),
+		ua.AttributeIDValueRank:   server.DataValueFromValue(int32(-1)),
+		ua.AttributeIDAccessLevel: server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite)),
+	}, nil, func() *ua.DataValue { return server.DataValueFromValue(value) }))
+
+	connect := func(t *testing.T, opts ...opcua.Option) *opcua.Client {
+		c, err := opcua.NewClient("opc.tcp://localhost:4840", append(opts, opcua.SecurityMode(ua.MessageSecurityModeNone))...)
+		require.NoError(t, err, "NewClient failed")
+		require.NoError(t, c.Connect(ctx), "Connect failed")
+		return c
+	}
+	read := func(t *testing.T, c *opcua.Client) *ua.DataValue {
+		res, err := c.Read(ctx, &ua.ReadRequest{NodesToRead: []*ua.ReadValueID{{NodeID: nid}}})
+		require.NoError(t, err, "Read failed")
+		return res.Results[0]
+	}
+
+	t.Run("scoped", func(t *testing.T) {
+		types := ua.NewScopedTypeRegistry()
+		require.NoError(t, types.RegisterURI(uri, ua.NewNumericNodeID(0, 5901), new(registryPoint)))
+		c := connect(t, opcua.TypeRegistry(types))
+		defer c.Close(ctx)
+
+		dv := read(t, c)
+		require.Equal(t, ua.StatusOK, dv.Status)
+		require.Equal(t, &registryPoint{X: 1, Y: 2}, dv.Value.Value().(*ua.ExtensionObject).Value)
+
+		testWrite(t, ctx, c, ua.StatusOK, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      nid,
+				AttributeID: ua.AttributeIDValue,
+				Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(ua.NewExtensionObject(&registryPoint{X: 3, Y: 4}))},
+			}},
+		})
+		got := srv.Node(nid).Value().Value.Value().(*ua.ExtensionObject)
+		require.Equal(t, &registryPoint{X: 3, Y: 4}, got.Value)
+
+		dv = read(t, c)
+		require.Equal(t, &registryPoint{X: 3, Y: 4}, dv.Value.Value().(*ua.ExtensionObject).Value)
+	})
+
+	t.Run("other namespace", func(t *testing.T) {
+		// the same id in another namespace is a different type
+		types := ua.NewScopedTypeRegistry()
+		require.NoError(t, types.RegisterURI("urn:gopcua:test:other", ua.NewNumericNodeID(0, 5901), new(registryPoint)))
+		c := connect(t, opcua.TypeRegistry(types))
+		defer c.Close(ctx)
+
+		dv := read(t, c)
+		require.Equal(t, ua.StatusBadDataTypeIDUnknown, dv.Status)
+	})
+
+	t.Run("structure data type", func(t *testing.T) {
+		// the encoding is looked up in the registry of the server
+		dt, err := ns.AddStructureDataType("Point", nil, new(registryPoint))
+		require.NoError(t, err, "AddStructureDataType failed")
+		enc := ua.NewNumericNodeID(ns.ID(), 5901)
+		require.NotNil(t, srv.Node(enc), "encoding node")
+		def, err := dt.Attribute(ua.AttributeIDDataTypeDefinition)
+		require.NoError(t, err, "DataTypeDefinition")
+		require.Equal(t, enc.String(), def.Value.Value.Value().(*ua.ExtensionObject).Value.(*ua.StructureDefinition).DefaultEncodingID.String())
+	})
+}
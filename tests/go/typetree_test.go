@@ -0,0 +1,105 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+func nodeIDStrings(ids []*ua.NodeID) []string {
+	var s []string
+	for _, id := range ids {
+		s = append(s, id.String())
+	}
+	return s
+}
+
+// TestTypeTree verifies that the server answers subtype queries and
+// browse requests with subtypes from the type hierarchy and that the
+// hierarchy is updated when types are added.
+func TestTypeTree(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	require.NoError(t, srv.ImportNodeSet(parseNodeSet(t, deviceNodeSet)), "ImportNodeSet failed")
+	nsi := namespaceIndex(t, srv, "urn:gopcua:test:device")
+	ns, err := srv.Namespace(int(nsi))
+	require.NoError(t, err)
+	nodeNS := ns.(*server.NodeNameSpace)
+
+	deviceType := ua.NewNumericNodeID(nsi, 1001)
+	machineType := ua.NewNumericNodeID(nsi, 2001)
+	require.True(t, srv.IsSubtype(machineType, ua.NewNumericNodeID(0, id.BaseObjectType)))
+	require.True(t, srv.IsSubtype(ua.NewNumericNodeID(0, id.HasComponent), ua.NewNumericNodeID(0, id.HierarchicalReferences)))
+	require.False(t, srv.IsSubtype(deviceType, machineType))
+	require.Equal(t, []string{machineType.String()}, nodeIDStrings(srv.Subtypes(deviceType)))
+
+	// the hierarchy changes when a type is added
+	pumpType := ua.NewNumericNodeID(nsi, 3001)
+	pump := server.NewNode(pumpType, server.Attributes{
+		ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassObjectType)),
+		ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: nsi, Name: "PumpType"}),
+		ua.AttributeIDDisplayName: server.DataValueFromValue(ua.NewLocalizedText("PumpType")),
+	}, nil, nil)
+	nodeNS.AddNode(pump)
+	machine := srv.Node(machineType)
+	machine.AddRef(pump, id.HasSubtype, true)
+	pump.AddRef(machine, id.HasSubtype, false)
+	require.Equal(t, []string{machineType.String(), pumpType.String()}, nodeIDStrings(srv.Subtypes(deviceType)))
+	require.True(t, srv.IsSubtype(pumpType, deviceType))
+
+	browse := func(refType uint32, subtypes bool, classes ua.NodeClass, mask ua.BrowseResultMask) []*ua.ReferenceDescription {
+		res := ns.Browse(&ua.BrowseDescription{
+			NodeID:          machineType,
+			BrowseDirection: ua.BrowseDirectionForward,
+			ReferenceTypeID: ua.NewNumericNodeID(0, refType),
+			IncludeSubtypes: subtypes,
+			NodeClassMask:   uint32(classes),
+			ResultMask:      uint32(mask),
+		})
+		require.Equal(t, ua.StatusGood, res.StatusCode)
+		return res.References
+	}
+
+	refs := browse(id.HierarchicalReferences, true, ua.NodeClassVariable, ua.BrowseResultMaskAll)
+	require.Len(t, refs, 1)
+	require.Equal(t, "Speed", refs[0].BrowseName.Name)
+	require.Equal(t, ua.NodeClassVariable, refs[0].NodeClass)
+	require.True(t, refs[0].TypeDefinition.NodeID.Equal(ua.NewNumericNodeID(0, id.BaseDataVariableType)))
+
+	require.Len(t, browse(id.HierarchicalReferences, true, ua.NodeClassObjectType, ua.BrowseResultMaskAll), 1, "PumpType")
+	require.Empty(t, browse(id.HierarchicalReferences, false, ua.NodeClassVariable, ua.BrowseResultMaskAll))
+
+	// the view service applies the result mask
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	resp, err := c.Browse(ctx, &ua.BrowseRequest{
+		NodesToBrowse: []*ua.BrowseDescription{{
+			NodeID:          machineType,
+			BrowseDirection: ua.BrowseDirectionForward,
+			ReferenceTypeID: ua.NewNumericNodeID(0, id.HasComponent),
+			ResultMask:      uint32(ua.BrowseResultMaskBrowseName),
+		}},
+	})
+	require.NoError(t, err, "Browse failed")
+	require.Len(t, resp.Results, 1)
+	refs = resp.Results[0].References
+	require.Len(t, refs, 1)
+	require.Equal(t, "Speed", refs[0].BrowseName.Name)
+	require.Equal(t, "", refs[0].DisplayName.Text)
+	require.Equal(t, ua.NodeClassUnspecified, refs[0].NodeClass)
+	require.True(t, refs[0].TypeDefinition.NodeID.Equal(ua.NewTwoByteNodeID(0)))
+}
@@ -0,0 +1,130 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+type motorState int32
+
+// TestVariable verifies that typed variables derive their attributes from
+// the Go type, that clients can read and write them and that Set notifies
+// the subscribers.
+func TestVariable(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	ns := server.NewNodeNameSpace(srv, "urn:gopcua:test:variable")
+	idx := ns.ID()
+	objects := srv.Node(ua.NewNumericNodeID(0, id.ObjectsFolder))
+
+	speed, err := server.NewVariable(ns, objects, ua.NewStringNodeID(idx, "Speed"), "Speed", 1200.0)
+	require.NoError(t, err, "NewVariable failed")
+	state, err := server.NewVariable(ns, objects, nil, "State", motorState(1))
+	require.NoError(t, err, "NewVariable failed")
+	setpoints, err := server.NewVariable(ns, objects, nil, "Setpoints", [3]int32{1, 2, 3})
+	require.NoError(t, err, "NewVariable failed")
+	unit, err := server.NewVariable(ns, objects, nil, "Unit", &ua.EUInformation{UnitID: -1, DisplayName: ua.NewLocalizedText("rpm"), Description: ua.NewLocalizedText("revolutions per minute")})
+	require.NoError(t, err, "NewVariable failed")
+	label, err := server.NewVariable(ns, nil, nil, "Label", ua.NewLocalizedText("motor"))
+	require.NoError(t, err, "NewVariable failed")
+	label.SetAccessLevel(ua.AccessLevelTypeCurrentRead)
+
+	_, err = server.NewVariable(ns, nil, nil, "Invalid", map[string]int{})
+	require.ErrorContains(t, err, "unsupported type")
+	_, err = server.NewVariable(ns, nil, ua.NewStringNodeID(idx, "Speed"), "Speed", 0.0)
+	require.ErrorContains(t, err, "duplicate node id")
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	tests := []struct {
+		node     *server.Node
+		dataType uint32
+		rank     int32
+		value    any
+	}{
+		{speed.Node, id.Double, -1, 1200.0},
+		{state.Node, id.Int32, -1, int32(1)},
+		{setpoints.Node, id.Int32, 1, []int32{1, 2, 3}},
+		{unit.Node, id.EUInformation, -1, ua.NewExtensionObject(unit.Get())},
+		{label.Node, id.LocalizedText, -1, label.Get()},
+	}
+	for _, tt := range tests {
+		attrs, err := c.Node(tt.node.ID()).Attributes(ctx, ua.AttributeIDDataType, ua.AttributeIDValueRank, ua.AttributeIDValue)
+		require.NoError(t, err, "Read failed")
+		require.Equal(t, ua.NewNumericNodeID(0, tt.dataType).String(), attrs[0].Value.ExpandedNodeID().NodeID.String(), tt.node.BrowseName().Name)
+		require.Equal(t, tt.rank, attrs[1].Value.Value(), tt.node.BrowseName().Name)
+		if eo, ok := tt.value.(*ua.ExtensionObject); ok {
+			require.Equal(t, eo.Value, attrs[2].Value.Value().(*ua.ExtensionObject).Value)
+			continue
+		}
+		require.Equal(t, tt.value, attrs[2].Value.Value(), tt.node.BrowseName().Name)
+	}
+
+	write := func(nid *ua.NodeID, val any) ua.StatusCode {
+		t.Helper()
+		res, err := c.Write(ctx, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      nid,
+				AttributeID: ua.AttributeIDValue,
+				Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(val)},
+			}},
+		})
+		require.NoError(t, err, "Write failed")
+		return res.Results[0]
+	}
+	require.Equal(t, ua.StatusOK, write(state.ID(), int32(2)))
+	require.Equal(t, motorState(2), state.Get())
+	require.Equal(t, ua.StatusOK, write(setpoints.ID(), []int32{4, 5, 6}))
+	require.Equal(t, [3]int32{4, 5, 6}, setpoints.Get())
+	require.Equal(t, ua.StatusBadNotWritable, write(label.ID(), ua.NewLocalizedText("pump")))
+	require.Equal(t, "motor", label.Get().Text)
+
+	notifyCh := make(chan *opcua.PublishNotificationData, 10)
+	sub, err := c.Subscribe(ctx, &opcua.SubscriptionParameters{Interval: 50 * time.Millisecond}, notifyCh)
+	require.NoError(t, err, "Subscribe failed")
+	defer sub.Cancel(ctx)
+
+	_, err = sub.Monitor(ctx, ua.TimestampsToReturnBoth, opcua.NewMonitoredItemCreateRequestWithDefaults(speed.ID(), ua.AttributeIDValue, 42))
+	require.NoError(t, err, "Monitor failed")
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	speed.Set(0, server.WithStatus(ua.StatusBadSensorFailure), server.WithSourceTimestamp(ts))
+	require.Equal(t, 0.0, speed.Get())
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case <-timeout:
+			t.Fatal("timeout waiting for data change")
+		case msg := <-notifyCh:
+			require.NoError(t, msg.Error)
+			dc, ok := msg.Value.(*ua.DataChangeNotification)
+			if !ok {
+				continue
+			}
+			for _, item := range dc.MonitoredItems {
+				if item.Value.Status == ua.StatusBadSensorFailure {
+					require.Equal(t, 0.0, item.Value.Value.Value())
+					require.True(t, ts.Equal(item.Value.SourceTimestamp))
+					return
+				}
+			}
+		}
+	}
+}
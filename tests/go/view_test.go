@@ -0,0 +1,159 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+const plantNodeSet = `<?xml version="1.0" encoding="utf-8"?>
+<UANodeSet xmlns="http://opcfoundation.org/UA/2011/03/UANodeSet.xsd">
+  <NamespaceUris>
+    <Uri>urn:gopcua:test:plant</Uri>
+  </NamespaceUris>
+  <Aliases>
+    <Alias Alias="Organizes">i=35</Alias>
+    <Alias Alias="HasComponent">i=47</Alias>
+    <Alias Alias="HasTypeDefinition">i=40</Alias>
+  </Aliases>
+  <UAObject NodeId="ns=1;i=5001" BrowseName="1:Line1">
+    <DisplayName>Line1</DisplayName>
+    <References>
+      <Reference ReferenceType="Organizes" IsForward="false">i=85</Reference>
+      <Reference ReferenceType="HasTypeDefinition">i=58</Reference>
+      <Reference ReferenceType="HasComponent">ns=1;i=5002</Reference>
+    </References>
+  </UAObject>
+  <UAVariable NodeId="ns=1;i=5002" BrowseName="1:Speed" ParentNodeId="ns=1;i=5001" DataType="Double">
+    <DisplayName>Speed</DisplayName>
+    <References>
+      <Reference ReferenceType="HasTypeDefinition">i=63</Reference>
+    </References>
+  </UAVariable>
+  <UAObject NodeId="ns=1;i=5003" BrowseName="1:Diagnostics">
+    <DisplayName>Diagnostics</DisplayName>
+    <References>
+      <Reference ReferenceType="Organizes" IsForward="false">i=85</Reference>
+      <Reference ReferenceType="HasTypeDefinition">i=58</Reference>
+    </References>
+  </UAObject>
+  <UAView NodeId="ns=1;i=5000" BrowseName="1:OperatorView">
+    <DisplayName>OperatorView</DisplayName>
+    <References>
+      <Reference ReferenceType="Organizes" IsForward="false">i=87</Reference>
+      <Reference ReferenceType="Organizes">ns=1;i=5001</Reference>
+    </References>
+  </UAView>
+</UANodeSet>`
+
+// TestViews verifies that views are imported from nodesets and created in
+// code and that Browse and TranslateBrowsePathsToNodeIDs are restricted
+// to the nodes and references of a view.
+func TestViews(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	require.NoError(t, srv.ImportNodeSet(parseNodeSet(t, plantNodeSet)), "ImportNodeSet failed")
+	nsi := namespaceIndex(t, srv, "urn:gopcua:test:plant")
+	ns, err := srv.Namespace(int(nsi))
+	require.NoError(t, err)
+	nodeNS := ns.(*server.NodeNameSpace)
+
+	operatorID := ua.NewNumericNodeID(nsi, 5000)
+	line1ID := ua.NewNumericNodeID(nsi, 5001)
+	speedID := ua.NewNumericNodeID(nsi, 5002)
+	diagID := ua.NewNumericNodeID(nsi, 5003)
+
+	operator := srv.View(operatorID)
+	require.NotNil(t, operator, "imported view")
+	require.True(t, operator.Contains(line1ID))
+	require.True(t, operator.Contains(speedID))
+	require.False(t, operator.Contains(diagID))
+
+	diag := srv.Node(diagID)
+	maintenance := nodeNS.AddView("MaintenanceView")
+	maintenance.AddNodes(diag)
+	maintenance.AddReference(diag, id.HasTypeDefinition, srv.Node(ua.NewNumericNodeID(0, id.BaseObjectType)))
+	require.Equal(t, maintenance, srv.View(maintenance.Node().ID()))
+	line := nodeNS.AddView("LineView")
+	line.AddTree(srv.Node(line1ID))
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	browse := func(view, nid *ua.NodeID) (*ua.BrowseResult, error) {
+		t.Helper()
+		resp, err := c.Browse(ctx, &ua.BrowseRequest{
+			View: &ua.ViewDescription{ViewID: view},
+			NodesToBrowse: []*ua.BrowseDescription{{
+				NodeID:          nid,
+				BrowseDirection: ua.BrowseDirectionBoth,
+				ReferenceTypeID: ua.NewNumericNodeID(0, id.References),
+				IncludeSubtypes: true,
+				ResultMask:      uint32(ua.BrowseResultMaskAll),
+			}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Results[0], nil
+	}
+	targets := func(res *ua.BrowseResult) []string {
+		var s []string
+		for _, r := range res.References {
+			s = append(s, r.BrowseName.Name)
+		}
+		return s
+	}
+
+	res, err := browse(nil, ua.NewNumericNodeID(0, id.ObjectsFolder))
+	require.NoError(t, err, "Browse failed")
+	require.Contains(t, targets(res), "Diagnostics")
+
+	res, err = browse(operatorID, ua.NewNumericNodeID(0, id.ObjectsFolder))
+	require.NoError(t, err, "Browse failed")
+	require.Equal(t, ua.StatusBadNodeNotInView, res.StatusCode)
+
+	res, err = browse(operatorID, line1ID)
+	require.NoError(t, err, "Browse failed")
+	require.Equal(t, ua.StatusOK, res.StatusCode)
+	require.ElementsMatch(t, []string{"OperatorView", "Speed"}, targets(res))
+
+	res, err = browse(maintenance.Node().ID(), diagID)
+	require.NoError(t, err, "Browse failed")
+	require.ElementsMatch(t, []string{"MaintenanceView", "BaseObjectType"}, targets(res))
+
+	path := []*ua.QualifiedName{{NamespaceIndex: nsi, Name: "Line1"}, {NamespaceIndex: nsi, Name: "Speed"}}
+	nid, err := c.Node(ua.NewNumericNodeID(0, id.ObjectsFolder)).TranslateBrowsePathsToNodeIDs(ctx, path)
+	require.NoError(t, err, "TranslateBrowsePathsToNodeIDs failed")
+	require.Equal(t, speedID.String(), nid.String())
+
+	nid, err = c.Node(operatorID).TranslateBrowsePathsToNodeIDs(ctx, path)
+	require.NoError(t, err, "TranslateBrowsePathsToNodeIDs failed")
+	require.Equal(t, speedID.String(), nid.String())
+
+	nid, err = c.Node(line.Node().ID()).TranslateBrowsePathsToNodeIDs(ctx, path)
+	require.NoError(t, err, "TranslateBrowsePathsToNodeIDs failed")
+	require.Equal(t, speedID.String(), nid.String())
+
+	_, err = c.Node(operatorID).TranslateBrowsePathsToNodeIDs(ctx, []*ua.QualifiedName{{NamespaceIndex: nsi, Name: "Diagnostics"}})
+	require.ErrorIs(t, err, ua.StatusBadNoMatch)
+	_, err = c.Node(maintenance.Node().ID()).TranslateBrowsePathsToNodeIDs(ctx, []*ua.QualifiedName{{NamespaceIndex: nsi, Name: "Diagnostics"}})
+	require.NoError(t, err, "TranslateBrowsePathsToNodeIDs failed")
+
+	// a bad service result makes the client reconnect so this is the last request
+	_, err = browse(ua.NewNumericNodeID(nsi, 9999), line1ID)
+	require.ErrorIs(t, err, ua.StatusBadViewIDUnknown)
+}
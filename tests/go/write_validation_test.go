@@ -0,0 +1,108 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWriteValidation verifies that the write service checks the access
+// level, the write mask, the data type and the value rank of the nodes.
+func TestWriteValidation(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	ns := server.NewNodeNameSpace(srv, "urn:gopcua:test:write")
+	variable := func(name string, dt uint32, rank int32, dims []uint32, level ua.AccessLevelType, v any) *ua.NodeID {
+		attrs := server.Attributes{
+			ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassVariable)),
+			ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: ns.ID(), Name: name}),
+			ua.AttributeIDDataType:    server.DataValueFromValue(ua.NewNumericExpandedNodeID(0, dt)),
+			ua.AttributeIDValueRank:   server.DataValueFromValue(rank),
+			ua.AttributeIDAccessLevel: server.DataValueFromValue(byte(level)),
+			ua.AttributeIDWriteMask:   server.DataValueFromValue(uint32(ua.AttributeWriteMaskDisplayName)),
+		}
+		if dims != nil {
+			attrs[ua.AttributeIDArrayDimensions] = server.DataValueFromValue(dims)
+		}
+		n := server.NewNode(ua.NewStringNodeID(ns.ID(), name), attrs, nil, func() *ua.DataValue { return server.DataValueFromValue(v) })
+		ns.AddNode(n)
+		return n.ID()
+	}
+	rw := ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite
+	readOnly := variable("ReadOnly", id.Double, -1, nil, ua.AccessLevelTypeCurrentRead, 1.0)
+	double := variable("Double", id.Double, -1, nil, rw, 1.0)
+	number := variable("Number", id.Number, -1, nil, rw, 1.0)
+	array := variable("Array", id.Int32, 1, []uint32{3}, rw, []int32{1, 2, 3})
+	status := variable("Status", id.Int32, -1, nil, rw|ua.AccessLevelTypeStatusWrite|ua.AccessLevelTypeTimestampWrite, int32(1))
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		id     *ua.NodeID
+		attr   ua.AttributeID
+		dv     *ua.DataValue
+		status ua.StatusCode
+	}{
+		{"read only", readOnly, ua.AttributeIDValue, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(2.0)}, ua.StatusBadNotWritable},
+		{"unknown namespace", ua.NewStringNodeID(99, "x"), ua.AttributeIDValue, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(2.0)}, ua.StatusBadNodeIDUnknown},
+		{"unknown node", ua.NewStringNodeID(ns.ID(), "x"), ua.AttributeIDValue, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(2.0)}, ua.StatusBadNodeIDUnknown},
+		{"type mismatch", double, ua.AttributeIDValue, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(int32(2))}, ua.StatusBadTypeMismatch},
+		{"scalar for array", array, ua.AttributeIDValue, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(int32(2))}, ua.StatusBadTypeMismatch},
+		{"array too long", array, ua.AttributeIDValue, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant([]int32{1, 2, 3, 4})}, ua.StatusBadOutOfRange},
+		{"status not writable", double, ua.AttributeIDValue, &ua.DataValue{EncodingMask: ua.DataValueValue | ua.DataValueStatusCode, Value: ua.MustVariant(2.0), Status: ua.StatusUncertain}, ua.StatusBadWriteNotSupported},
+		{"display name type", double, ua.AttributeIDDisplayName, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant("Double")}, ua.StatusBadTypeMismatch},
+		{"description not writable", double, ua.AttributeIDDescription, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(ua.NewLocalizedText("x"))}, ua.StatusBadNotWritable},
+		{"event notifier of variable", double, ua.AttributeIDEventNotifier, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(byte(1))}, ua.StatusBadAttributeIDInvalid},
+
+		{"double", double, ua.AttributeIDValue, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(2.0)}, ua.StatusOK},
+		{"subtype of number", number, ua.AttributeIDValue, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(uint16(2))}, ua.StatusOK},
+		{"array", array, ua.AttributeIDValue, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant([]int32{4, 5})}, ua.StatusOK},
+		{"display name", double, ua.AttributeIDDisplayName, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(ua.NewLocalizedText("Double2"))}, ua.StatusOK},
+		{"status and timestamp", status, ua.AttributeIDValue, &ua.DataValue{
+			EncodingMask:    ua.DataValueValue | ua.DataValueStatusCode | ua.DataValueSourceTimestamp,
+			Value:           ua.MustVariant(int32(2)),
+			Status:          ua.StatusUncertain,
+			SourceTimestamp: ts,
+		}, ua.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testWrite(t, ctx, c, tt.status, &ua.WriteRequest{
+				NodesToWrite: []*ua.WriteValue{{NodeID: tt.id, AttributeID: tt.attr, Value: tt.dv}},
+			})
+		})
+	}
+
+	res, err := c.Read(ctx, &ua.ReadRequest{
+		NodesToRead: []*ua.ReadValueID{
+			{NodeID: status, AttributeID: ua.AttributeIDValue},
+			{NodeID: double, AttributeID: ua.AttributeIDValue},
+			{NodeID: double, AttributeID: ua.AttributeIDDisplayName},
+		},
+		TimestampsToReturn: ua.TimestampsToReturnBoth,
+	})
+	require.NoError(t, err, "Read failed")
+	require.Equal(t, ua.StatusUncertain, res.Results[0].Status)
+	require.Equal(t, int32(2), res.Results[0].Value.Value())
+	require.Equal(t, ts, res.Results[0].SourceTimestamp.UTC())
+	require.Equal(t, 2.0, res.Results[1].Value.Value())
+	require.False(t, res.Results[1].SourceTimestamp.IsZero(), "source timestamp not set")
+	require.Equal(t, "Double2", res.Results[2].Value.Value().(*ua.LocalizedText).Text)
+}
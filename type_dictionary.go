@@ -0,0 +1,290 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+
+	"github.com/gopcua/opcua/debug"
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/schema"
+	"github.com/gopcua/opcua/ua"
+)
+
+// typeDictionary is an OPC Binary type dictionary of the server with the
+// structures which have been converted.
+type typeDictionary struct {
+	dict    *schema.TypeDictionary
+	structs map[string]*ua.StructureDescriptor
+}
+
+// LoadTypeDictionaries reads the OPC Binary type dictionaries of the
+// server and their DataTypeDescriptions so that the structures of servers
+// which do not provide the DataTypeDefinition attribute can be decoded.
+// This is usually the case for servers which implement a version of the
+// specification before 1.04.
+//
+// Calling it is optional since Read resolves the dictionary of an unknown
+// structure when it is needed. It returns an error if a dictionary cannot
+// be read or parsed. Structures which cannot be represented as
+// ua.DynamicStructure are skipped.
+func (c *Client) LoadTypeDictionaries(ctx context.Context) error {
+	dicts, err := c.Node(ua.NewNumericNodeID(0, id.OPCBinarySchema_TypeSystem)).References(ctx, id.HasComponent, ua.BrowseDirectionForward, ua.NodeClassVariable, false)
+	if err != nil {
+		return err
+	}
+	for _, r := range dicts {
+		descs, err := c.Node(r.NodeID.NodeID).References(ctx, id.HasComponent, ua.BrowseDirectionForward, ua.NodeClassVariable, false)
+		if err != nil {
+			return err
+		}
+		for _, desc := range descs {
+			encs, err := c.Node(desc.NodeID.NodeID).References(ctx, id.HasDescription, ua.BrowseDirectionInverse, ua.NodeClassObject, false)
+			if err != nil {
+				return err
+			}
+			for _, enc := range encs {
+				if _, err := c.structureByEncodingID(ctx, enc.NodeID.NodeID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dictionaryStructure returns the structure of the encoding from the
+// OPC Binary type dictionary of the server or nil if the encoding has no
+// DataTypeDescription.
+func (c *Client) dictionaryStructure(ctx context.Context, enc, dt *ua.NodeID) (*ua.StructureDescriptor, error) {
+	descs, err := c.Node(enc).References(ctx, id.HasDescription, ua.BrowseDirectionForward, ua.NodeClassVariable, false)
+	if err != nil || len(descs) == 0 {
+		return nil, err
+	}
+	desc := c.Node(descs[0].NodeID.NodeID)
+	v, err := desc.Value(ctx)
+	if _, ok := err.(ua.StatusCode); err != nil && !ok {
+		return nil, err
+	}
+	var name string
+	if v != nil {
+		name, _ = v.Value().(string)
+	}
+	if name == "" {
+		return nil, nil
+	}
+	dicts, err := desc.References(ctx, id.HasComponent, ua.BrowseDirectionInverse, ua.NodeClassVariable, false)
+	if err != nil || len(dicts) == 0 {
+		return nil, err
+	}
+	td, err := c.typeDictionary(ctx, dicts[0].NodeID.NodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.types.mu.Lock()
+	defer c.types.mu.Unlock()
+	d, err := td.structure(name)
+	if err != nil {
+		debug.Printf("client: cannot use structure %s of the type dictionary: %v", name, err)
+		return nil, nil
+	}
+	d.DataTypeID, d.EncodingID = dt, enc
+	return d, nil
+}
+
+// typeDictionary returns the parsed dictionary of the DataTypeDictionary
+// variable.
+func (c *Client) typeDictionary(ctx context.Context, nid *ua.NodeID) (*typeDictionary, error) {
+	key := nid.String()
+	c.types.mu.Lock()
+	td := c.types.dictionaries[key]
+	c.types.mu.Unlock()
+	if td != nil {
+		return td, nil
+	}
+
+	v, err := c.Node(nid).Value(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := v.Value().([]byte)
+	if !ok {
+		return nil, errors.Errorf("dictionary %s is not a ByteString", nid)
+	}
+	dict, err := schema.ParseTypeDictionary(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Errorf("dictionary %s: %s", nid, err)
+	}
+	td = &typeDictionary{dict: dict, structs: make(map[string]*ua.StructureDescriptor)}
+
+	c.types.mu.Lock()
+	defer c.types.mu.Unlock()
+	if x := c.types.dictionaries[key]; x != nil {
+		return x, nil
+	}
+	c.types.dictionaries[key] = td
+	return td, nil
+}
+
+// dictionaryBuiltins contains the built-in types of the OPC Binary type
+// system by their namespace and name.
+var dictionaryBuiltins = map[string]map[string]ua.TypeID{
+	schema.BinarySchemaNamespace: {
+		"Boolean":    ua.TypeIDBoolean,
+		"SByte":      ua.TypeIDSByte,
+		"Byte":       ua.TypeIDByte,
+		"Int16":      ua.TypeIDInt16,
+		"UInt16":     ua.TypeIDUint16,
+		"Int32":      ua.TypeIDInt32,
+		"UInt32":     ua.TypeIDUint32,
+		"Int64":      ua.TypeIDInt64,
+		"UInt64":     ua.TypeIDUint64,
+		"Float":      ua.TypeIDFloat,
+		"Double":     ua.TypeIDDouble,
+		"String":     ua.TypeIDString,
+		"CharArray":  ua.TypeIDString,
+		"DateTime":   ua.TypeIDDateTime,
+		"Guid":       ua.TypeIDGUID,
+		"ByteString": ua.TypeIDByteString,
+	},
+	schema.UANamespace: {
+		"XmlElement":      ua.TypeIDXMLElement,
+		"NodeId":          ua.TypeIDNodeID,
+		"ExpandedNodeId":  ua.TypeIDExpandedNodeID,
+		"StatusCode":      ua.TypeIDStatusCode,
+		"QualifiedName":   ua.TypeIDQualifiedName,
+		"LocalizedText":   ua.TypeIDLocalizedText,
+		"ExtensionObject": ua.TypeIDExtensionObject,
+		"DataValue":       ua.TypeIDDataValue,
+		"Variant":         ua.TypeIDVariant,
+		"DiagnosticInfo":  ua.TypeIDDiagnosticInfo,
+	},
+}
+
+// structure returns the descriptor of the structure with the name. It
+// must be called with the lock of the type cache held.
+//
+// The OPC Binary encoding of a structure matches the encoding of the
+// structures with a DataTypeDefinition if the switch of a union is a
+// UInt32 and if the optional fields are selected by 32 bits. Other
+// layouts are not supported.
+func (td *typeDictionary) structure(name string) (*ua.StructureDescriptor, error) {
+	if d, ok := td.structs[name]; ok {
+		return d, nil
+	}
+	st := td.dict.StructType(name)
+	if st == nil {
+		return nil, errors.Errorf("unknown structure")
+	}
+
+	d := &ua.StructureDescriptor{Name: name, StructureType: ua.StructureTypeStructure}
+	// the descriptor is added before the fields since structures can
+	// contain themselves.
+	td.structs[name] = d
+	if err := td.convert(d, st); err != nil {
+		delete(td.structs, name)
+		return nil, err
+	}
+	return d, nil
+}
+
+// convert adds the fields of the structure to the descriptor.
+func (td *typeDictionary) convert(d *ua.StructureDescriptor, st *schema.StructType) error {
+	var bits int
+	optionalBits := map[string]int{}
+	var union string
+	for _, f := range st.Fields {
+		ns, typ := td.dict.TypeName(f.Type)
+		switch {
+		case ns == schema.BinarySchemaNamespace && typ == "Bit":
+			if st.IsSwitchField(f) {
+				optionalBits[f.Name] = bits
+			}
+			bits += max(f.Length, 1)
+			continue
+		case st.IsLengthField(f):
+			// the length of an array is encoded with the array
+			continue
+		case st.IsSwitchField(f):
+			if union != "" || ns != schema.BinarySchemaNamespace || typ != "UInt32" {
+				return errors.Errorf("unsupported switch field %s", f.Name)
+			}
+			union = f.Name
+			continue
+		case f.Length > 0:
+			return errors.Errorf("unsupported fixed length field %s", f.Name)
+		}
+
+		fd := &ua.FieldDescriptor{Name: f.Name, ValueRank: -1}
+		if f.IsSlice() {
+			fd.ValueRank = 1
+		}
+		switch {
+		case f.SwitchField == "":
+		case f.SwitchField == union:
+			if f.SwitchValue != strconv.Itoa(len(d.Fields)+1) {
+				return errors.Errorf("unsupported switch value of field %s", f.Name)
+			}
+		default:
+			bit, ok := optionalBits[f.SwitchField]
+			if !ok || bit != countOptional(d.Fields) {
+				return errors.Errorf("unsupported switch field of field %s", f.Name)
+			}
+			fd.IsOptional = true
+		}
+
+		if t, ok := dictionaryBuiltins[ns][typ]; ok {
+			fd.BuiltinType = t
+			fd.DataType = ua.NewNumericNodeID(0, uint32(t))
+		} else if ns == td.dict.TargetNamespace && td.dict.EnumType(typ) != nil {
+			fd.BuiltinType = ua.TypeIDInt32
+			fd.DataType = ua.NewNumericNodeID(0, id.Enumeration)
+		} else if ns == td.dict.TargetNamespace && td.dict.StructType(typ) != nil {
+			nested, err := td.structure(typ)
+			if err != nil {
+				return errors.Errorf("field %s: %s", f.Name, err)
+			}
+			fd.BuiltinType = ua.TypeIDNull
+			fd.Structure = nested
+		} else {
+			return errors.Errorf("unsupported type %s of field %s", f.Type, f.Name)
+		}
+		d.Fields = append(d.Fields, fd)
+	}
+
+	switch {
+	case union != "":
+		if bits > 0 || len(d.Fields) == 0 || countOptional(d.Fields) > 0 {
+			return errors.Errorf("unsupported union")
+		}
+		for _, f := range st.Fields {
+			if f.Name != union && !st.IsLengthField(f) && f.SwitchField != union {
+				return errors.Errorf("field %s is not part of the union", f.Name)
+			}
+		}
+		d.StructureType = ua.StructureTypeUnion
+	case bits > 0:
+		if bits != 32 || len(optionalBits) != countOptional(d.Fields) {
+			return errors.Errorf("unsupported encoding mask with %d bits", bits)
+		}
+		d.StructureType = ua.StructureTypeStructureWithOptionalFields
+	}
+	return nil
+}
+
+// countOptional returns the number of optional fields.
+func countOptional(fields []*ua.FieldDescriptor) int {
+	n := 0
+	for _, f := range fields {
+		if f.IsOptional {
+			n++
+		}
+	}
+	return n
+}
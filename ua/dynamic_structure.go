@@ -0,0 +1,582 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package ua
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/gopcua/opcua/errors"
+)
+
+// maxStructureDepth limits the nesting of dynamic structures.
+const maxStructureDepth = 100
+
+// StructureDescriptor describes a structure DataType at runtime. Values
+// of DataTypes without a Go type are encoded and decoded as a
+// DynamicStructure with the descriptor of their DataType.
+type StructureDescriptor struct {
+	Name       string
+	DataTypeID *NodeID
+
+	// EncodingID is the id of the Default Binary encoding.
+	EncodingID *NodeID
+
+	StructureType StructureType
+	Fields        []*FieldDescriptor
+}
+
+// FieldDescriptor describes a field of a structure.
+type FieldDescriptor struct {
+	Name     string
+	DataType *NodeID
+
+	// BuiltinType is the built-in type which encodes the values of the
+	// field. It is TypeIDNull for fields whose DataType is a structure
+	// which is encoded in place. These fields are described by the
+	// descriptor which is registered for the DataType.
+	BuiltinType TypeID
+
+	// ValueRank is the number of dimensions of array fields. Fields with
+	// a ValueRank of -1 or lower are scalars and fields with a ValueRank
+	// of 0 are one-dimensional arrays.
+	ValueRank int32
+
+	// IsOptional is set for the optional fields of a structure with
+	// optional fields.
+	IsOptional bool
+
+	// Structure is the descriptor of the DataType of a structure which
+	// is encoded in place. If it is nil the descriptor which is
+	// registered for the DataType is used.
+	Structure *StructureDescriptor
+}
+
+// NewStructureDescriptor returns the descriptor of a structure DataType
+// with the definition. builtinType returns the built-in type of the
+// values of a DataType as described for FieldDescriptor.BuiltinType.
+//
+// Fields which allow subtypes in structures with subtyped values are
+// encoded as ExtensionObject if they are structures and as Variant
+// otherwise.
+func NewStructureDescriptor(name string, dataTypeID *NodeID, def *StructureDefinition, builtinType func(*NodeID) TypeID) *StructureDescriptor {
+	d := &StructureDescriptor{
+		Name:          name,
+		DataTypeID:    dataTypeID,
+		EncodingID:    def.DefaultEncodingID,
+		StructureType: def.StructureType,
+	}
+	subtyped := def.StructureType == StructureTypeStructureWithSubtypedValues || def.StructureType == StructureTypeUnionWithSubtypedValues
+	for _, f := range def.Fields {
+		fd := &FieldDescriptor{
+			Name:        f.Name,
+			DataType:    f.DataType,
+			BuiltinType: builtinType(f.DataType),
+			ValueRank:   f.ValueRank,
+			IsOptional:  f.IsOptional && def.StructureType == StructureTypeStructureWithOptionalFields,
+		}
+		if subtyped && f.IsOptional {
+			switch fd.BuiltinType {
+			case TypeIDNull, TypeIDExtensionObject:
+				fd.BuiltinType = TypeIDExtensionObject
+			default:
+				fd.BuiltinType = TypeIDVariant
+			}
+		}
+		d.Fields = append(d.Fields, fd)
+	}
+	return d
+}
+
+// isUnion returns true if only one of the fields of the structure is
+// encoded.
+func (d *StructureDescriptor) isUnion() bool {
+	return d.StructureType == StructureTypeUnion || d.StructureType == StructureTypeUnionWithSubtypedValues
+}
+
+// Field returns the index of the field with the name or -1.
+func (d *StructureDescriptor) Field(name string) int {
+	for i, f := range d.Fields {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// rank returns the number of dimensions of the values of the field.
+func (f *FieldDescriptor) rank() int {
+	switch {
+	case f.ValueRank < 0:
+		return 0
+	case f.ValueRank == 0:
+		return 1
+	default:
+		return int(f.ValueRank)
+	}
+}
+
+var dynamicStructureType = reflect.TypeOf(new(DynamicStructure))
+
+// elemType returns the Go type of the scalar values of the field.
+func (f *FieldDescriptor) elemType() reflect.Type {
+	if f.BuiltinType == TypeIDNull {
+		return dynamicStructureType
+	}
+	return variantTypeIDToType[f.BuiltinType]
+}
+
+// Type returns the Go type of the values of the field. Arrays are
+// slices and multi-dimensional arrays are nested slices of the Go type
+// of the built-in type or of *DynamicStructure for structures.
+func (f *FieldDescriptor) Type() reflect.Type {
+	t := f.elemType()
+	for i := 0; i < f.rank(); i++ {
+		t = reflect.SliceOf(t)
+	}
+	return t
+}
+
+// zero returns the zero value of a scalar value of the field. Pointer
+// values are not nil so that they can be encoded.
+func (f *FieldDescriptor) zero() reflect.Value {
+	switch f.BuiltinType {
+	case TypeIDNull:
+		if d := f.structure(); d != nil {
+			return reflect.ValueOf(&DynamicStructure{Type: d})
+		}
+		return reflect.Zero(dynamicStructureType)
+	case TypeIDExtensionObject:
+		return reflect.ValueOf(NewExtensionObject(nil))
+	case TypeIDExpandedNodeID:
+		return reflect.ValueOf(NewTwoByteExpandedNodeID(0))
+	}
+	t := f.elemType()
+	if t.Kind() == reflect.Pointer {
+		return reflect.New(t.Elem())
+	}
+	return reflect.Zero(t)
+}
+
+// structure returns the descriptor of a structure which is encoded in
+// place or nil.
+func (f *FieldDescriptor) structure() *StructureDescriptor {
+	if f.Structure != nil {
+		return f.Structure
+	}
+	return StructureByDataTypeID(f.DataType)
+}
+
+// isType returns true if the structure is of the type of the field.
+func (f *FieldDescriptor) isType(d *StructureDescriptor) bool {
+	if d == f.structure() {
+		return true
+	}
+	return d.DataTypeID != nil && f.DataType != nil && d.DataTypeID.Equal(f.DataType)
+}
+
+// structures contains the registered structure descriptors.
+var structures = struct {
+	mu         sync.RWMutex
+	byEncoding map[string]*StructureDescriptor
+	byDataType map[string]*StructureDescriptor
+}{
+	byEncoding: map[string]*StructureDescriptor{},
+	byDataType: map[string]*StructureDescriptor{},
+}
+
+// RegisterStructure registers the descriptor of a structure so that
+// extension objects with its encoding are decoded as DynamicStructure
+// if there is no Go type registered for the encoding. A descriptor
+// which is registered again for the same DataType replaces the previous
+// descriptor.
+func RegisterStructure(d *StructureDescriptor) {
+	structures.mu.Lock()
+	defer structures.mu.Unlock()
+	if d.EncodingID != nil {
+		structures.byEncoding[d.EncodingID.String()] = d
+	}
+	if d.DataTypeID != nil {
+		structures.byDataType[d.DataTypeID.String()] = d
+	}
+}
+
+// StructureByEncodingID returns the descriptor of the structure with the
+// encoding or nil if it is not registered.
+func StructureByEncodingID(id *NodeID) *StructureDescriptor {
+	if id == nil {
+		return nil
+	}
+	structures.mu.RLock()
+	defer structures.mu.RUnlock()
+	return structures.byEncoding[id.String()]
+}
+
+// StructureByDataTypeID returns the descriptor of the structure DataType
+// or nil if it is not registered.
+func StructureByDataTypeID(id *NodeID) *StructureDescriptor {
+	if id == nil {
+		return nil
+	}
+	structures.mu.RLock()
+	defer structures.mu.RUnlock()
+	return structures.byDataType[id.String()]
+}
+
+// DynamicStructure is the value of a structure which is described by a
+// StructureDescriptor instead of a Go type.
+//
+// Values contains the values of the fields in the order of the fields of
+// the descriptor. The values have the Go type of FieldDescriptor.Type.
+// The values of optional fields which are not set and of the fields of a
+// union which are not selected are nil.
+type DynamicStructure struct {
+	Type   *StructureDescriptor
+	Values []any
+}
+
+// NewDynamicStructure returns a structure of the type where all fields
+// have their zero value. Optional fields are not set and no field of a
+// union is selected.
+func NewDynamicStructure(t *StructureDescriptor) *DynamicStructure {
+	s := &DynamicStructure{Type: t, Values: make([]any, len(t.Fields))}
+	if t.isUnion() {
+		return s
+	}
+	for i, f := range t.Fields {
+		if f.IsOptional {
+			continue
+		}
+		if f.rank() > 0 {
+			s.Values[i] = reflect.Zero(f.Type()).Interface()
+			continue
+		}
+		s.Values[i] = f.zero().Interface()
+	}
+	return s
+}
+
+// Get returns the value of the field with the name or nil.
+func (s *DynamicStructure) Get(name string) any {
+	i := s.Type.Field(name)
+	if i < 0 || i >= len(s.Values) {
+		return nil
+	}
+	return s.Values[i]
+}
+
+// Set sets the value of the field with the name. A nil value unsets an
+// optional field. Setting a field of a union selects the field.
+func (s *DynamicStructure) Set(name string, v any) error {
+	i := s.Type.Field(name)
+	if i < 0 {
+		return errors.Errorf("%s has no field %s", s.Type.Name, name)
+	}
+	f := s.Type.Fields[i]
+	if v != nil && reflect.TypeOf(v) != f.Type() {
+		return errors.Errorf("%s.%s: got %T want %s", s.Type.Name, name, v, f.Type())
+	}
+	if len(s.Values) != len(s.Type.Fields) {
+		s.Values = append(s.Values, make([]any, len(s.Type.Fields)-len(s.Values))...)
+	}
+	if s.Type.isUnion() {
+		clear(s.Values)
+	}
+	s.Values[i] = v
+	return nil
+}
+
+// Map returns the values of the fields which are set by their name.
+func (s *DynamicStructure) Map() map[string]any {
+	m := make(map[string]any)
+	for i, f := range s.Type.Fields {
+		if i < len(s.Values) && s.Values[i] != nil {
+			m[f.Name] = s.Values[i]
+		}
+	}
+	return m
+}
+
+// Encode implements the codec interface.
+func (s *DynamicStructure) Encode() ([]byte, error) {
+	buf := NewBuffer(nil)
+	if err := s.encode(buf, 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), buf.Error()
+}
+
+func (s *DynamicStructure) encode(buf *Buffer, depth int) error {
+	if s.Type == nil {
+		return errors.Errorf("dynamic structure without type")
+	}
+	if depth > maxStructureDepth {
+		return StatusBadEncodingLimitsExceeded
+	}
+	value := func(i int) any {
+		if i < len(s.Values) {
+			return s.Values[i]
+		}
+		return nil
+	}
+
+	if s.Type.isUnion() {
+		for i, f := range s.Type.Fields {
+			if v := value(i); v != nil {
+				buf.WriteUint32(uint32(i + 1))
+				return s.encodeField(buf, f, v, depth)
+			}
+		}
+		// no field is selected
+		buf.WriteUint32(0)
+		return nil
+	}
+
+	if s.Type.StructureType == StructureTypeStructureWithOptionalFields {
+		var mask uint32
+		bit := 0
+		for i, f := range s.Type.Fields {
+			if !f.IsOptional {
+				continue
+			}
+			if value(i) != nil {
+				mask |= 1 << bit
+			}
+			bit++
+		}
+		buf.WriteUint32(mask)
+	}
+
+	for i, f := range s.Type.Fields {
+		v := value(i)
+		if v == nil && f.IsOptional {
+			continue
+		}
+		if err := s.encodeField(buf, f, v, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *DynamicStructure) encodeField(buf *Buffer, f *FieldDescriptor, v any, depth int) error {
+	if v == nil {
+		if f.rank() > 0 {
+			v = reflect.Zero(f.Type()).Interface()
+		} else {
+			v = f.zero().Interface()
+		}
+	}
+	val := reflect.ValueOf(v)
+	if val.Type() != f.Type() {
+		return errors.Errorf("%s.%s: got %T want %s", s.Type.Name, f.Name, v, f.Type())
+	}
+
+	switch rank := f.rank(); {
+	case rank == 0:
+		return encodeFieldValue(buf, f, val, depth)
+
+	case rank == 1:
+		if val.IsNil() {
+			buf.WriteInt32(-1)
+			return nil
+		}
+		buf.WriteInt32(int32(val.Len()))
+		for i := 0; i < val.Len(); i++ {
+			if err := encodeFieldValue(buf, f, val.Index(i), depth); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		// multi-dimensional arrays are encoded as the dimensions
+		// followed by the values in row-major order
+		dims := make([]int32, rank)
+		for i, x := 0, val; i < rank; i++ {
+			if x.Len() > 0 {
+				dims[i] = int32(x.Len())
+				x = x.Index(0)
+			}
+		}
+		buf.WriteInt32(int32(rank))
+		for _, d := range dims {
+			buf.WriteInt32(d)
+		}
+		return encodeFieldArray(buf, f, val, dims, depth)
+	}
+}
+
+// encodeFieldArray writes the values of a multi-dimensional array.
+func encodeFieldArray(buf *Buffer, f *FieldDescriptor, val reflect.Value, dims []int32, depth int) error {
+	if len(dims) == 0 {
+		return encodeFieldValue(buf, f, val, depth)
+	}
+	if val.Len() != int(dims[0]) {
+		return errUnbalancedSlice
+	}
+	for i := 0; i < val.Len(); i++ {
+		if err := encodeFieldArray(buf, f, val.Index(i), dims[1:], depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeFieldValue writes a scalar value of the field.
+func encodeFieldValue(buf *Buffer, f *FieldDescriptor, val reflect.Value, depth int) error {
+	if val.Kind() == reflect.Pointer && val.IsNil() {
+		val = f.zero()
+	}
+	if f.BuiltinType != TypeIDNull {
+		new(Variant).encodeValue(buf, val.Interface())
+		return buf.Error()
+	}
+
+	s, _ := val.Interface().(*DynamicStructure)
+	if s == nil {
+		return errors.Errorf("%s: unknown structure %s", f.Name, f.DataType)
+	}
+	if s.Type == nil || !f.isType(s.Type) {
+		return errors.Errorf("%s: structure has the wrong type", f.Name)
+	}
+	return s.encode(buf, depth+1)
+}
+
+// Decode implements the codec interface. The Type of the structure must
+// be set.
+func (s *DynamicStructure) Decode(b []byte) (int, error) {
+	buf := NewBuffer(b)
+	err := s.decode(buf, 0)
+	if err == nil {
+		err = buf.Error()
+	}
+	return buf.Pos(), err
+}
+
+func (s *DynamicStructure) decode(buf *Buffer, depth int) error {
+	if s.Type == nil {
+		return errors.Errorf("dynamic structure without type")
+	}
+	if depth > maxStructureDepth {
+		return StatusBadEncodingLimitsExceeded
+	}
+	s.Values = make([]any, len(s.Type.Fields))
+
+	if s.Type.isUnion() {
+		sw := buf.ReadUint32()
+		if sw == 0 {
+			return buf.Error()
+		}
+		if int(sw) > len(s.Type.Fields) {
+			return errors.Errorf("%s: invalid union switch %d", s.Type.Name, sw)
+		}
+		v, err := decodeField(buf, s.Type.Fields[sw-1], depth)
+		s.Values[sw-1] = v
+		return err
+	}
+
+	var mask uint32
+	if s.Type.StructureType == StructureTypeStructureWithOptionalFields {
+		mask = buf.ReadUint32()
+	}
+	bit := 0
+	for i, f := range s.Type.Fields {
+		if f.IsOptional {
+			set := mask&(1<<bit) != 0
+			bit++
+			if !set {
+				continue
+			}
+		}
+		v, err := decodeField(buf, f, depth)
+		if err != nil {
+			return err
+		}
+		s.Values[i] = v
+	}
+	return buf.Error()
+}
+
+// decodeField reads a value of the field.
+func decodeField(buf *Buffer, f *FieldDescriptor, depth int) (any, error) {
+	rank := f.rank()
+	if rank == 0 {
+		v, err := decodeFieldValue(buf, f, depth)
+		if err != nil {
+			return nil, err
+		}
+		return v.Interface(), nil
+	}
+
+	var dims []int
+	if rank == 1 {
+		n := buf.ReadInt32()
+		if n == -1 {
+			return reflect.Zero(f.Type()).Interface(), buf.Error()
+		}
+		dims = []int{int(n)}
+	} else {
+		n := buf.ReadInt32()
+		if n != int32(rank) {
+			return nil, errors.Errorf("%s: got %d dimensions want %d", f.Name, n, rank)
+		}
+		for i := 0; i < rank; i++ {
+			dims = append(dims, int(buf.ReadInt32()))
+		}
+	}
+	if buf.Error() != nil {
+		return nil, buf.Error()
+	}
+
+	count := 1
+	for _, d := range dims {
+		if d < 0 || d > MaxVariantArrayLength {
+			return nil, StatusBadEncodingLimitsExceeded
+		}
+		count *= d
+		if count > MaxVariantArrayLength {
+			return nil, StatusBadEncodingLimitsExceeded
+		}
+	}
+	vals := reflect.MakeSlice(reflect.SliceOf(f.elemType()), count, count)
+	for i := 0; i < count; i++ {
+		v, err := decodeFieldValue(buf, f, depth)
+		if err != nil {
+			return nil, err
+		}
+		vals.Index(i).Set(v)
+	}
+	if rank == 1 {
+		return vals.Interface(), nil
+	}
+	if count == 0 {
+		return reflect.MakeSlice(f.Type(), 0, 0).Interface(), nil
+	}
+	return split(0, 0, count, dims, vals).Interface(), nil
+}
+
+// decodeFieldValue reads a scalar value of the field.
+func decodeFieldValue(buf *Buffer, f *FieldDescriptor, depth int) (reflect.Value, error) {
+	if f.BuiltinType != TypeIDNull {
+		m := new(Variant)
+		m.setType(f.BuiltinType)
+		v := m.decodeValue(buf)
+		if v == nil {
+			return reflect.Value{}, errors.Errorf("%s: invalid type %d", f.Name, f.BuiltinType)
+		}
+		return reflect.ValueOf(v), buf.Error()
+	}
+
+	d := f.structure()
+	if d == nil {
+		return reflect.Value{}, errors.Errorf("%s: unknown structure %s", f.Name, f.DataType)
+	}
+	s := &DynamicStructure{Type: d}
+	if err := s.decode(buf, depth+1); err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(s), nil
+}
@@ -0,0 +1,216 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package ua
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamicStructure(t *testing.T) {
+	builtin := func(dt *NodeID) TypeID {
+		if dt.Namespace() == 0 {
+			return TypeID(dt.IntID())
+		}
+		return TypeIDNull
+	}
+	field := func(name string, dt *NodeID, rank int32, optional bool) *StructureField {
+		return &StructureField{Name: name, DataType: dt, ValueRank: rank, IsOptional: optional}
+	}
+	register := func(name string, id uint32, typ StructureType, fields ...*StructureField) *StructureDescriptor {
+		d := NewStructureDescriptor(name, NewNumericNodeID(1, id), &StructureDefinition{
+			DefaultEncodingID: NewNumericNodeID(1, id+1),
+			StructureType:     typ,
+			Fields:            fields,
+		}, builtin)
+		RegisterStructure(d)
+		return d
+	}
+
+	point := register("Point", 9000, StructureTypeStructure,
+		field("X", NewNumericNodeID(0, uint32(TypeIDInt32)), -1, false),
+		field("Y", NewNumericNodeID(0, uint32(TypeIDInt32)), -1, false),
+	)
+	optional := register("Optional", 9010, StructureTypeStructureWithOptionalFields,
+		field("A", NewNumericNodeID(0, uint32(TypeIDByte)), -1, true),
+		field("B", NewNumericNodeID(0, uint32(TypeIDString)), -1, false),
+		field("C", NewNumericNodeID(0, uint32(TypeIDByte)), -1, true),
+	)
+	union := register("Union", 9020, StructureTypeUnion,
+		field("A", NewNumericNodeID(0, uint32(TypeIDByte)), -1, false),
+		field("B", NewNumericNodeID(0, uint32(TypeIDString)), -1, false),
+	)
+	nested := register("Nested", 9030, StructureTypeStructure,
+		field("Origin", point.DataTypeID, -1, false),
+		field("Points", point.DataTypeID, 1, false),
+		field("Matrix", NewNumericNodeID(0, uint32(TypeIDByte)), 2, false),
+	)
+
+	value := func(d *StructureDescriptor, vals ...any) *DynamicStructure {
+		return &DynamicStructure{Type: d, Values: vals}
+	}
+
+	cases := []CodecTestCase{
+		{
+			Name:   "structure",
+			Struct: NewExtensionObject(value(point, int32(1), int32(2))),
+			Bytes: []byte{
+				// TypeID
+				0x02, 0x01, 0x00, 0x29, 0x23, 0x00, 0x00,
+				// EncodingMask
+				0x01,
+				// Length
+				0x08, 0x00, 0x00, 0x00,
+				// X
+				0x01, 0x00, 0x00, 0x00,
+				// Y
+				0x02, 0x00, 0x00, 0x00,
+			},
+		},
+		{
+			Name:   "optional fields",
+			Struct: NewExtensionObject(value(optional, nil, "a", byte(3))),
+			Bytes: []byte{
+				// TypeID
+				0x02, 0x01, 0x00, 0x33, 0x23, 0x00, 0x00,
+				// EncodingMask
+				0x01,
+				// Length
+				0x0a, 0x00, 0x00, 0x00,
+				// EncodingMask of the fields
+				0x02, 0x00, 0x00, 0x00,
+				// B
+				0x01, 0x00, 0x00, 0x00, 'a',
+				// C
+				0x03,
+			},
+		},
+		{
+			Name:   "union",
+			Struct: NewExtensionObject(value(union, nil, "ab")),
+			Bytes: []byte{
+				// TypeID
+				0x02, 0x01, 0x00, 0x3d, 0x23, 0x00, 0x00,
+				// EncodingMask
+				0x01,
+				// Length
+				0x0a, 0x00, 0x00, 0x00,
+				// SwitchField
+				0x02, 0x00, 0x00, 0x00,
+				// B
+				0x02, 0x00, 0x00, 0x00, 'a', 'b',
+			},
+		},
+		{
+			Name: "nested",
+			Struct: NewExtensionObject(value(nested,
+				value(point, int32(1), int32(2)),
+				[]*DynamicStructure{value(point, int32(3), int32(4))},
+				[][]byte{{1, 2, 3}, {4, 5, 6}},
+			)),
+			Bytes: []byte{
+				// TypeID
+				0x02, 0x01, 0x00, 0x47, 0x23, 0x00, 0x00,
+				// EncodingMask
+				0x01,
+				// Length
+				0x26, 0x00, 0x00, 0x00,
+				// Origin
+				0x01, 0x00, 0x00, 0x00,
+				0x02, 0x00, 0x00, 0x00,
+				// Points
+				0x01, 0x00, 0x00, 0x00,
+				0x03, 0x00, 0x00, 0x00,
+				0x04, 0x00, 0x00, 0x00,
+				// Matrix dimensions
+				0x02, 0x00, 0x00, 0x00,
+				0x02, 0x00, 0x00, 0x00,
+				0x03, 0x00, 0x00, 0x00,
+				// Matrix values
+				0x01, 0x02, 0x03, 0x04, 0x05, 0x06,
+			},
+		},
+	}
+	RunCodecTest(t, cases)
+
+	t.Run("zero values", func(t *testing.T) {
+		s := NewDynamicStructure(nested)
+		b, err := s.Encode()
+		require.NoError(t, err)
+
+		got := &DynamicStructure{Type: nested}
+		_, err = got.Decode(b)
+		require.NoError(t, err)
+		require.Equal(t, int32(0), got.Get("Origin").(*DynamicStructure).Get("X"))
+		require.Nil(t, got.Get("Points"))
+	})
+
+	t.Run("set", func(t *testing.T) {
+		s := NewDynamicStructure(union)
+		require.NoError(t, s.Set("A", byte(1)))
+		require.NoError(t, s.Set("B", "b"))
+		require.Equal(t, []any{nil, "b"}, s.Values)
+		require.Error(t, s.Set("A", 1))
+		require.Error(t, s.Set("C", byte(1)))
+	})
+
+	t.Run("unknown extension object", func(t *testing.T) {
+		b := []byte{
+			// TypeID
+			0x02, 0x01, 0x00, 0x51, 0x23, 0x00, 0x00,
+			// EncodingMask
+			0x01,
+			// Length
+			0x08, 0x00, 0x00, 0x00,
+			// X
+			0x01, 0x00, 0x00, 0x00,
+			// Y
+			0x02, 0x00, 0x00, 0x00,
+		}
+		eo := new(ExtensionObject)
+		_, err := Decode(b, eo)
+		require.NoError(t, err)
+		require.Nil(t, eo.Value)
+
+		// the body is encoded unchanged
+		got, err := Encode(eo)
+		require.NoError(t, err)
+		require.Equal(t, b, got)
+
+		require.NoError(t, eo.DecodeStructure(point))
+		require.Equal(t, value(point, int32(1), int32(2)), eo.Value)
+	})
+
+	t.Run("decode structures", func(t *testing.T) {
+		b := []byte{
+			// TypeID
+			0x02, 0x01, 0x00, 0x5b, 0x23, 0x00, 0x00,
+			// EncodingMask
+			0x01,
+			// Length
+			0x08, 0x00, 0x00, 0x00,
+			// X
+			0x01, 0x00, 0x00, 0x00,
+			// Y
+			0x02, 0x00, 0x00, 0x00,
+		}
+		eo := new(ExtensionObject)
+		_, err := Decode(b, eo)
+		require.NoError(t, err)
+		dv := &DataValue{Value: MustVariant(eo)}
+
+		DecodeStructures(dv, func(*NodeID) *StructureDescriptor { return nil })
+		require.Nil(t, eo.Value)
+
+		var got *NodeID
+		DecodeStructures(dv, func(id *NodeID) *StructureDescriptor {
+			got = id
+			return point
+		})
+		require.Equal(t, NewNumericNodeID(1, 9051), got)
+		require.Equal(t, value(point, int32(1), int32(2)), eo.Value)
+	})
+}
@@ -5,6 +5,8 @@
 package ua
 
 import (
+	"reflect"
+
 	"github.com/gopcua/opcua/debug"
 	"github.com/gopcua/opcua/id"
 )
@@ -14,6 +16,10 @@ var eotypes = NewTypeRegistry()
 
 // RegisterExtensionObject registers a new extension object type.
 // It panics if the type or the id is already registered.
+//
+// The types are shared by all clients and servers of the process. Types
+// which are specific to a server should be registered with a registry
+// from NewScopedTypeRegistry instead.
 func RegisterExtensionObject(typeID *NodeID, v interface{}) {
 	if err := eotypes.Register(typeID, v); err != nil {
 		panic("Extension object " + err.Error())
@@ -36,6 +42,13 @@ type ExtensionObject struct {
 	EncodingMask uint8
 	TypeID       *ExpandedNodeID
 	Value        interface{}
+
+	// body is the binary body of an extension object whose type is
+	// unknown or whose Go type is not a type of namespace 0. It is kept
+	// so that the value can be decoded later with DecodeStructure or with
+	// the type of a scoped registry with DecodeWith and is encoded
+	// unchanged if there is no value.
+	body []byte
 }
 
 func NewExtensionObject(value interface{}) *ExtensionObject {
@@ -75,12 +88,33 @@ func (e *ExtensionObject) Decode(b []byte) (int, error) {
 
 	typeID := e.TypeID.NodeID
 	e.Value = eotypes.New(typeID)
+	// a scoped registry can have another type than RegisterExtensionObject
+	// for the ids of the namespaces of a server.
+	scoped := e.Value != nil && (typeID.Namespace() != 0 || e.TypeID.NamespaceURI != "")
+	if scoped {
+		e.body = append([]byte(nil), body.Bytes()...)
+	}
+	if e.Value == nil {
+		// structures without a Go type are decoded with their
+		// registered descriptor.
+		if d := StructureByEncodingID(typeID); d != nil {
+			e.Value = &DynamicStructure{Type: d}
+		}
+	}
 	if e.Value == nil {
 		debug.Printf("ua: unknown extension object %s", typeID)
+		e.body = append([]byte(nil), body.Bytes()...)
 		return buf.Pos(), buf.Error()
 	}
 
 	body.ReadStruct(e.Value)
+	if body.Error() != nil && scoped {
+		// the body is decoded by DecodeWith with the type of the
+		// scoped registry.
+		debug.Printf("ua: cannot decode extension object %s: %v", typeID, body.Error())
+		e.Value = nil
+		return buf.Pos(), buf.Error()
+	}
 	return buf.Pos(), body.Error()
 }
 
@@ -95,6 +129,12 @@ func (e *ExtensionObject) Encode() ([]byte, error) {
 		return buf.Bytes(), buf.Error()
 	}
 
+	if e.Value == nil && e.body != nil {
+		buf.WriteUint32(uint32(len(e.body)))
+		buf.Write(e.body)
+		return buf.Bytes(), buf.Error()
+	}
+
 	body := NewBuffer(nil)
 	body.WriteStruct(e.Value)
 	if body.Error() != nil {
@@ -105,6 +145,59 @@ func (e *ExtensionObject) Encode() ([]byte, error) {
 	return buf.Bytes(), buf.Error()
 }
 
+// DecodeStructure decodes the binary body of an extension object whose
+// type was unknown when it was decoded into a DynamicStructure of the
+// type. It does nothing if the value has already been decoded.
+func (e *ExtensionObject) DecodeStructure(d *StructureDescriptor) error {
+	if e.Value != nil || e.body == nil {
+		return nil
+	}
+	s := &DynamicStructure{Type: d}
+	if _, err := s.Decode(e.body); err != nil {
+		return err
+	}
+	e.Value = s
+	e.body = nil
+	return nil
+}
+
+// DecodeWith decodes the body of the extension object with the type which
+// is registered for its TypeID in r if the value does not already have
+// this type. The types which were registered with RegisterURI are
+// resolved with the namespace table ns. It does nothing if the type is
+// not registered.
+func (e *ExtensionObject) DecodeWith(r *TypeRegistry, ns []string) error {
+	if e.EncodingMask != ExtensionObjectBinary || e.TypeID == nil || e.TypeID.NodeID == nil {
+		return nil
+	}
+	var v interface{}
+	if e.TypeID.NamespaceURI != "" {
+		// the namespace index of the id is ignored
+		v = r.newType(typeKey{uri: e.TypeID.NamespaceURI, id: localID(e.TypeID.NodeID)})
+	} else {
+		v = r.NewWithNamespaces(e.TypeID.NodeID, ns)
+	}
+	if v == nil || reflect.TypeOf(v) == reflect.TypeOf(e.Value) {
+		return nil
+	}
+
+	body := e.body
+	if body == nil {
+		// the value has been created by the application
+		b, err := Encode(e.Value)
+		if err != nil {
+			return err
+		}
+		body = b
+	}
+	if _, err := Decode(body, v); err != nil {
+		return err
+	}
+	e.Value = v
+	e.body = nil
+	return nil
+}
+
 func (e *ExtensionObject) UpdateMask() {
 	if e.Value == nil {
 		e.EncodingMask = ExtensionObjectEmpty
@@ -116,7 +209,7 @@ func (e *ExtensionObject) UpdateMask() {
 }
 
 func ExtensionObjectTypeID(v interface{}) *ExpandedNodeID {
-	switch v.(type) {
+	switch x := v.(type) {
 	case *AnonymousIdentityToken:
 		return NewFourByteExpandedNodeID(0, id.AnonymousIdentityToken_Encoding_DefaultBinary)
 	case *UserNameIdentityToken:
@@ -127,6 +220,11 @@ func ExtensionObjectTypeID(v interface{}) *ExpandedNodeID {
 		return NewFourByteExpandedNodeID(0, id.IssuedIdentityToken_Encoding_DefaultBinary)
 	case *ServerStatusDataType:
 		return NewFourByteExpandedNodeID(0, id.ServerStatusDataType_Encoding_DefaultBinary)
+	case *DynamicStructure:
+		if x.Type != nil && x.Type.EncodingID != nil {
+			return &ExpandedNodeID{NodeID: x.Type.EncodingID}
+		}
+		return NewTwoByteExpandedNodeID(0)
 	default:
 		if id := eotypes.Lookup(v); id != nil {
 			return &ExpandedNodeID{NodeID: id}
@@ -0,0 +1,204 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package ua
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// NumericRange selects elements of an array or characters of a string.
+// It has one dimension for every dimension of the array and an optional
+// dimension for the elements of an array of String or ByteString values.
+// A nil NumericRange selects the complete value.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/7.27
+type NumericRange []NumericRangeDimension
+
+// NumericRangeDimension is the range of indexes of a single dimension.
+// Min and Max are inclusive.
+type NumericRangeDimension struct {
+	Min, Max uint32
+}
+
+// ParseNumericRange parses an index range like "1", "0:2" or "0:1,2:3".
+// It returns StatusBadIndexRangeInvalid if the syntax is invalid.
+func ParseNumericRange(s string) (NumericRange, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var r NumericRange
+	for _, dim := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(dim, ":")
+		min, err := strconv.ParseUint(lo, 10, 32)
+		if err != nil {
+			return nil, StatusBadIndexRangeInvalid
+		}
+		max := min
+		if isRange {
+			max, err = strconv.ParseUint(hi, 10, 32)
+			if err != nil || max <= min {
+				return nil, StatusBadIndexRangeInvalid
+			}
+		}
+		r = append(r, NumericRangeDimension{Min: uint32(min), Max: uint32(max)})
+	}
+	return r, nil
+}
+
+// String returns the range in the syntax of ParseNumericRange.
+func (r NumericRange) String() string {
+	dims := make([]string, len(r))
+	for i, d := range r {
+		dims[i] = d.String()
+	}
+	return strings.Join(dims, ",")
+}
+
+func (d NumericRangeDimension) String() string {
+	if d.Min == d.Max {
+		return strconv.FormatUint(uint64(d.Min), 10)
+	}
+	return strconv.FormatUint(uint64(d.Min), 10) + ":" + strconv.FormatUint(uint64(d.Max), 10)
+}
+
+// Slice returns the elements of the value which are selected by the
+// range. Upper bounds which are out of range are truncated. It returns
+// StatusBadIndexRangeNoData if the value has no elements in the range or
+// if the range does not match the dimensions of the value.
+func (r NumericRange) Slice(v *Variant) (*Variant, error) {
+	if len(r) == 0 {
+		return v, nil
+	}
+	if v == nil || v.Value() == nil || !r.matches(reflect.TypeOf(v.Value())) {
+		return nil, StatusBadIndexRangeNoData
+	}
+	x, err := r.slice(reflect.ValueOf(v.Value()))
+	if err != nil {
+		return nil, err
+	}
+	return NewVariant(x.Interface())
+}
+
+// Patch returns a copy of the value where the elements which are
+// selected by the range are replaced with the elements of p. The range
+// must be within the bounds of the value and p must have the same type
+// as the value and as many elements as the range. It returns
+// StatusBadIndexRangeNoData if the range is out of bounds,
+// StatusBadIndexRangeInvalid if the number of elements do not match and
+// StatusBadTypeMismatch if the types differ.
+func (r NumericRange) Patch(v, p *Variant) (*Variant, error) {
+	if len(r) == 0 {
+		return p, nil
+	}
+	if v == nil || v.Value() == nil || !r.matches(reflect.TypeOf(v.Value())) {
+		return nil, StatusBadIndexRangeNoData
+	}
+	if p == nil || reflect.TypeOf(p.Value()) != reflect.TypeOf(v.Value()) {
+		return nil, StatusBadTypeMismatch
+	}
+	x, err := r.patch(reflect.ValueOf(v.Value()), reflect.ValueOf(p.Value()))
+	if err != nil {
+		return nil, err
+	}
+	return NewVariant(x.Interface())
+}
+
+var byteStringType = reflect.TypeOf([]byte{})
+
+// matches returns true if the range has a dimension for every dimension
+// of the array type and an optional dimension for the characters of
+// strings.
+func (r NumericRange) matches(t reflect.Type) bool {
+	depth := 0
+	for t.Kind() == reflect.Slice && t != byteStringType {
+		depth++
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.String || t == byteStringType {
+		return len(r) == depth || len(r) == depth+1
+	}
+	return len(r) == depth
+}
+
+func (r NumericRange) slice(v reflect.Value) (reflect.Value, error) {
+	if v.Kind() == reflect.String {
+		s := []rune(v.String())
+		lo, hi, err := r[0].bounds(len(s), true)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(string(s[lo:hi])), nil
+	}
+
+	lo, hi, err := r[0].bounds(v.Len(), true)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	x := reflect.MakeSlice(v.Type(), hi-lo, hi-lo)
+	if len(r) == 1 {
+		reflect.Copy(x, v.Slice(lo, hi))
+		return x, nil
+	}
+	for i := lo; i < hi; i++ {
+		e, err := r[1:].slice(v.Index(i))
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		x.Index(i - lo).Set(e)
+	}
+	return x, nil
+}
+
+func (r NumericRange) patch(v, p reflect.Value) (reflect.Value, error) {
+	if v.Kind() == reflect.String {
+		s, ps := []rune(v.String()), []rune(p.String())
+		lo, hi, err := r[0].bounds(len(s), false)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if len(ps) != hi-lo {
+			return reflect.Value{}, StatusBadIndexRangeInvalid
+		}
+		return reflect.ValueOf(string(s[:lo]) + string(ps) + string(s[hi:])), nil
+	}
+
+	lo, hi, err := r[0].bounds(v.Len(), false)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if p.Len() != hi-lo {
+		return reflect.Value{}, StatusBadIndexRangeInvalid
+	}
+	x := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(x, v)
+	for i := lo; i < hi; i++ {
+		e := p.Index(i - lo)
+		if len(r) > 1 {
+			if e, err = r[1:].patch(v.Index(i), e); err != nil {
+				return reflect.Value{}, err
+			}
+		}
+		x.Index(i).Set(e)
+	}
+	return x, nil
+}
+
+// bounds returns the start and the end of the dimension for n elements.
+// The end is truncated if truncate is set. Otherwise, the dimension must
+// be within the bounds.
+func (d NumericRangeDimension) bounds(n int, truncate bool) (int, int, error) {
+	switch {
+	case int64(d.Min) >= int64(n):
+		return 0, 0, StatusBadIndexRangeNoData
+	case int64(d.Max) < int64(n):
+		return int(d.Min), int(d.Max) + 1, nil
+	case truncate:
+		return int(d.Min), n, nil
+	default:
+		return 0, 0, StatusBadIndexRangeNoData
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package ua
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNumericRange(t *testing.T) {
+	cases := []struct {
+		s   string
+		r   NumericRange
+		err error
+	}{
+		// happy flows
+		{s: "", r: nil},
+		{s: "1", r: NumericRange{{1, 1}}},
+		{s: "0:2", r: NumericRange{{0, 2}}},
+		{s: "0:1,2:3", r: NumericRange{{0, 1}, {2, 3}}},
+		{s: "1,4294967295", r: NumericRange{{1, 1}, {4294967295, 4294967295}}},
+
+		// error flows
+		{s: "a", err: StatusBadIndexRangeInvalid},
+		{s: "-1", err: StatusBadIndexRangeInvalid},
+		{s: "2:1", err: StatusBadIndexRangeInvalid},
+		{s: "1:1", err: StatusBadIndexRangeInvalid},
+		{s: "1:", err: StatusBadIndexRangeInvalid},
+		{s: "1,", err: StatusBadIndexRangeInvalid},
+		{s: " 1", err: StatusBadIndexRangeInvalid},
+		{s: "4294967296", err: StatusBadIndexRangeInvalid},
+	}
+
+	for _, c := range cases {
+		t.Run(c.s, func(t *testing.T) {
+			r, err := ParseNumericRange(c.s)
+			require.Equal(t, c.err, err, "Error not equal")
+			require.Equal(t, c.r, r, "Parsed NumericRange not equal")
+			if err == nil {
+				require.Equal(t, c.s, r.String())
+			}
+		})
+	}
+}
+
+func TestNumericRangeSlice(t *testing.T) {
+	cases := []struct {
+		name string
+		r    string
+		v    any
+		want any
+		err  error
+	}{
+		// happy flows
+		{name: "element", r: "1", v: []int32{1, 2, 3}, want: []int32{2}},
+		{name: "range", r: "1:2", v: []int32{1, 2, 3}, want: []int32{2, 3}},
+		{name: "truncated", r: "1:5", v: []int32{1, 2, 3}, want: []int32{2, 3}},
+		{name: "byte array", r: "0:1", v: ByteArray{1, 2, 3}, want: ByteArray{1, 2}},
+		{name: "string", r: "1:3", v: "äbcd", want: "bcd"},
+		{name: "byte string", r: "1:2", v: []byte("abc"), want: []byte("bc")},
+		{name: "string array", r: "0:1,1:2", v: []string{"abc", "def"}, want: []string{"bc", "ef"}},
+		{name: "matrix", r: "1,0:1", v: [][]int32{{1, 2, 3}, {4, 5, 6}}, want: [][]int32{{4, 5}}},
+
+		// error flows
+		{name: "out of range", r: "3:4", v: []int32{1, 2, 3}, err: StatusBadIndexRangeNoData},
+		{name: "scalar", r: "0", v: int32(1), err: StatusBadIndexRangeNoData},
+		{name: "too many dimensions", r: "0,0", v: []int32{1, 2, 3}, err: StatusBadIndexRangeNoData},
+		{name: "too few dimensions", r: "0", v: [][]int32{{1, 2, 3}, {4, 5, 6}}, err: StatusBadIndexRangeNoData},
+		{name: "empty", r: "0", v: []int32{}, err: StatusBadIndexRangeNoData},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := ParseNumericRange(c.r)
+			require.NoError(t, err)
+			v, err := r.Slice(MustVariant(c.v))
+			require.Equal(t, c.err, err, "Error not equal")
+			if c.err == nil {
+				require.Equal(t, MustVariant(c.want), v)
+			}
+		})
+	}
+}
+
+func TestNumericRangePatch(t *testing.T) {
+	cases := []struct {
+		name string
+		r    string
+		v    any
+		p    any
+		want any
+		err  error
+	}{
+		// happy flows
+		{name: "element", r: "1", v: []int32{1, 2, 3}, p: []int32{5}, want: []int32{1, 5, 3}},
+		{name: "range", r: "1:2", v: []int32{1, 2, 3}, p: []int32{5, 6}, want: []int32{1, 5, 6}},
+		{name: "string", r: "1:2", v: "abcd", p: "xy", want: "axyd"},
+		{name: "string array", r: "1,0", v: []string{"abc", "def"}, p: []string{"x"}, want: []string{"abc", "xef"}},
+		{name: "matrix", r: "0:1,2", v: [][]int32{{1, 2, 3}, {4, 5, 6}}, p: [][]int32{{7}, {8}}, want: [][]int32{{1, 2, 7}, {4, 5, 8}}},
+
+		// error flows
+		{name: "out of range", r: "2:3", v: []int32{1, 2, 3}, p: []int32{5, 6}, err: StatusBadIndexRangeNoData},
+		{name: "length mismatch", r: "0:1", v: []int32{1, 2, 3}, p: []int32{5}, err: StatusBadIndexRangeInvalid},
+		{name: "type mismatch", r: "0", v: []int32{1, 2, 3}, p: []int64{5}, err: StatusBadTypeMismatch},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := ParseNumericRange(c.r)
+			require.NoError(t, err)
+			v := MustVariant(c.v)
+			got, err := r.Patch(v, MustVariant(c.p))
+			require.Equal(t, c.err, err, "Error not equal")
+			if c.err == nil {
+				require.Equal(t, MustVariant(c.want), got)
+			}
+			// the original value must not be modified
+			require.Equal(t, MustVariant(c.v), v)
+		})
+	}
+}
@@ -5,9 +5,12 @@
 package ua
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"sync"
 
+	"github.com/gopcua/opcua/debug"
 	"github.com/gopcua/opcua/errors"
 )
 
@@ -22,36 +25,81 @@ import (
 // The implementation is safe for concurrent use.
 type TypeRegistry struct {
 	mu    sync.Mutex
-	types map[string]reflect.Type
-	ids   map[reflect.Type]string
+	types map[typeKey]reflect.Type
+	ids   map[reflect.Type]typeKey
+
+	// parent is consulted for the types which are not registered.
+	parent *TypeRegistry
+}
+
+// typeKey is the id of a registered type. If uri is set then the
+// namespace of the id is the namespace with this uri and id has no
+// namespace.
+type typeKey struct {
+	uri string
+	id  string
 }
 
 // NewTypeRegistry returns a new type registry.
 func NewTypeRegistry() *TypeRegistry {
 	return &TypeRegistry{
-		types: make(map[string]reflect.Type),
-		ids:   make(map[reflect.Type]string),
+		types: make(map[typeKey]reflect.Type),
+		ids:   make(map[reflect.Type]typeKey),
 	}
 }
 
+// NewScopedTypeRegistry returns a registry for extension objects which
+// can be scoped to a client, a server or a single decoding operation.
+// Types which are not registered with it are looked up in the types
+// registered with RegisterExtensionObject.
+//
+// Servers may use different types with the same encoding ids in
+// different namespaces. Such types should be registered with RegisterURI
+// since the namespace index of a namespace can differ between servers.
+func NewScopedTypeRegistry() *TypeRegistry {
+	r := NewTypeRegistry()
+	r.parent = eotypes
+	return r
+}
+
 // New returns a new instance of the type with the given id.
 //
 // If the id is not known the function returns nil.
 //
 // New panics if id is nil.
 func (r *TypeRegistry) New(id *NodeID) interface{} {
+	return r.NewWithNamespaces(id, nil)
+}
+
+// NewWithNamespaces returns a new instance of the type with the given id
+// like New. The types which were registered with RegisterURI are resolved
+// with the namespace table ns and take precedence over the types which
+// were registered with Register.
+func (r *TypeRegistry) NewWithNamespaces(id *NodeID, ns []string) interface{} {
 	if id == nil {
 		panic("opcua: missing id in call to TypeRegistry.New")
 	}
+	var keys []typeKey
+	if int(id.Namespace()) < len(ns) {
+		keys = append(keys, typeKey{uri: ns[id.Namespace()], id: localID(id)})
+	}
+	return r.newType(append(keys, typeKey{id: id.String()})...)
+}
 
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	typ, ok := r.types[id.String()]
-	if !ok {
-		return nil
+// newType returns a new instance of the type with the first key which is
+// registered or nil.
+func (r *TypeRegistry) newType(keys ...typeKey) interface{} {
+	for ; r != nil; r = r.parent {
+		r.mu.Lock()
+		for _, key := range keys {
+			if typ, ok := r.types[key]; ok {
+				r.mu.Unlock()
+				return reflect.New(typ.Elem()).Interface()
+			}
+		}
+		r.mu.Unlock()
 	}
-	return reflect.New(typ.Elem()).Interface()
+	return nil
 }
 
 // Lookup returns the id of the type of v or nil if
@@ -60,10 +108,29 @@ func (r *TypeRegistry) New(id *NodeID) interface{} {
 // If the type was registered multiple times the first
 // registered id for this type is returned.
 func (r *TypeRegistry) Lookup(v interface{}) *NodeID {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if id, ok := r.ids[reflect.TypeOf(v)]; ok {
-		return MustParseNodeID(id)
+	return r.LookupWithNamespaces(v, nil)
+}
+
+// LookupWithNamespaces returns the id of the type of v like Lookup. The
+// ids of the types which were registered with RegisterURI are resolved
+// with the namespace table ns.
+func (r *TypeRegistry) LookupWithNamespaces(v interface{}, ns []string) *NodeID {
+	typ := reflect.TypeOf(v)
+	for ; r != nil; r = r.parent {
+		r.mu.Lock()
+		key, ok := r.ids[typ]
+		r.mu.Unlock()
+		if !ok {
+			continue
+		}
+		if key.uri == "" {
+			return MustParseNodeID(key.id)
+		}
+		for i, uri := range ns {
+			if uri == key.uri {
+				return MustParseNodeID(fmt.Sprintf("ns=%d;%s", i, key.id))
+			}
+		}
 	}
 	return nil
 }
@@ -77,20 +144,134 @@ func (r *TypeRegistry) Register(id *NodeID, v interface{}) error {
 	if id == nil {
 		panic("opcua: missing id in call to TypeRegistry.Register")
 	}
+	return r.register(typeKey{id: id.String()}, v)
+}
+
+// RegisterURI adds a new type to the registry with the id in the
+// namespace with the given uri. The namespace index of id is ignored.
+//
+// If the id is already registered as a different type the function returns an error.
+//
+// RegisterURI panics if id is nil.
+func (r *TypeRegistry) RegisterURI(uri string, id *NodeID, v interface{}) error {
+	if id == nil {
+		panic("opcua: missing id in call to TypeRegistry.RegisterURI")
+	}
+	return r.register(typeKey{uri: uri, id: localID(id)}, v)
+}
 
+func (r *TypeRegistry) register(key typeKey, v interface{}) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	typ := reflect.TypeOf(v)
-	ids := id.String()
-
-	if cur := r.types[ids]; cur != nil && cur != typ {
-		return errors.Errorf("%s is already registered as %v", id, cur)
+	if cur := r.types[key]; cur != nil && cur != typ {
+		if key.uri != "" {
+			return errors.Errorf("nsu=%s;%s is already registered as %v", key.uri, key.id, cur)
+		}
+		return errors.Errorf("%s is already registered as %v", key.id, cur)
 	}
-	r.types[ids] = typ
+	r.types[key] = typ
 
 	if _, exists := r.ids[typ]; !exists {
-		r.ids[typ] = ids
+		r.ids[typ] = key
 	}
 	return nil
 }
+
+// localID returns the string representation of the id without the
+// namespace.
+func localID(id *NodeID) string {
+	s := id.String()
+	if id.Namespace() == 0 {
+		return s
+	}
+	_, s, _ = strings.Cut(s, ";")
+	return s
+}
+
+// DecodeExtensionObjects decodes the extension objects in v, e.g. a
+// service request or response, whose type is registered with r with this
+// type. The extension objects whose type is not registered are not
+// changed. The types which were registered with RegisterURI are resolved
+// with the namespace table ns.
+func (r *TypeRegistry) DecodeExtensionObjects(v interface{}, ns []string) {
+	walkExtensionObjects(reflect.ValueOf(v), func(e *ExtensionObject) {
+		if err := e.DecodeWith(r, ns); err != nil {
+			debug.Printf("ua: cannot decode extension object %s: %v", e.TypeID, err)
+		}
+	})
+}
+
+// DecodeStructures decodes the extension objects in v, e.g. a service
+// request or response, whose type was unknown when they were decoded as
+// DynamicStructure with the descriptor which lookup returns for their
+// encoding id. The extension objects for which lookup returns nil are not
+// changed.
+func DecodeStructures(v interface{}, lookup func(*NodeID) *StructureDescriptor) {
+	walkExtensionObjects(reflect.ValueOf(v), func(e *ExtensionObject) {
+		if e.Value != nil || e.body == nil || e.TypeID == nil {
+			return
+		}
+		d := lookup(e.TypeID.NodeID)
+		if d == nil {
+			return
+		}
+		if err := e.DecodeStructure(d); err != nil {
+			debug.Printf("ua: cannot decode extension object %s: %v", e.TypeID, err)
+		}
+	})
+}
+
+// SetTypeIDs sets the TypeID of the extension objects in v, e.g. a
+// service request or response, which have a value without a TypeID to the
+// id of the type of the value in r. The types which were registered with
+// RegisterURI are resolved with the namespace table ns.
+func (r *TypeRegistry) SetTypeIDs(v interface{}, ns []string) {
+	walkExtensionObjects(reflect.ValueOf(v), func(e *ExtensionObject) {
+		if e.Value == nil || (e.TypeID != nil && e.TypeID.NodeID != nil && !e.TypeID.NodeID.Equal(NewTwoByteNodeID(0))) {
+			return
+		}
+		if id := r.LookupWithNamespaces(e.Value, ns); id != nil {
+			e.TypeID = &ExpandedNodeID{NodeID: id}
+			e.UpdateMask()
+		}
+	})
+}
+
+// walkExtensionObjects calls fn for the extension objects in v including
+// the extension objects in the values of other extension objects.
+func walkExtensionObjects(v reflect.Value, fn func(*ExtensionObject)) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		switch x := v.Interface().(type) {
+		case *ExtensionObject:
+			fn(x)
+			walkExtensionObjects(reflect.ValueOf(x.Value), fn)
+		case *Variant:
+			walkExtensionObjects(reflect.ValueOf(x.Value()), fn)
+		case *StructureDescriptor:
+			// descriptors can refer to themselves
+		default:
+			walkExtensionObjects(v.Elem(), fn)
+		}
+	case reflect.Interface:
+		walkExtensionObjects(v.Elem(), fn)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				walkExtensionObjects(v.Field(i), fn)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Struct, reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				walkExtensionObjects(v.Index(i), fn)
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package ua
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testTypeA struct{ A int32 }
+
+type testTypeB struct{ B string }
+
+type testTypeC struct{ A, B int64 }
+
+func TestScopedTypeRegistry(t *testing.T) {
+	// both servers use ns=2;i=5001 for a different type
+	encID := NewNumericNodeID(2, 5001)
+	ra, rb := NewScopedTypeRegistry(), NewScopedTypeRegistry()
+	require.NoError(t, ra.RegisterURI("urn:a", encID, new(testTypeA)))
+	require.NoError(t, rb.RegisterURI("urn:b", encID, new(testTypeB)))
+	require.Error(t, ra.RegisterURI("urn:a", NewNumericNodeID(0, 5001), new(testTypeB)))
+	nsa := []string{"http://opcfoundation.org/UA/", "urn:x", "urn:a"}
+	nsb := []string{"http://opcfoundation.org/UA/", "urn:b"}
+
+	t.Run("lookup", func(t *testing.T) {
+		require.Equal(t, "ns=2;i=5001", ra.LookupWithNamespaces(new(testTypeA), nsa).String())
+		require.Equal(t, "ns=1;i=5001", rb.LookupWithNamespaces(new(testTypeB), nsb).String())
+		require.Nil(t, ra.LookupWithNamespaces(new(testTypeA), nsb))
+		require.Nil(t, ra.Lookup(new(testTypeA)))
+		require.IsType(t, new(testTypeA), ra.NewWithNamespaces(encID, nsa))
+		require.Nil(t, ra.NewWithNamespaces(encID, nsb))
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		id := ExtensionObjectTypeID(new(ServerStatusDataType)).NodeID
+		require.IsType(t, new(ServerStatusDataType), ra.New(id))
+		require.Nil(t, NewTypeRegistry().New(id))
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		b, err := Encode(&ExtensionObject{
+			EncodingMask: ExtensionObjectBinary,
+			TypeID:       &ExpandedNodeID{NodeID: NewNumericNodeID(1, 5001)},
+			Value:        &testTypeB{B: "b"},
+		})
+		require.NoError(t, err)
+
+		res := &ReadResponse{Results: []*DataValue{{Value: MustVariant(new(ExtensionObject))}}}
+		eo := res.Results[0].Value.Value().(*ExtensionObject)
+		_, err = Decode(b, eo)
+		require.NoError(t, err)
+		require.Nil(t, eo.Value)
+
+		ra.DecodeExtensionObjects(res, nsb)
+		require.Nil(t, eo.Value)
+		rb.DecodeExtensionObjects(res, nsb)
+		require.Equal(t, &testTypeB{B: "b"}, eo.Value)
+	})
+
+	t.Run("global type", func(t *testing.T) {
+		// the global type of the id does not match the body
+		globalID := NewNumericNodeID(3, 5002)
+		RegisterExtensionObject(globalID, new(testTypeC))
+		rc := NewScopedTypeRegistry()
+		require.NoError(t, rc.RegisterURI("urn:c", globalID, new(testTypeB)))
+		nsc := []string{"http://opcfoundation.org/UA/", "urn:x", "urn:y", "urn:c"}
+
+		decode := func(v interface{}) *ExtensionObject {
+			b, err := Encode(&ExtensionObject{
+				EncodingMask: ExtensionObjectBinary,
+				TypeID:       &ExpandedNodeID{NodeID: globalID},
+				Value:        v,
+			})
+			require.NoError(t, err)
+			eo := new(ExtensionObject)
+			_, err = Decode(b, eo)
+			require.NoError(t, err)
+			return eo
+		}
+
+		// the body is too short for the global type
+		eo := decode(&testTypeB{B: "ab"})
+		require.Nil(t, eo.Value)
+		require.NoError(t, eo.DecodeWith(rc, nsc))
+		require.Equal(t, &testTypeB{B: "ab"}, eo.Value)
+
+		// the body is decoded with the global type but the scoped
+		// type is decoded from the original body
+		eo = decode(&testTypeB{B: "abcdefghijkl"})
+		require.IsType(t, new(testTypeC), eo.Value)
+		require.NoError(t, eo.DecodeWith(rc, nsc))
+		require.Equal(t, &testTypeB{B: "abcdefghijkl"}, eo.Value)
+	})
+
+	t.Run("set type ids", func(t *testing.T) {
+		eo := NewExtensionObject(&testTypeA{A: 1})
+		require.Equal(t, "i=0", eo.TypeID.NodeID.String())
+		req := &WriteRequest{NodesToWrite: []*WriteValue{{Value: &DataValue{Value: MustVariant(eo)}}}}
+		ra.SetTypeIDs(req, nsa)
+		require.Equal(t, "ns=2;i=5001", eo.TypeID.NodeID.String())
+	})
+}
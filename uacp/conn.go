@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -169,6 +170,116 @@ func (l *Listener) Endpoint() string {
 	return l.endpoint
 }
 
+// ReverseListener is a client side OPC UA Connection Protocol network
+// listener for connections which are initiated by the server.
+//
+// Specification: Part 6, 7.1.3
+type ReverseListener struct {
+	l        *net.TCPListener
+	ack      *Acknowledge
+	endpoint string
+}
+
+// ListenReverse announces on the local endpoint for servers
+// which open connections to the client with a ReverseHello message.
+//
+// The endpoint must be specified in "opc.tcp://<addr[:port]>" format.
+// ack defines the connection parameters requested by the client during
+// the HEL/ACK handshake and defaults to DefaultClientACK.
+func ListenReverse(endpoint string, ack *Acknowledge) (*ReverseListener, error) {
+	if ack == nil {
+		ack = DefaultClientACK
+	}
+	network, laddr, err := ResolveEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.ListenTCP(network, laddr)
+	if err != nil {
+		return nil, err
+	}
+	return &ReverseListener{
+		l:        l,
+		ack:      ack,
+		endpoint: endpoint,
+	}, nil
+}
+
+// Accept waits for the next ReverseHello with a matching ServerURI and
+// EndpointURL and performs the HEL/ACK handshake for endpoint on the
+// accepted connection. An empty serverURI matches any server.
+//
+// Connections from other servers are rejected with an ERR message and
+// Accept continues to wait until ctx is done.
+func (l *ReverseListener) Accept(ctx context.Context, serverURI, endpoint string) (*Conn, error) {
+	// unblock AcceptTCP when the context is cancelled. The deadline is
+	// cleared after the goroutine has stopped so that it cannot set it
+	// again for the next call of Accept.
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			l.l.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-done
+		l.l.SetDeadline(time.Time{})
+	}()
+
+	for {
+		c, err := l.l.AcceptTCP()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+
+		conn, err := NewConn(c, l.ack)
+		if err != nil {
+			debug.Printf("uacp: reverse connection from %s failed: %s", c.RemoteAddr(), err)
+			c.Close()
+			continue
+		}
+
+		// unblock the handshake when the context is cancelled since
+		// a peer may never send the RHE message.
+		stopConn := context.AfterFunc(ctx, func() { conn.Close() })
+
+		debug.Printf("uacp %d: waiting for RHE from %s", conn.id, c.RemoteAddr())
+		err = conn.reverseHandshake(ctx, serverURI, endpoint)
+		if !stopConn() {
+			// the context has closed the connection
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			debug.Printf("uacp %d: reverse handshake failed: %s", conn.id, err)
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
+
+// Close closes the ReverseListener.
+func (l *ReverseListener) Close() error {
+	return l.l.Close()
+}
+
+// Addr returns the listener's network address.
+func (l *ReverseListener) Addr() net.Addr {
+	return l.l.Addr()
+}
+
+// Endpoint returns the listener's EndpointURL.
+func (l *ReverseListener) Endpoint() string {
+	return l.endpoint
+}
+
 type Conn struct {
 	*net.TCPConn
 	id  uint32
@@ -281,6 +392,49 @@ func (c *Conn) Handshake(ctx context.Context, endpoint string) error {
 	}
 }
 
+// reverseHandshake receives the RHE message from the server, verifies that
+// it was sent by the expected server and then starts the regular HEL/ACK
+// handshake on the same connection.
+func (c *Conn) reverseHandshake(ctx context.Context, serverURI, endpoint string) error {
+	// set a deadline if there is one
+	if dl, ok := ctx.Deadline(); ok {
+		c.SetDeadline(dl)
+	}
+
+	b, err := c.Receive()
+	if err != nil {
+		return err
+	}
+
+	msgtyp := string(b[:4])
+	if msgtyp != "RHEF" {
+		c.SendError(ua.StatusBadTCPMessageTypeInvalid)
+		return errors.Errorf("uacp: expected RHE but got %q", msgtyp)
+	}
+
+	rhe := new(ReverseHello)
+	if _, err := rhe.Decode(b[hdrlen:]); err != nil {
+		c.SendError(ua.StatusBadTCPInternalError)
+		return errors.Errorf("uacp: decode RHE failed: %s", err)
+	}
+	debug.Printf("uacp %d: recv %#v", c.id, rhe)
+
+	if serverURI != "" && rhe.ServerURI != serverURI {
+		c.SendError(ua.StatusBadTCPEndpointURLInvalid)
+		return errors.Errorf("uacp: unexpected server uri %s", rhe.ServerURI)
+	}
+	if !strings.EqualFold(strings.TrimSuffix(rhe.EndpointURL, "/"), strings.TrimSuffix(endpoint, "/")) {
+		c.SendError(ua.StatusBadTCPEndpointURLInvalid)
+		return errors.Errorf("uacp: unexpected endpoint url %s", rhe.EndpointURL)
+	}
+
+	// clear the deadline. Handshake sets its own.
+	c.SetDeadline(time.Time{})
+
+	debug.Printf("uacp %d: start HEL/ACK handshake", c.id)
+	return c.Handshake(ctx, endpoint)
+}
+
 func (c *Conn) srvhandshake(endpoint string) error {
 	b, err := c.Receive()
 	if err != nil {
@@ -166,3 +166,75 @@ NEXT:
 	got = got[:n]
 	require.Equal(t, want, got)
 }
+
+func TestReverseConn(t *testing.T) {
+	ep := "opc.tcp://127.0.0.1:4840/foo/bar"
+	ln, err := ListenReverse("opc.tcp://127.0.0.1:4843", nil)
+	require.NoError(t, err, "ListenReverse failed")
+	defer ln.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// reverseDial opens a server side connection to the client
+	// and sends the RHE followed by the server side handshake.
+	reverseDial := func(serverURI, endpoint string) error {
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			return err
+		}
+		conn, err := NewConn(c.(*net.TCPConn), DefaultServerACK)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if err := conn.Send("RHEF", &ReverseHello{ServerURI: serverURI, EndpointURL: endpoint}); err != nil {
+			return err
+		}
+		return conn.srvhandshake(endpoint)
+	}
+
+	srvErr := make(chan error, 2)
+	go func() {
+		// the client must reject the connection from the wrong server
+		srvErr <- reverseDial("urn:other", ep)
+		srvErr <- reverseDial("urn:server", ep)
+	}()
+
+	c, err := ln.Accept(ctx, "urn:server", ep)
+	require.NoError(t, err, "Accept failed")
+	defer c.Close()
+	require.Equal(t, uint32(DefaultMaxMessageSize), c.MaxMessageSize())
+
+	require.Error(t, <-srvErr, "server with wrong uri not rejected")
+	require.NoError(t, <-srvErr, "server handshake failed")
+
+	t.Run("ctx cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := ln.Accept(ctx, "urn:server", ep)
+		require.ErrorIs(t, err, context.Canceled)
+
+		// the deadline of the cancelled call must not affect the next one
+		go func() { srvErr <- reverseDial("urn:server", ep) }()
+		ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		c, err := ln.Accept(ctx, "urn:server", ep)
+		require.NoError(t, err, "Accept after cancel failed")
+		c.Close()
+		require.NoError(t, <-srvErr, "server handshake failed")
+	})
+
+	t.Run("no RHE", func(t *testing.T) {
+		// the peer never sends the RHE so that Accept must return
+		// when the context is cancelled during the handshake.
+		c, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err, "Dial failed")
+		defer c.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		_, err = ln.Accept(ctx, "urn:server", ep)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -21,8 +21,8 @@ func ResolveEndpoint(endpoint string) (network string, addr *net.TCPAddr, err er
 	}
 
 	addrString := elems[2]
-	if !strings.Contains(addrString, ":") {
-		addrString += ":4840"
+	if _, _, err := net.SplitHostPort(addrString); err != nil {
+		addrString = net.JoinHostPort(strings.Trim(addrString, "[]"), "4840")
 	}
 
 	network = "tcp"
@@ -45,6 +45,32 @@ func TestResolveEndpoint(t *testing.T) {
 			},
 			"",
 		},
+		{ // Valid, IPv6 address
+			"opc.tcp://[::1]:4841/foo/bar",
+			"tcp",
+			&net.TCPAddr{
+				IP:   net.IPv6loopback,
+				Port: 4841,
+			},
+			"",
+		},
+		{ // Valid, IPv6 address and port number omitted
+			"opc.tcp://[::1]/foo/bar",
+			"tcp",
+			&net.TCPAddr{
+				IP:   net.IPv6loopback,
+				Port: 4840,
+			},
+			"",
+		},
+		{ // Valid, wildcard address
+			"opc.tcp://:4841",
+			"tcp",
+			&net.TCPAddr{
+				Port: 4841,
+			},
+			"",
+		},
 		{ // Invalid, schema is not "opc.tcp://"
 			"tcp://10.0.0.1:4840/foo/bar",
 			"",
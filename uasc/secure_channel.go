@@ -244,6 +244,22 @@ func (s *SecureChannel) RemoteAddr() net.Addr {
 	return s.c.TCPConn.RemoteAddr()
 }
 
+// SecurityPolicyURI returns the security policy of the secure channel.
+func (s *SecureChannel) SecurityPolicyURI() string {
+	return s.cfg.SecurityPolicyURI
+}
+
+// SecurityMode returns the message security mode of the secure channel.
+func (s *SecureChannel) SecurityMode() ua.MessageSecurityMode {
+	return s.cfg.SecurityMode
+}
+
+// RemoteCertificate returns the certificate of the remote side
+// of the secure channel. It is nil for unsecured channels.
+func (s *SecureChannel) RemoteCertificate() []byte {
+	return s.cfg.RemoteCertificate
+}
+
 func (s *SecureChannel) getActiveChannelInstance() (*channelInstance, error) {
 	s.instancesMu.Lock()
 	defer s.instancesMu.Unlock()